@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func handleExtractActionItems(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	format := llm.FormatMarkdown
+	if f, ok := request.GetArguments()["format"].(string); ok && f != "" {
+		if err := validateOutputFormat(f, llm.FormatMarkdown, llm.FormatJSON); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		format = f
+	}
+
+	diffContent := ""
+	if d, ok := request.GetArguments()["diff_content"].(string); ok {
+		diffContent = d
+	}
+
+	from := ""
+	if f, ok := request.GetArguments()["from"].(string); ok {
+		from = f
+	}
+
+	to := "HEAD"
+	if t, ok := request.GetArguments()["to"].(string); ok && t != "" {
+		to = t
+	}
+
+	if diffContent == "" && from == "" {
+		return mcp.NewToolResultError("either diff_content or from is required"), nil
+	}
+
+	// Get provider and model from request
+	providerName := ""
+	if p, ok := request.GetArguments()["provider"].(string); ok {
+		providerName = p
+	}
+
+	modelOverride := ""
+	if m, ok := request.GetArguments()["model"].(string); ok {
+		modelOverride = m
+	}
+
+	// Read the diff from the repository when it was not supplied directly
+	if diffContent == "" {
+		if err := validateGitRef(from); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid from: %v", err)), nil
+		}
+		if err := validateGitRef(to); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid to: %v", err)), nil
+		}
+
+		repoPath := "."
+		if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
+			repoPath = path
+		}
+
+		// Validate repo path
+		validPath, err := validateRepoPath(repoPath)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+		if err := requireGit(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		diffContent, err = getRangeDiff(ctx, validPath, from, to)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if diffContent == "" {
+			return mcp.NewToolResultText(fmt.Sprintf("No changes found between %s and %s.", from, to)), nil
+		}
+	}
+
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Create prompt for LLM analysis
+	prompt := llm.AnalysisPrompt("action_items", diffContent, map[string]any{
+		"format": format,
+	})
+
+	// Get analysis from LLM using optimization
+	contentSize := len(diffContent)
+	task := llm.GetTaskFromAnalysisType("action_items")
+	analysis, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, contentSize, task)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+	}
+
+	if format == llm.FormatJSON {
+		doc, err := llm.ExtractJSON(analysis)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("LLM returned malformed JSON: %v", err)), nil
+		}
+		return mcp.NewToolResultText(doc), nil
+	}
+
+	return mcp.NewToolResultText(limitResponse(analysis)), nil
+}
+
+// getRangeDiff returns the diff between two refs, prefixed with a truncation warning when needed
+func getRangeDiff(ctx context.Context, repoPath, from, to string) (string, error) {
+	for _, ref := range []string{from, to} {
+		if !refExists(ctx, repoPath, ref) {
+			return "", fmt.Errorf("ref '%s' does not exist", ref)
+		}
+	}
+
+	// Get diff using safe memory-limited approach
+	truncatedDiff, err := getGitDiffSafe(ctx, repoPath, &cfg.Memory, nil, from+".."+to)
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %v", err)
+	}
+
+	if truncatedDiff.IsTruncated {
+		return fmt.Sprintf("⚠️ WARNING: %s\nTotal size: %dKB, Files: %d\n\n%s",
+			truncatedDiff.WarningReason, truncatedDiff.TotalSizeKB, truncatedDiff.FileCount, truncatedDiff.Content), nil
+	}
+	return truncatedDiff.Content, nil
+}
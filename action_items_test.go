@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestHandleExtractActionItems(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, ChunkSizeMB: 1},
+	}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+	llmProviders = map[string]llm.Provider{
+		"mock": &MockProvider{
+			name:     "mock",
+			response: "Here you go:\n```json\n{\"todos\": [{\"file\": \"a.go\", \"line\": 3, \"text\": \"TODO: handle nil\"}], \"tech_debt\": [], \"untested_paths\": []}\n```",
+		},
+	}
+
+	diff := "diff --git a/a.go b/a.go\n+// TODO: handle nil\n"
+
+	call := func(args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		result, err := handleExtractActionItems(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "extract_action_items", Arguments: args},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result
+	}
+
+	t.Run("json format returns bare JSON", func(t *testing.T) {
+		result := call(map[string]any{"diff_content": diff, "format": "json"})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+
+		text := result.Content[0].(mcp.TextContent).Text
+		var items struct {
+			Todos []struct {
+				File string `json:"file"`
+				Text string `json:"text"`
+			} `json:"todos"`
+		}
+		if err := json.Unmarshal([]byte(text), &items); err != nil {
+			t.Fatalf("result is not JSON: %v\n%s", err, text)
+		}
+		if len(items.Todos) != 1 || items.Todos[0].File != "a.go" {
+			t.Errorf("unexpected todos: %+v", items.Todos)
+		}
+	})
+
+	t.Run("requires input", func(t *testing.T) {
+		result := call(map[string]any{})
+		if !result.IsError {
+			t.Error("expected error without diff_content or from")
+		}
+	})
+
+	t.Run("rejects unknown format", func(t *testing.T) {
+		result := call(map[string]any{"diff_content": diff, "format": "xml"})
+		if !result.IsError {
+			t.Error("expected error for unsupported format")
+		}
+	})
+}
+
+func TestGetRangeDiff(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{
+		Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+
+	repo := newTestRepo(t)
+	writeTestFile(t, repo, "todo.go", "package main\n\n// TODO: implement\nfunc Stub() {}\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add stub")
+
+	ctx := context.Background()
+	diff, err := getRangeDiff(ctx, repo, "HEAD~1", "HEAD")
+	if err != nil {
+		t.Fatalf("getRangeDiff failed: %v", err)
+	}
+	if !strings.Contains(diff, "TODO: implement") {
+		t.Errorf("diff missing added line:\n%s", diff)
+	}
+
+	if _, err := getRangeDiff(ctx, repo, "missing-branch", "HEAD"); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+}
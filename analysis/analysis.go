@@ -0,0 +1,191 @@
+// Package analysis runs the LLM-backed reviews behind the MCP tools so they can be embedded in
+// other Go programs. Callers supply the configuration and an llm.OptimizedProvider, typically
+// llm.NewOptimizedProvider wrapped around a provider from llm.NewProvider; gathering diffs and
+// commit details from git is left to the caller.
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+)
+
+// Result is the outcome of an analysis
+type Result struct {
+	// Text is the analysis in the requested format, capped at the configured MaxResponseChars
+	Text string
+	// ChangeType is the diff's change type from ClassifyDiff; it is set only by AnalyzeDiff
+	ChangeType string
+}
+
+// CodeReviewInput describes code to review
+type CodeReviewInput struct {
+	Code string
+	// Language selects a language-specific reviewer persona; empty uses the generic one
+	Language string
+	// Focus narrows the review to security, performance, style, or all (default: all), or to
+	// a free-text topic such as "concurrency safety"
+	Focus string
+	// Format is llm.FormatMarkdown (default), llm.FormatJSON, or llm.FormatSARIF
+	Format string
+	// MinSeverity drops findings below this level from structured formats and asks the model
+	// to omit them in markdown; empty reports every finding
+	MinSeverity llm.Severity
+	// Lint runs LintCode first and merges its findings with the model's
+	Lint bool
+}
+
+// DiffInput describes a git diff to analyze
+type DiffInput struct {
+	Diff string
+	// Summarize asks for a brief summary of the overall change
+	Summarize bool
+}
+
+// CommitInput describes a commit to analyze
+type CommitInput struct {
+	// Info is the commit metadata followed by its diff, e.g. the output of git show
+	Info string
+}
+
+// ReviewCode reviews code for quality, security, and best practices
+func ReviewCode(ctx context.Context, cfg *config.Config, provider llm.OptimizedProvider, input CodeReviewInput) (Result, error) {
+	// Give providers a reviewer persona for the language
+	if input.Language != "" {
+		opts := llm.CallOptionsFromContext(ctx)
+		opts.Language = input.Language
+		ctx = llm.WithCallOptions(ctx, opts)
+	}
+
+	focus := strings.TrimSpace(input.Focus)
+	if focus == "" {
+		focus = "all"
+	}
+	format := input.Format
+	if format == "" {
+		format = llm.FormatMarkdown
+	}
+
+	// Structured formats are rendered from the same JSON findings
+	promptFormat := llm.FormatMarkdown
+	if format != llm.FormatMarkdown {
+		promptFormat = llm.FormatJSON
+	}
+
+	prompt := llm.AnalysisPrompt("code_review", input.Code, map[string]any{
+		"language":     input.Language,
+		"focus":        focus,
+		"format":       promptFormat,
+		"min_severity": string(input.MinSeverity),
+	})
+
+	task := llm.GetTaskFromAnalysisType("code_review")
+	// If focus is security, use security-specific task
+	if focus == "security" {
+		task = llm.GetTaskFromAnalysisType("security")
+	}
+	review, err := provider.AnalyzeOptimized(ctx, prompt, len(input.Code), task)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// A dry run has no findings to convert
+	if llm.CallOptionsFromContext(ctx).DryRun {
+		return Result{Text: LimitResponse(cfg, review)}, nil
+	}
+
+	var lint []llm.ReviewFinding
+	if input.Lint {
+		lint = LintCode(input.Code)
+	}
+	return Result{Text: renderReview(cfg, review, format, input.MinSeverity, lint...)}, nil
+}
+
+// AnalyzeDiff explains and assesses the changes in a git diff
+func AnalyzeDiff(ctx context.Context, cfg *config.Config, provider llm.OptimizedProvider, input DiffInput) (Result, error) {
+	changeType := ClassifyDiff(input.Diff)
+	prompt := llm.AnalysisPrompt("diff", input.Diff, map[string]any{
+		"summarize":   input.Summarize,
+		"change_type": changeType,
+	})
+
+	analysis, err := provider.AnalyzeOptimized(ctx, prompt, len(input.Diff), llm.GetTaskFromAnalysisType("diff"))
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Text: LimitResponse(cfg, analysis), ChangeType: changeType}, nil
+}
+
+// AnalyzeCommit assesses a commit's changes, message quality, and adherence to best practices
+func AnalyzeCommit(ctx context.Context, cfg *config.Config, provider llm.OptimizedProvider, input CommitInput) (Result, error) {
+	prompt := llm.AnalysisPrompt("commit", input.Info, nil)
+
+	analysis, err := provider.AnalyzeOptimized(ctx, prompt, len(input.Info), llm.GetTaskFromAnalysisType("commit"))
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Text: LimitResponse(cfg, analysis)}, nil
+}
+
+// scoredFindings is the JSON review output: the findings with their severity-weighted risk score
+type scoredFindings struct {
+	*llm.ReviewFindings
+	RiskScore float64 `json:"risk_score"`
+	RiskBand  string  `json:"risk_band"`
+}
+
+// renderReview converts a review response into the requested format, keeping findings at or above
+// minSeverity when it is set. Lint findings come first: in structured formats they are merged with
+// the model's findings, and in markdown they follow the review as a section. Structured formats fall
+// back to the raw text with a warning when the model did not return usable findings.
+func renderReview(cfg *config.Config, review, format string, minSeverity llm.Severity, lint ...llm.ReviewFinding) string {
+	if minSeverity != "" {
+		lint = llm.FilterFindings(lint, minSeverity)
+	}
+	if format == llm.FormatMarkdown {
+		return LimitResponse(cfg, withLintSection(review, lint))
+	}
+
+	findings, err := llm.ParseFindings(review)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Could not produce structured findings (%v); returning the review as text.\n\n%s", err, LimitResponse(cfg, withLintSection(review, lint)))
+	}
+	findings.Findings = append(lint, findings.Findings...)
+	// Score every finding, so hiding minor ones with min_severity does not lower the risk
+	risk := llm.RiskScore(findings.Findings, func(s llm.Severity) float64 {
+		if cfg == nil {
+			return config.DefaultSeverityWeights[string(s)]
+		}
+		return cfg.SeverityWeight(string(s))
+	})
+	if minSeverity != "" {
+		findings.Findings = llm.FilterFindings(findings.Findings, minSeverity)
+	}
+
+	var output []byte
+	if format == llm.FormatSARIF {
+		version := ""
+		if cfg != nil {
+			version = cfg.ServerVersion
+		}
+		output, err = buildSARIF(findings.Findings, version)
+	} else {
+		output, err = json.MarshalIndent(scoredFindings{ReviewFindings: findings, RiskScore: risk, RiskBand: llm.RiskBand(risk)}, "", "  ")
+	}
+	if err != nil {
+		return fmt.Sprintf("⚠️ Could not encode findings (%v); returning the review as text.\n\n%s", err, review)
+	}
+	return string(output)
+}
+
+// withLintSection appends the lint findings to a text review
+func withLintSection(review string, lint []llm.ReviewFinding) string {
+	if len(lint) == 0 {
+		return review
+	}
+	return strings.TrimRight(review, "\n") + "\n\n" + formatLintFindings(lint)
+}
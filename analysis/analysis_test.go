@@ -0,0 +1,190 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+)
+
+// mockProvider records the last optimized analysis request and returns a canned response
+type mockProvider struct {
+	response string
+	err      error
+	prompt   string
+	task     config.AnalysisTask
+	language string
+	calls    int
+}
+
+func (m *mockProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	return m.AnalyzeOptimized(ctx, prompt, len(prompt), config.TaskGeneral)
+}
+
+func (m *mockProvider) AnalyzeOptimized(ctx context.Context, prompt string, _ int, task config.AnalysisTask) (string, error) {
+	m.calls++
+	m.prompt = prompt
+	m.task = task
+	m.language = llm.CallOptionsFromContext(ctx).Language
+	return m.response, m.err
+}
+
+func (m *mockProvider) Name() string {
+	return "mock"
+}
+
+func (m *mockProvider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{SupportsTemperature: true, SupportsJSON: true}
+}
+
+func TestReviewCode(t *testing.T) {
+	cfg := &config.Config{ServerVersion: "1.0.0"}
+	code := "func Login(pw string) bool { return pw == \"hunter2\" }"
+
+	t.Run("markdown", func(t *testing.T) {
+		provider := &mockProvider{response: "Looks fine."}
+		result, err := ReviewCode(context.Background(), cfg, provider, CodeReviewInput{Code: code, Language: "go", Focus: "security"})
+		if err != nil {
+			t.Fatalf("ReviewCode() unexpected error: %v", err)
+		}
+		if result.Text != "Looks fine." {
+			t.Errorf("Text = %q, want the model response", result.Text)
+		}
+		if !strings.Contains(provider.prompt, code) || !strings.Contains(provider.prompt, "security") {
+			t.Errorf("prompt missing code or focus:\n%s", provider.prompt)
+		}
+		if provider.task != config.TaskSecurityReview {
+			t.Errorf("task = %v, want %v", provider.task, config.TaskSecurityReview)
+		}
+		if provider.language != "go" {
+			t.Errorf("call language = %q, want go", provider.language)
+		}
+	})
+
+	t.Run("json findings", func(t *testing.T) {
+		provider := &mockProvider{response: "```json\n{\"findings\": [{\"severity\": \"high\", \"category\": \"security\", \"line\": 1, \"title\": \"Hardcoded password\", \"description\": \"credentials in source\"}]}\n```"}
+		result, err := ReviewCode(context.Background(), cfg, provider, CodeReviewInput{Code: code, Format: llm.FormatJSON})
+		if err != nil {
+			t.Fatalf("ReviewCode() unexpected error: %v", err)
+		}
+		var findings llm.ReviewFindings
+		if err := json.Unmarshal([]byte(result.Text), &findings); err != nil {
+			t.Fatalf("result is not JSON: %v\n%s", err, result.Text)
+		}
+		if len(findings.Findings) != 1 || findings.Findings[0].Title != "Hardcoded password" {
+			t.Errorf("unexpected findings: %+v", findings)
+		}
+		if provider.task != config.TaskCodeReview {
+			t.Errorf("task = %v, want %v", provider.task, config.TaskCodeReview)
+		}
+	})
+
+	t.Run("min severity", func(t *testing.T) {
+		provider := &mockProvider{response: `{"findings": [
+			{"severity": "low", "category": "style", "title": "Naming", "description": "short name"},
+			{"severity": "critical", "category": "security", "title": "Hardcoded password", "description": "credentials in source"},
+			{"severity": "medium", "category": "correctness", "title": "Timing", "description": "non-constant-time compare"}
+		]}`}
+		result, err := ReviewCode(context.Background(), cfg, provider, CodeReviewInput{Code: code, Format: llm.FormatJSON, MinSeverity: llm.SeverityMedium})
+		if err != nil {
+			t.Fatalf("ReviewCode() unexpected error: %v", err)
+		}
+		var findings llm.ReviewFindings
+		if err := json.Unmarshal([]byte(result.Text), &findings); err != nil {
+			t.Fatalf("result is not JSON: %v\n%s", err, result.Text)
+		}
+		if len(findings.Findings) != 2 || findings.Findings[0].Title != "Hardcoded password" || findings.Findings[1].Title != "Timing" {
+			t.Errorf("expected the low-severity finding to be dropped, got %+v", findings.Findings)
+		}
+
+		// The dropped finding still counts toward the risk: critical 10 + medium 2 + low 1
+		var scored scoredFindings
+		if err := json.Unmarshal([]byte(result.Text), &scored); err != nil {
+			t.Fatalf("result is not JSON: %v\n%s", err, result.Text)
+		}
+		if scored.RiskScore != 13 || scored.RiskBand != llm.RiskHigh {
+			t.Errorf("risk = %v (%s), want 13 (high risk)", scored.RiskScore, scored.RiskBand)
+		}
+
+		// Markdown reviews cannot be filtered afterwards, so the model is asked to apply the threshold
+		provider = &mockProvider{response: "Looks fine."}
+		if _, err := ReviewCode(context.Background(), cfg, provider, CodeReviewInput{Code: code, MinSeverity: llm.SeverityHigh}); err != nil {
+			t.Fatalf("ReviewCode() unexpected error: %v", err)
+		}
+		if !strings.Contains(provider.prompt, "Only report issues of high severity or higher") {
+			t.Errorf("markdown prompt missing the severity threshold:\n%s", provider.prompt)
+		}
+	})
+
+	t.Run("configured severity weights", func(t *testing.T) {
+		weighted := &config.Config{SeverityWeights: map[string]float64{"medium": 0.5}}
+		provider := &mockProvider{response: `{"findings": [
+			{"severity": "medium", "category": "style", "title": "Naming", "description": "short name"},
+			{"severity": "high", "category": "correctness", "title": "Timing", "description": "non-constant-time compare"}
+		]}`}
+		result, err := ReviewCode(context.Background(), weighted, provider, CodeReviewInput{Code: code, Format: llm.FormatJSON})
+		if err != nil {
+			t.Fatalf("ReviewCode() unexpected error: %v", err)
+		}
+		var scored scoredFindings
+		if err := json.Unmarshal([]byte(result.Text), &scored); err != nil {
+			t.Fatalf("result is not JSON: %v\n%s", err, result.Text)
+		}
+		if scored.RiskScore != 5.5 || scored.RiskBand != llm.RiskMedium {
+			t.Errorf("risk = %v (%s), want 5.5 (medium risk) from the configured medium weight", scored.RiskScore, scored.RiskBand)
+		}
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		provider := &mockProvider{err: errors.New("rate limited")}
+		if _, err := ReviewCode(context.Background(), cfg, provider, CodeReviewInput{Code: code}); err == nil || err.Error() != "rate limited" {
+			t.Errorf("ReviewCode() error = %v, want the provider error", err)
+		}
+	})
+}
+
+func TestAnalyzeDiff(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n+func A() {}\n"
+	provider := &mockProvider{response: "Adds A. It is a small change with no concerns worth raising."}
+
+	result, err := AnalyzeDiff(context.Background(), &config.Config{MaxResponseChars: 20}, provider, DiffInput{Diff: diff, Summarize: true})
+	if err != nil {
+		t.Fatalf("AnalyzeDiff() unexpected error: %v", err)
+	}
+	if !strings.Contains(provider.prompt, "+func A() {}") || !strings.Contains(provider.prompt, "Brief summary") {
+		t.Errorf("prompt missing diff or summary request:\n%s", provider.prompt)
+	}
+	if provider.task != config.TaskDiffAnalysis {
+		t.Errorf("task = %v, want %v", provider.task, config.TaskDiffAnalysis)
+	}
+	if result.ChangeType != ChangeCode || !strings.Contains(provider.prompt, "classified as: code") {
+		t.Errorf("ChangeType = %q, want the code classification passed to the prompt:\n%s", result.ChangeType, provider.prompt)
+	}
+	if !strings.HasSuffix(result.Text, "(output truncated at 20 characters)") {
+		t.Errorf("Text = %q, want it capped at max_response_chars", result.Text)
+	}
+}
+
+func TestAnalyzeCommit(t *testing.T) {
+	info := "commit abc123\nAuthor: Test User\n\n    Add greeting\n"
+	provider := &mockProvider{response: "Good commit."}
+
+	// A nil config applies no response cap
+	result, err := AnalyzeCommit(context.Background(), nil, provider, CommitInput{Info: info})
+	if err != nil {
+		t.Fatalf("AnalyzeCommit() unexpected error: %v", err)
+	}
+	if result.Text != "Good commit." {
+		t.Errorf("Text = %q, want the model response", result.Text)
+	}
+	if !strings.Contains(provider.prompt, "Add greeting") || !strings.Contains(provider.prompt, "Quality of the commit message") {
+		t.Errorf("prompt missing commit info or instructions:\n%s", provider.prompt)
+	}
+	if provider.task != config.TaskCommitAnalysis {
+		t.Errorf("task = %v, want %v", provider.task, config.TaskCommitAnalysis)
+	}
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"path"
+	"strings"
+)
+
+// Change types ClassifyDiff reports
+const (
+	ChangeTests  = "tests"
+	ChangeDocs   = "docs"
+	ChangeDeps   = "deps"
+	ChangeCI     = "ci"
+	ChangeConfig = "config"
+	ChangeCode   = "code"
+	// ChangeMixed is several non-code kinds together, such as docs and deps
+	ChangeMixed = "mixed"
+)
+
+// dependencyManifests are manifest and lock files whose changes are dependency updates
+var dependencyManifests = map[string]bool{
+	"go.mod": true, "go.sum": true, "go.work": true, "go.work.sum": true,
+	"package.json": true, "package-lock.json": true, "npm-shrinkwrap.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, "bun.lockb": true,
+	"requirements.txt": true, "pipfile": true, "pipfile.lock": true, "poetry.lock": true, "pyproject.toml": true, "uv.lock": true,
+	"cargo.toml": true, "cargo.lock": true,
+	"gemfile": true, "gemfile.lock": true,
+	"composer.json": true, "composer.lock": true,
+	"pom.xml": true, "build.gradle": true, "build.gradle.kts": true, "gradle.lockfile": true,
+}
+
+// docExtensions are file extensions of prose documentation
+var docExtensions = map[string]bool{".md": true, ".markdown": true, ".rst": true, ".adoc": true}
+
+// docNames are documentation files recognized by name, with or without an extension
+var docNames = map[string]bool{"license": true, "notice": true, "authors": true, "contributors": true, "changelog": true}
+
+// configExtensions are file extensions of configuration rather than code
+var configExtensions = map[string]bool{".yml": true, ".yaml": true, ".toml": true, ".ini": true, ".cfg": true, ".conf": true, ".json": true, ".properties": true, ".env": true}
+
+// ClassifyDiff labels a diff's primary change type from its file paths alone, without an LLM:
+// tests, docs, deps, ci, or config when every file is of that kind, code when any source file
+// changed, and mixed for several non-code kinds. It returns "" for a diff with no files.
+func ClassifyDiff(diff string) string {
+	kinds := make(map[string]bool)
+	for _, file := range ParseDiff(diff) {
+		kinds[classifyFile(file.Path())] = true
+	}
+
+	switch {
+	case len(kinds) == 0:
+		return ""
+	case kinds[ChangeCode]:
+		return ChangeCode
+	case len(kinds) == 1:
+		for kind := range kinds {
+			return kind
+		}
+	}
+	return ChangeMixed
+}
+
+// classifyFile returns the change type of a single file path
+func classifyFile(file string) string {
+	lower := strings.ToLower(file)
+	base := path.Base(lower)
+	ext := path.Ext(base)
+	dirs := "/" + path.Dir(lower) + "/"
+
+	switch {
+	case dependencyManifests[base] || (strings.HasPrefix(base, "requirements") && ext == ".txt") || strings.Contains(dirs, "/vendor/"):
+		return ChangeDeps
+	case strings.HasPrefix(lower, ".github/workflows/") || strings.HasPrefix(lower, ".circleci/") ||
+		base == ".gitlab-ci.yml" || base == ".travis.yml" || base == "jenkinsfile" || base == "azure-pipelines.yml":
+		return ChangeCI
+	case isTestFile(base, dirs):
+		return ChangeTests
+	case docExtensions[ext] || docNames[strings.TrimSuffix(base, ext)] || strings.HasPrefix(lower, "docs/"):
+		return ChangeDocs
+	case configExtensions[ext] || base == "dockerfile" || base == ".gitignore" || base == ".editorconfig" || strings.HasPrefix(base, ".env"):
+		return ChangeConfig
+	}
+	return ChangeCode
+}
+
+// isTestFile reports whether a file is a test by the naming conventions of common languages
+func isTestFile(base, dirs string) bool {
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	return strings.HasSuffix(stem, "_test") || strings.HasPrefix(stem, "test_") ||
+		strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec") ||
+		strings.Contains(dirs, "/testdata/") || strings.Contains(dirs, "/__tests__/") ||
+		strings.Contains(dirs, "/test/") || strings.Contains(dirs, "/tests/")
+}
@@ -0,0 +1,49 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// diffOf builds a minimal git diff touching files
+func diffOf(files ...string) string {
+	var diff strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&diff, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n@@ -1 +1 @@\n-old\n+new\n", file, file, file, file)
+	}
+	return diff.String()
+}
+
+func TestClassifyDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want string
+	}{
+		{"go tests", diffOf("calc_test.go", "internal/parse/parse_test.go"), ChangeTests},
+		{"js specs and fixtures", diffOf("src/app.spec.ts", "src/__tests__/util.js", "testdata/input.json"), ChangeTests},
+		{"python tests", diffOf("tests/test_api.py"), ChangeTests},
+		{"markdown", diffOf("README.md", "docs/guide.md"), ChangeDocs},
+		{"license", diffOf("LICENSE"), ChangeDocs},
+		{"go modules", diffOf("go.mod", "go.sum"), ChangeDeps},
+		{"npm lockfile", diffOf("web/package.json", "web/package-lock.json"), ChangeDeps},
+		{"vendored code", diffOf("vendor/github.com/pkg/errors/errors.go"), ChangeDeps},
+		{"requirements", diffOf("requirements-dev.txt"), ChangeDeps},
+		{"workflow", diffOf(".github/workflows/test.yml"), ChangeCI},
+		{"config", diffOf("config/settings.yaml", "Dockerfile"), ChangeConfig},
+		{"code", diffOf("main.go"), ChangeCode},
+		{"code with tests and docs", diffOf("main.go", "main_test.go", "README.md"), ChangeCode},
+		{"docs and deps", diffOf("README.md", "go.mod"), ChangeMixed},
+		{"no files", "", ""},
+		{"prose only", "not a diff", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyDiff(tt.diff); got != tt.want {
+				t.Errorf("ClassifyDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package analysis
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DiffFile is one file section of a git unified diff
+type DiffFile struct {
+	// OldPath is the file's path before the change, or "" for a new file
+	OldPath string
+	// NewPath is the file's path after the change, or "" for a deleted file
+	NewPath string
+	Added   bool
+	Deleted bool
+	Renamed bool
+	Binary  bool
+	// HasHunks is set when the section has at least one "@@" hunk
+	HasHunks bool
+	// Text is the section's raw text, starting at its "diff --git" header
+	Text string
+
+	hunkOffset int // byte offset of the first hunk header in Text
+}
+
+// Path is the file's path after the change, or before it for a deleted file
+func (f DiffFile) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// HunkLines returns the lines from the first hunk header to the end of the section,
+// or nil when the section has no hunks
+func (f DiffFile) HunkLines() []string {
+	if !f.HasHunks {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(f.Text[f.hunkOffset:], "\n"), "\n")
+}
+
+// ParseDiff splits a git unified diff into file sections at its "diff --git" headers, keeping
+// file order and ignoring any text before the first file. Paths come from the ---/+++ and
+// rename/copy lines when present, falling back to the header; quoted paths are unescaped and
+// /dev/null is reported as an empty path.
+func ParseDiff(diff string) []DiffFile {
+	var files []DiffFile
+	var current *DiffFile
+	var text strings.Builder
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Text = text.String()
+		if current.Added {
+			current.OldPath = ""
+		}
+		if current.Deleted {
+			current.NewPath = ""
+		}
+		files = append(files, *current)
+		text.Reset()
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			oldPath, newPath := ParseDiffHeader(line)
+			current = &DiffFile{OldPath: oldPath, NewPath: newPath}
+		}
+		if current == nil {
+			continue
+		}
+		if !current.HasHunks {
+			parseDiffFileHeaderLine(current, line, text.Len())
+		}
+		text.WriteString(line)
+		text.WriteString("\n")
+	}
+	flush()
+
+	return files
+}
+
+// parseDiffFileHeaderLine applies one extended header line of a file section to file.
+// offset is where line starts in the section's text.
+func parseDiffFileHeaderLine(file *DiffFile, line string, offset int) {
+	switch {
+	case strings.HasPrefix(line, "new file mode"):
+		file.Added = true
+	case strings.HasPrefix(line, "deleted file mode"):
+		file.Deleted = true
+	case strings.HasPrefix(line, "rename from "):
+		file.OldPath = unquoteDiffPath(strings.TrimPrefix(line, "rename from "))
+		file.Renamed = true
+	case strings.HasPrefix(line, "rename to "):
+		file.NewPath = unquoteDiffPath(strings.TrimPrefix(line, "rename to "))
+		file.Renamed = true
+	case strings.HasPrefix(line, "copy from "):
+		file.OldPath = unquoteDiffPath(strings.TrimPrefix(line, "copy from "))
+	case strings.HasPrefix(line, "copy to "):
+		file.NewPath = unquoteDiffPath(strings.TrimPrefix(line, "copy to "))
+	case strings.HasPrefix(line, "Binary files"), strings.HasPrefix(line, "GIT binary patch"):
+		file.Binary = true
+	case strings.HasPrefix(line, "--- "):
+		if path, ok := patchPath(strings.TrimPrefix(line, "--- "), "a/"); ok {
+			file.OldPath = path
+		}
+	case strings.HasPrefix(line, "+++ "):
+		if path, ok := patchPath(strings.TrimPrefix(line, "+++ "), "b/"); ok {
+			file.NewPath = path
+		}
+	case strings.HasPrefix(line, "@@"):
+		file.HasHunks = true
+		file.hunkOffset = offset
+	}
+}
+
+// patchPath parses the path of a ---/+++ line, stripping prefix; /dev/null becomes "".
+// Git ends a path containing spaces with a tab, which is dropped.
+func patchPath(field, prefix string) (string, bool) {
+	field = strings.TrimSuffix(field, "\t")
+	if field == "/dev/null" {
+		return "", true
+	}
+	path := unquoteDiffPath(field)
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(path, prefix), true
+}
+
+// ParseDiffHeader returns the old and new paths of a "diff --git a/<old> b/<new>" header.
+// Both paths are always present in the header, even for new and deleted files. Unquoted paths
+// containing " b/" are ambiguous; the split that gives equal paths is preferred, as git only
+// writes such headers for unrenamed files.
+func ParseDiffHeader(header string) (oldPath, newPath string) {
+	rest := strings.TrimPrefix(header, "diff --git ")
+
+	if strings.HasPrefix(rest, `"`) || strings.HasSuffix(rest, `"`) {
+		oldField, newField := splitQuotedHeader(rest)
+		return strings.TrimPrefix(unquoteDiffPath(oldField), "a/"), strings.TrimPrefix(unquoteDiffPath(newField), "b/")
+	}
+
+	// "a/<name> b/<name>" has an odd length with the separator exactly in the middle
+	if n := (len(rest) - len("a/ b/")) / 2; n > 0 && len(rest) == 2*n+len("a/ b/") &&
+		strings.HasPrefix(rest, "a/") && rest[2+n:2+n+3] == " b/" && rest[2:2+n] == rest[5+n:] {
+		return rest[2 : 2+n], rest[5+n:]
+	}
+
+	if i := strings.LastIndex(rest, " b/"); i >= 0 {
+		return strings.TrimPrefix(rest[:i], "a/"), rest[i+len(" b/"):]
+	}
+	return rest, rest
+}
+
+// splitQuotedHeader splits the two paths of a header where either may be quoted
+func splitQuotedHeader(rest string) (string, string) {
+	if strings.HasPrefix(rest, `"`) {
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				i++
+			case '"':
+				return rest[:i+1], strings.TrimPrefix(rest[i+1:], " ")
+			}
+		}
+		return rest, rest
+	}
+	// Only the new path is quoted
+	if i := strings.LastIndex(rest, ` "`); i >= 0 {
+		return rest[:i], rest[i+1:]
+	}
+	return rest, rest
+}
+
+// unquoteDiffPath decodes a path git quoted because it has special characters, such as
+// "a/caf\303\251.go"; unquoted paths are returned as they are
+func unquoteDiffPath(path string) string {
+	if len(path) < 2 || path[0] != '"' || path[len(path)-1] != '"' {
+		return path
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
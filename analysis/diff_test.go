@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	diff := `preamble text
+diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-old
++new
+diff --git a/old/name.go b/new/name.go
+similarity index 100%
+rename from old/name.go
+rename to new/name.go
+diff --git a/added.go b/added.go
+new file mode 100644
+--- /dev/null
++++ b/added.go
+@@ -0,0 +1 @@
++package main
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+diff --git "a/caf\303\251 menu.go" "b/caf\303\251 menu.go"
+--- "a/caf\303\251 menu.go"
++++ "b/caf\303\251 menu.go"
+@@ -1 +1 @@
+-a
++b
+diff --git a/my file.txt b/my file.txt
+--- a/my file.txt
++++ b/my file.txt
+@@ -1 +1 @@
+-a
++b
+diff --git a/a b/c b/a b/c
+index 1111111..2222222 100644
+Binary files a/a b/c and b/a b/c differ
+`
+	want := []struct {
+		oldPath, newPath string
+		added, deleted   bool
+		renamed, binary  bool
+		hasHunks         bool
+	}{
+		{"main.go", "main.go", false, false, false, false, true},
+		{"old/name.go", "new/name.go", false, false, true, false, false},
+		{"", "added.go", true, false, false, false, true},
+		{"gone.go", "", false, true, false, false, true},
+		{"café menu.go", "café menu.go", false, false, false, false, true},
+		{"my file.txt", "my file.txt", false, false, false, false, true},
+		{"a b/c", "a b/c", false, false, false, true, false},
+	}
+
+	files := ParseDiff(diff)
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(files), len(want))
+	}
+	for i, w := range want {
+		f := files[i]
+		if f.OldPath != w.oldPath || f.NewPath != w.newPath {
+			t.Errorf("file %d paths = %q -> %q, want %q -> %q", i, f.OldPath, f.NewPath, w.oldPath, w.newPath)
+		}
+		if f.Added != w.added || f.Deleted != w.deleted || f.Renamed != w.renamed || f.Binary != w.binary || f.HasHunks != w.hasHunks {
+			t.Errorf("file %d flags = %+v", i, f)
+		}
+		if !strings.HasPrefix(f.Text, "diff --git ") {
+			t.Errorf("file %d text should start at its header, got %q", i, f.Text)
+		}
+	}
+
+	if got := files[3].Path(); got != "gone.go" {
+		t.Errorf("deleted file Path() = %q, want gone.go", got)
+	}
+	if got := files[0].HunkLines(); strings.Join(got, "\n") != "@@ -1 +1 @@\n-old\n+new" {
+		t.Errorf("HunkLines() = %q", got)
+	}
+	if files[1].HunkLines() != nil {
+		t.Error("a section without hunks should have no hunk lines")
+	}
+}
+
+func TestParseDiffHeader(t *testing.T) {
+	tests := []struct {
+		header           string
+		oldPath, newPath string
+	}{
+		{"diff --git a/main.go b/main.go", "main.go", "main.go"},
+		{"diff --git a/old.go b/new.go", "old.go", "new.go"},
+		{"diff --git a/x b/y b/x b/y", "x b/y", "x b/y"},
+		{`diff --git "a/tab\there.go" "b/tab\there.go"`, "tab\there.go", "tab\there.go"},
+		{`diff --git a/plain.go "b/quo\"te.go"`, "plain.go", `quo"te.go`},
+	}
+	for _, tt := range tests {
+		oldPath, newPath := ParseDiffHeader(tt.header)
+		if oldPath != tt.oldPath || newPath != tt.newPath {
+			t.Errorf("ParseDiffHeader(%q) = %q, %q, want %q, %q", tt.header, oldPath, newPath, tt.oldPath, tt.newPath)
+		}
+	}
+}
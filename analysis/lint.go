@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dshills/second-opinion/llm"
+)
+
+// MaxLintLineLength is the longest line, in characters, the lint pre-pass accepts
+const MaxLintLineLength = 160
+
+// maxListedLines caps how many line numbers a lint finding lists
+const maxListedLines = 10
+
+// todoMarkerRegex matches a TODO-style marker and the note that follows it
+var todoMarkerRegex = regexp.MustCompile(`\b(TODO|FIXME|XXX|HACK)\b[:\s(]*(.*)`)
+
+// LintCode runs cheap deterministic checks over code before it is sent to a model: trailing
+// whitespace, mixed tab and space indentation, overlong lines, TODO/FIXME markers, and likely
+// hardcoded secrets. Line numbers are 1-based lines of code.
+func LintCode(code string) []llm.ReviewFinding {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+
+	var findings []llm.ReviewFinding
+	findings = append(findings, lintSecrets(code)...)
+
+	var trailing, long, tabIndented, spaceIndented []int
+	for i, line := range lines {
+		n := i + 1
+		if strings.TrimRight(line, " \t") != line {
+			trailing = append(trailing, n)
+		}
+		if utf8.RuneCountInString(line) > MaxLintLineLength {
+			long = append(long, n)
+		}
+		switch indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]; {
+		case indent == "" || strings.TrimSpace(line) == "":
+		case strings.Contains(indent, " \t"):
+			// A space before a tab mixes styles within a single line
+			tabIndented = append(tabIndented, n)
+			spaceIndented = append(spaceIndented, n)
+		case indent[0] == '\t':
+			tabIndented = append(tabIndented, n)
+		default:
+			spaceIndented = append(spaceIndented, n)
+		}
+	}
+
+	if len(long) > 0 {
+		findings = append(findings, lineFinding(llm.SeverityLow, "maintainability", long,
+			fmt.Sprintf("Lines longer than %d characters", MaxLintLineLength),
+			"Very long lines are hard to read and review.",
+			"Break long expressions and strings across several lines."))
+	}
+	if len(tabIndented) > 0 && len(spaceIndented) > 0 {
+		// Report whichever style is the exception
+		mixed := spaceIndented
+		if len(tabIndented) < len(spaceIndented) {
+			mixed = tabIndented
+		}
+		findings = append(findings, lineFinding(llm.SeverityLow, "style", mixed,
+			"Mixed tab and space indentation",
+			"The code is indented with both tabs and spaces, which renders inconsistently across editors.",
+			"Indent consistently, e.g. by running the language's formatter."))
+	}
+	findings = append(findings, lintTodoMarkers(lines)...)
+	if len(trailing) > 0 {
+		findings = append(findings, lineFinding(llm.SeverityInfo, "style", trailing,
+			"Trailing whitespace",
+			"Trailing whitespace adds noise to diffs.",
+			"Remove the trailing spaces and tabs, or configure the editor to strip them."))
+	}
+	return findings
+}
+
+// lintSecrets reports each likely hardcoded secret
+func lintSecrets(code string) []llm.ReviewFinding {
+	var findings []llm.ReviewFinding
+	for _, secret := range llm.FindSecrets(code) {
+		findings = append(findings, llm.ReviewFinding{
+			Severity:    llm.SeverityHigh,
+			Category:    "security",
+			Line:        secret.Line,
+			Title:       "Possible hardcoded secret",
+			Description: fmt.Sprintf("Line %d looks like it contains a secret (%s). Secrets in source code leak through version control and logs.", secret.Line, strings.ReplaceAll(secret.Rule, "_", " ")),
+			Suggestion:  "Load the secret from the environment or a secret manager, and rotate it if it was committed.",
+		})
+	}
+	return findings
+}
+
+// lintTodoMarkers reports each TODO, FIXME, XXX, or HACK marker
+func lintTodoMarkers(lines []string) []llm.ReviewFinding {
+	var findings []llm.ReviewFinding
+	for i, line := range lines {
+		m := todoMarkerRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		description := fmt.Sprintf("A %s marker flags unfinished work.", m[1])
+		if note := strings.TrimSpace(m[2]); note != "" {
+			description = fmt.Sprintf("A %s marker flags unfinished work: %s", m[1], note)
+		}
+		findings = append(findings, llm.ReviewFinding{
+			Severity:    llm.SeverityInfo,
+			Category:    "maintainability",
+			Line:        i + 1,
+			Title:       fmt.Sprintf("%s marker", m[1]),
+			Description: description,
+			Suggestion:  "Resolve it before merging, or track it in an issue.",
+		})
+	}
+	return findings
+}
+
+// lineFinding builds a finding for a check that matched several lines, pointing at the first
+func lineFinding(severity llm.Severity, category string, lines []int, title, description, suggestion string) llm.ReviewFinding {
+	listed := make([]string, 0, maxListedLines)
+	for _, n := range lines[:min(len(lines), maxListedLines)] {
+		listed = append(listed, fmt.Sprint(n))
+	}
+	where := "line " + strings.Join(listed, ", ")
+	if len(lines) > 1 {
+		where = "lines " + strings.Join(listed, ", ")
+	}
+	if len(lines) > maxListedLines {
+		where += fmt.Sprintf(", and %d more", len(lines)-maxListedLines)
+	}
+	return llm.ReviewFinding{
+		Severity:    severity,
+		Category:    category,
+		Line:        lines[0],
+		Title:       title,
+		Description: fmt.Sprintf("%s (%s)", description, where),
+		Suggestion:  suggestion,
+	}
+}
+
+// formatLintFindings renders lint findings as a markdown section for text reviews
+func formatLintFindings(findings []llm.ReviewFinding) string {
+	var b strings.Builder
+	b.WriteString("## Automated Checks\n")
+	for _, f := range findings {
+		fmt.Fprintf(&b, "- **[%s] %s** (line %d): %s", f.Severity, f.Title, f.Line, f.Description)
+		if f.Suggestion != "" {
+			fmt.Fprintf(&b, " %s", f.Suggestion)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// LimitResponse applies cfg's MaxResponseChars cap to an LLM response; a nil cfg leaves it unchanged
+func LimitResponse(cfg *config.Config, text string) string {
+	if cfg == nil {
+		return text
+	}
+	return truncateResponse(text, cfg.MaxResponseChars)
+}
+
+// truncateResponse shortens text to at most maxChars characters, preferring to cut at a line
+// or sentence boundary, and notes the truncation. A maxChars of zero or less means unlimited.
+func truncateResponse(text string, maxChars int) string {
+	runes := []rune(text)
+	if maxChars <= 0 || len(runes) <= maxChars {
+		return text
+	}
+
+	prefix := string(runes[:maxChars])
+	cut := len(prefix)
+
+	// Only accept a boundary in the second half so a tiny early break doesn't discard most of the output
+	minCut := len(prefix) / 2
+	if idx := strings.LastIndex(prefix, "\n"); idx >= minCut {
+		cut = idx
+	} else if idx := lastSentenceEnd(prefix); idx >= minCut {
+		cut = idx
+	} else if idx := strings.LastIndex(prefix, " "); idx >= minCut {
+		cut = idx
+	}
+
+	return fmt.Sprintf("%s\n\n(output truncated at %d characters)", strings.TrimRight(prefix[:cut], " \n"), maxChars)
+}
+
+// lastSentenceEnd returns the index just past the last sentence-ending punctuation followed by a space, or -1
+func lastSentenceEnd(text string) int {
+	best := -1
+	for _, end := range []string{". ", "! ", "? "} {
+		if idx := strings.LastIndex(text, end); idx >= 0 && idx+1 > best {
+			best = idx + 1
+		}
+	}
+	return best
+}
@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		want     string
+	}{
+		{
+			name:     "unlimited",
+			text:     "Everything fits.",
+			maxChars: 0,
+			want:     "Everything fits.",
+		},
+		{
+			name:     "under limit",
+			text:     "Short.",
+			maxChars: 100,
+			want:     "Short.",
+		},
+		{
+			name:     "cuts at line boundary",
+			text:     "## Summary\nFirst line of detail.\nSecond line that runs long",
+			maxChars: 40,
+			want:     "## Summary\nFirst line of detail.\n\n(output truncated at 40 characters)",
+		},
+		{
+			name:     "cuts at sentence boundary",
+			text:     "The first sentence is fine. The second sentence keeps going well past the limit",
+			maxChars: 45,
+			want:     "The first sentence is fine.\n\n(output truncated at 45 characters)",
+		},
+		{
+			name:     "cuts at word boundary",
+			text:     "one two three four five six seven eight",
+			maxChars: 20,
+			want:     "one two three four\n\n(output truncated at 20 characters)",
+		},
+		{
+			name:     "hard cut without boundaries",
+			text:     strings.Repeat("x", 30),
+			maxChars: 10,
+			want:     strings.Repeat("x", 10) + "\n\n(output truncated at 10 characters)",
+		},
+		{
+			name:     "counts characters not bytes",
+			text:     "héllo wörld ünïcode",
+			maxChars: 12,
+			want:     "héllo wörld\n\n(output truncated at 12 characters)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateResponse(tt.text, tt.maxChars); got != tt.want {
+				t.Errorf("truncateResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dshills/second-opinion/llm"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolURI = "https://github.com/dshills/second-opinion"
+)
+
+// sarifLog is the top-level SARIF 2.1.0 document
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// buildSARIF converts review findings into a SARIF 2.1.0 document
+func buildSARIF(findings []llm.ReviewFinding, toolVersion string) ([]byte, error) {
+	results := make([]sarifResult, 0, len(findings))
+	var rules []sarifRule
+	seenRules := make(map[string]bool)
+
+	for _, finding := range findings {
+		ruleID := sarifRuleID(finding.Category)
+		if !seenRules[ruleID] {
+			seenRules[ruleID] = true
+			rules = append(rules, sarifRule{
+				ID:               ruleID,
+				ShortDescription: sarifMessage{Text: ruleID + " issue"},
+			})
+		}
+
+		message := finding.Title
+		if finding.Description != "" {
+			message += ": " + finding.Description
+		}
+		if finding.Suggestion != "" {
+			message += " Suggestion: " + finding.Suggestion
+		}
+
+		result := sarifResult{
+			RuleID:  ruleID,
+			Level:   sarifLevel(finding.Severity),
+			Message: sarifMessage{Text: message},
+		}
+
+		// Code scanning needs a file to anchor a result; only add one when the line is known
+		if finding.Line > 0 {
+			uri := finding.File
+			if uri == "" {
+				uri = "input"
+			}
+			result.Locations = []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: uri},
+					Region:           &sarifRegion{StartLine: finding.Line},
+				},
+			}}
+		}
+
+		results = append(results, result)
+	}
+
+	if rules == nil {
+		rules = []sarifRule{}
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "second-opinion",
+				Version:        toolVersion,
+				InformationURI: sarifToolURI,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+
+	return json.MarshalIndent(log, "", "  ")
+}
+
+// sarifRuleID derives a stable rule id from a finding category
+func sarifRuleID(category string) string {
+	id := strings.ToLower(strings.TrimSpace(category))
+	id = strings.Join(strings.Fields(id), "-")
+	if id == "" {
+		return "general"
+	}
+	return id
+}
+
+// sarifLevel maps finding severity onto SARIF result levels
+func sarifLevel(severity llm.Severity) string {
+	switch severity {
+	case llm.SeverityCritical, llm.SeverityHigh:
+		return "error"
+	case llm.SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
@@ -0,0 +1,159 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+)
+
+// validateSARIFShape checks the parts of the SARIF 2.1.0 schema that code scanning relies on
+func validateSARIFShape(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("SARIF is not valid JSON: %v", err)
+	}
+	if doc["version"] != "2.1.0" {
+		t.Errorf("version = %v, want 2.1.0", doc["version"])
+	}
+	if schema, _ := doc["$schema"].(string); !strings.Contains(schema, "sarif-2.1.0") {
+		t.Errorf("$schema = %v, want a sarif-2.1.0 schema", doc["$schema"])
+	}
+
+	runs, ok := doc["runs"].([]any)
+	if !ok || len(runs) != 1 {
+		t.Fatalf("runs = %v, want one run", doc["runs"])
+	}
+	run := runs[0].(map[string]any)
+
+	driver, ok := run["tool"].(map[string]any)["driver"].(map[string]any)
+	if !ok || driver["name"] == "" {
+		t.Fatalf("tool.driver.name missing: %v", run["tool"])
+	}
+	ruleIDs := make(map[string]bool)
+	for _, r := range driver["rules"].([]any) {
+		ruleIDs[r.(map[string]any)["id"].(string)] = true
+	}
+
+	results, ok := run["results"].([]any)
+	if !ok {
+		t.Fatalf("results must be an array: %v", run["results"])
+	}
+	for i, r := range results {
+		result := r.(map[string]any)
+		ruleID, _ := result["ruleId"].(string)
+		if !ruleIDs[ruleID] {
+			t.Errorf("result %d ruleId %q not declared in rules", i, ruleID)
+		}
+		switch result["level"] {
+		case "none", "note", "warning", "error":
+		default:
+			t.Errorf("result %d has invalid level %v", i, result["level"])
+		}
+		if msg, _ := result["message"].(map[string]any)["text"].(string); msg == "" {
+			t.Errorf("result %d missing message.text", i)
+		}
+		if locations, ok := result["locations"].([]any); ok {
+			physical := locations[0].(map[string]any)["physicalLocation"].(map[string]any)
+			if uri, _ := physical["artifactLocation"].(map[string]any)["uri"].(string); uri == "" {
+				t.Errorf("result %d location missing artifactLocation.uri", i)
+			}
+			if line, _ := physical["region"].(map[string]any)["startLine"].(float64); line < 1 {
+				t.Errorf("result %d region.startLine = %v, want >= 1", i, line)
+			}
+		}
+	}
+	return doc
+}
+
+func TestBuildSARIF(t *testing.T) {
+	findings := []llm.ReviewFinding{
+		{Severity: llm.SeverityCritical, Category: "security", File: "db.go", Line: 12, Title: "SQL injection", Description: "query concatenates input", Suggestion: "use parameters"},
+		{Severity: llm.SeverityMedium, Category: "Error Handling", Line: 3, Title: "Ignored error"},
+		{Severity: llm.SeverityInfo, Category: "", Title: "Consider a doc comment"},
+	}
+
+	data, err := buildSARIF(findings, "1.0.0")
+	if err != nil {
+		t.Fatalf("buildSARIF() unexpected error: %v", err)
+	}
+	doc := validateSARIFShape(t, data)
+
+	results := doc["runs"].([]any)[0].(map[string]any)["results"].([]any)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	first := results[0].(map[string]any)
+	if first["level"] != "error" || first["ruleId"] != "security" {
+		t.Errorf("unexpected first result: %v", first)
+	}
+	if !strings.Contains(first["message"].(map[string]any)["text"].(string), "use parameters") {
+		t.Errorf("message missing suggestion: %v", first["message"])
+	}
+
+	second := results[1].(map[string]any)
+	if second["ruleId"] != "error-handling" || second["level"] != "warning" {
+		t.Errorf("unexpected second result: %v", second)
+	}
+	uri := second["locations"].([]any)[0].(map[string]any)["physicalLocation"].(map[string]any)["artifactLocation"].(map[string]any)["uri"]
+	if uri != "input" {
+		t.Errorf("uri = %v, want input for findings without a file", uri)
+	}
+
+	third := results[2].(map[string]any)
+	if _, hasLocation := third["locations"]; hasLocation {
+		t.Errorf("finding without a line should have no location: %v", third)
+	}
+	if third["ruleId"] != "general" || third["level"] != "note" {
+		t.Errorf("unexpected third result: %v", third)
+	}
+}
+
+func TestBuildSARIFEmpty(t *testing.T) {
+	data, err := buildSARIF(nil, "1.0.0")
+	if err != nil {
+		t.Fatalf("buildSARIF() unexpected error: %v", err)
+	}
+	validateSARIFShape(t, data)
+	if !strings.Contains(string(data), `"results": []`) {
+		t.Errorf("expected an empty results array:\n%s", data)
+	}
+}
+
+func TestRenderReview(t *testing.T) {
+	cfg := &config.Config{ServerVersion: "1.0.0"}
+
+	structured := `{"findings": [{"severity": "high", "category": "security", "line": 4, "title": "Hardcoded password", "description": "credentials in source"}]}`
+
+	t.Run("sarif", func(t *testing.T) {
+		validateSARIFShape(t, []byte(renderReview(cfg, structured, llm.FormatSARIF, "")))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var findings llm.ReviewFindings
+		if err := json.Unmarshal([]byte(renderReview(cfg, structured, llm.FormatJSON, "")), &findings); err != nil {
+			t.Fatalf("json output did not parse: %v", err)
+		}
+		if len(findings.Findings) != 1 || findings.Findings[0].Severity != llm.SeverityHigh {
+			t.Errorf("unexpected findings: %+v", findings)
+		}
+	})
+
+	t.Run("falls back to text", func(t *testing.T) {
+		output := renderReview(cfg, "The code looks fine.", llm.FormatSARIF, "")
+		if !strings.HasPrefix(output, "⚠️ Could not produce structured findings") || !strings.Contains(output, "The code looks fine.") {
+			t.Errorf("unexpected fallback output: %s", output)
+		}
+	})
+
+	t.Run("markdown passes through", func(t *testing.T) {
+		if output := renderReview(cfg, "plain review", llm.FormatMarkdown, ""); output != "plain review" {
+			t.Errorf("renderReview() = %q, want unchanged text", output)
+		}
+	})
+}
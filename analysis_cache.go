@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// cachedAnalysis is a commit analysis kept for reuse when the same commit is analyzed again
+type cachedAnalysis struct {
+	// Key is the cache key the analysis is stored under
+	Key           string    `json:"-"`
+	Provider      string    `json:"provider"`
+	Model         string    `json:"model"`
+	Commit        string    `json:"commit"`
+	PromptVersion string    `json:"prompt_version"`
+	AnalyzedAt    time.Time `json:"analyzed_at"`
+	Analysis      string    `json:"analysis"`
+}
+
+// maxCachedAnalyses bounds how many analyses the cache keeps; the oldest are pruned first
+const maxCachedAnalyses = 500
+
+// maxCachedAnalysisAge is how long a cached analysis is kept after it was saved
+const maxCachedAnalysisAge = 90 * 24 * time.Hour
+
+// analysisCache keeps one JSON file per cached analysis in a directory
+type analysisCache struct {
+	dir string
+}
+
+// newAnalysisCache stores analyses under the user's cache directory, falling back to the temp
+// directory when there is none
+func newAnalysisCache() *analysisCache {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return &analysisCache{dir: filepath.Join(base, "second-opinion", "analyses")}
+}
+
+func (c *analysisCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Load returns the analysis saved under key, or nil when there is none
+func (c *analysisCache) Load(key string) (*cachedAnalysis, error) {
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached analysis: %w", err)
+	}
+
+	var entry cachedAnalysis
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cached analysis %s: %w", c.path(key), err)
+	}
+	entry.Key = key
+	return &entry, nil
+}
+
+// Save stores entry under its key, replacing any earlier analysis
+func (c *analysisCache) Save(entry cachedAnalysis) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.dir, entry.Key+".json", data); err != nil {
+		return fmt.Errorf("failed to save cached analysis: %w", err)
+	}
+	c.prune(time.Now())
+	return nil
+}
+
+// prune removes analyses saved more than maxCachedAnalysisAge before now, then the oldest
+// beyond maxCachedAnalyses. Files that cannot be inspected or removed are left alone.
+func (c *analysisCache) prune(now time.Time) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	type cacheFile struct {
+		name    string
+		modTime time.Time
+	}
+	var files []cacheFile
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) > maxCachedAnalysisAge {
+			os.Remove(filepath.Join(c.dir, entry.Name()))
+			continue
+		}
+		files = append(files, cacheFile{entry.Name(), info.ModTime()})
+	}
+	if len(files) <= maxCachedAnalyses {
+		return
+	}
+
+	slices.SortFunc(files, func(a, b cacheFile) int { return a.modTime.Compare(b.modTime) })
+	for _, file := range files[:len(files)-maxCachedAnalyses] {
+		os.Remove(filepath.Join(c.dir, file.name))
+	}
+}
+
+// commitAnalysisCache holds analyze_commit results; nil disables caching, which tests rely on
+var commitAnalysisCache = newAnalysisCache()
+
+// writeFileAtomic writes data to name in dir, creating dir if needed. It writes to a temporary
+// file and renames it into place so a concurrent reader never sees a partial file.
+func writeFileAtomic(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, name+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// usesCommitCache reports whether a commit analysis request may be answered from the cache and
+// saved to it. no_cache skips both, as do dry runs, verbose diagnostics, and conversation IDs,
+// which all need a live LLM call.
+func usesCommitCache(request mcp.CallToolRequest) bool {
+	for _, name := range []string{"no_cache", "dry_run", "verbose", "return_conversation_id"} {
+		if set, ok := request.GetArguments()[name].(bool); ok && set {
+			return false
+		}
+	}
+	return true
+}
+
+// contentHash returns a hex SHA-256 of content, so identical diffs always hash alike
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// commitCacheKey identifies an analysis of a commit. Besides the provider, model, commit, and
+// prompt version, it covers a hash of the commit info sent to the model, which changes with the
+// requested paths and diff size limits, the system prompt, the model's effective temperature and
+// max_tokens after per-model overrides, secret redaction, and the options that change the response's text.
+func commitCacheKey(ctx context.Context, request mcp.CallToolRequest, entry cachedAnalysis, commitInfo string) string {
+	opts := llm.CallOptionsFromContext(ctx)
+	raw, _ := request.GetArguments()["raw"].(bool)
+	settings := newProviderConfig(entry.Provider, entry.Model)
+	parts := []string{
+		entry.Provider, entry.Model, entry.Commit, entry.PromptVersion, contentHash(commitInfo),
+		contentHash(llm.SystemPromptFor(ctx)),
+		opts.Persona, opts.OutputLanguage, fmt.Sprint(opts.Deterministic, opts.Seed, opts.JSONOutput, raw),
+		fmt.Sprint(settings.Temperature, settings.MaxTokens, cfg.ShouldRedactSecrets(entry.Provider)),
+	}
+	return contentHash(strings.Join(parts, "\x00"))[:32]
+}
+
+// resolveCommit returns the full hash of the commit ref names
+func resolveCommit(ctx context.Context, repoPath, ref string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve commit %s: %w", ref, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// lookupCommitAnalysis returns the cached analysis of a commit, if there is one, along with the
+// entry to save a new analysis under. The entry is nil when the request bypasses the cache or the
+// commit cannot be identified; cache problems never fail the analysis.
+func lookupCommitAnalysis(ctx context.Context, request mcp.CallToolRequest, repoPath, commitSHA, providerName, modelOverride, commitInfo string) (hit, pending *cachedAnalysis) {
+	if commitAnalysisCache == nil || !usesCommitCache(request) {
+		return nil, nil
+	}
+
+	commit, err := resolveCommit(ctx, repoPath, commitSHA)
+	if err != nil {
+		slog.Debug("not caching commit analysis", "commit", commitSHA, "error", err)
+		return nil, nil
+	}
+	provider, err := getOrCreateProvider(providerName, modelOverride)
+	if err != nil {
+		slog.Debug("not caching commit analysis", "commit", commitSHA, "error", err)
+		return nil, nil
+	}
+	model := ""
+	if reporter, ok := llm.BaseProvider(provider).(llm.ModelReporter); ok {
+		model = reporter.Model()
+	}
+
+	entry := &cachedAnalysis{
+		Provider:      provider.Name(),
+		Model:         model,
+		Commit:        commit,
+		PromptVersion: llm.PromptVersion("commit"),
+	}
+	entry.Key = commitCacheKey(ctx, request, *entry, commitInfo)
+
+	hit, err = commitAnalysisCache.Load(entry.Key)
+	if err != nil {
+		slog.Warn("ignoring unreadable cached analysis", "commit", commit, "error", err)
+		return nil, entry
+	}
+	return hit, entry
+}
+
+// saveCommitAnalysis caches a new analysis under pending, which may be nil
+func saveCommitAnalysis(pending *cachedAnalysis, analysis string) {
+	if pending == nil || commitAnalysisCache == nil {
+		return
+	}
+	pending.Analysis = analysis
+	pending.AnalyzedAt = time.Now().UTC()
+	if err := commitAnalysisCache.Save(*pending); err != nil {
+		slog.Warn("failed to cache commit analysis", "commit", pending.Commit, "error", err)
+	}
+}
+
+// cachedAnalysisNote tells the caller a result was reused and how to get a fresh one
+func cachedAnalysisNote(entry *cachedAnalysis) string {
+	label := entry.Provider
+	if entry.Model != "" {
+		label += "/" + entry.Model
+	}
+	return fmt.Sprintf("♻️ Cached analysis of %s by %s from %s; pass no_cache: true to analyze it again",
+		shortRev(entry.Commit), label, entry.AnalyzedAt.Format("2006-01-02 15:04 UTC"))
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// modelProvider is a counting provider that reports a model
+type modelProvider struct {
+	*countingProvider
+	model string
+}
+
+func (m modelProvider) Model() string {
+	return m.model
+}
+
+func TestCommitAnalysisCache(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	originalCache := commitAnalysisCache
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+		commitAnalysisCache = originalCache
+		llm.SetPromptRegistry(nil)
+	}()
+
+	repo := newTestRepo(t)
+	writeTestFile(t, repo, "calc.go", "package calc\n\nfunc Add(a, b int) int { return a + b }\n")
+	writeTestFile(t, repo, "calc_test.go", "package calc\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add calc")
+	commit := strings.TrimSpace(runGit(t, repo, "rev-parse", "HEAD"))
+
+	cfg = &config.Config{
+		DefaultProvider:  "mock",
+		AllowedRepoRoots: []string{repo},
+		Memory:           config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	commitAnalysisCache = &analysisCache{dir: t.TempDir()}
+
+	// analyze runs analyze_commit on a fresh provider and reports the result and how many LLM calls it made
+	analyze := func(args map[string]any) (string, int) {
+		t.Helper()
+		small := &countingProvider{name: "mock"}
+		large := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{
+			"mock":       modelProvider{small, "mock-small"},
+			"mock:large": modelProvider{large, "mock-large"},
+		}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		args["repo_path"] = repo
+		result, err := handleCommitAnalysis(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "analyze_commit", Arguments: args},
+		})
+		if err != nil || result.IsError {
+			t.Fatalf("unexpected error: %v %v", err, result.Content)
+		}
+		return getTextResponseMock(result), small.calls + large.calls
+	}
+	// expect checks whether a call was answered from the cache
+	expect := func(name string, args map[string]any, wantCached bool) string {
+		t.Helper()
+		text, calls := analyze(args)
+		if cached := calls == 0; cached != wantCached {
+			t.Errorf("%s: cached = %v (%d LLM calls), want %v:\n%s", name, cached, calls, wantCached, text)
+		}
+		if noted := strings.Contains(text, "♻️ Cached analysis of "+commit[:7]); noted != wantCached {
+			t.Errorf("%s: cached note = %v, want %v:\n%s", name, noted, wantCached, text)
+		}
+		return text
+	}
+
+	expect("first analysis", map[string]any{}, false)
+	text := expect("same commit again", map[string]any{"commit_sha": commit}, true)
+	if !strings.Contains(text, "by mock/mock-small") || !strings.Contains(text, "Summary 1") {
+		t.Errorf("expected the earlier analysis and its provider:\n%s", text)
+	}
+
+	expect("no_cache", map[string]any{"no_cache": true}, false)
+	// A dry run makes no LLM call, but must not be answered with an analysis either
+	if text, _ := analyze(map[string]any{"dry_run": true}); strings.Contains(text, "♻️") {
+		t.Errorf("dry run was answered from the cache:\n%s", text)
+	}
+	expect("other model", map[string]any{"model": "large"}, false)
+	expect("other model again", map[string]any{"model": "large"}, true)
+	expect("persona", map[string]any{"persona": "mentor"}, false)
+	expect("paths", map[string]any{"paths": []any{"calc.go"}}, false)
+
+	t.Run("sampling and redaction settings", func(t *testing.T) {
+		defer func() { cfg.Temperature, cfg.ModelOverrides, cfg.RedactSecrets = 0, nil, nil }()
+		cfg.Temperature = 0.7
+		expect("other temperature", map[string]any{"commit_sha": commit}, false)
+		cfg.ModelOverrides = map[string]config.ModelOverride{"mock-small": {MaxTokens: 2048}}
+		expect("model override", map[string]any{"commit_sha": commit}, false)
+		expect("model override again", map[string]any{"commit_sha": commit}, true)
+		redact := false
+		cfg.RedactSecrets = &redact
+		expect("redaction off", map[string]any{"commit_sha": commit}, false)
+	})
+
+	t.Run("new commit", func(t *testing.T) {
+		writeTestFile(t, repo, "calc.go", "package calc\n\nfunc Add(a, b int) int { return b + a }\n")
+		runGit(t, repo, "commit", "--quiet", "-a", "-m", "Swap operands")
+		if text, calls := analyze(map[string]any{}); calls == 0 || strings.Contains(text, "♻️") {
+			t.Errorf("a new HEAD commit was answered from the cache:\n%s", text)
+		}
+		expect("first commit by SHA", map[string]any{"commit_sha": commit}, true)
+	})
+
+	t.Run("prompt version change", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "commit.tmpl"), []byte("Review this commit:\n{{.Content}}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		registry, err := llm.LoadPromptRegistry(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		llm.SetPromptRegistry(registry)
+		expect("custom commit template", map[string]any{"commit_sha": commit}, false)
+		expect("custom commit template again", map[string]any{"commit_sha": commit}, true)
+
+		llm.SetPromptRegistry(nil)
+		expect("built-in prompts restored", map[string]any{"commit_sha": commit}, true)
+	})
+
+	t.Run("unreadable entry", func(t *testing.T) {
+		entries, err := filepath.Glob(filepath.Join(commitAnalysisCache.dir, "*.json"))
+		if err != nil || len(entries) == 0 {
+			t.Fatalf("expected cached analyses on disk, got %v %v", entries, err)
+		}
+		for _, entry := range entries {
+			if err := os.WriteFile(entry, []byte("{not json"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expect("corrupt cache", map[string]any{"commit_sha": commit}, false)
+		expect("rewritten cache", map[string]any{"commit_sha": commit}, true)
+	})
+}
+
+func TestAnalysisCachePrune(t *testing.T) {
+	cache := &analysisCache{dir: t.TempDir()}
+	now := time.Now()
+	write := func(name string, age time.Duration) {
+		t.Helper()
+		path := filepath.Join(cache.dir, name+".json")
+		if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("expired", maxCachedAnalysisAge+time.Hour)
+	for i := range maxCachedAnalyses + 1 {
+		write(fmt.Sprintf("entry-%03d", i), time.Duration(maxCachedAnalyses+1-i)*time.Minute)
+	}
+	cache.prune(now)
+
+	entries, err := filepath.Glob(filepath.Join(cache.dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxCachedAnalyses {
+		t.Errorf("kept %d analyses, want %d", len(entries), maxCachedAnalyses)
+	}
+	for _, gone := range []string{"expired", "entry-000"} {
+		if _, err := os.Stat(filepath.Join(cache.dir, gone+".json")); err == nil {
+			t.Errorf("%s should have been pruned", gone)
+		}
+	}
+}
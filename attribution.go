@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// analysisAttribution records which provider and model served a tool call, and every prompt they
+// were sent so a follow-up conversation can pick up from it
+type analysisAttribution struct {
+	mu       sync.Mutex
+	provider string
+	model    string
+	prompts  []string
+}
+
+type attributionKey struct{}
+
+// recordAttribution notes the provider that analyzed a request, and adds the prompt it was sent, on
+// the attribution carried by ctx, if any
+func recordAttribution(ctx context.Context, provider llm.OptimizedProvider, prompt string) {
+	attribution, ok := ctx.Value(attributionKey{}).(*analysisAttribution)
+	if !ok {
+		return
+	}
+	model := ""
+	if reporter, ok := provider.(llm.ModelReporter); ok {
+		model = reporter.Model()
+	}
+
+	attribution.mu.Lock()
+	defer attribution.mu.Unlock()
+	attribution.provider = provider.Name()
+	attribution.model = model
+	attribution.prompts = append(attribution.prompts, prompt)
+}
+
+// label returns "provider/model", or "" when no provider analyzed the request
+func (a *analysisAttribution) label() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.provider == "" {
+		return ""
+	}
+	if a.model == "" {
+		return a.provider
+	}
+	return a.provider + "/" + a.model
+}
+
+// exchange returns the provider, model, and prompt recorded, with provider "" if none was. An
+// analysis split across several requests, such as per-file or batch reviews, returns all of their
+// prompts, numbered, so the result is paired with everything that produced it.
+func (a *analysisAttribution) exchange() (provider, model, prompt string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.prompts) == 1 {
+		return a.provider, a.model, a.prompts[0]
+	}
+	parts := make([]string, len(a.prompts))
+	for i, p := range a.prompts {
+		parts[i] = fmt.Sprintf("Request %d of %d:\n\n%s", i+1, len(a.prompts), p)
+	}
+	return a.provider, a.model, strings.Join(parts, "\n\n")
+}
+
+// attributedProvider records itself as the analyzing provider before each analysis
+type attributedProvider struct {
+	llm.OptimizedProvider
+}
+
+// AnalyzeOptimized records the provider on the request's attribution and delegates
+func (a attributedProvider) AnalyzeOptimized(ctx context.Context, prompt string, contentSize int, task config.AnalysisTask) (string, error) {
+	recordAttribution(ctx, a.OptimizedProvider, prompt)
+	return a.OptimizedProvider.AnalyzeOptimized(ctx, prompt, contentSize, task)
+}
+
+// withAttribution prefixes successful results of an analysis tool with the provider, model, and
+// time that produced them. Structured formats, dry runs, quiet requests, and results that needed
+// no LLM call are left unchanged. Verbose requests also get the optimization diagnostics appended.
+func withAttribution(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		attribution := &analysisAttribution{}
+		ctx = context.WithValue(ctx, attributionKey{}, attribution)
+		var diagnostics *llm.DiagnosticsLog
+		if verbose, _ := request.GetArguments()["verbose"].(bool); verbose {
+			diagnostics = &llm.DiagnosticsLog{}
+			ctx = llm.WithDiagnosticsLog(ctx, diagnostics)
+		}
+		start := time.Now()
+		result, err := handler(ctx, request)
+		if err != nil || result == nil || result.IsError {
+			return result, err
+		}
+		if wantsConversationID(request) {
+			result = attachConversation(attribution, request, result)
+		}
+		// A separate content item keeps structured output parseable
+		if diagnostics != nil {
+			if section := diagnostics.String(); section != "" {
+				result.Content = append(result.Content, mcp.NewTextContent(section))
+			}
+		}
+		if !wantsAttribution(request) {
+			return result, nil
+		}
+
+		label := attribution.label()
+		if label == "" || len(result.Content) == 0 {
+			return result, nil
+		}
+		if text, ok := result.Content[0].(mcp.TextContent); ok {
+			text.Text = fmt.Sprintf("Analyzed by %s in %s\n\n%s", label, time.Since(start).Round(time.Millisecond), text.Text)
+			result.Content[0] = text
+		}
+		return result, nil
+	}
+}
+
+// wantsAttribution reports whether a request's result should carry the attribution header
+func wantsAttribution(request mcp.CallToolRequest) bool {
+	args := request.GetArguments()
+	if quiet, ok := args["quiet"].(bool); ok && quiet {
+		return false
+	}
+	if dryRun, ok := args["dry_run"].(bool); ok && dryRun {
+		return false
+	}
+	// A header would make JSON and SARIF output unparseable
+	format, _ := args["format"].(string)
+	return format != llm.FormatJSON && format != llm.FormatSARIF
+}
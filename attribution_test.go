@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestAttributionHeader(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalUsage := providerUsage
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		providerUsage = originalUsage
+		cfg = originalCfg
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"response": `{"summary": "fine", "findings": []}`, "done": true})
+	}))
+	defer server.Close()
+
+	cfg = &config.Config{
+		DefaultProvider: "ollama",
+		ModelAliases:    map[string]string{"fast": "llama3.2"},
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	cfg.Ollama.Endpoint = server.URL
+	cfg.Ollama.Model = "devstral:latest"
+	llmProviders = make(map[string]llm.Provider)
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+	providerUsage = newProviderLRU()
+
+	handler := withAttribution(handleCodeReview)
+	call := func(args map[string]any) string {
+		t.Helper()
+		args["code"] = "func Add(a, b int) int { return a + b }"
+		result, err := handler(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "review_code", Arguments: args},
+		})
+		if err != nil || result.IsError {
+			t.Fatalf("unexpected failure: %v %v", err, result)
+		}
+		return result.Content[0].(mcp.TextContent).Text
+	}
+
+	header := regexp.MustCompile(`^Analyzed by (\S+) in \S+\n\n`)
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"default model", map[string]any{}, "ollama/devstral:latest"},
+		{"model override", map[string]any{"model": "qwen2.5-coder"}, "ollama/qwen2.5-coder"},
+		{"alias resolved", map[string]any{"model": "fast"}, "ollama/llama3.2"},
+		{"raw mode", map[string]any{"model": "qwen2.5-coder", "raw": true}, "ollama/qwen2.5-coder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := call(tt.args)
+			match := header.FindStringSubmatch(text)
+			if match == nil {
+				t.Fatalf("result has no attribution header:\n%s", text)
+			}
+			if match[1] != tt.want {
+				t.Errorf("attributed to %q, want %q", match[1], tt.want)
+			}
+		})
+	}
+
+	for name, args := range map[string]map[string]any{
+		"quiet":       {"quiet": true},
+		"json format": {"format": "json"},
+		"dry run":     {"dry_run": true},
+	} {
+		if text := call(args); strings.HasPrefix(text, "Analyzed by") {
+			t.Errorf("%s: result should not carry a header:\n%s", name, text)
+		}
+	}
+}
+
+func TestVerboseDiagnostics(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	// A file limit of 1 makes any multi-file diff big enough to chunk at all be chunked
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	padding := strings.Repeat("+// padding line for the chunk test\n", 2*1024*1024/len("+// padding line for the chunk test\n"))
+	diff := "diff --git a/a.go b/a.go\n+func A() {}\n" + padding + "diff --git a/b.go b/b.go\n+func B() {}\ndiff --git a/c.go b/c.go\n+func C() {}\n"
+
+	call := func(args map[string]any) (*mcp.CallToolResult, *countingProvider) {
+		t.Helper()
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		result, err := withAttribution(handleGitDiff)(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "analyze_git_diff", Arguments: args},
+		})
+		if err != nil || result.IsError {
+			t.Fatalf("unexpected failure: %v %v", err, result)
+		}
+		return result, provider
+	}
+
+	result, provider := call(map[string]any{"diff_content": diff, "verbose": true})
+	if len(result.Content) != 2 {
+		t.Fatalf("got %d content items, want the analysis and the diagnostics", len(result.Content))
+	}
+	// Every chunk is analyzed, then the parts are summarized
+	chunks := provider.calls - 1
+	if chunks < 2 {
+		t.Fatalf("expected the diff to be chunked, got %d calls", provider.calls)
+	}
+	section := result.Content[1].(mcp.TextContent).Text
+	for _, want := range []string{
+		"## 🔍 Diagnostics",
+		"- Provider: mock",
+		"- Estimated file count: 3",
+		fmt.Sprintf("- Chunking: %d chunk(s) of up to %d bytes", chunks, 1024*1024),
+		"- Max tokens: ",
+		"- Temperature: 0.25",
+		"- Estimated prompt tokens: ",
+	} {
+		if !strings.Contains(section, want) {
+			t.Errorf("diagnostics missing %q:\n%s", want, section)
+		}
+	}
+
+	result, _ = call(map[string]any{"diff_content": "diff --git a/a.go b/a.go\n+func A() {}\n", "verbose": true})
+	if len(result.Content) != 2 || !strings.Contains(result.Content[1].(mcp.TextContent).Text, "- Chunking: none") {
+		t.Errorf("expected diagnostics reporting no chunking, got %v", result.Content)
+	}
+
+	if result, _ := call(map[string]any{"diff_content": diff}); len(result.Content) != 1 {
+		t.Errorf("got %d content items without verbose, want 1", len(result.Content))
+	}
+}
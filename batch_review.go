@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dshills/second-opinion/analysis"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxBatchItems caps how many snippets one review_code_batch call may review
+const maxBatchItems = 50
+
+// Bounds on how many snippets of a batch are reviewed at once
+const (
+	defaultBatchConcurrency = 4
+	maxBatchConcurrency     = 8
+)
+
+// batchItem is one snippet submitted to review_code_batch
+type batchItem struct {
+	ID       string
+	Code     string
+	Language string
+}
+
+// BatchReviewResult is the outcome of reviewing one snippet of a batch
+type BatchReviewResult struct {
+	Review         string `json:"review,omitempty"`
+	Error          string `json:"error,omitempty"`
+	PromptTokens   int    `json:"prompt_tokens_estimate"`
+	ResponseTokens int    `json:"response_tokens_estimate"`
+}
+
+// BatchReview collects the results of a batch keyed by item id, with totals across the batch
+type BatchReview struct {
+	Results        map[string]BatchReviewResult `json:"results"`
+	Succeeded      int                          `json:"succeeded"`
+	Failed         int                          `json:"failed"`
+	PromptTokens   int                          `json:"prompt_tokens_estimate"`
+	ResponseTokens int                          `json:"response_tokens_estimate"`
+}
+
+func handleReviewCodeBatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	items, err := parseBatchItems(request.GetArguments()["items"])
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	focus := "all"
+	if f, ok := request.GetArguments()["focus"].(string); ok && strings.TrimSpace(f) != "" {
+		if len([]rune(f)) > llm.MaxFocusLength {
+			return mcp.NewToolResultError(fmt.Sprintf("focus must be at most %d characters", llm.MaxFocusLength)), nil
+		}
+		focus = f
+	}
+
+	format := llm.FormatMarkdown
+	if f, ok := request.GetArguments()["format"].(string); ok && f != "" {
+		if err := validateOutputFormat(f, llm.FormatMarkdown, llm.FormatJSON); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		format = f
+	}
+	// format shapes the batch envelope; the reviews inside it are always markdown
+	opts := llm.CallOptionsFromContext(ctx)
+	opts.JSONOutput = false
+	ctx = llm.WithCallOptions(ctx, opts)
+
+	concurrency := defaultBatchConcurrency
+	if c, ok := request.GetArguments()["concurrency"].(float64); ok {
+		if c < 1 || c > maxBatchConcurrency {
+			return mcp.NewToolResultError(fmt.Sprintf("concurrency must be between 1 and %d", maxBatchConcurrency)), nil
+		}
+		concurrency = int(c)
+	}
+
+	// Get provider and model from request
+	providerName := ""
+	if p, ok := request.GetArguments()["provider"].(string); ok {
+		providerName = p
+	}
+
+	modelOverride := ""
+	if m, ok := request.GetArguments()["model"].(string); ok {
+		modelOverride = m
+	}
+
+	// One provider serves the whole batch
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	batch := reviewBatch(ctx, optimizedProvider, items, focus, concurrency)
+
+	if format == llm.FormatJSON {
+		output, err := json.MarshalIndent(batch, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to encode results: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	}
+	return mcp.NewToolResultText(formatBatchReview(items, batch)), nil
+}
+
+// parseBatchItems validates the items argument: an array of {id, code, language} objects with
+// unique, non-empty ids. Empty code is left for the item's own result to report.
+func parseBatchItems(value any) ([]batchItem, error) {
+	raw, ok := value.([]any)
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("items is required: an array of {id, code, language} objects")
+	}
+	if len(raw) > maxBatchItems {
+		return nil, fmt.Errorf("too many items: %d (maximum %d)", len(raw), maxBatchItems)
+	}
+
+	items := make([]batchItem, 0, len(raw))
+	seen := make(map[string]bool)
+	for i, entry := range raw {
+		fields, ok := entry.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("items[%d] must be an object with id, code, and language", i)
+		}
+		id, _ := fields["id"].(string)
+		if strings.TrimSpace(id) == "" {
+			return nil, fmt.Errorf("items[%d] is missing an id", i)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate item id %q", id)
+		}
+		seen[id] = true
+
+		code, _ := fields["code"].(string)
+		language, _ := fields["language"].(string)
+		items = append(items, batchItem{ID: id, Code: code, Language: language})
+	}
+	return items, nil
+}
+
+// reviewBatch reviews every item with at most concurrency requests in flight. A failing item is
+// recorded in its result without affecting the others.
+func reviewBatch(ctx context.Context, provider llm.OptimizedProvider, items []batchItem, focus string, concurrency int) BatchReview {
+	results := make([]BatchReviewResult, len(items))
+	slots := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item batchItem) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = reviewBatchItem(ctx, provider, item, focus)
+		}(i, item)
+	}
+	wg.Wait()
+
+	batch := BatchReview{Results: make(map[string]BatchReviewResult, len(items))}
+	for i, item := range items {
+		result := results[i]
+		batch.Results[item.ID] = result
+		if result.Error != "" {
+			batch.Failed++
+		} else {
+			batch.Succeeded++
+		}
+		batch.PromptTokens += result.PromptTokens
+		batch.ResponseTokens += result.ResponseTokens
+	}
+	return batch
+}
+
+// reviewBatchItem reviews a single snippet of a batch
+func reviewBatchItem(ctx context.Context, provider llm.OptimizedProvider, item batchItem, focus string) BatchReviewResult {
+	if strings.TrimSpace(item.Code) == "" {
+		return BatchReviewResult{Error: "code is empty"}
+	}
+
+	result := BatchReviewResult{PromptTokens: cfg.EstimateTokensForText(item.Code)}
+	review, err := analysis.ReviewCode(ctx, cfg, provider, analysis.CodeReviewInput{
+		Code:     item.Code,
+		Language: item.Language,
+		Focus:    focus,
+	})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Review = review.Text
+	result.ResponseTokens = cfg.EstimateTokensForText(review.Text)
+	return result
+}
+
+// formatBatchReview renders batch results in item order as one markdown section per item
+func formatBatchReview(items []batchItem, batch BatchReview) string {
+	var out strings.Builder
+	out.WriteString("# Batch Code Review\n\n")
+	out.WriteString(fmt.Sprintf("%d of %d items reviewed (estimated tokens: %d prompt, %d response)\n",
+		batch.Succeeded, len(items), batch.PromptTokens, batch.ResponseTokens))
+
+	for _, item := range items {
+		result := batch.Results[item.ID]
+		out.WriteString(fmt.Sprintf("\n## %s\n\n", item.ID))
+		if result.Error != "" {
+			out.WriteString(fmt.Sprintf("❌ Error: %s\n", result.Error))
+			continue
+		}
+		out.WriteString(result.Review)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// batchProvider fails prompts containing "panic(" and records peak concurrency
+type batchProvider struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (b *batchProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	b.mu.Lock()
+	b.inFlight++
+	b.peak = max(b.peak, b.inFlight)
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if strings.Contains(prompt, "panic(") {
+		return "", errors.New("model refused the request")
+	}
+	return "No issues found.", nil
+}
+
+func (b *batchProvider) Name() string {
+	return "batchmock"
+}
+
+func (b *batchProvider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{SupportsTemperature: true}
+}
+
+func TestHandleReviewCodeBatch(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "batchmock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+
+	call := func(args map[string]any) (*mcp.CallToolResult, *batchProvider) {
+		t.Helper()
+		provider := &batchProvider{}
+		llmProviders = map[string]llm.Provider{"batchmock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		result, err := handleReviewCodeBatch(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "review_code_batch", Arguments: args},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result, provider
+	}
+
+	items := []any{
+		map[string]any{"id": "add", "code": "func Add(a, b int) int { return a + b }", "language": "go"},
+		map[string]any{"id": "crash", "code": "func Crash() { panic(\"boom\") }", "language": "go"},
+		map[string]any{"id": "empty", "code": "  "},
+		map[string]any{"id": "greet", "code": "def greet(): return 'hi'", "language": "python"},
+		map[string]any{"id": "sub", "code": "func Sub(a, b int) int { return a - b }"},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		result, provider := call(map[string]any{"items": items, "format": "json", "concurrency": float64(2)})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+
+		var batch BatchReview
+		if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &batch); err != nil {
+			t.Fatalf("result is not JSON: %v", err)
+		}
+		if batch.Succeeded != 3 || batch.Failed != 2 || len(batch.Results) != 5 {
+			t.Errorf("unexpected totals: %+v", batch)
+		}
+		for _, id := range []string{"add", "greet", "sub"} {
+			if r := batch.Results[id]; r.Review != "No issues found." || r.Error != "" {
+				t.Errorf("%s: unexpected result %+v", id, r)
+			}
+		}
+		if r := batch.Results["crash"]; !strings.Contains(r.Error, "model refused the request") || r.Review != "" {
+			t.Errorf("crash: expected the provider error, got %+v", r)
+		}
+		if r := batch.Results["empty"]; r.Error != "code is empty" {
+			t.Errorf("empty: expected an empty code error, got %+v", r)
+		}
+		if batch.PromptTokens == 0 || batch.ResponseTokens == 0 {
+			t.Errorf("expected aggregated token estimates, got %+v", batch)
+		}
+		if provider.peak > 2 {
+			t.Errorf("peak concurrency = %d, want at most 2", provider.peak)
+		}
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		result, _ := call(map[string]any{"items": items})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		text := result.Content[0].(mcp.TextContent).Text
+		for _, want := range []string{"3 of 5 items reviewed", "## add\n\nNo issues found.", "## crash\n\n❌ Error:", "## empty\n\n❌ Error: code is empty"} {
+			if !strings.Contains(text, want) {
+				t.Errorf("expected result to contain %q, got:\n%s", want, text)
+			}
+		}
+		// Sections keep the submitted order
+		if strings.Index(text, "## greet") > strings.Index(text, "## sub") {
+			t.Error("expected results in item order")
+		}
+	})
+
+	t.Run("invalid items", func(t *testing.T) {
+		for name, args := range map[string]map[string]any{
+			"missing":      {},
+			"missing id":   {"items": []any{map[string]any{"code": "x"}}},
+			"duplicate id": {"items": []any{map[string]any{"id": "a", "code": "x"}, map[string]any{"id": "a", "code": "y"}}},
+			"not objects":  {"items": []any{"x"}},
+			"concurrency":  {"items": items, "concurrency": float64(100)},
+		} {
+			if result, provider := call(args); !result.IsError || provider.peak != 0 {
+				t.Errorf("%s: expected a tool error without LLM calls", name)
+			}
+		}
+	})
+}
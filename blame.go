@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshills/second-opinion/analysis"
+)
+
+// maxBlameLines bounds how many removed or changed lines are blamed for one review
+const maxBlameLines = 400
+
+// maxBlameSummaryBytes bounds the blame summary added to a prompt
+const maxBlameSummaryBytes = 8 * 1024
+
+// maxBlameSubjectLength is the longest commit subject quoted in a blame summary, in characters
+const maxBlameSubjectLength = 72
+
+// hunkHeaderRegex matches a unified diff hunk header, capturing the old and new line ranges
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// lineRange is an inclusive range of 1-based line numbers
+type lineRange struct {
+	start, end int
+}
+
+// removedLineRanges returns the ranges of old-side lines a diff removes or changes, keyed by the
+// file's path before the change, along with the paths in diff order. New files have no old lines.
+func removedLineRanges(diff string) ([]string, map[string][]lineRange) {
+	var paths []string
+	ranges := make(map[string][]lineRange)
+
+	for _, file := range analysis.ParseDiff(diff) {
+		path := file.OldPath
+		if path == "" {
+			continue
+		}
+		oldLine, oldLeft, newLeft := 0, 0, 0
+		for _, line := range file.HunkLines() {
+			if oldLeft > 0 || newLeft > 0 {
+				switch {
+				case strings.HasPrefix(line, "-"):
+					if r := ranges[path]; len(r) > 0 && r[len(r)-1].end == oldLine-1 {
+						r[len(r)-1].end = oldLine
+					} else {
+						if len(r) == 0 {
+							paths = append(paths, path)
+						}
+						ranges[path] = append(r, lineRange{oldLine, oldLine})
+					}
+					oldLine++
+					oldLeft--
+				case strings.HasPrefix(line, "+"):
+					newLeft--
+				case strings.HasPrefix(line, " "), line == "":
+					oldLine++
+					oldLeft--
+					newLeft--
+				}
+				continue
+			}
+			if m := hunkHeaderRegex.FindStringSubmatch(line); m != nil {
+				oldLine, _ = strconv.Atoi(m[1])
+				oldLeft, newLeft = hunkCount(m[2]), hunkCount(m[3])
+			}
+		}
+	}
+	return paths, ranges
+}
+
+// hunkCount parses a hunk header line count, which is 1 when omitted
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// blameCommit is the commit that last touched a blamed line
+type blameCommit struct {
+	author  string
+	time    time.Time
+	subject string
+}
+
+// blameLine is one blamed line and the commit that last touched it
+type blameLine struct {
+	line   int
+	commit string
+}
+
+// blameSummary describes who last changed each line that diff removes or changes, blamed as of
+// rev, so the model can weigh recency and ownership. Consecutive lines from the same commit are
+// merged, at most maxBlameLines lines are blamed, and the summary stops at maxBlameSummaryBytes.
+// Files that cannot be blamed are skipped, and "" is returned when there is nothing to report.
+func blameSummary(ctx context.Context, repoPath, rev, diff string) string {
+	paths, ranges := removedLineRanges(diff)
+
+	var entries []string
+	budget := maxBlameLines
+	limited := false
+	for _, path := range paths {
+		if budget == 0 {
+			limited = true
+			break
+		}
+		var args []string
+		for _, r := range ranges[path] {
+			if budget == 0 {
+				limited = true
+				break
+			}
+			end := min(r.end, r.start+budget-1)
+			limited = limited || end < r.end
+			budget -= end - r.start + 1
+			args = append(args, "-L", fmt.Sprintf("%d,%d", r.start, end))
+		}
+
+		lines, commits, err := blameLines(ctx, repoPath, rev, path, args)
+		if err != nil {
+			slog.Debug("skipping blame for file", "path", path, "rev", rev, "error", err)
+			continue
+		}
+		entries = append(entries, formatBlameEntries(path, lines, commits)...)
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Blame for the lines this diff removes or changes (the last commit to touch them as of %s):\n", shortRev(rev))
+	for i, entry := range entries {
+		if b.Len()+len(entry) > maxBlameSummaryBytes {
+			fmt.Fprintf(&b, "- ... %d more range(s) omitted\n", len(entries)-i)
+			break
+		}
+		b.WriteString(entry)
+	}
+	if limited {
+		fmt.Fprintf(&b, "- ... blame limited to the first %d removed or changed lines\n", maxBlameLines)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// blameLines runs git blame for the line range arguments of path as of rev
+func blameLines(ctx context.Context, repoPath, rev, path string, rangeArgs []string) ([]blameLine, map[string]blameCommit, error) {
+	args := append([]string{"-C", repoPath, "blame", "--porcelain"}, rangeArgs...)
+	cmd := exec.CommandContext(ctx, "git", append(args, rev, "--", path)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("git blame failed: %w", err)
+	}
+
+	var lines []blameLine
+	commits := make(map[string]blameCommit)
+	current := ""
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			continue
+		}
+		// Each blamed line starts with "<sha> <original line> <final line> [<group size>]"
+		if fields := strings.Fields(line); len(fields) >= 3 && len(fields[0]) == 40 {
+			if final, err := strconv.Atoi(fields[2]); err == nil {
+				current = fields[0]
+				lines = append(lines, blameLine{line: final, commit: current})
+				continue
+			}
+		}
+		key, value, _ := strings.Cut(line, " ")
+		commit := commits[current]
+		switch key {
+		case "author":
+			commit.author = value
+		case "author-time":
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				commit.time = time.Unix(seconds, 0).UTC()
+			}
+		case "summary":
+			commit.subject = value
+		default:
+			continue
+		}
+		commits[current] = commit
+	}
+	return lines, commits, scanner.Err()
+}
+
+// formatBlameEntries renders blamed lines as one list entry per run of consecutive lines from the same commit
+func formatBlameEntries(path string, lines []blameLine, commits map[string]blameCommit) []string {
+	var entries []string
+	for i := 0; i < len(lines); {
+		j := i + 1
+		for j < len(lines) && lines[j].commit == lines[i].commit && lines[j].line == lines[j-1].line+1 {
+			j++
+		}
+
+		location := fmt.Sprintf("%s:%d", path, lines[i].line)
+		if j-i > 1 {
+			location = fmt.Sprintf("%s-%d", location, lines[j-1].line)
+		}
+		commit := commits[lines[i].commit]
+		subject := commit.subject
+		if runes := []rune(subject); len(runes) > maxBlameSubjectLength {
+			subject = string(runes[:maxBlameSubjectLength-3]) + "..."
+		}
+		entries = append(entries, fmt.Sprintf("- %s: %s by %s on %s: %s\n",
+			location, shortRev(lines[i].commit), commit.author, commit.time.Format("2006-01-02"), subject))
+		i = j
+	}
+	return entries
+}
+
+// shortRev abbreviates a full commit hash, leaving other revisions unchanged
+func shortRev(rev string) string {
+	if len(rev) == 40 {
+		return rev[:7]
+	}
+	return rev
+}
+
+// withBlame appends the blame summary for the lines diff removes or changes to content, when there is one
+func withBlame(ctx context.Context, repoPath, rev, content string) string {
+	if summary := blameSummary(ctx, repoPath, rev, content); summary != "" {
+		return content + "\n\n" + summary
+	}
+	return content
+}
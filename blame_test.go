@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestRemovedLineRanges(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -3,6 +3,4 @@ func A() {
+ 	x := 1
+-	y := 2
+-	z := 3
++	y := 20
+ 	return
+-	// done
+ }
+@@ -20 +19 @@
+-old
++new
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package main
++
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-
+`
+	paths, ranges := removedLineRanges(diff)
+	if !reflect.DeepEqual(paths, []string{"a.go", "gone.go"}) {
+		t.Errorf("paths = %v, want a.go and gone.go", paths)
+	}
+	want := map[string][]lineRange{
+		"a.go":    {{4, 5}, {7, 7}, {20, 20}},
+		"gone.go": {{1, 2}},
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("ranges = %v, want %v", ranges, want)
+	}
+}
+
+func TestBlameSummary(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	writeTestFile(t, repo, "parser.go", "package main\n\nfunc parse() {\n\tstep1()\n\tstep2()\n\tstep3()\n}\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "--author=Alice <alice@example.com>", "--date=2023-05-01T12:00:00Z", "-m", "Add parser")
+	writeTestFile(t, repo, "parser.go", "package main\n\nfunc parse() {\n\tstep1()\n\tstep2()\n\tvalidate()\n}\n")
+	runGit(t, repo, "commit", "--quiet", "-a", "--author=Bob <bob@example.com>", "--date=2024-02-10T12:00:00Z", "-m", "Validate parsed input")
+	bob := strings.TrimSpace(runGit(t, repo, "rev-parse", "--short=7", "HEAD"))
+	alice := strings.TrimSpace(runGit(t, repo, "rev-parse", "--short=7", "HEAD~1"))
+
+	// Rewrite lines from both commits in the working tree
+	writeTestFile(t, repo, "parser.go", "package main\n\nfunc parse() {\n\tstep1()\n\tstepTwo()\n\tcheck()\n}\n")
+	diff := runGit(t, repo, "diff", "HEAD")
+
+	summary := blameSummary(ctx, repo, "HEAD", diff)
+	for _, want := range []string{
+		"as of HEAD",
+		fmt.Sprintf("- parser.go:5: %s by Alice on 2023-05-01: Add parser", alice),
+		fmt.Sprintf("- parser.go:6: %s by Bob on 2024-02-10: Validate parsed input", bob),
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "parser.go:4") {
+		t.Errorf("unchanged lines should not be blamed:\n%s", summary)
+	}
+
+	t.Run("added lines only", func(t *testing.T) {
+		writeTestFile(t, repo, "new.go", "package main\n")
+		runGit(t, repo, "add", "new.go")
+		if summary := blameSummary(ctx, repo, "HEAD", runGit(t, repo, "diff", "--cached", "--", "new.go")); summary != "" {
+			t.Errorf("expected no summary for a new file, got:\n%s", summary)
+		}
+		runGit(t, repo, "rm", "--quiet", "--cached", "new.go")
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		var long strings.Builder
+		for i := range maxBlameLines + 50 {
+			fmt.Fprintf(&long, "line %d\n", i)
+		}
+		writeTestFile(t, repo, "long.txt", long.String())
+		runGit(t, repo, "add", "long.txt")
+		runGit(t, repo, "commit", "--quiet", "-m", "Add long file")
+		writeTestFile(t, repo, "long.txt", "")
+
+		summary := blameSummary(ctx, repo, "HEAD", runGit(t, repo, "diff", "HEAD", "--", "long.txt"))
+		if !strings.Contains(summary, fmt.Sprintf("long.txt:1-%d:", maxBlameLines)) || !strings.Contains(summary, "blame limited to the first") {
+			t.Errorf("expected blame cut off at %d lines:\n%s", maxBlameLines, summary)
+		}
+	})
+}
+
+func TestUncommittedWorkIncludeBlame(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	repo := newTestRepo(t)
+	cfg = &config.Config{
+		DefaultProvider:  "mock",
+		AllowedRepoRoots: []string{repo},
+		Memory:           config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	writeTestFile(t, repo, "README.md", "# renamed repo\n")
+
+	for _, include := range []bool{false, true} {
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		result, err := handleAnalyzeUncommittedWork(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Arguments: map[string]any{"repo_path": repo, "include_blame": include}},
+		})
+		if err != nil || result.IsError {
+			t.Fatalf("include_blame=%v: unexpected error: %v %v", include, err, result.Content)
+		}
+		if len(provider.prompts) != 1 {
+			t.Fatalf("include_blame=%v: expected one LLM call, got %d", include, len(provider.prompts))
+		}
+		if got := strings.Contains(provider.prompts[0], "README.md:1: ") && strings.Contains(provider.prompts[0], "by Test User"); got != include {
+			t.Errorf("include_blame=%v: blame in prompt = %v:\n%s", include, got, provider.prompts[0])
+		}
+	}
+}
+
+func TestCommitAnalysisIncludeBlame(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	repo := newTestRepo(t)
+	cfg = &config.Config{
+		DefaultProvider:  "mock",
+		AllowedRepoRoots: []string{repo},
+		Memory:           config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	writeTestFile(t, repo, "README.md", "# renamed repo\n")
+	runGit(t, repo, "commit", "--quiet", "-a", "-m", "Rename repo")
+
+	tools := map[string]func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error){
+		"analyze_commit":       handleCommitAnalysis,
+		"analyze_commit_range": handleAnalyzeCommitRange,
+	}
+	for name, handler := range tools {
+		for _, include := range []bool{false, true} {
+			provider := &countingProvider{name: "mock"}
+			llmProviders = map[string]llm.Provider{"mock": provider}
+			optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+			result, err := handler(context.Background(), mcp.CallToolRequest{
+				Params: mcp.CallToolParams{Name: name, Arguments: map[string]any{"repo_path": repo, "count": float64(1), "include_blame": include, "no_cache": true}},
+			})
+			if err != nil || result.IsError {
+				t.Fatalf("%s include_blame=%v: unexpected error: %v %v", name, include, err, result.Content)
+			}
+			if got := strings.Contains(provider.prompts[0], "README.md:1: ") && strings.Contains(provider.prompts[0], "by Test User"); got != include {
+				t.Errorf("%s include_blame=%v: blame in prompt = %v:\n%s", name, include, got, provider.prompts[0])
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxBranchLogCommits limits how many unique commits are listed per branch
+const maxBranchLogCommits = 100
+
+func handleCompareBranches(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	branchB, err := request.RequireString("branch_b")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Validate branch names
+	branchA, _ := request.GetArguments()["branch_a"].(string)
+	if branchA != "" {
+		if err := validateBranchName(branchA); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid branch_a: %v", err)), nil
+		}
+	}
+	if err := validateBranchName(branchB); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid branch_b: %v", err)), nil
+	}
+
+	repoPath := "."
+	if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
+		repoPath = path
+	}
+
+	// Validate repo path
+	validPath, err := validateRepoPath(repoPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Compare against the repository's default branch when no base is given
+	if branchA == "" {
+		branchA = defaultBranch(ctx, validPath)
+	}
+
+	// Get provider and model from request
+	providerName := ""
+	if p, ok := request.GetArguments()["provider"].(string); ok {
+		providerName = p
+	}
+
+	modelOverride := ""
+	if m, ok := request.GetArguments()["model"].(string); ok {
+		modelOverride = m
+	}
+
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	filter, err := diffFilterFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	contextArgs, err := contextLinesArgsFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Collect divergence information
+	comparison, err := getBranchComparison(ctx, validPath, branchA, branchB, filter, contextArgs...)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// The net diff runs from branch_a, so removed lines are blamed there
+	if blame, ok := request.GetArguments()["include_blame"].(bool); ok && blame {
+		comparison = withBlame(ctx, validPath, branchA, comparison)
+	}
+
+	// Create prompt for LLM analysis
+	prompt := llm.AnalysisPrompt("compare_branches", comparison, map[string]any{
+		"branch_a": branchA,
+		"branch_b": branchB,
+	})
+
+	// Get analysis from LLM using optimization
+	contentSize := len(comparison)
+	task := llm.GetTaskFromAnalysisType("compare_branches")
+	analysis, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, contentSize, task)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(limitResponse(analysis)), nil
+}
+
+// getBranchComparison collects unique commits on each side and the net diff between two branches,
+// keeping only diff files that pass filter and passing diffArgs such as -U10 to git diff
+func getBranchComparison(ctx context.Context, repoPath, branchA, branchB string, filter *DiffFilter, diffArgs ...string) (string, error) {
+	// Fail early with a specific error for missing branches
+	for _, branch := range []string{branchA, branchB} {
+		if !refExists(ctx, repoPath, branch) {
+			return "", fmt.Errorf("branch '%s' does not exist", branch)
+		}
+	}
+
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("🔀 Branch Comparison: %s vs %s\n\n", branchA, branchB))
+
+	// Commits unique to each branch
+	onlyA, err := getUniqueCommits(ctx, repoPath, branchB, branchA)
+	if err != nil {
+		return "", err
+	}
+	onlyB, err := getUniqueCommits(ctx, repoPath, branchA, branchB)
+	if err != nil {
+		return "", err
+	}
+
+	info.WriteString(fmt.Sprintf("Commits only on %s:\n", branchA))
+	info.WriteString(formatCommitList(onlyA))
+	info.WriteString(fmt.Sprintf("\nCommits only on %s:\n", branchB))
+	info.WriteString(formatCommitList(onlyB))
+
+	// Net diff using safe memory-limited approach
+	memConfig := &cfg.Memory
+	truncatedDiff, err := getGitDiffSafe(ctx, repoPath, memConfig, filter, append(diffArgs, branchA+".."+branchB)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch diff: %v", err)
+	}
+
+	info.WriteString(fmt.Sprintf("\nNet diff (%s..%s):\n", branchA, branchB))
+
+	// Add warning if truncated
+	if truncatedDiff.IsTruncated {
+		info.WriteString(fmt.Sprintf("\n⚠️ WARNING: %s\n", truncatedDiff.WarningReason))
+		info.WriteString(fmt.Sprintf("Total size: %dKB, Files: %d\n\n", truncatedDiff.TotalSizeKB, truncatedDiff.FileCount))
+	}
+	if note := filteredFilesNote(truncatedDiff.FilesFiltered); note != "" {
+		info.WriteString(note + "\n\n")
+	}
+
+	if truncatedDiff.Content == "" && !truncatedDiff.IsTruncated {
+		info.WriteString("(no content differences)\n")
+	}
+	info.WriteString(truncatedDiff.Content)
+
+	return info.String(), nil
+}
+
+// getUniqueCommits lists commits reachable from head but not from base
+func getUniqueCommits(ctx context.Context, repoPath, base, head string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "log", "--oneline",
+		fmt.Sprintf("--max-count=%d", maxBranchLogCommits+1), base+".."+head)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits for %s..%s: %v", base, head, err)
+	}
+
+	trimmed := strings.TrimSpace(string(output))
+	if trimmed == "" {
+		return nil, nil
+	}
+	return strings.Split(trimmed, "\n"), nil
+}
+
+// formatCommitList renders a commit list, noting when it was capped
+func formatCommitList(commits []string) string {
+	if len(commits) == 0 {
+		return "(none)\n"
+	}
+
+	var out strings.Builder
+	for i, commit := range commits {
+		if i == maxBranchLogCommits {
+			out.WriteString(fmt.Sprintf("... (showing first %d commits)\n", maxBranchLogCommits))
+			break
+		}
+		out.WriteString(commit)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// refExists reports whether a ref resolves to a commit in the repository
+func refExists(ctx context.Context, repoPath, ref string) bool {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	return cmd.Run() == nil
+}
+
+// defaultBranch returns the branch to compare against when none is given: the branch origin's HEAD
+// points to (e.g. origin/develop), else main or master, preferring local branches over
+// remote-tracking ones. Repositories without any of these fall back to "main", which callers
+// report as missing.
+func defaultBranch(ctx context.Context, repoPath string) string {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+	if output, err := cmd.Output(); err == nil {
+		if branch := strings.TrimSpace(string(output)); branch != "" && refExists(ctx, repoPath, branch) {
+			return branch
+		}
+	}
+
+	for _, branch := range []string{"main", "master", "origin/main", "origin/master"} {
+		if refExists(ctx, repoPath, branch) {
+			return branch
+		}
+	}
+	return "main"
+}
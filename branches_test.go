@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateBranchName(t *testing.T) {
+	tests := []struct {
+		name    string
+		branch  string
+		wantErr bool
+	}{
+		{"simple", "main", false},
+		{"nested", "feature/login-form", false},
+		{"remote tracking", "origin/main", false},
+		{"version", "release-1.2", false},
+		{"empty", "", true},
+		{"option injection", "--output=/tmp/x", true},
+		{"range syntax", "main..dev", true},
+		{"trailing slash", "feature/", true},
+		{"lock suffix", "main.lock", true},
+		{"shell metacharacters", "main;rm -rf", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBranchName(tt.branch)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateBranchName(%q) error = %v, wantErr %v", tt.branch, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetBranchComparison(t *testing.T) {
+	repo := newTestRepo(t)
+
+	runGit(t, repo, "checkout", "--quiet", "-b", "feature")
+	writeTestFile(t, repo, "feature.go", "package main\n\nfunc Feature() {}\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add feature")
+
+	runGit(t, repo, "checkout", "--quiet", "main")
+	writeTestFile(t, repo, "hotfix.go", "package main\n\nfunc Hotfix() {}\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Apply hotfix")
+
+	ctx := context.Background()
+	comparison, err := getBranchComparison(ctx, repo, "main", "feature", nil)
+	if err != nil {
+		t.Fatalf("getBranchComparison failed: %v", err)
+	}
+
+	for _, want := range []string{"Commits only on main", "Apply hotfix", "Commits only on feature", "Add feature", "feature.go"} {
+		if !strings.Contains(comparison, want) {
+			t.Errorf("comparison missing %q:\n%s", want, comparison)
+		}
+	}
+
+	t.Run("missing branch", func(t *testing.T) {
+		_, err := getBranchComparison(ctx, repo, "main", "does-not-exist", nil)
+		if err == nil {
+			t.Fatal("expected error for missing branch")
+		}
+		if !strings.Contains(err.Error(), "branch 'does-not-exist' does not exist") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestDefaultBranch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no remote", func(t *testing.T) {
+		repo := newTestRepo(t)
+		if got := defaultBranch(ctx, repo); got != "main" {
+			t.Errorf("defaultBranch = %q, want main", got)
+		}
+	})
+
+	t.Run("master without remote", func(t *testing.T) {
+		repo := newTestRepo(t)
+		runGit(t, repo, "branch", "--quiet", "-m", "main", "master")
+		if got := defaultBranch(ctx, repo); got != "master" {
+			t.Errorf("defaultBranch = %q, want master", got)
+		}
+	})
+
+	t.Run("remote HEAD", func(t *testing.T) {
+		origin := newTestRepo(t)
+		runGit(t, origin, "checkout", "--quiet", "-b", "develop")
+
+		clone := filepath.Join(t.TempDir(), "clone")
+		runGit(t, origin, "clone", "--quiet", origin, clone)
+		if got := defaultBranch(ctx, clone); got != "origin/develop" {
+			t.Errorf("defaultBranch = %q, want origin/develop", got)
+		}
+	})
+
+	t.Run("no branches", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git not available")
+		}
+		repo := t.TempDir()
+		runGit(t, repo, "init", "--quiet", "--initial-branch=trunk")
+		if got := defaultBranch(ctx, repo); got != "main" {
+			t.Errorf("defaultBranch = %q, want the main fallback", got)
+		}
+	})
+}
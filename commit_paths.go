@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxCommitPaths bounds how many paths a single request may limit a commit to
+const maxCommitPaths = 100
+
+// pathspecGlobChars are the characters git expands as wildcards in a pathspec
+const pathspecGlobChars = "*?[]"
+
+// commitPathsFromRequest returns the validated paths argument, or nil when the whole commit is wanted.
+// Paths are matched literally by missingCommitPaths, so pathspec magic and wildcards are rejected
+// rather than letting git expand them to files the check doesn't know about.
+func commitPathsFromRequest(request mcp.CallToolRequest) ([]string, error) {
+	raw := request.GetStringSlice("paths", nil)
+	if len(raw) > maxCommitPaths {
+		return nil, fmt.Errorf("too many paths: %d (maximum %d)", len(raw), maxCommitPaths)
+	}
+
+	paths := make([]string, 0, len(raw))
+	for _, path := range raw {
+		valid, err := validateRepoRelativePath(path)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ContainsAny(valid, pathspecGlobChars) {
+			return nil, fmt.Errorf("path %q contains a wildcard; name files or directories exactly", path)
+		}
+		paths = append(paths, valid)
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	return paths, nil
+}
+
+// missingCommitPaths returns the paths that match no file changed by commitSHA. A path matches
+// a changed file with the same name or, for a directory, any file beneath it.
+func missingCommitPaths(ctx context.Context, repoPath, commitSHA string, paths []string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "diff-tree", "--no-commit-id", "--name-only", "-r", "--root", commitSHA)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed by commit: %v", err)
+	}
+	changed := strings.Split(strings.TrimSpace(string(output)), "\n")
+
+	var missing []string
+	for _, path := range paths {
+		found := false
+		for _, file := range changed {
+			if file == path || strings.HasPrefix(file, path+"/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, path)
+		}
+	}
+	return missing, nil
+}
+
+// missingPathsNote warns that some requested paths were not changed by the commit, or is "" if all were
+func missingPathsNote(missing []string) string {
+	if len(missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("⚠️ Not changed in this commit, so not reviewed: %s", strings.Join(missing, ", "))
+}
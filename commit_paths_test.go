@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestGetCommitInfoPaths(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1}}
+
+	repo := newTestRepo(t)
+	writeTestFile(t, repo, "api/handler.go", "package api\n\nfunc Handle() {}\n")
+	writeTestFile(t, repo, "api/handler_test.go", "package api\n\nfunc TestHandle() {}\n")
+	writeTestFile(t, repo, "docs/notes.md", "notes\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add handler")
+
+	info, err := getCommitInfo(context.Background(), repo, "HEAD", "api/handler.go", "docs")
+	if err != nil {
+		t.Fatalf("getCommitInfo() unexpected error: %v", err)
+	}
+	for _, want := range []string{"Add handler", "+func Handle() {}", "+notes"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("commit info missing %q:\n%s", want, info)
+		}
+	}
+	if strings.Contains(info, "handler_test.go") {
+		t.Errorf("commit info includes a file outside the requested paths:\n%s", info)
+	}
+
+	missing, err := missingCommitPaths(context.Background(), repo, "HEAD", []string{"api", "docs/notes.md", "README.md", "do"})
+	if err != nil {
+		t.Fatalf("missingCommitPaths() unexpected error: %v", err)
+	}
+	if strings.Join(missing, ",") != "README.md,do" {
+		t.Errorf("missing = %v, want [README.md do]", missing)
+	}
+}
+
+func TestHandleCommitAnalysisPaths(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	repo := newTestRepo(t)
+	writeTestFile(t, repo, "core.go", "package main\n\nfunc Core() {}\n")
+	writeTestFile(t, repo, "generated.go", "package main\n\nvar Generated = 1\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add core")
+
+	cfg = &config.Config{
+		DefaultProvider:  "mock",
+		AllowedRepoRoots: []string{repo},
+		Memory:           config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+
+	call := func(paths ...any) (*mcp.CallToolResult, *countingProvider) {
+		t.Helper()
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		result, err := handleCommitAnalysis(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "analyze_commit", Arguments: map[string]any{"repo_path": repo, "paths": paths}},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result, provider
+	}
+
+	result, provider := call("core.go", "missing.go")
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %v", result.Content)
+	}
+	if provider.calls != 1 {
+		t.Fatalf("provider called %d times, want 1", provider.calls)
+	}
+	prompt := provider.prompts[0]
+	if !strings.Contains(prompt, "func Core()") || strings.Contains(prompt, "Generated") {
+		t.Errorf("prompt should contain only core.go:\n%s", prompt)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "Not changed in this commit, so not reviewed: missing.go") {
+		t.Errorf("result missing the unchanged-path warning:\n%s", text)
+	}
+
+	// Requesting only untouched paths fails without calling the provider
+	result, provider = call("missing.go")
+	if !result.IsError || provider.calls != 0 {
+		t.Errorf("expected an error without a provider call, got IsError=%v and %d calls", result.IsError, provider.calls)
+	}
+
+	result, provider = call("../etc/passwd")
+	if !result.IsError || provider.calls != 0 {
+		t.Errorf("expected a path outside the repository to be rejected, got IsError=%v and %d calls", result.IsError, provider.calls)
+	}
+
+	for _, pathspec := range []string{"*.go", "core.g?", "[cg]ore.go", ":(glob)**/*.go"} {
+		result, provider = call(pathspec)
+		if !result.IsError || provider.calls != 0 {
+			t.Errorf("expected pathspec %q to be rejected, got IsError=%v and %d calls", pathspec, result.IsError, provider.calls)
+		}
+	}
+}
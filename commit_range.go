@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultCommitRangeCount is how many commits are analyzed when no range or count is given
+const defaultCommitRangeCount = 5
+
+// CommitRef identifies a commit within a range
+type CommitRef struct {
+	SHA     string
+	Subject string
+	Body    string // message after the subject, trimmed
+	Merge   bool   // the commit has more than one parent
+}
+
+// CommitRange holds the commits selected for analysis
+type CommitRange struct {
+	Commits []CommitRef // oldest first
+	Total   int         // commits in the requested range before capping
+}
+
+// IsTruncated reports whether the range was capped
+func (r *CommitRange) IsTruncated() bool {
+	return r.Total > len(r.Commits)
+}
+
+func handleAnalyzeCommitRange(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	from := ""
+	if f, ok := request.GetArguments()["from"].(string); ok {
+		from = f
+	}
+
+	to := "HEAD"
+	if t, ok := request.GetArguments()["to"].(string); ok && t != "" {
+		to = t
+	}
+
+	count := 0
+	if c, ok := request.GetArguments()["count"].(float64); ok {
+		count = int(c)
+	}
+
+	// Validate range endpoints
+	if from != "" {
+		if err := validateCommitSHA(from); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid from: %v", err)), nil
+		}
+	}
+	if err := validateCommitSHA(to); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid to: %v", err)), nil
+	}
+	if count < 0 {
+		return mcp.NewToolResultError("count must be a positive number"), nil
+	}
+	if from == "" && count == 0 {
+		count = defaultCommitRangeCount
+	}
+
+	repoPath := "."
+	if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
+		repoPath = path
+	}
+
+	// Validate repo path
+	validPath, err := validateRepoPath(repoPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Get provider and model from request
+	providerName := ""
+	if p, ok := request.GetArguments()["provider"].(string); ok {
+		providerName = p
+	}
+
+	modelOverride := ""
+	if m, ok := request.GetArguments()["model"].(string); ok {
+		modelOverride = m
+	}
+
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	commitRange, err := listCommitRange(ctx, validPath, from, to, count, maxCommitsPerRange())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if len(commitRange.Commits) == 0 {
+		return mcp.NewToolResultText("No commits found in the requested range."), nil
+	}
+
+	includeBlame, _ := request.GetArguments()["include_blame"].(bool)
+	analysis, err := summarizeCommitRange(ctx, optimizedProvider, validPath, commitRange, includeBlame)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(limitResponse(analysis)), nil
+}
+
+// maxCommitsPerRange returns the configured cap on commits analyzed per range
+func maxCommitsPerRange() int {
+	if cfg.MaxCommitsPerRange > 0 {
+		return cfg.MaxCommitsPerRange
+	}
+	return config.DefaultMaxCommitsPerRange
+}
+
+// listCommitRange lists commits oldest-first, either in from..to or the last count commits up to to.
+// At most limit of the most recent commits are returned.
+func listCommitRange(ctx context.Context, repoPath, from, to string, count, limit int) (*CommitRange, error) {
+	// Fields are separated by unit separators and commits by record separators, since bodies span lines
+	args := []string{"-C", repoPath, "log", "--format=%H%x1f%P%x1f%s%x1f%b%x1e"}
+	if from != "" {
+		args = append(args, from+".."+to)
+	} else {
+		args = append(args, fmt.Sprintf("--max-count=%d", count), to)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %v", err)
+	}
+
+	// git log lists newest first
+	var commits []CommitRef
+	for _, record := range strings.Split(string(output), "\x1e") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x1f", 4)
+		if len(fields) < 3 {
+			continue
+		}
+		commit := CommitRef{
+			SHA:     fields[0],
+			Subject: fields[2],
+			Merge:   len(strings.Fields(fields[1])) > 1,
+		}
+		if len(fields) == 4 {
+			commit.Body = strings.TrimSpace(fields[3])
+		}
+		commits = append(commits, commit)
+	}
+
+	commitRange := &CommitRange{Total: len(commits)}
+	if limit > 0 && len(commits) > limit {
+		commits = commits[:limit]
+	}
+
+	// Present oldest first
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	commitRange.Commits = commits
+
+	return commitRange, nil
+}
+
+// summarizeCommitRange asks the LLM for a summary of each commit and then a rollup of the range
+func summarizeCommitRange(ctx context.Context, provider llm.OptimizedProvider, repoPath string, commitRange *CommitRange, includeBlame bool) (string, error) {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("📚 Commit Range Analysis (%d commits)\n\n", len(commitRange.Commits)))
+
+	if commitRange.IsTruncated() {
+		out.WriteString(fmt.Sprintf("⚠️ Note: range contains %d commits; only the most recent %d were analyzed (see max_commits_per_range)\n\n",
+			commitRange.Total, len(commitRange.Commits)))
+	}
+
+	task := llm.GetTaskFromAnalysisType("commit")
+	summaries := make([]string, 0, len(commitRange.Commits))
+	for _, commit := range commitRange.Commits {
+		shortSHA := commit.SHA[:min(7, len(commit.SHA))]
+		header := fmt.Sprintf("%s %s", shortSHA, commit.Subject)
+
+		commitInfo, err := getCommitInfo(ctx, repoPath, commit.SHA)
+		if err != nil {
+			return "", err
+		}
+		if includeBlame {
+			commitInfo = withBlame(ctx, repoPath, commit.SHA+"^", commitInfo)
+		}
+
+		prompt := llm.AnalysisPrompt("commit_summary", commitInfo, nil)
+		summary, err := provider.AnalyzeOptimized(ctx, prompt, len(commitInfo), task)
+		if err != nil {
+			return "", fmt.Errorf("commit %s: %w", shortSHA, err)
+		}
+
+		out.WriteString(fmt.Sprintf("## %s\n%s\n\n", header, strings.TrimSpace(summary)))
+		summaries = append(summaries, fmt.Sprintf("%s:\n%s", header, strings.TrimSpace(summary)))
+	}
+
+	// Roll the per-commit summaries up into an overview
+	combined := strings.Join(summaries, "\n\n")
+	rollupPrompt := llm.AnalysisPrompt("commit_range", combined, nil)
+	rollup, err := provider.AnalyzeOptimized(ctx, rollupPrompt, len(combined), task)
+	if err != nil {
+		return "", fmt.Errorf("range summary: %w", err)
+	}
+
+	out.WriteString("## Overall Summary\n")
+	out.WriteString(strings.TrimSpace(rollup))
+	out.WriteString("\n")
+
+	return out.String(), nil
+}
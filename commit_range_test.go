@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+)
+
+// newCommitRangeRepo creates a repo with an initial commit followed by n numbered commits
+func newCommitRangeRepo(t *testing.T, n int) string {
+	t.Helper()
+
+	repo := newTestRepo(t)
+	for i := 1; i <= n; i++ {
+		writeTestFile(t, repo, fmt.Sprintf("file%d.txt", i), fmt.Sprintf("content %d\n", i))
+		runGit(t, repo, "add", ".")
+		runGit(t, repo, "commit", "--quiet", "-m", fmt.Sprintf("Commit %d", i))
+	}
+	return repo
+}
+
+func TestListCommitRange(t *testing.T) {
+	repo := newCommitRangeRepo(t, 4)
+	ctx := context.Background()
+
+	t.Run("count", func(t *testing.T) {
+		r, err := listCommitRange(ctx, repo, "", "HEAD", 2, 20)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(r.Commits) != 2 || r.IsTruncated() {
+			t.Fatalf("got %d commits (truncated=%v), want 2", len(r.Commits), r.IsTruncated())
+		}
+		if r.Commits[0].Subject != "Commit 3" || r.Commits[1].Subject != "Commit 4" {
+			t.Errorf("commits not oldest-first: %+v", r.Commits)
+		}
+	})
+
+	t.Run("from..to", func(t *testing.T) {
+		r, err := listCommitRange(ctx, repo, "HEAD~3", "HEAD", 0, 20)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(r.Commits) != 3 || r.Commits[0].Subject != "Commit 2" {
+			t.Errorf("unexpected commits: %+v", r.Commits)
+		}
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		r, err := listCommitRange(ctx, repo, "HEAD~4", "HEAD", 0, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !r.IsTruncated() || r.Total != 4 || len(r.Commits) != 2 {
+			t.Fatalf("got total=%d commits=%d, want capped 4 -> 2", r.Total, len(r.Commits))
+		}
+		// The most recent commits are kept
+		if r.Commits[1].Subject != "Commit 4" {
+			t.Errorf("expected most recent commits, got %+v", r.Commits)
+		}
+	})
+}
+
+func TestSummarizeCommitRange(t *testing.T) {
+	repo := newCommitRangeRepo(t, 3)
+	ctx := context.Background()
+
+	testCfg := &config.Config{
+		Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	originalCfg := cfg
+	cfg = testCfg
+	defer func() { cfg = originalCfg }()
+
+	commitRange, err := listCommitRange(ctx, repo, "HEAD~3", "HEAD", 0, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mock := &countingProvider{name: "mock"}
+	result, err := summarizeCommitRange(ctx, llm.NewOptimizedProvider(mock, testCfg), repo, commitRange, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// One call per commit plus the rollup
+	if mock.calls != 3 {
+		t.Errorf("provider called %d times, want 3", mock.calls)
+	}
+
+	for _, want := range []string{
+		"Commit Range Analysis (2 commits)",
+		"range contains 3 commits; only the most recent 2 were analyzed",
+		"Commit 2",
+		"Commit 3",
+		"## Overall Summary",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("result missing %q:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "Commit 1\n") {
+		t.Errorf("capped commit should not be analyzed:\n%s", result)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxCompareTargets caps how many provider/model pairs one comparison may call
+const maxCompareTargets = 8
+
+// defaultCompareTimeout bounds each provider's response in a comparison
+const defaultCompareTimeout = 5 * time.Minute
+
+// compareTarget is a provider and optional model to include in a comparison
+type compareTarget struct {
+	Provider string
+	Model    string
+}
+
+// ProviderComparison holds one provider's answer to a compared prompt
+type ProviderComparison struct {
+	Provider       string
+	Model          string
+	Response       string
+	Latency        time.Duration
+	PromptTokens   int
+	ResponseTokens int
+	Error          string
+}
+
+func handleCompareProviders(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	prompt, ok := request.GetArguments()["prompt"].(string)
+	if !ok || strings.TrimSpace(prompt) == "" {
+		return mcp.NewToolResultError("prompt is required"), nil
+	}
+
+	rawTargets, _ := request.GetArguments()["targets"].(string)
+	targets, err := parseCompareTargets(rawTargets)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	timeout := defaultCompareTimeout
+	if secs, ok := request.GetArguments()["timeout_seconds"].(float64); ok && secs > 0 {
+		timeout = time.Duration(secs * float64(time.Second))
+	}
+
+	comparisons := compareProviders(ctx, request, targets, prompt, timeout)
+
+	return mcp.NewToolResultText(formatProviderComparisons(comparisons)), nil
+}
+
+// parseCompareTargets parses a list like "openai,ollama:llama3.2" into provider/model pairs.
+// Everything after the first colon is the model, so OpenRouter IDs such as "meta-llama/llama-3-8b:free" survive.
+func parseCompareTargets(value string) ([]compareTarget, error) {
+	var targets []compareTarget
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		provider, model, _ := strings.Cut(entry, ":")
+		targets = append(targets, compareTarget{
+			Provider: strings.TrimSpace(provider),
+			Model:    strings.TrimSpace(model),
+		})
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("targets is required (e.g. \"openai,ollama:llama3.2\")")
+	}
+	if len(targets) > maxCompareTargets {
+		return nil, fmt.Errorf("too many targets: %d (maximum %d)", len(targets), maxCompareTargets)
+	}
+	return targets, nil
+}
+
+// compareProviders sends prompt to every target concurrently, returning results in input order.
+// A failing target is reported in its result rather than aborting the others.
+func compareProviders(ctx context.Context, request mcp.CallToolRequest, targets []compareTarget, prompt string, timeout time.Duration) []ProviderComparison {
+	comparisons := make([]ProviderComparison, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target compareTarget) {
+			defer wg.Done()
+			comparisons[i] = compareProvider(ctx, request, target, prompt, timeout)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return comparisons
+}
+
+// compareProvider runs prompt against a single target
+func compareProvider(ctx context.Context, request mcp.CallToolRequest, target compareTarget, prompt string, timeout time.Duration) ProviderComparison {
+	comparison := ProviderComparison{
+		Provider:     target.Provider,
+		Model:        cfg.ResolveModelAlias(target.Provider, target.Model),
+		PromptTokens: cfg.EstimateTokensForText(prompt),
+	}
+	if comparison.Model == "" {
+		_, comparison.Model, _ = cfg.GetProviderConfig(target.Provider)
+	}
+
+	provider, err := getAnalysisProvider(request, target.Provider, target.Model)
+	if err != nil {
+		comparison.Error = err.Error()
+		return comparison
+	}
+
+	compareCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	response, err := provider.AnalyzeOptimized(compareCtx, prompt, len(prompt), config.TaskGeneral)
+	comparison.Latency = time.Since(start)
+	if err != nil {
+		comparison.Error = err.Error()
+		return comparison
+	}
+
+	comparison.Response = limitResponse(response)
+	comparison.ResponseTokens = cfg.EstimateTokensForText(response)
+	return comparison
+}
+
+// formatProviderComparisons renders comparison results as a summary table followed by each response
+func formatProviderComparisons(comparisons []ProviderComparison) string {
+	var out strings.Builder
+	out.WriteString("# Provider Comparison\n\n")
+	out.WriteString("| Provider | Model | Status | Latency | Prompt tokens (est.) | Response tokens (est.) |\n")
+	out.WriteString("|---|---|---|---|---|---|\n")
+
+	succeeded := 0
+	for _, c := range comparisons {
+		status := "✅ ok"
+		responseTokens := fmt.Sprintf("%d", c.ResponseTokens)
+		if c.Error != "" {
+			status = "❌ failed"
+			responseTokens = "—"
+		} else {
+			succeeded++
+		}
+		out.WriteString(fmt.Sprintf("| %s | %s | %s | %dms | %d | %s |\n",
+			c.Provider, c.Model, status, c.Latency.Milliseconds(), c.PromptTokens, responseTokens))
+	}
+	out.WriteString(fmt.Sprintf("\n%d of %d providers responded\n", succeeded, len(comparisons)))
+
+	for _, c := range comparisons {
+		out.WriteString(fmt.Sprintf("\n## %s (%s)\n\n", c.Provider, c.Model))
+		if c.Error != "" {
+			out.WriteString(fmt.Sprintf("❌ Error: %s\n", c.Error))
+			continue
+		}
+		out.WriteString(c.Response)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// delayedProvider answers after a fixed delay
+type delayedProvider struct {
+	name     string
+	delay    time.Duration
+	response string
+}
+
+func (d *delayedProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	select {
+	case <-time.After(d.delay):
+		return d.response, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (d *delayedProvider) Name() string {
+	return d.name
+}
+
+func (d *delayedProvider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{SupportsTemperature: true}
+}
+
+func TestHandleCompareProviders(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "openai",
+		Memory: config.MemoryConfig{
+			MaxDiffSizeMB: 10,
+			MaxFileCount:  1000,
+			MaxLineLength: 1000,
+			ChunkSizeMB:   1,
+		},
+	}
+	cfg.OpenAI.Model = "gpt-4o-mini"
+	cfg.Ollama.Model = "devstral:latest"
+	cfg.ModelAliases = map[string]string{"smart": "gpt-4o"}
+
+	llmProviders = map[string]llm.Provider{
+		"openai":        &delayedProvider{name: "openai", delay: 150 * time.Millisecond, response: "slow answer"},
+		"openai:gpt-4o": &delayedProvider{name: "openai", delay: 10 * time.Millisecond, response: "fast answer"},
+		"ollama":        &delayedProvider{name: "ollama", delay: 100 * time.Millisecond, response: "local answer"},
+		"mistral":       &MockProvider{name: "mistral", err: errors.New("invalid API key")},
+	}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Name: "compare_providers",
+			Arguments: map[string]any{
+				"prompt":  "What does a mutex do?",
+				"targets": "openai, openai:smart, ollama, mistral",
+			},
+		},
+	}
+
+	start := time.Now()
+	result, err := handleCompareProviders(context.Background(), req)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %v", result.Content)
+	}
+
+	// Requests run concurrently, so the call takes about as long as the slowest provider
+	if elapsed >= 260*time.Millisecond {
+		t.Errorf("comparison took %v; providers were not called concurrently", elapsed)
+	}
+
+	text := result.Content[0].(mcp.TextContent).Text
+	for _, want := range []string{
+		"| openai | gpt-4o-mini | ✅ ok |",
+		"| openai | gpt-4o | ✅ ok |",
+		"| ollama | devstral:latest | ✅ ok |",
+		"| mistral |",
+		"❌ failed",
+		"3 of 4 providers responded",
+		"slow answer",
+		"fast answer",
+		"local answer",
+		"❌ Error: invalid API key",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected result to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	// Results keep the requested order regardless of which provider finished first
+	if strings.Index(text, "slow answer") > strings.Index(text, "fast answer") {
+		t.Error("expected responses in target order")
+	}
+}
+
+func TestCompareProvidersLatency(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "openai",
+		Memory: config.MemoryConfig{
+			MaxDiffSizeMB: 10,
+			MaxFileCount:  1000,
+			MaxLineLength: 1000,
+			ChunkSizeMB:   1,
+		},
+	}
+	llmProviders = map[string]llm.Provider{
+		"openai": &delayedProvider{name: "openai", delay: 80 * time.Millisecond, response: "A mutex serializes access to shared state."},
+		"ollama": &delayedProvider{name: "ollama", delay: time.Second, response: "too late"},
+	}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	targets := []compareTarget{{Provider: "openai"}, {Provider: "ollama"}}
+	comparisons := compareProviders(context.Background(), mcp.CallToolRequest{}, targets, "hello", 300*time.Millisecond)
+
+	if comparisons[0].Error != "" || comparisons[0].Latency < 80*time.Millisecond {
+		t.Errorf("expected openai to succeed after its delay, got %+v", comparisons[0])
+	}
+	if comparisons[0].PromptTokens == 0 || comparisons[0].ResponseTokens == 0 {
+		t.Errorf("expected token estimates, got %+v", comparisons[0])
+	}
+	if comparisons[1].Error == "" {
+		t.Errorf("expected ollama to time out, got %+v", comparisons[1])
+	}
+}
+
+func TestParseCompareTargets(t *testing.T) {
+	targets, err := parseCompareTargets("openai, openrouter:meta-llama/llama-3-8b:free,,ollama:llama3.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []compareTarget{
+		{Provider: "openai"},
+		{Provider: "openrouter", Model: "meta-llama/llama-3-8b:free"},
+		{Provider: "ollama", Model: "llama3.2"},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("got %d targets, want %d: %+v", len(targets), len(want), targets)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target %d = %+v, want %+v", i, targets[i], want[i])
+		}
+	}
+
+	if _, err := parseCompareTargets(" , "); err == nil {
+		t.Error("expected an error for an empty target list")
+	}
+	if _, err := parseCompareTargets(strings.Repeat("openai,", maxCompareTargets+1)); err == nil {
+		t.Error("expected an error for too many targets")
+	}
+}
@@ -2,13 +2,76 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// DefaultMaxCommitsPerRange caps how many commits a range analysis sends to the LLM
+const DefaultMaxCommitsPerRange = 20
+
+// DefaultMaxCachedProviders caps how many provider/model instances are kept in memory
+const DefaultMaxCachedProviders = 32
+
+// DefaultCompressMinBytes is the smallest request body compressed when compress_requests is on
+const DefaultCompressMinBytes = 64 * 1024
+
+// Server transports
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+)
+
+// DefaultServerAddr is the listen address for the http transport; it only accepts local connections
+const DefaultServerAddr = "localhost:8080"
+
+// IsLoopbackAddr reports whether a host:port listen address only accepts local connections
+func IsLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return IsLoopbackHost(host)
+}
+
+// IsLoopbackHost reports whether host names this machine: localhost or a loopback IP
+func IsLoopbackHost(host string) bool {
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// DefaultTemperature is the sampling temperature used when none is configured
+const DefaultTemperature = 0.3
+
+// DefaultSeverityWeights are the points each finding adds to a review's risk score, by severity
+var DefaultSeverityWeights = map[string]float64{"critical": 10, "high": 5, "medium": 2, "low": 1, "info": 0}
+
+// DefaultGitHubAPIURL is the GitHub API used to fetch pull requests unless github.api_url is set
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// GitHubConfig holds settings for fetching pull requests from the GitHub API
+type GitHubConfig struct {
+	// Token authenticates API requests; it is needed for private repositories and raises the rate limit
+	Token string `json:"token"`
+	// APIURL is the API base URL, set for GitHub Enterprise Server (e.g. https://github.example.com/api/v3)
+	APIURL string `json:"api_url"`
+}
+
 // MemoryConfig holds memory management settings
 type MemoryConfig struct {
 	MaxDiffSizeMB   int  `json:"max_diff_size_mb"`
@@ -16,6 +79,64 @@ type MemoryConfig struct {
 	MaxLineLength   int  `json:"max_line_length"`
 	EnableStreaming bool `json:"enable_streaming"`
 	ChunkSizeMB     int  `json:"chunk_size_mb"`
+	// TruncationStrategy reduces a single file larger than a chunk to one chunk instead of
+	// splitting it (head, tail, head_tail, or smart); empty keeps splitting it into parts
+	TruncationStrategy string `json:"truncation_strategy"`
+}
+
+// Truncation strategies for a single file that exceeds the chunk size
+const (
+	// TruncationHead keeps the beginning of the file
+	TruncationHead = "head"
+	// TruncationTail keeps the end of the file
+	TruncationTail = "tail"
+	// TruncationHeadTail keeps the beginning and the end with an elision marker between them
+	TruncationHeadTail = "head_tail"
+	// TruncationSmart keeps a diff's file headers, hunk headers, and changed lines ahead of unchanged context
+	TruncationSmart = "smart"
+)
+
+// TruncationStrategies lists the valid values of MemoryConfig.TruncationStrategy
+var TruncationStrategies = []string{TruncationHead, TruncationTail, TruncationHeadTail, TruncationSmart}
+
+// Circuit breaker defaults
+const (
+	DefaultCircuitBreakerThreshold       = 5
+	DefaultCircuitBreakerWindowSeconds   = 60
+	DefaultCircuitBreakerCooldownSeconds = 30
+)
+
+// CircuitBreakerConfig controls when a failing provider is skipped instead of retried
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit (negative disables it)
+	FailureThreshold int `json:"failure_threshold"`
+	// WindowSeconds is how close together failures must be to count as consecutive
+	WindowSeconds int `json:"window_seconds"`
+	// CooldownSeconds is how long an open circuit fails fast before probing the provider again
+	CooldownSeconds int `json:"cooldown_seconds"`
+}
+
+// setDefaults fills in unset circuit breaker settings
+func (c *CircuitBreakerConfig) setDefaults() {
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = DefaultCircuitBreakerThreshold
+	}
+	if c.WindowSeconds == 0 {
+		c.WindowSeconds = DefaultCircuitBreakerWindowSeconds
+	}
+	if c.CooldownSeconds == 0 {
+		c.CooldownSeconds = DefaultCircuitBreakerCooldownSeconds
+	}
+}
+
+// Window returns WindowSeconds as a duration
+func (c CircuitBreakerConfig) Window() time.Duration {
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+// Cooldown returns CooldownSeconds as a duration
+func (c CircuitBreakerConfig) Cooldown() time.Duration {
+	return time.Duration(c.CooldownSeconds) * time.Second
 }
 
 // Config holds the application configuration.
@@ -28,54 +149,280 @@ type Config struct {
 	// Provider-specific configurations
 	OpenAI struct {
 		APIKey string `json:"api_key"`
-		Model  string `json:"model"`
+		// APIKeyCommand is run at load time and its trimmed output used as APIKey, e.g. "op read op://dev/openai/key".
+		// It is passed to the shell as written, so it is not interpolated.
+		APIKeyCommand string `json:"api_key_command" interpolate:"-"`
+		Model         string `json:"model"`
+		Organization  string `json:"organization"`
+		Project       string `json:"project"`
 	} `json:"openai"`
 	Google struct {
-		APIKey string `json:"api_key"`
-		Model  string `json:"model"`
+		APIKey        string `json:"api_key"`
+		APIKeyCommand string `json:"api_key_command" interpolate:"-"`
+		Model         string `json:"model"`
+		// SafetySettings maps harm categories (e.g. "dangerous_content") to block thresholds (e.g. "BLOCK_NONE")
+		SafetySettings map[string]string `json:"safety_settings,omitempty"`
+		// UseVertex calls Vertex AI in Project and Region with Application Default Credentials
+		// instead of the Generative Language API with APIKey
+		UseVertex bool   `json:"use_vertex"`
+		Project   string `json:"project"`
+		Region    string `json:"region"`
 	} `json:"google"`
 	Ollama struct {
 		Endpoint string `json:"endpoint"`
 		Model    string `json:"model"`
+		// Options overrides individual sampling options sent to Ollama (e.g. top_k, top_p)
+		Options map[string]any `json:"options,omitempty"`
+		// KeepAlive is how long the model stays loaded after a request ("30m", or "-1" for indefinitely)
+		KeepAlive string `json:"keep_alive"`
+		// Preload loads the model once at startup so the first request does not pay the load time
+		Preload bool `json:"preload"`
+		// VerifyOnStartup checks the endpoint is reachable when the provider is created
+		VerifyOnStartup bool `json:"verify_on_startup"`
 	} `json:"ollama"`
 	Mistral struct {
-		APIKey string `json:"api_key"`
-		Model  string `json:"model"`
+		APIKey        string `json:"api_key"`
+		APIKeyCommand string `json:"api_key_command" interpolate:"-"`
+		Model         string `json:"model"`
+		// SafePrompt prepends Mistral's safety system prompt to every request
+		SafePrompt bool `json:"safe_prompt"`
 	} `json:"mistral"`
+	OpenRouter struct {
+		APIKey        string `json:"api_key"`
+		APIKeyCommand string `json:"api_key_command" interpolate:"-"`
+		Model         string `json:"model"`
+		// Models lists fallback models OpenRouter tries in order when the primary model fails
+		Models []string `json:"models,omitempty"`
+		// Referer and Title are sent as the HTTP-Referer and X-Title attribution headers
+		Referer string `json:"referer"`
+		Title   string `json:"title"`
+	} `json:"openrouter"`
+	Perplexity struct {
+		APIKey        string `json:"api_key"`
+		APIKeyCommand string `json:"api_key_command" interpolate:"-"`
+		Model         string `json:"model"`
+	} `json:"perplexity"`
 
 	// Server settings
 	ServerName    string `json:"server_name"`
 	ServerVersion string `json:"server_version"`
+	// ServerTransport selects how clients connect: stdio (default) or http
+	ServerTransport string `json:"server_transport"`
+	// ServerAddr is the listen address used by the http transport
+	ServerAddr string `json:"server_addr"`
+	// ServerAuthToken, when set, must be sent by http clients as "Authorization: Bearer <token>"
+	ServerAuthToken string `json:"server_auth_token,omitempty"`
+	// ServerAllowedOrigins lists the browser origins, besides localhost, allowed to call the http transport
+	ServerAllowedOrigins []string `json:"server_allowed_origins,omitempty"`
+	// MetricsAddr, when set, is the listen address of a separate, unauthenticated Prometheus metrics endpoint
+	MetricsAddr string `json:"metrics_addr,omitempty"`
+
+	// LogLevel is the minimum level logged: debug, info, warn, or error
+	LogLevel string `json:"log_level"`
 
 	// Memory management settings
 	Memory MemoryConfig `json:"memory"`
 
-	ConfigType string
+	// GitHub configures access to pull requests for review_github_pr
+	GitHub GitHubConfig `json:"github"`
+
+	// AllowedRepoRoots are absolute paths under which repositories may be analyzed in addition to the working directory
+	AllowedRepoRoots []string `json:"allowed_repo_roots,omitempty"`
+
+	// MaxCommitsPerRange caps how many commits are analyzed by range tools
+	MaxCommitsPerRange int `json:"max_commits_per_range"`
+
+	// MaxResponseChars truncates LLM responses returned by tools to this many characters (0 = unlimited)
+	MaxResponseChars int `json:"max_response_chars"`
+
+	// MaxCachedProviders caps cached provider instances; least recently used ones are evicted
+	MaxCachedProviders int `json:"max_cached_providers"`
+
+	// PreinitializeProviders creates every configured provider at startup instead of on first use
+	PreinitializeProviders bool `json:"preinitialize_providers"`
+
+	// MaxConcurrentRequests bounds in-flight LLM requests across all providers (0 = unlimited)
+	MaxConcurrentRequests int `json:"max_concurrent_requests"`
+
+	// MaxCostPerRequestUSD refuses LLM requests whose worst-case estimated cost, from the model's list
+	// price, exceeds this many US dollars (0 = unlimited). Models without a known price are not checked.
+	MaxCostPerRequestUSD float64 `json:"max_cost_per_request_usd"`
+
+	// RateLimits caps requests per second for each provider name; providers without an entry are unlimited
+	RateLimits map[string]float64 `json:"rate_limits,omitempty"`
+
+	// CircuitBreaker fails fast for a provider after repeated failures
+	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+
+	// RedactSecrets scrubs likely secrets from prompts before they are sent.
+	// When unset it defaults to on for every provider except the local Ollama.
+	RedactSecrets *bool `json:"redact_secrets,omitempty"`
+
+	// DedupFindings merges issues repeated across chunks of a large diff (default: on)
+	DedupFindings *bool `json:"dedup_findings,omitempty"`
+
+	// LintCode runs deterministic checks on code sent to review_code and merges their findings
+	// with the model's (default: off)
+	LintCode *bool `json:"lint_code,omitempty"`
+
+	// SeverityWeights overrides the risk score points for some severities; the rest keep
+	// DefaultSeverityWeights
+	SeverityWeights map[string]float64 `json:"severity_weights,omitempty"`
+
+	// RetryEmptyResponses retries an empty LLM response once at a slightly higher temperature (default: on)
+	RetryEmptyResponses *bool `json:"retry_empty_responses,omitempty"`
+
+	// ModelOverrides pins request settings for specific models, taking precedence over provider rules
+	ModelOverrides map[string]ModelOverride `json:"model_overrides,omitempty"`
+
+	// TaskTokenOverrides sets the output token budget for an analysis task in place of the
+	// size-based one; it is still clamped to provider and model limits
+	TaskTokenOverrides map[AnalysisTask]int `json:"task_token_overrides,omitempty"`
+
+	// ModelAliases maps short names like "fast" to model IDs so the model argument can use them.
+	// A "provider:alias" key applies only to that provider and wins over a plain "alias" key.
+	ModelAliases map[string]string `json:"model_aliases,omitempty"`
+
+	// StrictEnv makes ${VAR} references to unset environment variables in the JSON config an error
+	// instead of expanding them to an empty string
+	StrictEnv bool `json:"strict_env"`
+
+	// TLSCACertFile is a PEM file of extra CA certificates to trust, for endpoints behind a private CA
+	TLSCACertFile string `json:"tls_ca_cert_file"`
+	// TLSInsecureSkipVerify disables TLS certificate verification; it is logged as insecure
+	TLSInsecureSkipVerify bool `json:"tls_insecure_skip_verify"`
+	// ProviderTLS overrides the TLS settings for individual provider names
+	ProviderTLS map[string]TLSConfig `json:"provider_tls,omitempty"`
+	// ProxyURL is the proxy provider requests go through; empty uses HTTP_PROXY, HTTPS_PROXY, and NO_PROXY
+	ProxyURL string `json:"proxy_url"`
+	// CompressRequests gzips large request bodies sent to providers that accept compressed requests
+	CompressRequests bool `json:"compress_requests"`
+	// CompressMinBytes is the smallest request body CompressRequests compresses
+	CompressMinBytes int `json:"compress_min_bytes"`
+
+	// OutputLanguage is the default language reviews are written in, as a code ("es") or
+	// English name ("Spanish"); empty leaves it to the model
+	OutputLanguage string `json:"output_language"`
+	// Persona is the default review persona, one of Personas; empty uses the neutral reviewer voice
+	Persona string `json:"persona"`
+
+	// DebugLogFile is a file that provider request and response bodies are appended to, with secrets
+	// redacted; it is only written when LogLevel is debug
+	DebugLogFile string `json:"debug_log_file"`
+
+	// PromptDir holds "<analysis type>.tmpl" text/template files that replace the built-in prompts
+	PromptDir string `json:"prompt_dir"`
+
+	// ConfigType describes where the configuration came from: "environment", or the files read,
+	// highest precedence first (e.g. "/home/me/.second-opinion.json over /etc/second-opinion/config.json")
+	ConfigType string `json:"-"`
 }
 
+// TLSConfig overrides TLS settings for one provider; unset fields fall back to the global settings
+type TLSConfig struct {
+	CACertFile         string `json:"ca_cert_file"`
+	InsecureSkipVerify *bool  `json:"insecure_skip_verify,omitempty"`
+}
+
+// ModelOverride holds explicit per-model request settings. A zero MaxTokens or nil Temperature
+// leaves the computed setting in place; an explicit temperature of 0 is applied.
+type ModelOverride struct {
+	MaxTokens   int      `json:"max_tokens"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+// Config file locations checked by Load
+const (
+	// ConfigPathEnv names an explicit config file, which is then the only file read
+	ConfigPathEnv = "SECOND_OPINION_CONFIG"
+	// homeConfigName is the per-user config file in the home directory
+	homeConfigName = ".second-opinion.json"
+)
+
+// systemConfigPath is the system-wide config file that per-user files override
+var systemConfigPath = "/etc/second-opinion/config.json"
+
+// Load reads the configuration from, in order of precedence: the file named by
+// SECOND_OPINION_CONFIG, a user file ($XDG_CONFIG_HOME/second-opinion/config.json, else
+// ~/.second-opinion.json) merged over the system file /etc/second-opinion/config.json, and
+// finally environment variables when no file exists.
 func Load() (*Config, error) {
-	conf, err := loadFromHome()
-	if err == nil {
-		conf.ConfigType = ".second-opinion.json"
-		return conf, nil
+	paths, err := configFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return loadEnv()
 	}
-	return loadEnv()
+	return loadFiles(paths...)
 }
 
-func loadFromHome() (*Config, error) {
+// configFiles returns the config files to read, lowest precedence first
+func configFiles() ([]string, error) {
+	if explicit := os.Getenv(ConfigPathEnv); explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return nil, fmt.Errorf("%s: %w", ConfigPathEnv, err)
+		}
+		return []string{explicit}, nil
+	}
+
+	var paths []string
+	if isFile(systemConfigPath) {
+		paths = append(paths, systemConfigPath)
+	}
+	for _, user := range userConfigPaths() {
+		if isFile(user) {
+			paths = append(paths, user)
+			break
+		}
+	}
+	return paths, nil
+}
+
+// userConfigPaths lists the per-user config file locations, preferred first
+func userConfigPaths() []string {
+	var paths []string
+	configHome := os.Getenv("XDG_CONFIG_HOME")
 	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, err
+	if configHome == "" && err == nil {
+		configHome = filepath.Join(homeDir, ".config")
 	}
-	fPath := filepath.Join(homeDir, ".second-opinion.json")
-	f, err := os.Open(fPath)
-	if err != nil {
-		return nil, err
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "second-opinion", "config.json"))
 	}
-	defer f.Close()
+	if err == nil {
+		paths = append(paths, filepath.Join(homeDir, homeConfigName))
+	}
+	return paths
+}
+
+// isFile reports whether path exists and is a regular file
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// loadFiles decodes each file over the previous ones, so later files override the keys they set
+// and keep the rest, then fills in defaults for anything still unset
+func loadFiles(paths ...string) (*Config, error) {
+	// Fields absent from every file keep these values; an explicit "temperature": 0 is kept as 0
+	conf := Config{Temperature: DefaultTemperature}
+	for _, path := range paths {
+		if err := decodeFile(path, &conf); err != nil {
+			return nil, err
+		}
+	}
+
+	// Highest precedence first
+	sources := slices.Clone(paths)
+	slices.Reverse(sources)
+	conf.ConfigType = strings.Join(sources, " over ")
 
-	conf := Config{ConfigType: ".second-opinion.json"}
-	err = json.NewDecoder(f).Decode(&conf)
+	// Let the files reference secrets such as ${OPENAI_API_KEY} instead of containing them
+	err := interpolateEnv(&conf, conf.StrictEnv)
+	if err == nil {
+		err = resolveAPIKeyCommands(&conf)
+	}
 
 	// Set memory defaults if not specified in JSON
 	if conf.Memory.MaxDiffSizeMB == 0 {
@@ -95,9 +442,56 @@ func loadFromHome() (*Config, error) {
 		conf.Memory.EnableStreaming = true
 	}
 
+	if conf.MaxCommitsPerRange == 0 {
+		conf.MaxCommitsPerRange = DefaultMaxCommitsPerRange
+	}
+
+	if conf.LogLevel == "" {
+		conf.LogLevel = "info"
+	}
+
+	if conf.ServerTransport == "" {
+		conf.ServerTransport = TransportStdio
+	}
+	if conf.ServerAddr == "" {
+		conf.ServerAddr = DefaultServerAddr
+	}
+
+	if conf.MaxTokens == 0 {
+		conf.MaxTokens = 4096
+	}
+
+	if conf.GitHub.APIURL == "" {
+		conf.GitHub.APIURL = DefaultGitHubAPIURL
+	}
+
+	if conf.MaxCachedProviders == 0 {
+		conf.MaxCachedProviders = DefaultMaxCachedProviders
+	}
+
+	if conf.CompressMinBytes == 0 {
+		conf.CompressMinBytes = DefaultCompressMinBytes
+	}
+
+	conf.CircuitBreaker.setDefaults()
+
 	return &conf, err
 }
 
+// decodeFile decodes the JSON config file at path into conf
+func decodeFile(path string, conf *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(conf); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
 // Load loads configuration from environment variables.
 func loadEnv() (*Config, error) {
 	// Load .env file if it exists
@@ -110,27 +504,73 @@ func loadEnv() (*Config, error) {
 		DefaultProvider: getEnv("DEFAULT_PROVIDER", "openai"),
 		ServerName:      getEnv("SERVER_NAME", "Second Opinion 🔍"),
 		ServerVersion:   getEnv("SERVER_VERSION", "1.0.0"),
+		ServerTransport: getEnv("SERVER_TRANSPORT", TransportStdio),
+		ServerAddr:      getEnv("SERVER_ADDR", DefaultServerAddr),
+		LogLevel:        getEnv("LOG_LEVEL", "info"),
+		ConfigType:      "environment",
 	}
 
 	// Load provider-specific configurations
 	cfg.OpenAI.APIKey = getEnv("OPENAI_API_KEY", "")
+	cfg.OpenAI.APIKeyCommand = getEnv("OPENAI_API_KEY_COMMAND", "")
 	cfg.OpenAI.Model = getEnv("OPENAI_MODEL", "gpt-4o-mini")
+	cfg.OpenAI.Organization = getEnv("OPENAI_ORGANIZATION", "")
+	cfg.OpenAI.Project = getEnv("OPENAI_PROJECT", "")
 
 	cfg.Google.APIKey = getEnv("GOOGLE_API_KEY", "")
+	cfg.Google.APIKeyCommand = getEnv("GOOGLE_API_KEY_COMMAND", "")
 	cfg.Google.Model = getEnv("GOOGLE_MODEL", "gemini-2.0-flash-exp")
+	if settings := getEnv("GOOGLE_SAFETY_SETTINGS", ""); settings != "" {
+		cfg.Google.SafetySettings = parseSafetySettings(settings)
+	}
+	if vertex := getEnv("GOOGLE_USE_VERTEX", ""); vertex != "" {
+		cfg.Google.UseVertex = vertex == "true" || vertex == "1"
+	}
+	cfg.Google.Project = getEnv("GOOGLE_CLOUD_PROJECT", "")
+	cfg.Google.Region = getEnv("GOOGLE_CLOUD_REGION", "")
 
 	cfg.Ollama.Endpoint = getEnv("OLLAMA_ENDPOINT", "http://localhost:11434")
 	cfg.Ollama.Model = getEnv("OLLAMA_MODEL", "devstral:latest")
+	cfg.Ollama.KeepAlive = getEnv("OLLAMA_KEEP_ALIVE", "")
+	if preload := getEnv("OLLAMA_PRELOAD", ""); preload != "" {
+		cfg.Ollama.Preload = preload == "true" || preload == "1"
+	}
+	if verify := getEnv("OLLAMA_VERIFY_ON_STARTUP", ""); verify != "" {
+		cfg.Ollama.VerifyOnStartup = verify == "true" || verify == "1"
+	}
+	if options := getEnv("OLLAMA_OPTIONS", ""); options != "" {
+		cfg.Ollama.Options = parseOllamaOptions(options)
+	}
 
 	cfg.Mistral.APIKey = getEnv("MISTRAL_API_KEY", "")
+	cfg.Mistral.APIKeyCommand = getEnv("MISTRAL_API_KEY_COMMAND", "")
 	cfg.Mistral.Model = getEnv("MISTRAL_MODEL", "mistral-small-latest")
+	if safePrompt := getEnv("MISTRAL_SAFE_PROMPT", ""); safePrompt != "" {
+		cfg.Mistral.SafePrompt = safePrompt == "true" || safePrompt == "1"
+	}
+
+	cfg.OpenRouter.APIKey = getEnv("OPENROUTER_API_KEY", "")
+	cfg.OpenRouter.APIKeyCommand = getEnv("OPENROUTER_API_KEY_COMMAND", "")
+	cfg.OpenRouter.Model = getEnv("OPENROUTER_MODEL", "openai/gpt-4o-mini")
+	if models := getEnv("OPENROUTER_MODELS", ""); models != "" {
+		for _, model := range strings.Split(models, ",") {
+			if model = strings.TrimSpace(model); model != "" {
+				cfg.OpenRouter.Models = append(cfg.OpenRouter.Models, model)
+			}
+		}
+	}
+	cfg.OpenRouter.Referer = getEnv("OPENROUTER_REFERER", "")
+	cfg.OpenRouter.Title = getEnv("OPENROUTER_TITLE", "")
+
+	cfg.Perplexity.APIKey = getEnv("PERPLEXITY_API_KEY", "")
+	cfg.Perplexity.APIKeyCommand = getEnv("PERPLEXITY_API_KEY_COMMAND", "")
+	cfg.Perplexity.Model = getEnv("PERPLEXITY_MODEL", "sonar")
 
 	// Parse temperature
-	if temp := getEnv("LLM_TEMPERATURE", "0.3"); temp != "" {
+	cfg.Temperature = DefaultTemperature
+	if temp := getEnv("LLM_TEMPERATURE", ""); temp != "" {
 		if t, err := strconv.ParseFloat(temp, 64); err == nil {
 			cfg.Temperature = t
-		} else {
-			cfg.Temperature = 0.3
 		}
 	}
 
@@ -174,10 +614,474 @@ func loadEnv() (*Config, error) {
 			cfg.Memory.ChunkSizeMB = v
 		}
 	}
+	cfg.Memory.TruncationStrategy = getEnv("TRUNCATION_STRATEGY", "")
+
+	cfg.MaxCommitsPerRange = DefaultMaxCommitsPerRange
+	if maxCommits := getEnv("MAX_COMMITS_PER_RANGE", ""); maxCommits != "" {
+		if v, err := strconv.Atoi(maxCommits); err == nil {
+			cfg.MaxCommitsPerRange = v
+		}
+	}
+
+	if preinit := getEnv("PREINITIALIZE_PROVIDERS", ""); preinit != "" {
+		cfg.PreinitializeProviders = preinit == "true" || preinit == "1"
+	}
+
+	cfg.MaxCachedProviders = DefaultMaxCachedProviders
+	if maxCached := getEnv("MAX_CACHED_PROVIDERS", ""); maxCached != "" {
+		if v, err := strconv.Atoi(maxCached); err == nil {
+			cfg.MaxCachedProviders = v
+		}
+	}
+
+	if maxChars := getEnv("MAX_RESPONSE_CHARS", ""); maxChars != "" {
+		if v, err := strconv.Atoi(maxChars); err == nil {
+			cfg.MaxResponseChars = v
+		}
+	}
+
+	if maxConcurrent := getEnv("MAX_CONCURRENT_REQUESTS", ""); maxConcurrent != "" {
+		if v, err := strconv.Atoi(maxConcurrent); err == nil {
+			cfg.MaxConcurrentRequests = v
+		}
+	}
+
+	if maxCost := getEnv("MAX_COST_PER_REQUEST_USD", ""); maxCost != "" {
+		if v, err := strconv.ParseFloat(maxCost, 64); err == nil {
+			cfg.MaxCostPerRequestUSD = v
+		}
+	}
+
+	cfg.ServerAuthToken = getEnv("SERVER_AUTH_TOKEN", "")
+	cfg.MetricsAddr = getEnv("METRICS_ADDR", "")
+	if origins := getEnv("SERVER_ALLOWED_ORIGINS", ""); origins != "" {
+		cfg.ServerAllowedOrigins = strings.Split(origins, ",")
+	}
+
+	if roots := getEnv("ALLOWED_REPO_ROOTS", ""); roots != "" {
+		cfg.AllowedRepoRoots = filepath.SplitList(roots)
+	}
+
+	if rateLimits := getEnv("RATE_LIMITS", ""); rateLimits != "" {
+		cfg.RateLimits = parseRateLimits(rateLimits)
+	}
+
+	if weights := getEnv("SEVERITY_WEIGHTS", ""); weights != "" {
+		cfg.SeverityWeights = parseNamedFloats(weights)
+	}
+
+	if taskTokens := getEnv("TASK_TOKEN_OVERRIDES", ""); taskTokens != "" {
+		cfg.TaskTokenOverrides = make(map[AnalysisTask]int)
+		for task, tokens := range parseNamedFloats(taskTokens) {
+			cfg.TaskTokenOverrides[AnalysisTask(task)] = int(tokens)
+		}
+	}
+
+	if aliases := getEnv("MODEL_ALIASES", ""); aliases != "" {
+		cfg.ModelAliases = parseModelAliases(aliases)
+	}
+
+	for name, field := range map[string]*int{
+		"CIRCUIT_BREAKER_THRESHOLD":        &cfg.CircuitBreaker.FailureThreshold,
+		"CIRCUIT_BREAKER_WINDOW_SECONDS":   &cfg.CircuitBreaker.WindowSeconds,
+		"CIRCUIT_BREAKER_COOLDOWN_SECONDS": &cfg.CircuitBreaker.CooldownSeconds,
+	} {
+		if value := getEnv(name, ""); value != "" {
+			if v, err := strconv.Atoi(value); err == nil {
+				*field = v
+			}
+		}
+	}
+	cfg.CircuitBreaker.setDefaults()
+
+	if redact := getEnv("REDACT_SECRETS", ""); redact != "" {
+		enabled := redact == "true" || redact == "1"
+		cfg.RedactSecrets = &enabled
+	}
+
+	if dedup := getEnv("DEDUP_FINDINGS", ""); dedup != "" {
+		enabled := dedup == "true" || dedup == "1"
+		cfg.DedupFindings = &enabled
+	}
+
+	if lint := getEnv("LINT_CODE", ""); lint != "" {
+		enabled := lint == "true" || lint == "1"
+		cfg.LintCode = &enabled
+	}
+
+	if retry := getEnv("RETRY_EMPTY_RESPONSES", ""); retry != "" {
+		enabled := retry == "true" || retry == "1"
+		cfg.RetryEmptyResponses = &enabled
+	}
+
+	cfg.TLSCACertFile = getEnv("TLS_CA_CERT_FILE", "")
+	if insecure := getEnv("TLS_INSECURE_SKIP_VERIFY", ""); insecure != "" {
+		cfg.TLSInsecureSkipVerify = insecure == "true" || insecure == "1"
+	}
+
+	cfg.ProxyURL = getEnv("PROXY_URL", "")
+
+	if compress := getEnv("COMPRESS_REQUESTS", ""); compress != "" {
+		cfg.CompressRequests = compress == "true" || compress == "1"
+	}
+	cfg.CompressMinBytes = DefaultCompressMinBytes
+	if minBytes := getEnv("COMPRESS_MIN_BYTES", ""); minBytes != "" {
+		if v, err := strconv.Atoi(minBytes); err == nil {
+			cfg.CompressMinBytes = v
+		}
+	}
+
+	cfg.GitHub.Token = getEnv("GITHUB_TOKEN", "")
+	cfg.GitHub.APIURL = getEnv("GITHUB_API_URL", DefaultGitHubAPIURL)
+
+	cfg.OutputLanguage = getEnv("OUTPUT_LANGUAGE", "")
+	cfg.Persona = getEnv("REVIEW_PERSONA", "")
+	cfg.DebugLogFile = getEnv("DEBUG_LOG_FILE", "")
+	cfg.PromptDir = getEnv("PROMPT_DIR", "")
+
+	if err := resolveAPIKeyCommands(cfg); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
 
+// Validate checks that the configuration is usable, returning every problem found at once
+func (c *Config) Validate() error {
+	var problems []error
+
+	switch c.DefaultProvider {
+	case "openai":
+		if c.OpenAI.APIKey == "" {
+			problems = append(problems, errors.New("default provider is openai but no API key is set (set openai.api_key or OPENAI_API_KEY)"))
+		}
+	case "google":
+		if c.Google.UseVertex && c.Google.Project == "" {
+			problems = append(problems, errors.New("default provider is google with Vertex AI but no project is set (set google.project or GOOGLE_CLOUD_PROJECT)"))
+		} else if !c.Google.UseVertex && c.Google.APIKey == "" {
+			problems = append(problems, errors.New("default provider is google but no API key is set (set google.api_key or GOOGLE_API_KEY)"))
+		}
+	case "mistral":
+		if c.Mistral.APIKey == "" {
+			problems = append(problems, errors.New("default provider is mistral but no API key is set (set mistral.api_key or MISTRAL_API_KEY)"))
+		}
+	case "ollama":
+		if c.Ollama.Endpoint == "" {
+			problems = append(problems, errors.New("default provider is ollama but no endpoint is set (set ollama.endpoint or OLLAMA_ENDPOINT)"))
+		}
+	case "openrouter":
+		if c.OpenRouter.APIKey == "" {
+			problems = append(problems, errors.New("default provider is openrouter but no API key is set (set openrouter.api_key or OPENROUTER_API_KEY)"))
+		}
+	case "perplexity":
+		if c.Perplexity.APIKey == "" {
+			problems = append(problems, errors.New("default provider is perplexity but no API key is set (set perplexity.api_key or PERPLEXITY_API_KEY)"))
+		}
+	case "":
+		problems = append(problems, errors.New("no default provider is set (set default_provider or DEFAULT_PROVIDER)"))
+	default:
+		problems = append(problems, fmt.Errorf("unsupported default provider %q (use openai, google, ollama, mistral, openrouter, or perplexity)", c.DefaultProvider))
+	}
+
+	if c.Temperature < 0 || c.Temperature > 2 {
+		problems = append(problems, fmt.Errorf("temperature must be between 0 and 2, got %g", c.Temperature))
+	}
+	if c.MaxTokens <= 0 {
+		problems = append(problems, fmt.Errorf("max_tokens must be greater than 0, got %d", c.MaxTokens))
+	}
+
+	switch c.ServerTransport {
+	case "", TransportStdio:
+	case TransportHTTP:
+		if c.ServerAddr == "" {
+			problems = append(problems, errors.New("server_addr is required for the http transport (set server_addr or SERVER_ADDR)"))
+		} else if c.ServerAuthToken == "" && !IsLoopbackAddr(c.ServerAddr) {
+			// Every tool, including repository and file reads, would be open to the network
+			problems = append(problems, fmt.Errorf("server_addr %q accepts remote connections, so server_auth_token (SERVER_AUTH_TOKEN) is required", c.ServerAddr))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("unsupported server_transport %q (use stdio or http)", c.ServerTransport))
+	}
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			problems = append(problems, fmt.Errorf("metrics_addr must be a host:port listen address, got %q", c.MetricsAddr))
+		} else if c.ServerTransport == TransportHTTP && c.MetricsAddr == c.ServerAddr {
+			problems = append(problems, fmt.Errorf("metrics_addr must differ from server_addr, since metrics are served without authentication"))
+		}
+	}
+
+	if c.Memory.MaxDiffSizeMB <= 0 {
+		problems = append(problems, fmt.Errorf("memory.max_diff_size_mb must be greater than 0, got %d", c.Memory.MaxDiffSizeMB))
+	}
+	if c.Memory.MaxFileCount <= 0 {
+		problems = append(problems, fmt.Errorf("memory.max_file_count must be greater than 0, got %d", c.Memory.MaxFileCount))
+	}
+	if c.Memory.MaxLineLength <= 0 {
+		problems = append(problems, fmt.Errorf("memory.max_line_length must be greater than 0, got %d", c.Memory.MaxLineLength))
+	}
+	if c.Memory.ChunkSizeMB <= 0 {
+		problems = append(problems, fmt.Errorf("memory.chunk_size_mb must be greater than 0, got %d", c.Memory.ChunkSizeMB))
+	} else if c.Memory.MaxDiffSizeMB > 0 && c.Memory.ChunkSizeMB > c.Memory.MaxDiffSizeMB {
+		problems = append(problems, fmt.Errorf("memory.chunk_size_mb (%d) must not exceed memory.max_diff_size_mb (%d)", c.Memory.ChunkSizeMB, c.Memory.MaxDiffSizeMB))
+	}
+
+	if c.Memory.TruncationStrategy != "" && !slices.Contains(TruncationStrategies, c.Memory.TruncationStrategy) {
+		problems = append(problems, fmt.Errorf("unsupported memory.truncation_strategy %q (use %s)", c.Memory.TruncationStrategy, strings.Join(TruncationStrategies, ", ")))
+	}
+
+	for _, root := range c.AllowedRepoRoots {
+		if !filepath.IsAbs(root) {
+			problems = append(problems, fmt.Errorf("allowed_repo_roots entries must be absolute paths, got %q", root))
+		}
+	}
+
+	if c.MaxResponseChars < 0 {
+		problems = append(problems, fmt.Errorf("max_response_chars must not be negative, got %d", c.MaxResponseChars))
+	}
+
+	if c.CircuitBreaker.FailureThreshold > 0 {
+		if c.CircuitBreaker.WindowSeconds <= 0 {
+			problems = append(problems, fmt.Errorf("circuit_breaker.window_seconds must be greater than 0, got %d", c.CircuitBreaker.WindowSeconds))
+		}
+		if c.CircuitBreaker.CooldownSeconds <= 0 {
+			problems = append(problems, fmt.Errorf("circuit_breaker.cooldown_seconds must be greater than 0, got %d", c.CircuitBreaker.CooldownSeconds))
+		}
+	}
+
+	if c.MaxConcurrentRequests < 0 {
+		problems = append(problems, fmt.Errorf("max_concurrent_requests must not be negative, got %d", c.MaxConcurrentRequests))
+	}
+
+	if c.MaxCostPerRequestUSD < 0 {
+		problems = append(problems, fmt.Errorf("max_cost_per_request_usd must not be negative, got %g", c.MaxCostPerRequestUSD))
+	}
+
+	if c.Google.UseVertex && c.Google.Region != "" && !ValidVertexRegion(c.Google.Region) {
+		problems = append(problems, fmt.Errorf("google.region %q is not a Vertex AI region (use a name like us-central1, or global)", c.Google.Region))
+	}
+
+	for category, threshold := range c.Google.SafetySettings {
+		if !slices.Contains(GoogleHarmCategories, NormalizeHarmCategory(category)) {
+			problems = append(problems, fmt.Errorf("google.safety_settings has unknown harm category %q", category))
+		}
+		if !googleSafetyThresholds[strings.ToUpper(threshold)] {
+			problems = append(problems, fmt.Errorf("google.safety_settings.%s has unknown threshold %q (use BLOCK_NONE, BLOCK_ONLY_HIGH, BLOCK_MEDIUM_AND_ABOVE, BLOCK_LOW_AND_ABOVE, or OFF)", category, threshold))
+		}
+	}
+
+	for model, override := range c.ModelOverrides {
+		if override.MaxTokens < 0 {
+			problems = append(problems, fmt.Errorf("model_overrides.%s.max_tokens must not be negative, got %d", model, override.MaxTokens))
+		}
+		if t := override.Temperature; t != nil && (*t < 0 || *t > 2) {
+			problems = append(problems, fmt.Errorf("model_overrides.%s.temperature must be between 0 and 2, got %g", model, *t))
+		}
+	}
+
+	if _, err := ResolveOutputLanguage(c.OutputLanguage); err != nil {
+		problems = append(problems, fmt.Errorf("output_language: %w", err))
+	}
+	if _, err := NormalizePersona(c.Persona); err != nil {
+		problems = append(problems, fmt.Errorf("persona: %w", err))
+	}
+
+	if c.TLSCACertFile != "" {
+		if _, err := os.Stat(c.TLSCACertFile); err != nil {
+			problems = append(problems, fmt.Errorf("tls_ca_cert_file is not readable: %w", err))
+		}
+	}
+	if c.GitHub.APIURL != "" {
+		if u, err := url.Parse(c.GitHub.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("github.api_url must be an http or https URL, got %q", c.GitHub.APIURL))
+		}
+	}
+
+	if c.ProxyURL != "" {
+		if _, err := ParseProxyURL(c.ProxyURL); err != nil {
+			problems = append(problems, fmt.Errorf("proxy_url: %w", err))
+		}
+	}
+	if c.CompressMinBytes < 0 {
+		problems = append(problems, fmt.Errorf("compress_min_bytes must not be negative, got %d", c.CompressMinBytes))
+	}
+	for severity, weight := range c.SeverityWeights {
+		if _, ok := DefaultSeverityWeights[severity]; !ok {
+			problems = append(problems, fmt.Errorf("severity_weights has unknown severity %q (use critical, high, medium, low, or info)", severity))
+		} else if weight < 0 {
+			problems = append(problems, fmt.Errorf("severity_weights.%s must not be negative, got %g", severity, weight))
+		}
+	}
+	for task, tokens := range c.TaskTokenOverrides {
+		if !slices.Contains(AnalysisTasks, task) {
+			problems = append(problems, fmt.Errorf("task_token_overrides has unknown task %q", task))
+		} else if tokens <= 0 {
+			problems = append(problems, fmt.Errorf("task_token_overrides.%s must be positive, got %d", task, tokens))
+		}
+	}
+	for provider, tls := range c.ProviderTLS {
+		if tls.CACertFile != "" {
+			if _, err := os.Stat(tls.CACertFile); err != nil {
+				problems = append(problems, fmt.Errorf("provider_tls.%s.ca_cert_file is not readable: %w", provider, err))
+			}
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// parseRateLimits parses a list like "openai=2,mistral=0.5" into requests per second by provider.
+// Malformed entries are skipped.
+func parseRateLimits(value string) map[string]float64 {
+	return parseNamedFloats(value)
+}
+
+// parseNamedFloats parses a list like "critical=20,info=0.5" into numbers by name, such as
+// severity weights or rate limits by provider. Malformed entries are skipped.
+func parseNamedFloats(value string) map[string]float64 {
+	limits := make(map[string]float64)
+	for _, entry := range strings.Split(value, ",") {
+		provider, rate, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		rps, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil {
+			continue
+		}
+		limits[strings.TrimSpace(provider)] = rps
+	}
+	return limits
+}
+
+// parseModelAliases parses a list like "fast=gpt-4o-mini,ollama:fast=llama3.2" into model aliases.
+// Malformed entries are skipped.
+func parseModelAliases(value string) map[string]string {
+	aliases := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		alias, model, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		alias, model = strings.TrimSpace(alias), strings.TrimSpace(model)
+		if !ok || alias == "" || model == "" {
+			continue
+		}
+		aliases[alias] = model
+	}
+	return aliases
+}
+
+// parseOllamaOptions parses a list like "top_k=30,top_p=0.7" into Ollama sampling options.
+// Malformed entries are skipped.
+func parseOllamaOptions(value string) map[string]any {
+	options := make(map[string]any)
+	for _, entry := range strings.Split(value, ",") {
+		name, raw, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			continue
+		}
+		options[strings.TrimSpace(name)] = v
+	}
+	return options
+}
+
+// vertexRegionPattern matches Vertex AI region names such as us-central1 and europe-west4
+var vertexRegionPattern = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+$`)
+
+// ValidVertexRegion reports whether region is a Vertex AI region name or "global".
+// The region becomes part of the API host name, so nothing else is accepted.
+func ValidVertexRegion(region string) bool {
+	return region == "global" || vertexRegionPattern.MatchString(region)
+}
+
+// GoogleHarmCategories are the Gemini harm categories safety settings apply to
+var GoogleHarmCategories = []string{
+	"HARM_CATEGORY_HARASSMENT",
+	"HARM_CATEGORY_HATE_SPEECH",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"HARM_CATEGORY_DANGEROUS_CONTENT",
+}
+
+// googleSafetyThresholds are the block thresholds Gemini accepts
+var googleSafetyThresholds = map[string]bool{
+	"BLOCK_NONE":             true,
+	"BLOCK_ONLY_HIGH":        true,
+	"BLOCK_MEDIUM_AND_ABOVE": true,
+	"BLOCK_LOW_AND_ABOVE":    true,
+	"OFF":                    true,
+}
+
+// NormalizeHarmCategory maps a category such as "dangerous_content" to its API name
+func NormalizeHarmCategory(category string) string {
+	name := strings.ToUpper(strings.TrimSpace(category))
+	if !strings.HasPrefix(name, "HARM_CATEGORY_") {
+		name = "HARM_CATEGORY_" + name
+	}
+	return name
+}
+
+// parseSafetySettings parses a list like "dangerous_content=BLOCK_NONE,harassment=BLOCK_ONLY_HIGH".
+// Malformed entries are skipped.
+func parseSafetySettings(value string) map[string]string {
+	settings := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		category, threshold, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		settings[strings.TrimSpace(category)] = strings.ToUpper(strings.TrimSpace(threshold))
+	}
+	return settings
+}
+
+// ResolveModelAlias returns the model ID an alias stands for with the given provider, preferring
+// a "provider:alias" entry over a plain one. Names that are not aliases are returned unchanged.
+func (c *Config) ResolveModelAlias(provider, model string) string {
+	if model == "" {
+		return model
+	}
+	if resolved, ok := c.ModelAliases[provider+":"+model]; ok {
+		return resolved
+	}
+	if resolved, ok := c.ModelAliases[model]; ok {
+		return resolved
+	}
+	return model
+}
+
+// TLSSettings returns the CA file and verification setting for a provider, applying any
+// provider_tls override on top of the global settings
+func (c *Config) TLSSettings(provider string) (caCertFile string, insecureSkipVerify bool) {
+	caCertFile, insecureSkipVerify = c.TLSCACertFile, c.TLSInsecureSkipVerify
+	if override, ok := c.ProviderTLS[provider]; ok {
+		if override.CACertFile != "" {
+			caCertFile = override.CACertFile
+		}
+		if override.InsecureSkipVerify != nil {
+			insecureSkipVerify = *override.InsecureSkipVerify
+		}
+	}
+	return caCertFile, insecureSkipVerify
+}
+
+// ParseProxyURL parses a proxy URL, which must name an http, https, or socks5 proxy host
+func ParseProxyURL(proxyURL string) (*url.URL, error) {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy URL %q must use http, https, or socks5", parsed.Redacted())
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", parsed.Redacted())
+	}
+	return parsed, nil
+}
+
 // GetProviderConfig returns the configuration for a specific provider.
 func (c *Config) GetProviderConfig(provider string) (apiKey, model, endpoint string) {
 	switch provider {
@@ -189,6 +1093,10 @@ func (c *Config) GetProviderConfig(provider string) (apiKey, model, endpoint str
 		return "", c.Ollama.Model, c.Ollama.Endpoint
 	case "mistral":
 		return c.Mistral.APIKey, c.Mistral.Model, ""
+	case "openrouter":
+		return c.OpenRouter.APIKey, c.OpenRouter.Model, ""
+	case "perplexity":
+		return c.Perplexity.APIKey, c.Perplexity.Model, ""
 	default:
 		// Return config for default provider if different from requested
 		if provider != c.DefaultProvider && c.DefaultProvider != "" {
@@ -199,6 +1107,71 @@ func (c *Config) GetProviderConfig(provider string) (apiKey, model, endpoint str
 	}
 }
 
+// Providers lists the supported provider names
+var Providers = []string{"openai", "google", "ollama", "mistral", "openrouter", "perplexity"}
+
+// NormalizeProviderName trims and lowercases a provider name, returning an error naming the
+// supported providers if it is not one of them
+func NormalizeProviderName(name string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if !slices.Contains(Providers, normalized) {
+		return "", fmt.Errorf("unknown provider '%s'; valid: %s", strings.TrimSpace(name), strings.Join(Providers, ", "))
+	}
+	return normalized, nil
+}
+
+// GetLogLevel returns the configured log level, defaulting to info for unknown values
+func (c *Config) GetLogLevel() slog.Level {
+	switch strings.ToLower(c.LogLevel) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ShouldRedactSecrets reports whether prompts for the given provider should be scrubbed of secrets
+func (c *Config) ShouldRedactSecrets(provider string) bool {
+	if c.RedactSecrets != nil {
+		return *c.RedactSecrets
+	}
+	// Ollama runs locally, so prompts never leave the machine
+	return provider != "ollama"
+}
+
+// ShouldDedupFindings reports whether findings repeated across chunks should be merged
+func (c *Config) ShouldDedupFindings() bool {
+	if c.DedupFindings != nil {
+		return *c.DedupFindings
+	}
+	return true
+}
+
+// SeverityWeight returns the risk score points for a finding of severity
+func (c *Config) SeverityWeight(severity string) float64 {
+	if weight, ok := c.SeverityWeights[severity]; ok {
+		return weight
+	}
+	return DefaultSeverityWeights[severity]
+}
+
+// ShouldLintCode reports whether review_code runs the deterministic lint pre-pass
+func (c *Config) ShouldLintCode() bool {
+	return c.LintCode != nil && *c.LintCode
+}
+
+// ShouldRetryEmptyResponses reports whether an empty LLM response should be retried once
+func (c *Config) ShouldRetryEmptyResponses() bool {
+	if c.RetryEmptyResponses != nil {
+		return *c.RetryEmptyResponses
+	}
+	return true
+}
+
 // AnalysisTask defines the type of analysis being performed
 type AnalysisTask string
 
@@ -211,8 +1184,43 @@ const (
 	TaskGeneral            AnalysisTask = "general"
 )
 
-// GetOptimalTokensForDiff returns optimal token count based on diff size
-func (c *Config) GetOptimalTokensForDiff(diffSizeBytes int) int {
+// AnalysisTasks lists every analysis task
+var AnalysisTasks = []AnalysisTask{TaskCodeReview, TaskSecurityReview, TaskCommitAnalysis, TaskArchitectureReview, TaskDiffAnalysis, TaskGeneral}
+
+// promptOverheadTokens approximates the instructions and system prompt sent around a diff
+const promptOverheadTokens = 1024
+
+// minOutputTokens is the smallest output budget requested. An input too large to leave even
+// this much room is reported by the context window check rather than sent with no room to answer.
+const minOutputTokens = 1024
+
+// GetOptimalTokensForDiff returns the output token budget for a diff sent to model. The budget
+// grows with the diff size but is clamped to the model's output limit and to the room its context
+// window leaves after the estimated input; models missing from the tables are not clamped.
+func (c *Config) GetOptimalTokensForDiff(model string, diffSizeBytes int) int {
+	return clampTokensForModel(model, diffSizeBytes, desiredTokensForDiff(diffSizeBytes))
+}
+
+// clampTokensForModel limits an output budget to model's output limit and to the room its context
+// window leaves after a diff of diffSizeBytes. The minOutputTokens floor applies only to that room,
+// so an explicitly lower budget is never raised.
+func clampTokensForModel(model string, diffSizeBytes, tokens int) int {
+	if limit := ModelMaxOutputTokens(model); limit > 0 {
+		tokens = min(tokens, limit)
+	}
+
+	window := ModelContextWindow(model)
+	if window == 0 {
+		return tokens
+	}
+	// Same ~4 bytes per token estimate as EstimateTokensForText
+	available := window - diffSizeBytes/4 - promptOverheadTokens
+	return min(tokens, max(available, minOutputTokens))
+}
+
+// desiredTokensForDiff returns the output budget a diff of this size warrants, before any
+// model limits are applied
+func desiredTokensForDiff(diffSizeBytes int) int {
 	// Convert bytes to KB for easier thresholds
 	diffSizeKB := diffSizeBytes / 1024
 
@@ -250,9 +1258,15 @@ func (c *Config) GetOptimalTemperatureForTask(task AnalysisTask) float64 {
 	}
 }
 
-// GetProviderOptimizedConfig returns provider-specific optimized configuration
-func (c *Config) GetProviderOptimizedConfig(provider string, diffSize int, task AnalysisTask) (maxTokens int, temperature float64, providerConfig map[string]any) {
-	baseTokens := c.GetOptimalTokensForDiff(diffSize)
+// GetProviderOptimizedConfig returns provider-specific optimized configuration.
+// A TaskTokenOverrides entry for task replaces the size-based token budget before provider
+// and model limits apply. Any ModelOverrides entry for model is applied last so explicit
+// per-model settings win.
+func (c *Config) GetProviderOptimizedConfig(provider, model string, diffSize int, task AnalysisTask) (maxTokens int, temperature float64, providerConfig map[string]any) {
+	baseTokens := c.GetOptimalTokensForDiff(model, diffSize)
+	if tokens := c.TaskTokenOverrides[task]; tokens > 0 {
+		baseTokens = clampTokensForModel(model, diffSize, tokens)
+	}
 	baseTemp := c.GetOptimalTemperatureForTask(task)
 
 	// Provider-specific adjustments
@@ -301,13 +1315,54 @@ func (c *Config) GetProviderOptimizedConfig(provider string, diffSize int, task
 		providerConfig = map[string]any{}
 	}
 
+	if override, ok := c.ModelOverrides[model]; ok {
+		if override.MaxTokens > 0 {
+			maxTokens = override.MaxTokens
+			// Keep provider-native token settings in step with the override
+			for _, key := range []string{"max_tokens", "num_predict"} {
+				if _, ok := providerConfig[key]; ok {
+					providerConfig[key] = maxTokens
+				}
+			}
+		}
+		if override.Temperature != nil && !HasFixedTemperature(provider, model) {
+			temperature = *override.Temperature
+		}
+	}
+
 	return maxTokens, temperature, providerConfig
 }
 
+// HasFixedTemperature reports whether a model rejects custom temperatures (OpenAI o3/o4 series)
+func HasFixedTemperature(provider, model string) bool {
+	if provider != "openai" {
+		return false
+	}
+	modelLower := strings.ToLower(model)
+	return strings.Contains(modelLower, "o3") || strings.Contains(modelLower, "o4")
+}
+
+// MinChunkableDiffBytes is the size below which a diff is never chunked, however many files it
+// appears to touch; splitting a prompt this small only multiplies requests
+const MinChunkableDiffBytes = 16 * 1024
+
 // ShouldChunkDiff determines if a diff should be chunked based on size and complexity
 func (c *Config) ShouldChunkDiff(diffSizeBytes int, fileCount int) (shouldChunk bool, chunkSizeBytes int) {
 	maxSizeBytes := c.Memory.MaxDiffSizeMB * 1024 * 1024
 
+	// Calculate optimal chunk size
+	chunkSizeBytes = c.Memory.ChunkSizeMB * 1024 * 1024
+
+	// Adjust chunk size based on file count
+	if fileCount > 100 {
+		// Smaller chunks for repos with many files
+		chunkSizeBytes = chunkSizeBytes / 2
+	}
+
+	if diffSizeBytes < MinChunkableDiffBytes {
+		return false, chunkSizeBytes
+	}
+
 	// Check size threshold
 	if diffSizeBytes > maxSizeBytes {
 		shouldChunk = true
@@ -318,15 +1373,6 @@ func (c *Config) ShouldChunkDiff(diffSizeBytes int, fileCount int) (shouldChunk
 		shouldChunk = true
 	}
 
-	// Calculate optimal chunk size
-	chunkSizeBytes = c.Memory.ChunkSizeMB * 1024 * 1024
-
-	// Adjust chunk size based on file count
-	if fileCount > 100 {
-		// Smaller chunks for repos with many files
-		chunkSizeBytes = chunkSizeBytes / 2
-	}
-
 	return shouldChunk, chunkSizeBytes
 }
 
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// UnresolvedEnvError reports environment variables referenced by the JSON config that are not set
+type UnresolvedEnvError struct {
+	Names []string
+}
+
+func (e *UnresolvedEnvError) Error() string {
+	return fmt.Sprintf("config references unset environment variables: %s", strings.Join(e.Names, ", "))
+}
+
+// interpolateEnv expands ${VAR} and $VAR references in every string field of conf, including
+// strings inside slices and map values; "$$" yields a literal "$". Fields tagged interpolate:"-",
+// such as shell commands that do their own expansion, are left as written. Unset variables expand to ""
+// unless strict is true, in which case they are left in place and reported as an *UnresolvedEnvError.
+func interpolateEnv(conf *Config, strict bool) error {
+	var missing []string
+	expand := func(s string) string {
+		if !strings.Contains(s, "$") {
+			return s
+		}
+		return os.Expand(s, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				if !slices.Contains(missing, name) {
+					missing = append(missing, name)
+				}
+				if strict {
+					return "${" + name + "}"
+				}
+			}
+			return value
+		})
+	}
+
+	interpolateValue(reflect.ValueOf(conf).Elem(), expand)
+
+	if strict && len(missing) > 0 {
+		return &UnresolvedEnvError{Names: missing}
+	}
+	return nil
+}
+
+// interpolateValue applies expand to the strings reachable from v, leaving other kinds untouched
+func interpolateValue(v reflect.Value, expand func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expand(v.String()))
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).Tag.Get("interpolate") == "-" {
+				continue
+			}
+			interpolateValue(v.Field(i), expand)
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			interpolateValue(v.Index(i), expand)
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			interpolateValue(v.Elem(), expand)
+		}
+	case reflect.Map:
+		// Map values are not addressable, so expand a copy and store it back
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			if value.Kind() == reflect.Interface {
+				s, ok := value.Interface().(string)
+				if !ok {
+					continue
+				}
+				value = reflect.ValueOf(expand(s))
+			} else {
+				interpolateValue(value, expand)
+			}
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("SO_TEST_KEY", "sk-test")
+	t.Setenv("SO_TEST_HOST", "ollama.internal")
+	t.Setenv("SO_TEST_ROOT", "/srv/repos")
+	t.Setenv("SO_TEST_THRESHOLD", "BLOCK_NONE")
+
+	conf := &Config{MaxTokens: 100, MaxCommitsPerRange: 7}
+	conf.OpenAI.APIKey = "${SO_TEST_KEY}"
+	conf.OpenAI.Organization = "pa$$word"
+	conf.Ollama.Endpoint = "http://$SO_TEST_HOST:11434"
+	conf.Ollama.Options = map[string]any{"stop": "${SO_TEST_HOST}", "top_k": 30.0}
+	conf.Google.SafetySettings = map[string]string{"dangerous_content": "${SO_TEST_THRESHOLD}"}
+	conf.AllowedRepoRoots = []string{"${SO_TEST_ROOT}/src", "/plain"}
+	conf.ModelAliases = map[string]string{"$SO_TEST_HOST": "gpt-4o"}
+	conf.Mistral.APIKeyCommand = `awk '{print $2}' "$HOME/.mistral"`
+
+	if err := interpolateEnv(conf, true); err != nil {
+		t.Fatalf("interpolateEnv() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"openai.api_key", conf.OpenAI.APIKey, "sk-test"},
+		{"openai.organization", conf.OpenAI.Organization, "pa$word"},
+		{"ollama.endpoint", conf.Ollama.Endpoint, "http://ollama.internal:11434"},
+		{"ollama.options.stop", conf.Ollama.Options["stop"].(string), "ollama.internal"},
+		{"google.safety_settings", conf.Google.SafetySettings["dangerous_content"], "BLOCK_NONE"},
+		{"allowed_repo_roots[0]", conf.AllowedRepoRoots[0], "/srv/repos/src"},
+		{"allowed_repo_roots[1]", conf.AllowedRepoRoots[1], "/plain"},
+		{"model_aliases keys are not expanded", conf.ModelAliases["$SO_TEST_HOST"], "gpt-4o"},
+		{"api_key_command is left for the shell", conf.Mistral.APIKeyCommand, `awk '{print $2}' "$HOME/.mistral"`},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Non-string fields are untouched
+	if conf.MaxTokens != 100 || conf.MaxCommitsPerRange != 7 || conf.Ollama.Options["top_k"] != 30.0 {
+		t.Errorf("non-string fields changed: max_tokens=%d max_commits=%d top_k=%v",
+			conf.MaxTokens, conf.MaxCommitsPerRange, conf.Ollama.Options["top_k"])
+	}
+}
+
+func TestInterpolateEnvMissing(t *testing.T) {
+	t.Run("lenient expands to empty", func(t *testing.T) {
+		conf := &Config{}
+		conf.OpenAI.APIKey = "${SO_TEST_UNSET}"
+		if err := interpolateEnv(conf, false); err != nil {
+			t.Fatalf("interpolateEnv() unexpected error: %v", err)
+		}
+		if conf.OpenAI.APIKey != "" {
+			t.Errorf("api_key = %q, want empty", conf.OpenAI.APIKey)
+		}
+	})
+
+	t.Run("strict reports each variable", func(t *testing.T) {
+		conf := &Config{}
+		conf.OpenAI.APIKey = "${SO_TEST_UNSET}"
+		conf.Google.APIKey = "$SO_TEST_ALSO_UNSET"
+		conf.Mistral.APIKey = "${SO_TEST_UNSET}"
+
+		err := interpolateEnv(conf, true)
+		var envErr *UnresolvedEnvError
+		if !errors.As(err, &envErr) {
+			t.Fatalf("expected *UnresolvedEnvError, got %v", err)
+		}
+		if len(envErr.Names) != 2 || envErr.Names[0] != "SO_TEST_UNSET" || envErr.Names[1] != "SO_TEST_ALSO_UNSET" {
+			t.Errorf("Names = %v, want [SO_TEST_UNSET SO_TEST_ALSO_UNSET]", envErr.Names)
+		}
+		if conf.OpenAI.APIKey != "${SO_TEST_UNSET}" {
+			t.Errorf("unresolved reference should be left in place, got %q", conf.OpenAI.APIKey)
+		}
+	})
+}
+
+func TestLoadInterpolatesJSONConfig(t *testing.T) {
+	home := isolateConfigFiles(t)
+	t.Setenv("SO_TEST_KEY", "sk-from-env")
+
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(home, ".second-opinion.json"), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+
+	writeConfig(`{"default_provider": "openai", "openai": {"api_key": "${SO_TEST_KEY}"}}`)
+	conf, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if conf.ConfigType != filepath.Join(home, ".second-opinion.json") || conf.OpenAI.APIKey != "sk-from-env" {
+		t.Errorf("config type %q, api_key %q; want the JSON config with the interpolated key", conf.ConfigType, conf.OpenAI.APIKey)
+	}
+
+	writeConfig(`{"strict_env": true, "openai": {"api_key": "${SO_TEST_UNSET}"}}`)
+	if _, err := Load(); err == nil {
+		t.Error("expected strict mode to fail on an unset variable rather than fall back")
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// outputLanguages maps the supported review output language codes to their English names
+var outputLanguages = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"hi": "Hindi",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"sv": "Swedish",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// OutputLanguageCodes returns the supported output language codes in sorted order
+func OutputLanguageCodes() []string {
+	codes := make([]string, 0, len(outputLanguages))
+	for code := range outputLanguages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ResolveOutputLanguage returns the English name of a supported output language given its code
+// or name in any case (e.g. "es", "ES", or "spanish" all give "Spanish"). An empty language
+// resolves to "", meaning the model's default.
+func ResolveOutputLanguage(language string) (string, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		return "", nil
+	}
+	if name, ok := outputLanguages[language]; ok {
+		return name, nil
+	}
+	for _, name := range outputLanguages {
+		if strings.ToLower(name) == language {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported output language %q (use one of %s, or the language's English name)", language, strings.Join(OutputLanguageCodes(), ", "))
+}
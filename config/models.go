@@ -0,0 +1,170 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// modelContextWindows maps model name prefixes to their total context window in tokens
+var modelContextWindows = map[string]int{
+	// OpenAI
+	"gpt-4o":        128000,
+	"gpt-4o-mini":   128000,
+	"gpt-4-turbo":   128000,
+	"gpt-4.1":       1047576,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"o1":            200000,
+	"o1-mini":       128000,
+	"o3":            200000,
+	"o4-mini":       200000,
+
+	// Google
+	"gemini-1.5-pro":   2097152,
+	"gemini-1.5-flash": 1048576,
+	"gemini-2.0-flash": 1048576,
+	"gemini-2.5":       1048576,
+
+	// Mistral
+	"mistral-small":  32000,
+	"mistral-medium": 128000,
+	"mistral-large":  128000,
+	"codestral":      256000,
+	"open-mistral":   128000,
+
+	// Perplexity
+	"sonar":     128000,
+	"sonar-pro": 200000,
+
+	// Common Ollama models
+	"devstral":  128000,
+	"llama3.1":  128000,
+	"llama3.2":  128000,
+	"codellama": 16384,
+	"qwen2.5":   32768,
+}
+
+// modelMaxOutputTokens maps model name prefixes to the most output tokens a response may use
+var modelMaxOutputTokens = map[string]int{
+	// OpenAI
+	"gpt-4o":        16384,
+	"gpt-4-turbo":   4096,
+	"gpt-4.1":       32768,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 4096,
+	"o1":            100000,
+	"o1-mini":       65536,
+	"o3":            100000,
+	"o4-mini":       100000,
+
+	// Google
+	"gemini-1.5":       8192,
+	"gemini-2.0-flash": 8192,
+	"gemini-2.5":       65536,
+
+	// Perplexity
+	"sonar-pro": 8000,
+}
+
+// ModelPrice is a model's list price in US dollars per million tokens
+type ModelPrice struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// modelPrices maps model name prefixes to their list prices. Local Ollama models are free and
+// have no entry; prices change, so these serve cost estimates rather than billing.
+var modelPrices = map[string]ModelPrice{
+	// OpenAI
+	"gpt-4o":        {InputPerMTok: 2.50, OutputPerMTok: 10},
+	"gpt-4o-mini":   {InputPerMTok: 0.15, OutputPerMTok: 0.60},
+	"gpt-4-turbo":   {InputPerMTok: 10, OutputPerMTok: 30},
+	"gpt-4.1":       {InputPerMTok: 2, OutputPerMTok: 8},
+	"gpt-4.1-mini":  {InputPerMTok: 0.40, OutputPerMTok: 1.60},
+	"gpt-4.1-nano":  {InputPerMTok: 0.10, OutputPerMTok: 0.40},
+	"gpt-4":         {InputPerMTok: 30, OutputPerMTok: 60},
+	"gpt-3.5-turbo": {InputPerMTok: 0.50, OutputPerMTok: 1.50},
+	"o1":            {InputPerMTok: 15, OutputPerMTok: 60},
+	"o1-mini":       {InputPerMTok: 1.10, OutputPerMTok: 4.40},
+	"o3":            {InputPerMTok: 2, OutputPerMTok: 8},
+	"o3-mini":       {InputPerMTok: 1.10, OutputPerMTok: 4.40},
+	"o4-mini":       {InputPerMTok: 1.10, OutputPerMTok: 4.40},
+
+	// Google
+	"gemini-1.5-pro":   {InputPerMTok: 1.25, OutputPerMTok: 5},
+	"gemini-1.5-flash": {InputPerMTok: 0.075, OutputPerMTok: 0.30},
+	"gemini-2.0-flash": {InputPerMTok: 0.10, OutputPerMTok: 0.40},
+	"gemini-2.5-pro":   {InputPerMTok: 1.25, OutputPerMTok: 10},
+	"gemini-2.5-flash": {InputPerMTok: 0.30, OutputPerMTok: 2.50},
+
+	// Mistral
+	"mistral-small":  {InputPerMTok: 0.10, OutputPerMTok: 0.30},
+	"mistral-medium": {InputPerMTok: 0.40, OutputPerMTok: 2},
+	"mistral-large":  {InputPerMTok: 2, OutputPerMTok: 6},
+	"codestral":      {InputPerMTok: 0.30, OutputPerMTok: 0.90},
+	"open-mistral":   {InputPerMTok: 0.15, OutputPerMTok: 0.15},
+
+	// Perplexity
+	"sonar":     {InputPerMTok: 1, OutputPerMTok: 1},
+	"sonar-pro": {InputPerMTok: 3, OutputPerMTok: 15},
+}
+
+// prefixesByLength lists a table's keys longest first so the most specific prefix wins
+func prefixesByLength[T any](table map[string]T) []string {
+	prefixes := make([]string, 0, len(table))
+	for prefix := range table {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) > len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	return prefixes
+}
+
+var (
+	contextWindowPrefixes = prefixesByLength(modelContextWindows)
+	maxOutputPrefixes     = prefixesByLength(modelMaxOutputTokens)
+	pricePrefixes         = prefixesByLength(modelPrices)
+)
+
+// lookupModel returns the table entry for the longest prefix of model, or the zero value if none matches
+func lookupModel[T any](table map[string]T, prefixes []string, model string) T {
+	name := strings.ToLower(model)
+
+	// Strip routing prefixes like "openai/gpt-4o-mini"
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return table[prefix]
+		}
+	}
+	var zero T
+	return zero
+}
+
+// ModelContextWindow returns the total context window in tokens for a model, or 0 if unknown
+func ModelContextWindow(model string) int {
+	return lookupModel(modelContextWindows, contextWindowPrefixes, model)
+}
+
+// ModelMaxOutputTokens returns the most output tokens a model may generate, or 0 if unknown
+func ModelMaxOutputTokens(model string) int {
+	return lookupModel(modelMaxOutputTokens, maxOutputPrefixes, model)
+}
+
+// ModelPricing returns a model's list price, and false if it is unknown
+func ModelPricing(model string) (ModelPrice, bool) {
+	price := lookupModel(modelPrices, pricePrefixes, model)
+	return price, price != ModelPrice{}
+}
+
+// Cost returns the price in US dollars of inputTokens prompt tokens and outputTokens response tokens
+func (p ModelPrice) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.InputPerMTok + float64(outputTokens)*p.OutputPerMTok) / 1e6
+}
@@ -0,0 +1,59 @@
+package config
+
+import "testing"
+
+func TestModelContextWindow(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"gpt-4o-mini", 128000},
+		{"gpt-4o-2024-08-06", 128000},
+		{"gpt-4", 8192},
+		{"gpt-4-turbo-preview", 128000},
+		{"GPT-3.5-TURBO", 16385},
+		{"o3-mini", 200000},
+		{"mistral-small-latest", 32000},
+		{"gemini-2.0-flash-exp", 1048576},
+		{"devstral:latest", 128000},
+		{"openai/gpt-4o-mini", 128000},
+		{"unknown-model", 0},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := ModelContextWindow(tt.model); got != tt.want {
+				t.Errorf("ModelContextWindow(%q) = %d, want %d", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelPricing(t *testing.T) {
+	tests := []struct {
+		model  string
+		want   ModelPrice
+		wantOK bool
+	}{
+		{"gpt-4o-mini-2024-07-18", ModelPrice{InputPerMTok: 0.15, OutputPerMTok: 0.60}, true},
+		{"gpt-4o", ModelPrice{InputPerMTok: 2.50, OutputPerMTok: 10}, true},
+		{"openrouter/o1", ModelPrice{InputPerMTok: 15, OutputPerMTok: 60}, true},
+		{"sonar-pro", ModelPrice{InputPerMTok: 3, OutputPerMTok: 15}, true},
+		{"devstral:latest", ModelPrice{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, ok := ModelPricing(tt.model)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ModelPricing(%q) = %+v, %v; want %+v, %v", tt.model, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	price, _ := ModelPricing("gpt-4o")
+	if got := price.Cost(1_000_000, 100_000); got != 3.5 {
+		t.Errorf("Cost = %v, want 3.5", got)
+	}
+}
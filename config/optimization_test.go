@@ -53,7 +53,7 @@ func TestGetOptimalTokensForDiff(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tokens := cfg.GetOptimalTokensForDiff(tt.diffSizeKB)
+			tokens := cfg.GetOptimalTokensForDiff("", tt.diffSizeKB)
 
 			if tokens < tt.expectedMin || tokens > tt.expectedMax {
 				t.Errorf("GetOptimalTokensForDiff(%d) = %d, expected between %d and %d",
@@ -63,6 +63,40 @@ func TestGetOptimalTokensForDiff(t *testing.T) {
 	}
 }
 
+func TestGetOptimalTokensForDiffModelLimits(t *testing.T) {
+	cfg := &Config{}
+
+	tests := []struct {
+		name     string
+		model    string
+		diffSize int
+		expected int
+	}{
+		{"large window keeps the size-based budget", "gemini-2.5-pro", 1024 * 1024, 32768},
+		{"output limit caps a large window", "gemini-1.5-pro", 1024 * 1024, 8192},
+		{"routed model names are recognized", "openai/gpt-4o-mini", 300 * 1024, 16384},
+		{"small diff fits a small window", "gpt-4", 2 * 1024, 4096},
+		{"small window leaves room for the input", "gpt-4", 20 * 1024, 8192 - 5*1024 - 1024},
+		{"output limit caps a small window", "gpt-3.5-turbo", 30 * 1024, 4096},
+		{"input filling the window keeps a minimum budget", "gpt-4", 100 * 1024, 1024},
+		{"unknown model is not clamped", "my-custom-model", 1024 * 1024, 32768},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := cfg.GetOptimalTokensForDiff(tt.model, tt.diffSize)
+			if tokens != tt.expected {
+				t.Errorf("GetOptimalTokensForDiff(%q, %d) = %d, expected %d", tt.model, tt.diffSize, tokens, tt.expected)
+			}
+
+			// Whatever fits is never more than the window minus the input
+			if window := ModelContextWindow(tt.model); window > 0 && tokens > minOutputTokens && tokens+tt.diffSize/4 > window {
+				t.Errorf("budget %d plus input exceeds the %d token window", tokens, window)
+			}
+		})
+	}
+}
+
 func TestGetOptimalTemperatureForTask(t *testing.T) {
 	cfg := &Config{}
 
@@ -168,7 +202,7 @@ func TestGetProviderOptimizedConfig(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			maxTokens, temperature, providerConfig := cfg.GetProviderOptimizedConfig(
-				tt.provider, tt.diffSize, tt.task)
+				tt.provider, "", tt.diffSize, tt.task)
 
 			if maxTokens != tt.expectedTokens {
 				t.Errorf("GetProviderOptimizedConfig maxTokens = %d, expected %d",
@@ -217,6 +251,13 @@ func TestShouldChunkDiff(t *testing.T) {
 			expectedChunk:     true,
 			expectedChunkSize: 1024 * 1024, // 1MB
 		},
+		{
+			name:              "Tiny diff should not chunk despite many files",
+			diffSizeBytes:     4 * 1024, // 4KB
+			fileCount:         150,
+			expectedChunk:     false,
+			expectedChunkSize: 512 * 1024, // 512KB (halved)
+		},
 		{
 			name:              "Many files should chunk with smaller chunks",
 			diffSizeBytes:     2 * 1024 * 1024, // 2MB
@@ -329,3 +370,153 @@ func TestGetMemoryOptimizedConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestGetProviderOptimizedConfigModelOverrides(t *testing.T) {
+	zero, low, mid, high := 0.0, 0.5, 0.6, 0.7
+	cfg := &Config{
+		Memory: MemoryConfig{
+			MaxDiffSizeMB: 10,
+			MaxFileCount:  1000,
+		},
+		ModelOverrides: map[string]ModelOverride{
+			"o3-mini":     {MaxTokens: 12000, Temperature: &high},
+			"gpt-4o":      {Temperature: &mid},
+			"gpt-4-turbo": {Temperature: &zero},
+			"mistral-big": {MaxTokens: 2048},
+			"llama3.2":    {MaxTokens: 1024, Temperature: &low},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		provider       string
+		model          string
+		diffSize       int
+		task           AnalysisTask
+		expectedTokens int
+		expectedTemp   float64
+	}{
+		{
+			name:           "override tokens win over diff size",
+			provider:       "openai",
+			model:          "o3-mini",
+			diffSize:       200 * 1024,
+			task:           TaskCodeReview,
+			expectedTokens: 12000,
+			expectedTemp:   0.2, // o3 temperature is fixed, so the override is ignored
+		},
+		{
+			name:           "temperature-only override keeps computed tokens",
+			provider:       "openai",
+			model:          "gpt-4o",
+			diffSize:       1024,
+			task:           TaskCodeReview,
+			expectedTokens: 4096,
+			expectedTemp:   0.6,
+		},
+		{
+			name:           "override beats provider cap",
+			provider:       "mistral",
+			model:          "mistral-big",
+			diffSize:       30 * 1024,
+			task:           TaskDiffAnalysis,
+			expectedTokens: 2048,
+			expectedTemp:   0.25,
+		},
+		{
+			name:           "explicit zero temperature wins over the task default",
+			provider:       "openai",
+			model:          "gpt-4-turbo",
+			diffSize:       1024,
+			task:           TaskCodeReview,
+			expectedTokens: 4096,
+			expectedTemp:   0,
+		},
+		{
+			name:           "model without override uses provider rules",
+			provider:       "openai",
+			model:          "gpt-4o-mini",
+			diffSize:       1024,
+			task:           TaskCodeReview,
+			expectedTokens: 4096,
+			expectedTemp:   0.2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxTokens, temperature, _ := cfg.GetProviderOptimizedConfig(tt.provider, tt.model, tt.diffSize, tt.task)
+			if maxTokens != tt.expectedTokens {
+				t.Errorf("maxTokens = %d, expected %d", maxTokens, tt.expectedTokens)
+			}
+			if temperature != tt.expectedTemp {
+				t.Errorf("temperature = %f, expected %f", temperature, tt.expectedTemp)
+			}
+		})
+	}
+
+	t.Run("provider token settings follow the override", func(t *testing.T) {
+		_, _, providerConfig := cfg.GetProviderOptimizedConfig("ollama", "llama3.2", 1024, TaskCodeReview)
+		if providerConfig["num_predict"] != 1024 {
+			t.Errorf("num_predict = %v, expected 1024", providerConfig["num_predict"])
+		}
+		_, _, providerConfig = cfg.GetProviderOptimizedConfig("mistral", "mistral-big", 1024, TaskCodeReview)
+		if providerConfig["max_tokens"] != 2048 {
+			t.Errorf("max_tokens = %v, expected 2048", providerConfig["max_tokens"])
+		}
+	})
+}
+
+func TestGetProviderOptimizedConfigTaskTokenOverrides(t *testing.T) {
+	cfg := &Config{
+		TaskTokenOverrides: map[AnalysisTask]int{TaskSecurityReview: 16000, TaskCodeReview: 500},
+		ModelOverrides:     map[string]ModelOverride{"o3-mini": {MaxTokens: 12000}},
+	}
+
+	tests := []struct {
+		name           string
+		provider       string
+		model          string
+		diffSize       int
+		task           AnalysisTask
+		expectedTokens int
+	}{
+		{"override wins over diff size", "openai", "gpt-4o", 1024, TaskSecurityReview, 16000},
+		{"other tasks keep the size-based budget", "openai", "gpt-4o", 1024, TaskDiffAnalysis, 4096},
+		{"model output limit clamps the override", "openai", "gpt-4o-mini", 1024, TaskSecurityReview, 16000},
+		{"small output limit clamps the override", "openai", "gpt-3.5-turbo", 1024, TaskSecurityReview, 4096},
+		{"context window clamps the override", "openai", "gpt-4", 20 * 1024, TaskSecurityReview, 8192 - 5*1024 - 1024},
+		{"provider cap clamps the override", "google", "gemini-2.5-pro", 1024, TaskSecurityReview, 8192},
+		{"model overrides still win", "openai", "o3-mini", 1024, TaskSecurityReview, 12000},
+		{"override below the floor is kept on a known model", "openai", "gpt-4o-mini", 1024, TaskCodeReview, 500},
+		{"override below the floor is kept on an unknown model", "openai", "my-finetune", 1024, TaskCodeReview, 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxTokens, _, _ := cfg.GetProviderOptimizedConfig(tt.provider, tt.model, tt.diffSize, tt.task)
+			if maxTokens != tt.expectedTokens {
+				t.Errorf("maxTokens = %d, expected %d", maxTokens, tt.expectedTokens)
+			}
+		})
+	}
+}
+
+func TestHasFixedTemperature(t *testing.T) {
+	tests := []struct {
+		provider string
+		model    string
+		expected bool
+	}{
+		{"openai", "o3-mini", true},
+		{"openai", "o4-mini", true},
+		{"openai", "gpt-4o", false},
+		{"ollama", "o3-custom", false},
+	}
+
+	for _, tt := range tests {
+		if got := HasFixedTemperature(tt.provider, tt.model); got != tt.expected {
+			t.Errorf("HasFixedTemperature(%q, %q) = %v, expected %v", tt.provider, tt.model, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Personas lists the supported review personas, which set the tone of every review
+var Personas = []string{"security_auditor", "mentor", "ci_bot"}
+
+// NormalizePersona trims and lowercases a persona name, accepting hyphens for underscores
+// (e.g. "CI-Bot" gives "ci_bot"). An empty name resolves to "", meaning no persona.
+func NormalizePersona(name string) (string, error) {
+	normalized := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+	if normalized == "" || slices.Contains(Personas, normalized) {
+		return normalized, nil
+	}
+	return "", fmt.Errorf("unknown persona '%s'; valid: %s", strings.TrimSpace(name), strings.Join(Personas, ", "))
+}
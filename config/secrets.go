@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// secretCommandTimeout bounds how long an api_key_command may run
+var secretCommandTimeout = 10 * time.Second
+
+// resolveAPIKeyCommands runs each provider's api_key_command and stores its output as the API key,
+// replacing any key set directly. It fails on the first command that errors or prints nothing.
+func resolveAPIKeyCommands(conf *Config) error {
+	commands := []struct {
+		name    string
+		command string
+		key     *string
+	}{
+		{"openai", conf.OpenAI.APIKeyCommand, &conf.OpenAI.APIKey},
+		{"google", conf.Google.APIKeyCommand, &conf.Google.APIKey},
+		{"mistral", conf.Mistral.APIKeyCommand, &conf.Mistral.APIKey},
+		{"openrouter", conf.OpenRouter.APIKeyCommand, &conf.OpenRouter.APIKey},
+		{"perplexity", conf.Perplexity.APIKeyCommand, &conf.Perplexity.APIKey},
+	}
+
+	for _, c := range commands {
+		if strings.TrimSpace(c.command) == "" {
+			continue
+		}
+		key, err := runSecretCommand(c.command)
+		if err != nil {
+			return fmt.Errorf("%s.api_key_command: %w", c.name, err)
+		}
+		*c.key = key
+	}
+	return nil
+}
+
+// runSecretCommand runs command through the shell and returns its trimmed standard output.
+// Errors include the command's standard error but never its output, which may hold the secret.
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait on children of a killed shell that still hold its output open
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("timed out after %s", secretCommandTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	key := strings.TrimSpace(stdout.String())
+	if key == "" {
+		return "", errors.New("command printed no key")
+	}
+	return key, nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSecretScript writes an executable shell script standing in for a secrets manager CLI
+func writeSecretScript(t *testing.T, dir, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("secret command scripts need a POSIX shell")
+	}
+	path := filepath.Join(dir, "fake-vault")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAPIKeyCommand(t *testing.T) {
+	load := func(t *testing.T, command string) (*Config, error) {
+		t.Helper()
+		home := isolateConfigFiles(t)
+		config := `{"default_provider": "openai", "openai": {"api_key": "sk-in-file", "api_key_command": "` + command + ` openai"}}`
+		if err := os.WriteFile(filepath.Join(home, ".second-opinion.json"), []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return Load()
+	}
+
+	t.Run("output replaces the key", func(t *testing.T) {
+		script := writeSecretScript(t, t.TempDir(), `printf '  sk-from-vault-%s\n' "$1"`)
+		c, err := load(t, script)
+		if err != nil {
+			t.Fatalf("Load() unexpected error: %v", err)
+		}
+		if c.OpenAI.APIKey != "sk-from-vault-openai" {
+			t.Errorf("APIKey = %q, want the trimmed command output", c.OpenAI.APIKey)
+		}
+	})
+
+	t.Run("failing command", func(t *testing.T) {
+		script := writeSecretScript(t, t.TempDir(), `echo "sk-partial"; echo "vault is sealed" >&2; exit 2`)
+		_, err := load(t, script)
+		if err == nil || !strings.Contains(err.Error(), "openai.api_key_command") || !strings.Contains(err.Error(), "vault is sealed") {
+			t.Fatalf("expected an error naming the field with the command's stderr, got %v", err)
+		}
+		if strings.Contains(err.Error(), "sk-partial") {
+			t.Errorf("error must not include the command's output: %v", err)
+		}
+	})
+
+	t.Run("no output", func(t *testing.T) {
+		script := writeSecretScript(t, t.TempDir(), `exit 0`)
+		if _, err := load(t, script); err == nil || !strings.Contains(err.Error(), "printed no key") {
+			t.Errorf("expected an error for empty output, got %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		original := secretCommandTimeout
+		secretCommandTimeout = 100 * time.Millisecond
+		defer func() { secretCommandTimeout = original }()
+
+		script := writeSecretScript(t, t.TempDir(), `sleep 5`)
+		start := time.Now()
+		if _, err := load(t, script); err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("expected a timeout error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("Load() took %s; the command should have been killed", elapsed)
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		isolateConfigFiles(t)
+		script := writeSecretScript(t, t.TempDir(), `echo "mistral-from-vault"`)
+		t.Setenv("MISTRAL_API_KEY_COMMAND", script)
+		c, err := Load()
+		if err != nil {
+			t.Fatalf("Load() unexpected error: %v", err)
+		}
+		if c.Mistral.APIKey != "mistral-from-vault" {
+			t.Errorf("APIKey = %q, want the command output", c.Mistral.APIKey)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxContextLines bounds context_lines so a single hunk cannot pull in whole files
+const maxContextLines = 100
+
+// inlineContextLinesNote explains that context_lines cannot widen a diff the caller supplied
+const inlineContextLinesNote = "ℹ️ context_lines has no effect on a diff that is passed in; regenerate it with `git diff -U<n>` for more surrounding code."
+
+// contextLinesArgsFromRequest returns the git diff arguments for a request's context_lines, such as
+// -U10, or nil when it is not set so git's default of three lines applies
+func contextLinesArgsFromRequest(request mcp.CallToolRequest) ([]string, error) {
+	n, ok := request.GetArguments()["context_lines"].(float64)
+	if !ok {
+		return nil, nil
+	}
+	if n < 0 || n > maxContextLines || n != float64(int(n)) {
+		return nil, fmt.Errorf("context_lines must be an integer from 0 to %d, got %v", maxContextLines, n)
+	}
+	return []string{fmt.Sprintf("-U%d", int(n))}, nil
+}
+
+// withResultNote prefixes a successful text result with a note for the caller
+func withResultNote(result *mcp.CallToolResult, note string) *mcp.CallToolResult {
+	if result == nil || result.IsError || len(result.Content) == 0 {
+		return result
+	}
+	if text, ok := result.Content[0].(mcp.TextContent); ok {
+		text.Text = note + "\n\n" + text.Text
+		result.Content[0] = text
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestContextLinesArgsFromRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		want    []string
+		wantErr bool
+	}{
+		{name: "unset", args: map[string]any{}, want: nil},
+		{name: "zero", args: map[string]any{"context_lines": float64(0)}, want: []string{"-U0"}},
+		{name: "ten", args: map[string]any{"context_lines": float64(10)}, want: []string{"-U10"}},
+		{name: "maximum", args: map[string]any{"context_lines": float64(maxContextLines)}, want: []string{fmt.Sprintf("-U%d", maxContextLines)}},
+		{name: "negative", args: map[string]any{"context_lines": float64(-1)}, wantErr: true},
+		{name: "too large", args: map[string]any{"context_lines": float64(maxContextLines + 1)}, wantErr: true},
+		{name: "fractional", args: map[string]any{"context_lines": 2.5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := contextLinesArgsFromRequest(mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: tt.args}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("args = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextLinesWidenRepositoryDiffs(t *testing.T) {
+	repo := newTestRepo(t)
+
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	writeTestFile(t, repo, "notes.txt", strings.Join(lines, "\n")+"\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add notes")
+	runGit(t, repo, "checkout", "--quiet", "-b", "feature")
+	lines[14] = "line 15 changed"
+	writeTestFile(t, repo, "notes.txt", strings.Join(lines, "\n")+"\n")
+
+	ctx := context.Background()
+
+	// git's default of three lines puts the hunk at line 12; ten lines start it at line 5
+	changes, err := getUncommittedChanges(ctx, repo, false, "", nil)
+	if err != nil {
+		t.Fatalf("getUncommittedChanges failed: %v", err)
+	}
+	if !strings.Contains(changes, "@@ -12,7 +12,7 @@") {
+		t.Errorf("expected the default context without -U:\n%s", changes)
+	}
+
+	changes, err = getUncommittedChanges(ctx, repo, false, "", nil, "-U10")
+	if err != nil {
+		t.Fatalf("getUncommittedChanges failed: %v", err)
+	}
+	if !strings.Contains(changes, "@@ -5,21 +5,21 @@") || !strings.Contains(changes, " line 5\n") {
+		t.Errorf("expected -U10 to widen the hunk:\n%s", changes)
+	}
+
+	runGit(t, repo, "commit", "--quiet", "-am", "Change notes")
+	comparison, err := getBranchComparison(ctx, repo, "main", "feature", nil, "-U0")
+	if err != nil {
+		t.Fatalf("getBranchComparison failed: %v", err)
+	}
+	if !strings.Contains(comparison, "@@ -15 +15 @@") || strings.Contains(comparison, "\n line 14\n") {
+		t.Errorf("expected -U0 to drop the context:\n%s", comparison)
+	}
+}
+
+func TestHandleGitDiffContextLinesInline(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	provider := &countingProvider{name: "mock"}
+	llmProviders = map[string]llm.Provider{"mock": provider}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	result, err := handleGitDiff(context.Background(), mcp.CallToolRequest{
+		Params: mcp.CallToolParams{Name: "analyze_git_diff", Arguments: map[string]any{
+			"diff_content":  cannedPRDiff,
+			"context_lines": float64(10),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError || provider.calls != 1 {
+		t.Fatalf("expected the diff to be reviewed as given, got %v", result.Content)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.HasPrefix(text, inlineContextLinesNote) {
+		t.Errorf("expected the result to note that context_lines was ignored, got %q", text)
+	}
+}
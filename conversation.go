@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// conversationTTL is how long a conversation is kept after its last use
+	conversationTTL = 30 * time.Minute
+	// maxConversationMessages caps a conversation's history; the original analysis is always kept
+	maxConversationMessages = 20
+	// maxConversations caps how many conversations are held at once; the least recently used go first
+	maxConversations = 100
+)
+
+// errConversationNotFound is returned for an unknown or expired conversation ID
+var errConversationNotFound = errors.New("conversation not found or expired; run the analysis again with return_conversation_id")
+
+// conversation is the history of an analysis and its follow-ups
+type conversation struct {
+	provider string
+	model    string
+	messages []llm.Message
+	expires  time.Time
+}
+
+// conversationStore holds conversations in memory, expiring them after conversationTTL unused
+type conversationStore struct {
+	mu            sync.Mutex
+	conversations map[string]*conversation
+	now           func() time.Time
+}
+
+// conversations holds the server's follow-up conversations
+var conversations = newConversationStore()
+
+func newConversationStore() *conversationStore {
+	return &conversationStore{conversations: make(map[string]*conversation), now: time.Now}
+}
+
+// create stores a new conversation and returns its ID
+func (s *conversationStore) create(provider, model string, messages []llm.Message) (string, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate conversation ID: %w", err)
+	}
+	id := hex.EncodeToString(idBytes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	if len(s.conversations) >= maxConversations {
+		s.evictOldestLocked()
+	}
+	s.conversations[id] = &conversation{
+		provider: provider,
+		model:    model,
+		messages: trimConversation(messages),
+		expires:  s.now().Add(conversationTTL),
+	}
+	return id, nil
+}
+
+// get returns a copy of a live conversation
+func (s *conversationStore) get(id string) (conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	c, ok := s.conversations[id]
+	if !ok {
+		return conversation{}, errConversationNotFound
+	}
+	copied := *c
+	copied.messages = append([]llm.Message(nil), c.messages...)
+	return copied, nil
+}
+
+// append adds messages to a live conversation and extends its lifetime
+func (s *conversationStore) append(id string, messages ...llm.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	c, ok := s.conversations[id]
+	if !ok {
+		return errConversationNotFound
+	}
+	c.messages = trimConversation(append(c.messages, messages...))
+	c.expires = s.now().Add(conversationTTL)
+	return nil
+}
+
+// pruneLocked drops expired conversations; s.mu must be held
+func (s *conversationStore) pruneLocked() {
+	now := s.now()
+	for id, c := range s.conversations {
+		if now.After(c.expires) {
+			delete(s.conversations, id)
+		}
+	}
+}
+
+// evictOldestLocked drops the conversation closest to expiring; s.mu must be held
+func (s *conversationStore) evictOldestLocked() {
+	oldestID := ""
+	for id, c := range s.conversations {
+		if oldestID == "" || c.expires.Before(s.conversations[oldestID].expires) {
+			oldestID = id
+		}
+	}
+	delete(s.conversations, oldestID)
+}
+
+// trimConversation keeps the original analysis exchange and the most recent follow-ups within
+// maxConversationMessages
+func trimConversation(messages []llm.Message) []llm.Message {
+	if len(messages) <= maxConversationMessages {
+		return messages
+	}
+	const kept = 2 // the analysis prompt and its result
+	recent := messages[len(messages)-(maxConversationMessages-kept):]
+	return append(append([]llm.Message(nil), messages[:kept]...), recent...)
+}
+
+// wantsConversationID reports whether a tool request asked for a follow-up conversation
+func wantsConversationID(request mcp.CallToolRequest) bool {
+	want, _ := request.GetArguments()["return_conversation_id"].(bool)
+	return want
+}
+
+// attachConversation starts a follow-up conversation from the analysis a tool call made and adds
+// its ID to the result as a separate content item, leaving structured output parseable. Results
+// that needed no LLM call, and dry runs, start none.
+func attachConversation(attribution *analysisAttribution, request mcp.CallToolRequest, result *mcp.CallToolResult) *mcp.CallToolResult {
+	provider, model, prompt := attribution.exchange()
+	if provider == "" || len(result.Content) == 0 {
+		return result
+	}
+	if dryRun, _ := request.GetArguments()["dry_run"].(bool); dryRun {
+		return result
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		return result
+	}
+
+	id, err := conversations.create(provider, model, []llm.Message{
+		{Role: llm.RoleUser, Content: prompt},
+		{Role: llm.RoleAssistant, Content: text.Text},
+	})
+	if err != nil {
+		result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf("⚠️ No conversation ID: %v", err)))
+		return result
+	}
+	result.Content = append(result.Content, mcp.NewTextContent(conversationNote(id)))
+	return result
+}
+
+// conversationNote tells the caller how to follow up on a result
+func conversationNote(id string) string {
+	return fmt.Sprintf("💬 Conversation ID: %s (pass it to followup within %s to ask about this analysis)", id, conversationTTL)
+}
+
+func handleFollowup(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	id, _ := request.GetArguments()["conversation_id"].(string)
+	question, _ := request.GetArguments()["question"].(string)
+	if strings.TrimSpace(id) == "" {
+		return mcp.NewToolResultError("conversation_id is required"), nil
+	}
+	if strings.TrimSpace(question) == "" {
+		return mcp.NewToolResultError("question is required"), nil
+	}
+
+	conv, err := conversations.get(id)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Continue with the provider and model that produced the analysis
+	provider, err := getOrCreateProvider(conv.provider, conv.model)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	model := ""
+	if reporter, ok := llm.BaseProvider(provider).(llm.ModelReporter); ok {
+		model = reporter.Model()
+	}
+	// Follow-ups skip task tuning like raw analyses, but keep redaction and the request guards
+	defaults := newProviderConfig(provider.Name(), model)
+	chat := llm.NewRawProvider(provider, cfg, defaults.MaxTokens, defaults.Temperature).(llm.ChatProvider)
+
+	asked := llm.Message{Role: llm.RoleUser, Content: question}
+	reply, err := chat.Chat(ctx, append(conv.messages, asked))
+	if errors.Is(err, llm.ErrChatUnsupported) {
+		return mcp.NewToolResultError(fmt.Sprintf("%s does not support follow-up conversations", provider.Name())), nil
+	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+	}
+	if err := conversations.append(id, asked, llm.Message{Role: llm.RoleAssistant, Content: reply}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return mcp.NewToolResultText(limitResponse(reply)), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// diffCache remembers the diffs read during one tool call so retrieving the same diff again
+// reuses the first result instead of running git again
+type diffCache struct {
+	mu    sync.Mutex
+	diffs map[string]*TruncatedDiff
+}
+
+type diffCacheKey struct{}
+
+// withDiffCache gives ctx a fresh diff cache, scoping reuse to a single tool call
+func withDiffCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, diffCacheKey{}, &diffCache{diffs: make(map[string]*TruncatedDiff)})
+}
+
+// diffCacheFromContext returns ctx's diff cache, or nil if it has none
+func diffCacheFromContext(ctx context.Context) *diffCache {
+	cache, _ := ctx.Value(diffCacheKey{}).(*diffCache)
+	return cache
+}
+
+// diffCacheKeyFor identifies a diff by repository, filter, and git arguments; the memory
+// limits are the configured ones throughout a call, so they are not part of the key
+func diffCacheKeyFor(repoPath string, filter *DiffFilter, args []string) string {
+	return fmt.Sprintf("%s\x00%p\x00%s", repoPath, filter, strings.Join(args, "\x00"))
+}
+
+// get returns a copy of the cached diff for key, if any; a nil cache holds nothing
+func (c *diffCache) get(key string) (*TruncatedDiff, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	diff, ok := c.diffs[key]
+	if !ok {
+		return nil, false
+	}
+	cached := *diff
+	return &cached, true
+}
+
+// put stores a copy of diff under key; a nil cache discards it
+func (c *diffCache) put(key string, diff *TruncatedDiff) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := *diff
+	c.diffs[key] = &cached
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// countGitInvocations puts a git wrapper first on PATH that logs each invocation's arguments,
+// returning a function that reads the log
+func countGitInvocations(t *testing.T) func() []string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("git wrapper is a shell script")
+	}
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "git.log")
+	script := "#!/bin/sh\necho \"$*\" >> '" + logPath + "'\nexec '" + realGit + "' \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "git"), []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write git wrapper: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return func() []string {
+		data, err := os.ReadFile(logPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("failed to read git log: %v", err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestUncommittedChangesGitInvocations(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1}}
+
+	repo := newTestRepo(t)
+	writeTestFile(t, repo, "README.md", "# test repo\n\nMore detail.\n")
+	invocations := countGitInvocations(t)
+
+	ctx := withDiffCache(context.Background())
+	changes, err := getUncommittedChanges(ctx, repo, false, "", nil)
+	if err != nil {
+		t.Fatalf("getUncommittedChanges failed: %v", err)
+	}
+	if !strings.Contains(changes, "+More detail.") || !strings.Contains(changes, "1 file(s) changed, 2 insertion(s)(+), 0 deletion(s)(-)") {
+		t.Errorf("changes missing the diff or its statistics:\n%s", changes)
+	}
+
+	// git status, the size check's numstat, and the diff itself; statistics reuse the numstat
+	first := invocations()
+	if len(first) != 3 {
+		t.Fatalf("first retrieval ran git %d times, want 3: %q", len(first), first)
+	}
+	for _, args := range first {
+		if strings.Contains(args, "--stat") {
+			t.Errorf("statistics should not run git diff --stat: %q", args)
+		}
+	}
+
+	// Retrieving the same diff again in the call only re-checks the status
+	if _, err := getUncommittedChanges(ctx, repo, false, "", nil); err != nil {
+		t.Fatalf("getUncommittedChanges failed: %v", err)
+	}
+	if again := invocations()[len(first):]; len(again) != 1 || !strings.Contains(again[0], "status") {
+		t.Errorf("second retrieval ran git %q, want only git status", again)
+	}
+
+	// A new call gets a new cache
+	if _, err := getUncommittedChanges(withDiffCache(context.Background()), repo, false, "", nil); err != nil {
+		t.Fatalf("getUncommittedChanges failed: %v", err)
+	}
+	if got := len(invocations()); got != len(first)+1+3 {
+		t.Errorf("a fresh cache should read the diff again, got %d invocations in total", got)
+	}
+}
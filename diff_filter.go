@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dshills/second-opinion/analysis"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// errNoMatchingFiles reports that include/exclude patterns removed every file from a diff
+var errNoMatchingFiles = errors.New("no changed files match include_patterns/exclude_patterns")
+
+// noMatchingFilesMessage is the tool result when the patterns leave nothing to review
+const noMatchingFilesMessage = "No changed files match include_patterns/exclude_patterns."
+
+// DiffFilter selects which files of a diff are kept, using .gitignore-style glob patterns
+type DiffFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewDiffFilter compiles include and exclude patterns, returning nil when both are empty
+func NewDiffFilter(include, exclude []string) (*DiffFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	filter := &DiffFilter{}
+	for _, pattern := range include {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.include = append(filter.include, re)
+	}
+	for _, pattern := range exclude {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+	return filter, nil
+}
+
+// Keep reports whether a file path passes the filter: it must match an include pattern,
+// if any are given, and no exclude pattern. A nil filter keeps everything.
+func (f *DiffFilter) Keep(path string) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.include) > 0 && !matchesAny(f.include, path) {
+		return false
+	}
+	return !matchesAny(f.exclude, path)
+}
+
+// matchesAny reports whether path matches any of the patterns
+func matchesAny(patterns []*regexp.Regexp, path string) bool {
+	for _, re := range patterns {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// globToRegexp converts a .gitignore-style glob to a regular expression over slash-separated paths.
+// "*" and "?" stay within a path segment, "**" spans segments, patterns without a slash match
+// at any depth, a leading slash anchors to the repository root, and a match on a directory
+// includes everything beneath it.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	glob := strings.TrimSpace(pattern)
+	if glob == "" || glob == "/" {
+		return nil, fmt.Errorf("empty file pattern")
+	}
+
+	anchored := strings.HasPrefix(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+	glob = strings.TrimSuffix(glob, "/")
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !anchored && !strings.Contains(glob, "/") {
+		expr.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			expr.WriteString("[^/]*")
+		case glob[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	expr.WriteString("(?:/.*)?$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// diffSectionFilter drops the lines of diff file sections rejected by a DiffFilter
+type diffSectionFilter struct {
+	filter   *DiffFilter
+	skipping bool
+	filtered int
+}
+
+// keepLine reports whether a diff line belongs to a kept file section.
+// Text before the first file header is always kept.
+func (s *diffSectionFilter) keepLine(line string) bool {
+	if strings.HasPrefix(line, "diff --git ") {
+		_, path := analysis.ParseDiffHeader(line)
+		s.skipping = !s.filter.Keep(path)
+		if s.skipping {
+			s.filtered++
+		}
+	}
+	return !s.skipping
+}
+
+// filterDiff removes file sections rejected by filter from an in-memory diff and returns
+// the filtered diff with the number of files removed
+func filterDiff(diff string, filter *DiffFilter) (string, int) {
+	if filter == nil {
+		return diff, 0
+	}
+
+	sections := &diffSectionFilter{filter: filter}
+	lines := strings.SplitAfter(diff, "\n")
+	var kept strings.Builder
+	for _, line := range lines {
+		if sections.keepLine(strings.TrimSuffix(line, "\n")) {
+			kept.WriteString(line)
+		}
+	}
+	return kept.String(), sections.filtered
+}
+
+// filteredFilesNote describes how many files include/exclude patterns removed, or "" if none
+func filteredFilesNote(filtered int) string {
+	if filtered == 0 {
+		return ""
+	}
+	return fmt.Sprintf("ℹ️ %d file(s) filtered out by include_patterns/exclude_patterns", filtered)
+}
+
+// withDiffFilterOptions appends the include/exclude pattern and context_lines arguments shared by diff tools
+func withDiffFilterOptions(opts ...mcp.ToolOption) []mcp.ToolOption {
+	return append(opts,
+		mcp.WithArray("include_patterns",
+			mcp.Description("Only review files matching these .gitignore-style globs, e.g. [\"internal/**/*.go\"]"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithArray("exclude_patterns",
+			mcp.Description("Skip files matching these .gitignore-style globs, e.g. [\"vendor/\", \"*_test.go\"]"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithNumber("context_lines",
+			mcp.Description(fmt.Sprintf("Lines of surrounding code to show around each change, 0-%d (default: git's 3). Only applies when the diff is read from the repository", maxContextLines)),
+		),
+	)
+}
+
+// diffFilterFromRequest builds the file filter from a tool request's pattern arguments
+func diffFilterFromRequest(request mcp.CallToolRequest) (*DiffFilter, error) {
+	return NewDiffFilter(
+		request.GetStringSlice("include_patterns", nil),
+		request.GetStringSlice("exclude_patterns", nil),
+	)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mixedPathDiff touches Go and non-Go files inside and outside internal/
+const mixedPathDiff = `diff --git a/internal/server.go b/internal/server.go
+--- a/internal/server.go
++++ b/internal/server.go
+@@ -1 +1 @@
+-old server
++new server
+diff --git a/internal/store/db.go b/internal/store/db.go
+--- a/internal/store/db.go
++++ b/internal/store/db.go
+@@ -1 +1 @@
+-old db
++new db
+diff --git a/internal/store/db_test.go b/internal/store/db_test.go
+--- a/internal/store/db_test.go
++++ b/internal/store/db_test.go
+@@ -1 +1 @@
+-old test
++new test
+diff --git a/internal/README.md b/internal/README.md
+--- a/internal/README.md
++++ b/internal/README.md
+@@ -1 +1 @@
+-old docs
++new docs
+diff --git a/cmd/main.go b/cmd/main.go
+--- a/cmd/main.go
++++ b/cmd/main.go
+@@ -1 +1 @@
+-old main
++new main
+`
+
+func TestDiffFilterKeep(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		path     string
+		wantKeep bool
+	}{
+		{"no patterns", nil, nil, "any/file.go", true},
+		{"basename glob at any depth", []string{"*.go"}, nil, "a/b/c.go", true},
+		{"basename glob rejects other extensions", []string{"*.go"}, nil, "a/b/c.md", false},
+		{"double star under directory", []string{"internal/**/*.go"}, nil, "internal/store/db.go", true},
+		{"double star matches directly inside", []string{"internal/**/*.go"}, nil, "internal/server.go", true},
+		{"double star outside directory", []string{"internal/**/*.go"}, nil, "cmd/main.go", false},
+		{"single star stays in one segment", []string{"internal/*.go"}, nil, "internal/store/db.go", false},
+		{"directory pattern", nil, []string{"vendor/"}, "vendor/lib/x.go", false},
+		{"directory name at any depth", nil, []string{"testdata"}, "pkg/testdata/input.txt", false},
+		{"anchored pattern", nil, []string{"/cmd"}, "tools/cmd/main.go", true},
+		{"question mark", []string{"file?.txt"}, nil, "file1.txt", true},
+		{"exclude wins over include", []string{"*.go"}, []string{"*_test.go"}, "db_test.go", false},
+		{"literal dots", []string{"*.go"}, nil, "main.gox", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewDiffFilter(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("NewDiffFilter() unexpected error: %v", err)
+			}
+			if got := filter.Keep(tt.path); got != tt.wantKeep {
+				t.Errorf("Keep(%q) = %v, want %v", tt.path, got, tt.wantKeep)
+			}
+		})
+	}
+
+	if _, err := NewDiffFilter([]string{" "}, nil); err == nil {
+		t.Error("expected an error for an empty pattern")
+	}
+}
+
+func TestSafeDiffProcessorFilter(t *testing.T) {
+	filter, err := NewDiffFilter([]string{"internal/**/*.go"}, []string{"*_test.go"})
+	if err != nil {
+		t.Fatalf("NewDiffFilter() unexpected error: %v", err)
+	}
+
+	processor := NewSafeDiffProcessor(&config.MemoryConfig{MaxDiffSizeMB: 1, MaxFileCount: 10, MaxLineLength: 100})
+	processor.SetFilter(filter)
+	if err := processor.ProcessChunk([]byte(mixedPathDiff)); err != nil {
+		t.Fatalf("ProcessChunk failed: %v", err)
+	}
+	result := processor.GetResult()
+
+	if result.FileCount != 2 || result.FilesFiltered != 3 {
+		t.Errorf("FileCount = %d, FilesFiltered = %d, want 2 and 3", result.FileCount, result.FilesFiltered)
+	}
+	for _, kept := range []string{"new server", "new db"} {
+		if !strings.Contains(result.Content, kept) {
+			t.Errorf("expected %q to be kept:\n%s", kept, result.Content)
+		}
+	}
+	for _, dropped := range []string{"new test", "new docs", "new main"} {
+		if strings.Contains(result.Content, dropped) {
+			t.Errorf("expected %q to be filtered out:\n%s", dropped, result.Content)
+		}
+	}
+
+	// The in-memory filter used for inline diffs agrees with the streaming one
+	filtered, count := filterDiff(mixedPathDiff, filter)
+	if filtered != result.Content || count != result.FilesFiltered {
+		t.Errorf("filterDiff() = (%d files filtered)\n%s\nwant the processor's result", count, filtered)
+	}
+}
+
+func TestHandleGitDiffFilterPatterns(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	provider := &countingProvider{name: "mock"}
+	llmProviders = map[string]llm.Provider{"mock": provider}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	call := func(include, exclude []any) string {
+		t.Helper()
+		result, err := handleGitDiff(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "analyze_git_diff", Arguments: map[string]any{
+				"diff_content":     mixedPathDiff,
+				"include_patterns": include,
+				"exclude_patterns": exclude,
+			}},
+		})
+		if err != nil || result.IsError {
+			t.Fatalf("unexpected failure: %v %v", err, result)
+		}
+		return result.Content[0].(mcp.TextContent).Text
+	}
+
+	call([]any{"*.go"}, []any{"cmd/"})
+	if provider.calls != 1 {
+		t.Fatalf("provider called %d times, want 1", provider.calls)
+	}
+	prompt := provider.prompts[0]
+	if !strings.Contains(prompt, "2 file(s) filtered out") {
+		t.Errorf("prompt should report the filtered files:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "cmd/main.go") || strings.Contains(prompt, "README.md") {
+		t.Errorf("filtered files should not reach the LLM:\n%s", prompt)
+	}
+
+	if text := call([]any{"docs/**"}, nil); text != noMatchingFilesMessage {
+		t.Errorf("result = %q, want %q", text, noMatchingFilesMessage)
+	}
+	if provider.calls != 1 {
+		t.Errorf("no LLM call expected when every file is filtered out, got %d calls", provider.calls)
+	}
+}
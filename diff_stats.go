@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// handleGetDiffStats reports diff statistics without calling an LLM
+func handleGetDiffStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	format := llm.FormatMarkdown
+	if f, ok := request.GetArguments()["format"].(string); ok && f != "" {
+		if err := validateOutputFormat(f, llm.FormatMarkdown, llm.FormatJSON); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		format = f
+	}
+
+	from := ""
+	if f, ok := request.GetArguments()["from"].(string); ok {
+		from = f
+	}
+
+	to := ""
+	if t, ok := request.GetArguments()["to"].(string); ok {
+		to = t
+	}
+
+	stagedOnly := false
+	if s, ok := request.GetArguments()["staged_only"].(bool); ok {
+		stagedOnly = s
+	}
+
+	if to != "" && from == "" {
+		return mcp.NewToolResultError("from is required when to is set"), nil
+	}
+	if stagedOnly && to != "" {
+		return mcp.NewToolResultError("staged_only cannot be combined with to"), nil
+	}
+	for name, ref := range map[string]string{"from": from, "to": to} {
+		if ref == "" {
+			continue
+		}
+		if err := validateGitRef(ref); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid %s: %v", name, err)), nil
+		}
+	}
+
+	repoPath := "."
+	if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
+		repoPath = path
+	}
+
+	// Validate repo path
+	validPath, err := validateRepoPath(repoPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	for _, ref := range []string{from, to} {
+		if ref != "" && !refExists(ctx, validPath, ref) {
+			return mcp.NewToolResultError(fmt.Sprintf("ref '%s' does not exist", ref)), nil
+		}
+	}
+
+	args, description := diffStatsArgs(from, to, stagedOnly)
+	stats, err := getDiffStats(ctx, validPath, nil, args...)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if format == llm.FormatJSON {
+		output, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to encode diff stats: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	}
+
+	return mcp.NewToolResultText(formatDiffStats(stats, description)), nil
+}
+
+// diffStatsArgs builds the git diff arguments for the requested comparison and describes it
+func diffStatsArgs(from, to string, stagedOnly bool) ([]string, string) {
+	switch {
+	case from != "" && to != "":
+		return []string{from + ".." + to}, fmt.Sprintf("%s..%s", from, to)
+	case from != "" && stagedOnly:
+		return []string{"--cached", from}, fmt.Sprintf("%s..staged changes", from)
+	case from != "":
+		return []string{from}, fmt.Sprintf("%s..working tree", from)
+	case stagedOnly:
+		return []string{"--cached"}, "staged changes"
+	default:
+		return []string{"HEAD"}, "uncommitted changes"
+	}
+}
+
+// formatDiffStats renders diff statistics for the tool response
+func formatDiffStats(stats *DiffStats, description string) string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("📊 Diff Statistics (%s):\n\n", description))
+	out.WriteString(fmt.Sprintf("Files changed: %d\n", stats.FileCount))
+	out.WriteString(fmt.Sprintf("Insertions: +%d\n", stats.Insertions))
+	out.WriteString(fmt.Sprintf("Deletions: -%d\n", stats.Deletions))
+	out.WriteString(fmt.Sprintf("Estimated size: %dKB\n", stats.EstimatedSizeKB))
+	return out.String()
+}
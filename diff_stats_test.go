@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestHandleGetDiffStats(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+
+	repo := newTestRepo(t)
+	cfg = &config.Config{AllowedRepoRoots: []string{repo}}
+
+	writeTestFile(t, repo, "a.go", "package a\n\nfunc A() {}\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add a.go")
+	writeTestFile(t, repo, "a.go", "package a\n\nfunc A() int { return 1 }\n")
+	writeTestFile(t, repo, "README.md", "# test repo\nmore\n")
+
+	call := func(args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		args["repo_path"] = repo
+		result, err := handleGetDiffStats(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "get_diff_stats", Arguments: args},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result
+	}
+
+	t.Run("uncommitted changes as json", func(t *testing.T) {
+		result := call(map[string]any{"format": "json"})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+
+		var stats DiffStats
+		if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &stats); err != nil {
+			t.Fatalf("result is not JSON: %v", err)
+		}
+		want := DiffStats{FileCount: 2, Insertions: 2, Deletions: 1}
+		if stats != want {
+			t.Errorf("stats = %+v, want %+v", stats, want)
+		}
+	})
+
+	t.Run("commit range as markdown", func(t *testing.T) {
+		result := call(map[string]any{"from": "HEAD~1", "to": "HEAD"})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+
+		text := result.Content[0].(mcp.TextContent).Text
+		for _, want := range []string{"HEAD~1..HEAD", "Files changed: 1", "Insertions: +3", "Deletions: -0"} {
+			if !strings.Contains(text, want) {
+				t.Errorf("output missing %q:\n%s", want, text)
+			}
+		}
+	})
+
+	t.Run("staged only", func(t *testing.T) {
+		runGit(t, repo, "add", "a.go")
+		result := call(map[string]any{"staged_only": true, "format": "json"})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, `"file_count": 1`) {
+			t.Errorf("expected one staged file, got %s", text)
+		}
+	})
+
+	errorCases := []struct {
+		name string
+		args map[string]any
+	}{
+		{"to without from", map[string]any{"to": "HEAD"}},
+		{"unknown ref", map[string]any{"from": "no-such-branch"}},
+		{"invalid ref", map[string]any{"from": "HEAD; rm -rf /"}},
+		{"unsupported format", map[string]any{"format": "sarif"}},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := call(tc.args); !result.IsError {
+				t.Error("expected a tool error")
+			}
+		})
+	}
+}
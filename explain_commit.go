@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func handleExplainCommit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	commitSHA := "HEAD"
+	if sha, ok := request.GetArguments()["commit_sha"].(string); ok && sha != "" {
+		commitSHA = sha
+	}
+
+	// Validate commit SHA
+	if err := validateCommitSHA(commitSHA); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid commit SHA: %v", err)), nil
+	}
+
+	audience := llm.AudienceEngineer
+	if a, ok := request.GetArguments()["audience"].(string); ok && a != "" {
+		switch a {
+		case llm.AudienceEngineer, llm.AudienceManager, llm.AudienceChangelog:
+			audience = a
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("unsupported audience %q (use %s, %s, or %s)",
+				a, llm.AudienceEngineer, llm.AudienceManager, llm.AudienceChangelog)), nil
+		}
+	}
+
+	repoPath := "."
+	if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
+		repoPath = path
+	}
+
+	// Validate repo path
+	validPath, err := validateRepoPath(repoPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Get provider and model from request
+	providerName := ""
+	if p, ok := request.GetArguments()["provider"].(string); ok {
+		providerName = p
+	}
+
+	modelOverride := ""
+	if m, ok := request.GetArguments()["model"].(string); ok {
+		modelOverride = m
+	}
+
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Get commit information
+	commitInfo, err := getCommitInfo(ctx, validPath, commitSHA)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Explain the commit rather than judge it, in terms suited to the audience
+	prompt := llm.AnalysisPrompt("explain_commit", commitInfo, map[string]any{
+		"audience": audience,
+	})
+
+	contentSize := len(commitInfo)
+	task := llm.GetTaskFromAnalysisType("explain_commit")
+	explanation, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, contentSize, task)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(limitResponse(explanation)), nil
+}
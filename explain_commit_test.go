@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestHandleExplainCommit(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	repo := newTestRepo(t)
+	writeTestFile(t, repo, "greet.go", "package main\n\nfunc Greet() string { return \"hi\" }\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add greeting")
+
+	cfg = &config.Config{
+		DefaultProvider:  "mock",
+		AllowedRepoRoots: []string{repo},
+		Memory:           config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+
+	call := func(audience string) (*mcp.CallToolResult, *countingProvider) {
+		t.Helper()
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		args := map[string]any{"repo_path": repo}
+		if audience != "" {
+			args["audience"] = audience
+		}
+		result, err := handleExplainCommit(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "explain_commit", Arguments: args},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result, provider
+	}
+
+	tests := []struct {
+		audience string
+		want     string
+	}{
+		{"", "Explain this git commit to an engineer"},
+		{"manager", "Explain this git commit to a non-technical manager"},
+		{"changelog", "Write a changelog entry for this git commit"},
+	}
+	for _, tt := range tests {
+		result, provider := call(tt.audience)
+		if result.IsError {
+			t.Fatalf("audience %q: unexpected tool error: %v", tt.audience, result.Content)
+		}
+		if provider.calls != 1 {
+			t.Fatalf("audience %q: provider called %d times, want 1", tt.audience, provider.calls)
+		}
+		prompt := provider.prompts[0]
+		if !strings.Contains(prompt, tt.want) {
+			t.Errorf("audience %q: prompt missing %q:\n%s", tt.audience, tt.want, prompt)
+		}
+		if !strings.Contains(prompt, "Add greeting") || !strings.Contains(prompt, "func Greet()") {
+			t.Errorf("audience %q: prompt missing commit details:\n%s", tt.audience, prompt)
+		}
+	}
+
+	result, provider := call("board")
+	if !result.IsError {
+		t.Error("expected an error for an unknown audience")
+	}
+	if provider.calls != 0 {
+		t.Errorf("provider called %d times for an invalid request", provider.calls)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+)
+
+// errGitNotFound is returned by repository tools when git is not installed
+var errGitNotFound = errors.New("git not found on PATH; install git to use repo tools")
+
+var (
+	gitCheckOnce sync.Once
+	gitCheckErr  error
+)
+
+// requireGit reports whether git can be run, checking once and caching the result.
+// Repository tools call it so a missing git yields an actionable error rather than an exec failure.
+func requireGit() error {
+	gitCheckOnce.Do(func() {
+		gitCheckErr = checkGit()
+	})
+	return gitCheckErr
+}
+
+// checkGit verifies that git --version succeeds
+func checkGit() error {
+	if err := exec.Command("git", "--version").Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return errGitNotFound
+		}
+		return fmt.Errorf("git is installed but not working (git --version failed: %v); repo tools are unavailable", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestCheckGitMissing(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	if err := checkGit(); !errors.Is(err, errGitNotFound) {
+		t.Errorf("checkGit() = %v, want errGitNotFound", err)
+	}
+}
+
+func TestToolsWithoutGit(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+		// Re-check with the real PATH in later tests
+		gitCheckOnce = sync.Once{}
+	}()
+
+	t.Setenv("PATH", t.TempDir())
+	gitCheckOnce = sync.Once{}
+
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	provider := &countingProvider{name: "mock"}
+	llmProviders = map[string]llm.Provider{"mock": provider}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	call := func(handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		result, err := handler(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: args}})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result
+	}
+
+	for name, handler := range map[string]func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error){
+		"get_repo_info":            handleRepoInfo,
+		"analyze_commit":           handleCommitAnalysis,
+		"analyze_uncommitted_work": handleAnalyzeUncommittedWork,
+		"get_diff_stats":           handleGetDiffStats,
+	} {
+		result := call(handler, map[string]any{})
+		if !result.IsError {
+			t.Errorf("%s: expected an error without git", name)
+			continue
+		}
+		if text := result.Content[0].(mcp.TextContent).Text; text != errGitNotFound.Error() {
+			t.Errorf("%s: error = %q, want %q", name, text, errGitNotFound.Error())
+		}
+	}
+
+	// Tools that do not touch a repository keep working
+	result := call(handleCodeReview, map[string]any{"code": "func main() {}"})
+	if result.IsError || provider.calls != 1 {
+		t.Errorf("review_code should work without git: %v (%d calls)", result.Content, provider.calls)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// githubDiffMediaType asks the GitHub API for a pull request as a unified diff
+const githubDiffMediaType = "application/vnd.github.diff"
+
+// githubNamePattern matches GitHub owner and repository names
+var githubNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// pullRequestRef identifies a pull request on GitHub
+type pullRequestRef struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+func (r pullRequestRef) String() string {
+	return fmt.Sprintf("%s/%s#%d", r.Owner, r.Repo, r.Number)
+}
+
+func handleReviewGitHubPR(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	ref, err := pullRequestRefFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	filter, err := diffFilterFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// GitHub serves pull request diffs with its own fixed context
+	contextArgs, err := contextLinesArgsFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	truncatedDiff, err := fetchPullRequestDiff(ctx, &cfg.GitHub, ref, &cfg.Memory, filter)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if truncatedDiff.Content == "" && truncatedDiff.FilesFiltered == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("Pull request %s has no changes.", ref)), nil
+	}
+
+	diffContent := truncatedDiff.Content
+	if truncatedDiff.IsTruncated {
+		diffContent = fmt.Sprintf("⚠️ WARNING: %s\nTotal size read: %dKB, Files: %d\n\n%s",
+			truncatedDiff.WarningReason, truncatedDiff.TotalSizeKB, truncatedDiff.FileCount, diffContent)
+	}
+
+	result, err := reviewDiffContent(ctx, request, diffContent, truncatedDiff.FilesFiltered)
+	if contextArgs != nil {
+		result = withResultNote(result, inlineContextLinesNote)
+	}
+	return result, err
+}
+
+// pullRequestRefFromRequest reads the pull request from either pr_url or owner, repo, and number
+func pullRequestRefFromRequest(request mcp.CallToolRequest) (pullRequestRef, error) {
+	args := request.GetArguments()
+	prURL, _ := args["pr_url"].(string)
+	owner, _ := args["owner"].(string)
+	repo, _ := args["repo"].(string)
+	number, hasNumber := args["number"].(float64)
+
+	if prURL != "" {
+		if owner != "" || repo != "" || hasNumber {
+			return pullRequestRef{}, errors.New("provide either pr_url or owner, repo, and number, not both")
+		}
+		return parsePullRequestURL(prURL, cfg.GitHub.APIURL)
+	}
+
+	if owner == "" || repo == "" || !hasNumber {
+		return pullRequestRef{}, errors.New("pr_url, or owner, repo, and number, must be provided")
+	}
+	if number < 1 || number != float64(int(number)) {
+		return pullRequestRef{}, fmt.Errorf("number must be a positive integer, got %v", number)
+	}
+	ref := pullRequestRef{Owner: owner, Repo: repo, Number: int(number)}
+	return ref, validatePullRequestRef(ref)
+}
+
+// parsePullRequestURL parses a pull request URL such as https://github.com/owner/repo/pull/123.
+// Requests always go to apiURL, so the URL must be on that GitHub's web host; otherwise a pull
+// request on another server would be silently fetched from this one.
+func parsePullRequestURL(rawURL, apiURL string) (pullRequestRef, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return pullRequestRef{}, fmt.Errorf("invalid pr_url %q: expected https://github.com/OWNER/REPO/pull/NUMBER", rawURL)
+	}
+	hosts := githubWebHosts(apiURL)
+	if !slices.Contains(hosts, strings.ToLower(u.Host)) {
+		return pullRequestRef{}, fmt.Errorf("pr_url host %q does not match the configured GitHub (%s); set github.api_url (GITHUB_API_URL) to review pull requests on another server", u.Host, hosts[0])
+	}
+
+	// Trailing segments such as /files or /commits are allowed
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[2] != "pull" {
+		return pullRequestRef{}, fmt.Errorf("invalid pr_url %q: expected https://github.com/OWNER/REPO/pull/NUMBER", rawURL)
+	}
+	number, err := strconv.Atoi(parts[3])
+	if err != nil || number < 1 {
+		return pullRequestRef{}, fmt.Errorf("invalid pull request number %q in pr_url", parts[3])
+	}
+
+	ref := pullRequestRef{Owner: parts[0], Repo: parts[1], Number: number}
+	return ref, validatePullRequestRef(ref)
+}
+
+// githubWebHosts returns the hosts that serve pull request pages for the GitHub API at apiURL:
+// github.com for api.github.com, and the API's own host for GitHub Enterprise Server
+func githubWebHosts(apiURL string) []string {
+	if apiURL == "" {
+		apiURL = config.DefaultGitHubAPIURL
+	}
+	host := ""
+	if u, err := url.Parse(apiURL); err == nil {
+		host = strings.ToLower(u.Host)
+	}
+	if web, ok := strings.CutPrefix(host, "api."); ok {
+		return []string{web, "www." + web}
+	}
+	return []string{host}
+}
+
+// validatePullRequestRef rejects owner and repository names that could alter the API path
+func validatePullRequestRef(ref pullRequestRef) error {
+	if !githubNamePattern.MatchString(ref.Owner) || ref.Owner == "." || ref.Owner == ".." {
+		return fmt.Errorf("invalid GitHub owner %q", ref.Owner)
+	}
+	if !githubNamePattern.MatchString(ref.Repo) || ref.Repo == "." || ref.Repo == ".." {
+		return fmt.Errorf("invalid GitHub repository %q", ref.Repo)
+	}
+	return nil
+}
+
+// fetchPullRequestDiff downloads a pull request's diff through the memory-safe reader, so a diff
+// over the memory limits is truncated rather than read in full
+func fetchPullRequestDiff(ctx context.Context, gh *config.GitHubConfig, ref pullRequestRef, memConfig *config.MemoryConfig, filter *DiffFilter) (*TruncatedDiff, error) {
+	apiURL := gh.APIURL
+	if apiURL == "" {
+		apiURL = config.DefaultGitHubAPIURL
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", strings.TrimSuffix(apiURL, "/"),
+		url.PathEscape(ref.Owner), url.PathEscape(ref.Repo), ref.Number)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub request: %w", err)
+	}
+	req.Header.Set("Accept", githubDiffMediaType)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if gh.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+gh.Token)
+	}
+
+	resp, err := llm.SharedHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pull request %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, githubError(resp, ref, gh.Token != "")
+	}
+
+	diff, err := readDiffSafe(ctx, resp.Body, memConfig, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pull request %s: %w", ref, err)
+	}
+	return diff, nil
+}
+
+// githubError explains a failed GitHub API response, including when an exhausted rate limit resets
+func githubError(resp *http.Response, ref pullRequestRef, authenticated bool) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	message := strings.TrimSpace(string(body))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0"):
+		wait := ""
+		if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = fmt.Sprintf("; retry in %ds", retry)
+		} else if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			wait = fmt.Sprintf("; it resets at %s", time.Unix(reset, 0).UTC().Format(time.RFC3339))
+		}
+		if !authenticated {
+			wait += " (set GITHUB_TOKEN for a higher limit)"
+		}
+		return fmt.Errorf("GitHub API rate limit exceeded%s", wait)
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized:
+		if !authenticated {
+			return fmt.Errorf("pull request %s not found; set GITHUB_TOKEN to access private repositories", ref)
+		}
+		return fmt.Errorf("pull request %s not found or not accessible with the configured token (HTTP %d)", ref, resp.StatusCode)
+	case resp.StatusCode == http.StatusNotAcceptable:
+		return fmt.Errorf("pull request %s is too large for GitHub to return as a diff", ref)
+	default:
+		return fmt.Errorf("GitHub API returned HTTP %d for pull request %s: %s", resp.StatusCode, ref, message)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const cannedPRDiff = `diff --git a/calc.go b/calc.go
+index 1111111..2222222 100644
+--- a/calc.go
++++ b/calc.go
+@@ -1,3 +1,7 @@
+ package calc
+
+ func Add(a, b int) int { return a + b }
++
++func Div(a, b int) int { return a / b }
+diff --git a/go.sum b/go.sum
+index 3333333..4444444 100644
+--- a/go.sum
++++ b/go.sum
+@@ -1 +1,2 @@
+ example.com/a v1.0.0 h1:abc=
++example.com/b v1.0.0 h1:def=
+`
+
+func TestHandleReviewGitHubPR(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/acme/private/pulls/1" && r.Header.Get("Authorization") == "":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/repos/acme/limited/pulls/1":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "1760000000")
+			w.WriteHeader(http.StatusForbidden)
+		case r.Header.Get("Accept") != githubDiffMediaType:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		case r.URL.Path == "/repos/acme/widgets/pulls/42" || r.URL.Path == "/repos/acme/private/pulls/1":
+			w.Write([]byte(cannedPRDiff))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// The test server stands in for GitHub Enterprise, which serves pages and the API from one host
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		GitHub:          config.GitHubConfig{APIURL: server.URL},
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+
+	call := func(args map[string]any) (*mcp.CallToolResult, *countingProvider) {
+		t.Helper()
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		result, err := handleReviewGitHubPR(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "review_github_pr", Arguments: args},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result, provider
+	}
+
+	t.Run("by url", func(t *testing.T) {
+		result, provider := call(map[string]any{"pr_url": server.URL + "/acme/widgets/pull/42/files"})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		if provider.calls != 1 || !strings.Contains(provider.prompts[0], "+func Div") {
+			t.Errorf("expected the PR diff to be reviewed, got prompts %v", provider.prompts)
+		}
+	})
+
+	t.Run("by number with filter", func(t *testing.T) {
+		result, provider := call(map[string]any{
+			"owner": "acme", "repo": "widgets", "number": float64(42),
+			"exclude_patterns": []any{"go.sum"},
+		})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		if strings.Contains(provider.prompts[0], "example.com/b") {
+			t.Errorf("expected go.sum to be filtered out:\n%s", provider.prompts[0])
+		}
+	})
+
+	t.Run("private repository", func(t *testing.T) {
+		result, _ := call(map[string]any{"pr_url": server.URL + "/acme/private/pull/1"})
+		if text := result.Content[0].(mcp.TextContent).Text; !result.IsError || !strings.Contains(text, "GITHUB_TOKEN") {
+			t.Errorf("expected a hint to set GITHUB_TOKEN, got %q", text)
+		}
+
+		cfg.GitHub.Token = "ghp_test"
+		defer func() { cfg.GitHub.Token = "" }()
+		if result, _ := call(map[string]any{"pr_url": server.URL + "/acme/private/pull/1"}); result.IsError {
+			t.Errorf("expected the token to grant access, got %v", result.Content)
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		result, provider := call(map[string]any{"pr_url": server.URL + "/acme/limited/pull/1"})
+		text := result.Content[0].(mcp.TextContent).Text
+		if !result.IsError || !strings.Contains(text, "rate limit exceeded") || !strings.Contains(text, "resets at 2025-10-09") {
+			t.Errorf("expected a rate limit error with the reset time, got %q", text)
+		}
+		if provider.calls != 0 {
+			t.Error("expected no LLM call")
+		}
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		cfg.Memory.MaxFileCount = 1
+		defer func() { cfg.Memory.MaxFileCount = 1000 }()
+		result, provider := call(map[string]any{"pr_url": server.URL + "/acme/widgets/pull/42"})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		if !strings.Contains(provider.prompts[0], "Truncated at 1 files limit") || strings.Contains(provider.prompts[0], "example.com/b") {
+			t.Errorf("expected the diff to be truncated:\n%s", provider.prompts[0])
+		}
+	})
+}
+
+func TestParsePullRequestURL(t *testing.T) {
+	valid := map[string]pullRequestRef{
+		"https://github.com/acme/widgets/pull/42":       {"acme", "widgets", 42},
+		"https://github.com/acme/widgets/pull/42/files": {"acme", "widgets", 42},
+		"https://GitHub.com/acme/my.repo/pull/7?w=1":    {"acme", "my.repo", 7},
+	}
+	for input, want := range valid {
+		got, err := parsePullRequestURL(input, config.DefaultGitHubAPIURL)
+		if err != nil || got != want {
+			t.Errorf("parsePullRequestURL(%q) = %+v, %v; want %+v", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{
+		"github.com/acme/widgets/pull/42",
+		"https://github.com/acme/widgets/issues/42",
+		"https://github.com/acme/widgets/pull/0",
+		"https://github.com/acme/widgets/pull/abc",
+		"https://github.com/acme/../pull/1",
+		"file:///acme/widgets/pull/1",
+		"https://ghe.example.com/team/repo/pull/7",
+		"https://github.com.evil.example/acme/widgets/pull/42",
+	} {
+		if _, err := parsePullRequestURL(input, config.DefaultGitHubAPIURL); err == nil {
+			t.Errorf("parsePullRequestURL(%q) should fail", input)
+		}
+	}
+
+	// GitHub Enterprise Server serves pages from the API's host
+	const enterpriseAPI = "https://ghe.example.com/api/v3"
+	if got, err := parsePullRequestURL("https://ghe.example.com/team/my.repo/pull/7", enterpriseAPI); err != nil || got != (pullRequestRef{"team", "my.repo", 7}) {
+		t.Errorf("enterprise URL = %+v, %v", got, err)
+	}
+	if _, err := parsePullRequestURL("https://github.com/acme/widgets/pull/42", enterpriseAPI); err == nil {
+		t.Error("a github.com URL should be rejected when github.api_url points at GitHub Enterprise")
+	}
+}
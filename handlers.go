@@ -2,20 +2,88 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
 
+	"github.com/dshills/second-opinion/analysis"
 	"github.com/dshills/second-opinion/llm"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func handleGitDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	diffContent, err := request.RequireString("diff_content")
+	ctx = withCallOptions(ctx, request)
+
+	diffContent := ""
+	if d, ok := request.GetArguments()["diff_content"].(string); ok {
+		diffContent = d
+	}
+
+	diffFile := ""
+	if f, ok := request.GetArguments()["diff_file"].(string); ok {
+		diffFile = f
+	}
+
+	// Exactly one diff source must be given
+	if (diffContent == "") == (diffFile == "") {
+		return mcp.NewToolResultError("exactly one of diff_content or diff_file must be provided"), nil
+	}
+
+	filter, err := diffFilterFromRequest(request)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	contextArgs, err := contextLinesArgsFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	filtered := 0
+	if diffFile != "" {
+		validPath, err := validateFilePath(diffFile)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid diff_file: %v", err)), nil
+		}
+
+		content, fileFiltered, err := readDiffFile(ctx, validPath, filter)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if content == "" && fileFiltered == 0 {
+			return mcp.NewToolResultText("The diff file is empty."), nil
+		}
+		diffContent, filtered = content, fileFiltered
+	} else {
+		diffContent, filtered = filterDiff(diffContent, filter)
+	}
+
+	result, err := reviewDiffContent(ctx, request, diffContent, filtered)
+	if contextArgs != nil {
+		result = withResultNote(result, inlineContextLinesNote)
+	}
+	return result, err
+}
+
+// reviewDiffContent reviews a diff that filter patterns have already been applied to, skipping
+// trivial changes and reviewing per file when asked. filtered is how many files the patterns removed.
+func reviewDiffContent(ctx context.Context, request mcp.CallToolRequest, diffContent string, filtered int) (*mcp.CallToolResult, error) {
+	// Nothing left to review once the patterns are applied
+	if filtered > 0 && len(analysis.ParseDiff(diffContent)) == 0 {
+		return mcp.NewToolResultText(noMatchingFilesMessage), nil
+	}
+	if note := filteredFilesNote(filtered); note != "" {
+		diffContent = note + "\n\n" + diffContent
+	}
+
+	// Renames and reformatting need no review
+	if !forceRequested(request) {
+		if note := trivialDiffNote(diffContent); note != "" {
+			return mcp.NewToolResultText(note), nil
+		}
+	}
+
 	summarize := false
 	if s, ok := request.GetArguments()["summarize"].(bool); ok {
 		summarize = s
@@ -32,29 +100,58 @@ func handleGitDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 		modelOverride = m
 	}
 
-	// Get or create the appropriate optimized provider
-	optimizedProvider, err := getOrCreateOptimizedProvider(providerName, modelOverride)
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	// Create prompt for LLM analysis
-	prompt := llm.AnalysisPrompt("diff", diffContent, map[string]interface{}{
-		"summarize": summarize,
-	})
+	// Review each file separately when asked, for a more actionable PR breakdown
+	if perFile, ok := request.GetArguments()["per_file"].(bool); ok && perFile {
+		review, err := reviewDiffPerFile(ctx, optimizedProvider, diffContent, summarize)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+		}
+		return mcp.NewToolResultText(withChangeType(analysis.ClassifyDiff(diffContent), limitResponse(review))), nil
+	}
 
-	// Get analysis from LLM using optimization
-	contentSize := len(diffContent)
-	task := llm.GetTaskFromAnalysisType("diff")
-	analysis, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, contentSize, task)
+	result, err := analysis.AnalyzeDiff(ctx, cfg, optimizedProvider, analysis.DiffInput{
+		Diff:      diffContent,
+		Summarize: summarize,
+	})
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(analysis), nil
+	return mcp.NewToolResultText(withChangeType(result.ChangeType, result.Text)), nil
+}
+
+// withChangeType labels a diff review with the change type classified from its file paths
+func withChangeType(changeType, review string) string {
+	if changeType == "" {
+		return review
+	}
+	return fmt.Sprintf("**Change type:** %s (from file paths)\n\n%s", changeType, review)
+}
+
+// readDiffFile reads a diff file through the memory-safe reader, prefixing a warning when it was truncated.
+// It also returns how many files filter removed.
+func readDiffFile(ctx context.Context, path string, filter *DiffFilter) (string, int, error) {
+	truncatedDiff, err := readDiffFileSafe(ctx, path, &cfg.Memory, filter)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if truncatedDiff.IsTruncated {
+		return fmt.Sprintf("⚠️ WARNING: %s\nTotal size read: %dKB, Files: %d\n\n%s",
+			truncatedDiff.WarningReason, truncatedDiff.TotalSizeKB, truncatedDiff.FileCount, truncatedDiff.Content), truncatedDiff.FilesFiltered, nil
+	}
+	return truncatedDiff.Content, truncatedDiff.FilesFiltered, nil
 }
 
 func handleCodeReview(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
 	code, err := request.RequireString("code")
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
@@ -66,10 +163,35 @@ func handleCodeReview(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 	}
 
 	focus := "all"
-	if f, ok := request.GetArguments()["focus"].(string); ok {
+	if f, ok := request.GetArguments()["focus"].(string); ok && strings.TrimSpace(f) != "" {
+		if len([]rune(f)) > llm.MaxFocusLength {
+			return mcp.NewToolResultError(fmt.Sprintf("focus must be at most %d characters", llm.MaxFocusLength)), nil
+		}
 		focus = f
 	}
 
+	format := llm.FormatMarkdown
+	if f, ok := request.GetArguments()["format"].(string); ok && f != "" {
+		if err := validateOutputFormat(f, llm.FormatMarkdown, llm.FormatJSON, llm.FormatSARIF); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		format = f
+	}
+
+	var minSeverity llm.Severity
+	if s, ok := request.GetArguments()["min_severity"].(string); ok && s != "" {
+		severity, err := llm.ParseSeverity(s)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		minSeverity = severity
+	}
+
+	lint := cfg.ShouldLintCode()
+	if l, ok := request.GetArguments()["lint"].(bool); ok {
+		lint = l
+	}
+
 	// Get provider and model from request
 	providerName := ""
 	if p, ok := request.GetArguments()["provider"].(string); ok {
@@ -81,31 +203,30 @@ func handleCodeReview(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		modelOverride = m
 	}
 
-	// Get or create the appropriate optimized provider
-	optimizedProvider, err := getOrCreateOptimizedProvider(providerName, modelOverride)
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	// Create prompt for LLM analysis
-	prompt := llm.AnalysisPrompt("code_review", code, map[string]interface{}{
-		"language": language,
-		"focus":    focus,
-	})
-
-	// Get review from LLM using optimization
-	contentSize := len(code)
-	task := llm.GetTaskFromAnalysisType("code_review")
-	// If focus is security, use security-specific task
-	if focus == "security" {
-		task = llm.GetTaskFromAnalysisType("security")
+	// Structured formats are only usable once complete, so only markdown reviews are streamed
+	if format == llm.FormatMarkdown {
+		ctx = withProgressStream(ctx, request)
 	}
-	review, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, contentSize, task)
+
+	review, err := analysis.ReviewCode(ctx, cfg, optimizedProvider, analysis.CodeReviewInput{
+		Code:        code,
+		Language:    language,
+		Focus:       focus,
+		Format:      format,
+		MinSeverity: minSeverity,
+		Lint:        lint,
+	})
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("LLM review failed: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(review), nil
+	return mcp.NewToolResultText(review.Text), nil
 }
 
 func handleRepoInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -119,6 +240,9 @@ func handleRepoInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.Call
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
 	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	info := getRepoInfo(ctx, validPath)
 
@@ -126,6 +250,8 @@ func handleRepoInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.Call
 }
 
 func handleCommitAnalysis(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
 	commitSHA := "HEAD"
 	if sha, ok := request.GetArguments()["commit_sha"].(string); ok && sha != "" {
 		commitSHA = sha
@@ -136,6 +262,11 @@ func handleCommitAnalysis(ctx context.Context, request mcp.CallToolRequest) (*mc
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid commit SHA: %v", err)), nil
 	}
 
+	paths, err := commitPathsFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid paths: %v", err)), nil
+	}
+
 	repoPath := "."
 	if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
 		repoPath = path
@@ -146,6 +277,9 @@ func handleCommitAnalysis(ctx context.Context, request mcp.CallToolRequest) (*mc
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
 	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	// Get provider and model from request
 	providerName := ""
@@ -158,37 +292,64 @@ func handleCommitAnalysis(ctx context.Context, request mcp.CallToolRequest) (*mc
 		modelOverride = m
 	}
 
-	// Get or create the appropriate optimized provider
-	optimizedProvider, err := getOrCreateOptimizedProvider(providerName, modelOverride)
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	// Warn about requested paths the commit didn't touch, and don't send an empty diff
+	var missing []string
+	if len(paths) > 0 {
+		missing, err = missingCommitPaths(ctx, validPath, commitSHA, paths)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(missing) == len(paths) {
+			return mcp.NewToolResultError(fmt.Sprintf("None of the requested paths were changed in %s: %s", commitSHA, strings.Join(paths, ", "))), nil
+		}
+	}
+
 	// Get commit information
-	commitInfo, err := getCommitInfo(ctx, validPath, commitSHA)
+	commitInfo, err := getCommitInfo(ctx, validPath, commitSHA, paths...)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	// Lines are blamed in the commit's parent, where the lines it removes or changes last lived
+	if blame, ok := request.GetArguments()["include_blame"].(bool); ok && blame {
+		commitInfo = withBlame(ctx, validPath, commitSHA+"^", commitInfo)
+	}
 
-	// Create prompt for LLM analysis
-	prompt := llm.AnalysisPrompt("commit", commitInfo, nil)
+	// Commits are immutable, so an earlier analysis of the same commit and prompt can be reused
+	cached, pending := lookupCommitAnalysis(ctx, request, validPath, commitSHA, providerName, modelOverride, commitInfo)
+	if cached != nil {
+		result := withResultNote(mcp.NewToolResultText(cached.Analysis), cachedAnalysisNote(cached))
+		if len(missing) > 0 {
+			result = withResultNote(result, missingPathsNote(missing))
+		}
+		return result, nil
+	}
 
-	// Get analysis from LLM using optimization
-	contentSize := len(commitInfo)
-	task := llm.GetTaskFromAnalysisType("commit")
-	analysis, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, contentSize, task)
+	result, err := analysis.AnalyzeCommit(ctx, cfg, optimizedProvider, analysis.CommitInput{Info: commitInfo})
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
 	}
+	saveCommitAnalysis(pending, result.Text)
 
-	return mcp.NewToolResultText(analysis), nil
+	return withResultNote(mcp.NewToolResultText(result.Text), missingPathsNote(missing)), nil
 }
 
-func getCommitInfo(ctx context.Context, repoPath, commitSHA string) (string, error) {
+// getCommitInfo returns a commit's message, stat, and diff, limited to paths when any are given
+func getCommitInfo(ctx context.Context, repoPath, commitSHA string, paths ...string) (string, error) {
 	var info strings.Builder
 
+	var pathArgs []string
+	if len(paths) > 0 {
+		pathArgs = append([]string{"--"}, paths...)
+	}
+
 	// Get commit info with diff
-	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "show", "--stat", commitSHA)
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repoPath, "show", "--stat", commitSHA}, pathArgs...)...)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to get commit info: %v", err)
@@ -198,10 +359,14 @@ func getCommitInfo(ctx context.Context, repoPath, commitSHA string) (string, err
 
 	// Get the actual diff using safe memory-limited approach
 	memConfig := &cfg.Memory
-	truncatedDiff, err := getGitDiffSafe(ctx, repoPath, memConfig, commitSHA+"^", commitSHA)
+	truncatedDiff, err := getGitDiffSafe(ctx, repoPath, memConfig, nil, append([]string{commitSHA + "^", commitSHA}, pathArgs...)...)
 	if err != nil {
-		// If this is the first commit, try to get the full content
-		truncatedDiff, err = getGitDiffSafe(ctx, repoPath, memConfig, commitSHA)
+		// If this is the first commit, diff it against the empty tree to get the full content
+		emptyTree, treeErr := emptyTreeHash(ctx, repoPath)
+		if treeErr != nil {
+			return "", fmt.Errorf("failed to get commit diff: %v", treeErr)
+		}
+		truncatedDiff, err = getGitDiffSafe(ctx, repoPath, memConfig, nil, append([]string{emptyTree, commitSHA}, pathArgs...)...)
 		if err != nil {
 			// If both commands fail, return a meaningful error
 			return "", fmt.Errorf("failed to get commit diff: %v", err)
@@ -282,6 +447,8 @@ func getRepoInfo(ctx context.Context, repoPath string) string {
 }
 
 func handleAnalyzeUncommittedWork(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
 	repoPath := "."
 	if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
 		repoPath = path
@@ -292,12 +459,23 @@ func handleAnalyzeUncommittedWork(ctx context.Context, request mcp.CallToolReque
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
 	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	stagedOnly := false
 	if staged, ok := request.GetArguments()["staged_only"].(bool); ok {
 		stagedOnly = staged
 	}
 
+	baseRef := ""
+	if b, ok := request.GetArguments()["base_ref"].(string); ok && b != "" {
+		if err := validateGitRef(b); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid base_ref: %v", err)), nil
+		}
+		baseRef = b
+	}
+
 	// Get provider and model from request
 	providerName := ""
 	if p, ok := request.GetArguments()["provider"].(string); ok {
@@ -309,25 +487,60 @@ func handleAnalyzeUncommittedWork(ctx context.Context, request mcp.CallToolReque
 		modelOverride = m
 	}
 
-	// Get or create the appropriate optimized provider
-	optimizedProvider, err := getOrCreateOptimizedProvider(providerName, modelOverride)
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	filter, err := diffFilterFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	contextArgs, err := contextLinesArgsFromRequest(request)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	// Get uncommitted changes
-	diffContent, err := getUncommittedChanges(ctx, validPath, stagedOnly)
+	diffContent, err := getUncommittedChanges(ctx, validPath, stagedOnly, baseRef, filter, contextArgs...)
+	if errors.Is(err, errNoMatchingFiles) {
+		return mcp.NewToolResultText(noMatchingFilesMessage), nil
+	}
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	if diffContent == "" {
+		if baseRef != "" {
+			return mcp.NewToolResultText(fmt.Sprintf("No changes found since %s.", baseRef)), nil
+		}
 		return mcp.NewToolResultText("No uncommitted changes found."), nil
 	}
 
+	// Renames and reformatting need no review
+	if !forceRequested(request) {
+		if note := trivialDiffNote(diffContent); note != "" {
+			return mcp.NewToolResultText(note), nil
+		}
+	}
+
+	// Blame the removed lines as of the commit the diff is taken against
+	if blame, ok := request.GetArguments()["include_blame"].(bool); ok && blame {
+		rev := "HEAD"
+		if baseRef != "" {
+			if rev, err = getMergeBase(ctx, validPath, baseRef); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		diffContent = withBlame(ctx, validPath, rev, diffContent)
+	}
+
 	// Create prompt for LLM analysis
 	prompt := llm.AnalysisPrompt("uncommitted_work", diffContent, map[string]any{
 		"staged_only": stagedOnly,
+		"base_ref":    baseRef,
 	})
 
 	// Get analysis from LLM using optimization
@@ -338,16 +551,31 @@ func handleAnalyzeUncommittedWork(ctx context.Context, request mcp.CallToolReque
 		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(analysis), nil
+	return mcp.NewToolResultText(limitResponse(analysis)), nil
 }
 
-func getUncommittedChanges(ctx context.Context, repoPath string, stagedOnly bool) (string, error) {
+// getUncommittedChanges describes the working tree's changes and their diff, passing diffArgs such as
+// -U10 to git diff
+func getUncommittedChanges(ctx context.Context, repoPath string, stagedOnly bool, baseRef string, filter *DiffFilter, diffArgs ...string) (string, error) {
 	var info strings.Builder
 
+	// Diff against the fork point with baseRef when given, otherwise HEAD
+	diffBase := "HEAD"
+	if baseRef != "" {
+		mergeBase, err := getMergeBase(ctx, repoPath, baseRef)
+		if err != nil {
+			return "", err
+		}
+		diffBase = mergeBase
+	}
+
 	// Add header
-	if stagedOnly {
+	switch {
+	case baseRef != "":
+		info.WriteString(fmt.Sprintf("🌿 Changes Since %s\n\n", baseRef))
+	case stagedOnly:
 		info.WriteString("📋 Staged Changes Analysis\n\n")
-	} else {
+	default:
 		info.WriteString("📝 Uncommitted Work Analysis\n\n")
 	}
 
@@ -358,13 +586,16 @@ func getUncommittedChanges(ctx context.Context, repoPath string, stagedOnly bool
 		return "", fmt.Errorf("failed to get git status: %v", err)
 	}
 
-	if len(statusOutput) == 0 {
+	// Committed changes since baseRef can exist with a clean working tree
+	if len(statusOutput) == 0 && baseRef == "" {
 		return "", nil
 	}
 
-	info.WriteString("Files changed:\n")
-	info.WriteString(string(statusOutput))
-	info.WriteString("\n")
+	if len(statusOutput) > 0 {
+		info.WriteString("Files changed:\n")
+		info.WriteString(string(statusOutput))
+		info.WriteString("\n")
+	}
 
 	// Get diff using safe memory-limited approach
 	memConfig := &cfg.Memory
@@ -372,10 +603,14 @@ func getUncommittedChanges(ctx context.Context, repoPath string, stagedOnly bool
 
 	if stagedOnly {
 		// Get only staged changes
-		truncatedDiff, err = getGitDiffSafe(ctx, repoPath, memConfig, "--cached")
+		if baseRef != "" {
+			truncatedDiff, err = getGitDiffSafe(ctx, repoPath, memConfig, filter, append(diffArgs, "--cached", diffBase)...)
+		} else {
+			truncatedDiff, err = getGitDiffSafe(ctx, repoPath, memConfig, filter, append(diffArgs, "--cached")...)
+		}
 	} else {
 		// Get all changes (staged and unstaged)
-		truncatedDiff, err = getGitDiffSafe(ctx, repoPath, memConfig, "HEAD")
+		truncatedDiff, err = getGitDiffSafe(ctx, repoPath, memConfig, filter, append(diffArgs, diffBase)...)
 	}
 
 	if err != nil {
@@ -383,8 +618,8 @@ func getUncommittedChanges(ctx context.Context, repoPath string, stagedOnly bool
 	}
 
 	// If no diff from HEAD, try to get staged changes
-	if truncatedDiff.Content == "" && !stagedOnly {
-		stagedDiff, err := getGitDiffSafe(ctx, repoPath, memConfig, "--cached")
+	if truncatedDiff.Content == "" && !stagedOnly && baseRef == "" {
+		stagedDiff, err := getGitDiffSafe(ctx, repoPath, memConfig, filter, append(diffArgs, "--cached")...)
 		if err != nil {
 			// Log the error but continue since we might have unstaged changes
 			info.WriteString(fmt.Sprintf("\nNote: Failed to get staged changes: %v\n", err))
@@ -393,30 +628,49 @@ func getUncommittedChanges(ctx context.Context, repoPath string, stagedOnly bool
 		}
 	}
 
+	// Every changed file was filtered out, so there is nothing to review
+	if truncatedDiff.Content == "" && truncatedDiff.FilesFiltered > 0 && !truncatedDiff.IsTruncated {
+		return "", errNoMatchingFiles
+	}
+
+	if baseRef != "" && truncatedDiff.Content == "" && !truncatedDiff.IsTruncated {
+		return "", nil
+	}
+
 	if truncatedDiff.Content != "" {
 		// Add warning if truncated
 		if truncatedDiff.IsTruncated {
 			info.WriteString(fmt.Sprintf("\n⚠️ WARNING: %s\n", truncatedDiff.WarningReason))
 			info.WriteString(fmt.Sprintf("Total size: %dKB, Files: %d\n\n", truncatedDiff.TotalSizeKB, truncatedDiff.FileCount))
 		}
+		if note := filteredFilesNote(truncatedDiff.FilesFiltered); note != "" {
+			info.WriteString("\n" + note + "\n")
+		}
 
 		info.WriteString("Diff:\n")
 		info.WriteString(truncatedDiff.Content)
 	}
 
-	// Get statistics
-	var statCmd *exec.Cmd
-	if stagedOnly {
-		statCmd = exec.CommandContext(ctx, "git", "-C", repoPath, "diff", "--cached", "--stat")
-	} else {
-		statCmd = exec.CommandContext(ctx, "git", "-C", repoPath, "diff", "HEAD", "--stat")
-	}
-
-	statOutput, _ := statCmd.Output()
-	if len(statOutput) > 0 {
+	// Statistics come from the numstat already run to size-check the diff
+	if stats := truncatedDiff.Stats; stats != nil && stats.FileCount > 0 {
 		info.WriteString("\n\nStatistics:\n")
-		info.WriteString(string(statOutput))
+		info.WriteString(fmt.Sprintf(" %d file(s) changed, %d insertion(s)(+), %d deletion(s)(-)\n", stats.FileCount, stats.Insertions, stats.Deletions))
 	}
 
 	return info.String(), nil
 }
+
+// getMergeBase returns the commit where HEAD forked from ref
+func getMergeBase(ctx context.Context, repoPath, ref string) (string, error) {
+	if !refExists(ctx, repoPath, ref) {
+		return "", fmt.Errorf("base_ref '%s' does not exist in the repository", ref)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "merge-base", ref, "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge base with %s: %v", ref, err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultHealthCheckTimeout bounds each individual provider check
+const defaultHealthCheckTimeout = 30 * time.Second
+
+// healthCheckPrompt is the minimal prompt used for providers without a health endpoint
+const healthCheckPrompt = "Reply with the single word: OK"
+
+// ProviderStatus holds the result of a single provider health check
+type ProviderStatus struct {
+	Provider  string
+	Model     string
+	Reachable bool
+	Latency   time.Duration
+	Error     string
+}
+
+// configuredProviders returns the providers that have the credentials or endpoint they need
+func configuredProviders() []string {
+	return enabledProviders(cfg)
+}
+
+func handleCheckProviders(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	timeout := defaultHealthCheckTimeout
+	if secs, ok := request.GetArguments()["timeout_seconds"].(float64); ok && secs > 0 {
+		timeout = time.Duration(secs * float64(time.Second))
+	}
+
+	providers := configuredProviders()
+	if len(providers) == 0 {
+		return mcp.NewToolResultText("No providers are configured."), nil
+	}
+
+	statuses := checkProviders(ctx, providers, timeout)
+
+	return mcp.NewToolResultText(formatProviderStatuses(statuses)), nil
+}
+
+// checkProviders checks every provider concurrently, returning results in input order
+func checkProviders(ctx context.Context, providers []string, timeout time.Duration) []ProviderStatus {
+	statuses := make([]ProviderStatus, len(providers))
+
+	var wg sync.WaitGroup
+	for i, name := range providers {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			statuses[i] = checkProvider(ctx, name, timeout)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+// checkProvider checks a single provider, preferring its health endpoint when available
+func checkProvider(ctx context.Context, providerName string, timeout time.Duration) ProviderStatus {
+	_, model, _ := cfg.GetProviderConfig(providerName)
+	status := ProviderStatus{
+		Provider: providerName,
+		Model:    model,
+	}
+
+	provider, err := getOrCreateProvider(providerName, "")
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if checker, ok := llm.BaseProvider(provider).(llm.HealthChecker); ok {
+		err = checker.HealthCheck(checkCtx)
+	} else {
+		_, err = provider.Analyze(checkCtx, healthCheckPrompt)
+	}
+	status.Latency = time.Since(start)
+
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Reachable = true
+	return status
+}
+
+// formatProviderStatuses renders health check results for the tool response
+func formatProviderStatuses(statuses []ProviderStatus) string {
+	var out strings.Builder
+	out.WriteString("🩺 Provider Health:\n\n")
+
+	healthy := 0
+	for _, status := range statuses {
+		if status.Reachable {
+			healthy++
+			out.WriteString(fmt.Sprintf("✅ %s (%s): reachable in %dms\n",
+				status.Provider, status.Model, status.Latency.Milliseconds()))
+			continue
+		}
+		out.WriteString(fmt.Sprintf("❌ %s (%s): %s\n", status.Provider, status.Model, status.Error))
+	}
+
+	out.WriteString(fmt.Sprintf("\n%d of %d providers reachable\n", healthy, len(statuses)))
+	return out.String()
+}
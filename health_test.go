@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// blockingProvider never answers until its context is done
+type blockingProvider struct {
+	name string
+}
+
+func (b *blockingProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (b *blockingProvider) Name() string {
+	return b.name
+}
+
+func (b *blockingProvider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{SupportsTemperature: true}
+}
+
+func TestHandleCheckProviders(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("unexpected Ollama path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"models":[]}`))
+	}))
+	defer ollama.Close()
+
+	cfg = &config.Config{DefaultProvider: "openai"}
+	cfg.OpenAI.APIKey = "test-key"
+	cfg.OpenAI.Model = "gpt-4o-mini"
+	cfg.Mistral.APIKey = "test-key"
+	cfg.Mistral.Model = "mistral-small-latest"
+	cfg.Google.APIKey = "test-key"
+	cfg.Google.Model = "gemini-2.0-flash-exp"
+	cfg.Ollama.Endpoint = ollama.URL
+	cfg.Ollama.Model = "devstral:latest"
+
+	llmProviders = map[string]llm.Provider{
+		"openai":  &MockProvider{name: "openai", response: "OK"},
+		"mistral": &MockProvider{name: "mistral", err: errors.New("invalid API key")},
+		"google":  &blockingProvider{name: "google"},
+	}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Name: "check_providers",
+			Arguments: map[string]any{
+				"timeout_seconds": 0.2,
+			},
+		},
+	}
+
+	start := time.Now()
+	result, err := handleCheckProviders(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("one failing provider must not fail the call: %v", result.Content)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("checks did not run in parallel with timeout, took %v", elapsed)
+	}
+
+	response := getTextResponseMock(result)
+	for _, want := range []string{
+		"✅ openai (gpt-4o-mini)",
+		"✅ ollama (devstral:latest)",
+		"❌ mistral (mistral-small-latest): invalid API key",
+		"❌ google (gemini-2.0-flash-exp): context deadline exceeded",
+		"2 of 4 providers reachable",
+	} {
+		if !strings.Contains(response, want) {
+			t.Errorf("response missing %q:\n%s", want, response)
+		}
+	}
+}
+
+func TestCheckProvidersRespectsCancellation(t *testing.T) {
+	originalProviders := llmProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{DefaultProvider: "openai"}
+	llmProviders = map[string]llm.Provider{
+		"openai":  &blockingProvider{name: "openai"},
+		"mistral": &blockingProvider{name: "mistral"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	statuses := checkProviders(ctx, []string{"openai", "mistral"}, time.Minute)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("checks ignored cancellation, took %v", elapsed)
+	}
+
+	for _, status := range statuses {
+		if status.Reachable {
+			t.Errorf("%s reported reachable after cancellation", status.Provider)
+		}
+		if !strings.Contains(status.Error, "context canceled") {
+			t.Errorf("%s error = %q, want context canceled", status.Provider, status.Error)
+		}
+	}
+}
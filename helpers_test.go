@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dshills/second-opinion/llm"
+)
+
+// newTestRepo creates a temporary git repository with a single initial commit
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet", "--initial-branch=main")
+	writeTestFile(t, dir, "README.md", "# test repo\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "Initial commit")
+	return dir
+}
+
+// runGit runs a git command in dir with a fixed identity and returns its output
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test User",
+		"GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test User",
+		"GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+	return string(output)
+}
+
+// writeTestFile writes content to a file relative to dir, creating parent directories
+func writeTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+}
+
+// countingProvider records prompts and returns a canned response
+type countingProvider struct {
+	name    string
+	calls   int
+	prompts []string
+}
+
+func (c *countingProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	c.calls++
+	c.prompts = append(c.prompts, prompt)
+	return fmt.Sprintf("Summary %d", c.calls), nil
+}
+
+func (c *countingProvider) Name() string {
+	return c.name
+}
+
+func (c *countingProvider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{SupportsTemperature: true}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// withHTTPGuard protects the http transport from web pages and unauthorized clients. Requests
+// from a browser must come from a localhost origin or one listed in server_allowed_origins, so a
+// page the user visits cannot drive the tools through their browser; requests without an Origin
+// header come from ordinary MCP clients. When server_auth_token is set every request must also
+// carry it as a bearer token.
+func withHTTPGuard(conf *config.Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !allowedOrigin(conf, origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if conf.ServerAuthToken != "" && !validBearerToken(r, conf.ServerAuthToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="second-opinion"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedOrigin reports whether a browser origin may call the server
+func allowedOrigin(conf *config.Config, origin string) bool {
+	if slices.ContainsFunc(conf.ServerAllowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimRight(strings.TrimSpace(allowed), "/"), origin)
+	}) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return config.IsLoopbackHost(u.Hostname())
+}
+
+// validBearerToken reports whether the request carries token as its bearer token
+func validBearerToken(r *http.Request, token string) bool {
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(value)), []byte(token)) == 1
+}
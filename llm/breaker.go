@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting a provider while its circuit is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitState is the state of a provider's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests fast until the cooldown elapses
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through to test recovery
+	CircuitHalfOpen
+)
+
+// String returns the state name
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops sending requests to a provider after repeated failures
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int           // consecutive failures that open the circuit
+	window    time.Duration // failures further apart than this start a new streak
+	cooldown  time.Duration // how long the circuit stays open before a probe
+
+	state        CircuitState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+	now          func() time.Time
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive failures within window
+// and allows a probe request once cooldown has elapsed
+func NewCircuitBreaker(threshold int, window, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// State returns the current state, moving an open circuit to half-open once its cooldown has elapsed
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advanceLocked()
+	return b.state
+}
+
+// advanceLocked moves an open circuit to half-open once its cooldown has elapsed
+func (b *CircuitBreaker) advanceLocked() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = CircuitHalfOpen
+		b.probing = false
+	}
+}
+
+// Allow reports whether a request may proceed, returning ErrCircuitOpen when it may not
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advanceLocked()
+
+	switch b.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		// Only one probe at a time; everyone else keeps failing fast until it reports back
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record reports the outcome of a request allowed by Allow
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// A caller giving up, or a request rejected on its merits or for bad credentials, says nothing
+	// about the provider's health
+	if err != nil && !isProviderFailure(err) {
+		if b.state == CircuitHalfOpen {
+			b.probing = false
+		}
+		return
+	}
+
+	now := b.now()
+	if err == nil {
+		b.state = CircuitClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	if b.state == CircuitHalfOpen {
+		b.open(now)
+		return
+	}
+
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.window {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.open(now)
+	}
+}
+
+// isProviderFailure reports whether err means the provider itself is struggling: a server error,
+// throttling, a timeout, or a network failure. Errors such as a rejected key or an oversized prompt
+// would recur on a healthy provider, so they don't count toward opening the circuit.
+func isProviderFailure(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	return errors.Is(err, ErrServer) || errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, context.DeadlineExceeded) || IsRetryableError(err)
+}
+
+// open trips the circuit; b.mu must be held
+func (b *CircuitBreaker) open(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.failures = 0
+	b.probing = false
+}
+
+// circuitBreakerSettings configures the breakers created for each provider; a threshold of zero disables them
+type circuitBreakerSettings struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+}
+
+// circuitBreakers holds the breaker for each provider and model, created on first use
+var (
+	circuitBreakers    = make(map[string]*CircuitBreaker)
+	breakerSettings    circuitBreakerSettings
+	circuitBreakersMux sync.Mutex
+)
+
+// SetCircuitBreakers configures per-model circuit breakers, resetting any existing state.
+// A threshold of zero or less disables them.
+func SetCircuitBreakers(threshold int, window, cooldown time.Duration) {
+	circuitBreakersMux.Lock()
+	defer circuitBreakersMux.Unlock()
+	breakerSettings = circuitBreakerSettings{threshold: threshold, window: window, cooldown: cooldown}
+	circuitBreakers = make(map[string]*CircuitBreaker)
+}
+
+// GetCircuitBreaker returns the breaker for a provider's model, or nil if circuit breaking is
+// disabled. Each model has its own, so one overloaded model doesn't block the provider's others.
+func GetCircuitBreaker(provider, model string) *CircuitBreaker {
+	circuitBreakersMux.Lock()
+	defer circuitBreakersMux.Unlock()
+
+	if breakerSettings.threshold <= 0 {
+		return nil
+	}
+	key := breakerLabel(provider, model)
+	breaker, ok := circuitBreakers[key]
+	if !ok {
+		breaker = NewCircuitBreaker(breakerSettings.threshold, breakerSettings.window, breakerSettings.cooldown)
+		circuitBreakers[key] = breaker
+	}
+	return breaker
+}
+
+// breakerLabel returns "provider/model", or just the provider when the model is unknown
+func breakerLabel(provider, model string) string {
+	if model == "" {
+		return provider
+	}
+	return provider + "/" + model
+}
+
+// callWithCircuitBreaker runs call unless the model's circuit is open, recording its outcome
+func callWithCircuitBreaker(provider, model string, call func() (string, error)) (string, error) {
+	breaker := GetCircuitBreaker(provider, model)
+	if breaker == nil {
+		return call()
+	}
+
+	if err := breaker.Allow(); err != nil {
+		return "", fmt.Errorf("%s is failing repeatedly, skipping the request: %w", breakerLabel(provider, model), err)
+	}
+	result, err := call()
+	breaker.Record(err)
+	return result, err
+}
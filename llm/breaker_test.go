@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// newTestBreaker returns a breaker driven by a fake clock
+func newTestBreaker(threshold int, window, cooldown time.Duration) (*CircuitBreaker, *time.Time) {
+	now := time.Unix(0, 0)
+	breaker := NewCircuitBreaker(threshold, window, cooldown)
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	breaker, now := newTestBreaker(3, time.Minute, 30*time.Second)
+	failure := fmt.Errorf("503 service unavailable: %w", ErrServer)
+
+	// Closed: failures below the threshold keep the circuit closed
+	for i := 0; i < 2; i++ {
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("Allow() in closed state: %v", err)
+		}
+		breaker.Record(failure)
+	}
+	if got := breaker.State(); got != CircuitClosed {
+		t.Fatalf("state after 2 failures = %v, want closed", got)
+	}
+
+	// Open: the third consecutive failure trips it and requests fail fast
+	breaker.Record(failure)
+	if got := breaker.State(); got != CircuitOpen {
+		t.Fatalf("state after 3 failures = %v, want open", got)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() while open = %v, want ErrCircuitOpen", err)
+	}
+
+	// Half-open: after the cooldown a single probe is let through
+	*now = now.Add(30 * time.Second)
+	if got := breaker.State(); got != CircuitHalfOpen {
+		t.Fatalf("state after cooldown = %v, want half-open", got)
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("probe Allow() = %v, want nil", err)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second Allow() during probe = %v, want ErrCircuitOpen", err)
+	}
+
+	// A failed probe reopens the circuit for another cooldown
+	breaker.Record(failure)
+	if got := breaker.State(); got != CircuitOpen {
+		t.Fatalf("state after failed probe = %v, want open", got)
+	}
+
+	// A successful probe closes it again
+	*now = now.Add(30 * time.Second)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("probe Allow() = %v, want nil", err)
+	}
+	breaker.Record(nil)
+	if got := breaker.State(); got != CircuitClosed {
+		t.Fatalf("state after successful probe = %v, want closed", got)
+	}
+}
+
+func TestCircuitBreakerWindowAndCancellation(t *testing.T) {
+	breaker, now := newTestBreaker(2, time.Minute, time.Minute)
+
+	failure := fmt.Errorf("HTTP 429: %w", ErrRateLimited)
+
+	// Failures further apart than the window do not accumulate
+	breaker.Record(failure)
+	*now = now.Add(2 * time.Minute)
+	breaker.Record(failure)
+	if got := breaker.State(); got != CircuitClosed {
+		t.Fatalf("state after spread-out failures = %v, want closed", got)
+	}
+
+	// Cancellations by the caller are not provider failures
+	breaker.Record(context.Canceled)
+	if got := breaker.State(); got != CircuitClosed {
+		t.Fatalf("state after cancellation = %v, want closed", got)
+	}
+
+	// A success resets the streak
+	breaker.Record(nil)
+	breaker.Record(failure)
+	if got := breaker.State(); got != CircuitClosed {
+		t.Fatalf("state after success then failure = %v, want closed", got)
+	}
+}
+
+func TestOptimizedProviderCircuitBreaker(t *testing.T) {
+	SetCircuitBreakers(2, time.Minute, time.Hour)
+	defer SetCircuitBreakers(0, 0, 0)
+
+	mock := NewMockProvider("breaker-test")
+	mock.Error = fmt.Errorf("upstream down: %w", ErrServer)
+	provider := NewOptimizedProvider(mock, &config.Config{
+		Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, ChunkSizeMB: 1},
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.AnalyzeOptimized(context.Background(), "prompt", 6, config.TaskCodeReview); err == nil {
+			t.Fatal("expected the provider error")
+		}
+	}
+	if got := GetCircuitBreaker("breaker-test", "").State(); got != CircuitOpen {
+		t.Fatalf("state = %v, want open", got)
+	}
+
+	// The open circuit fails fast without calling the provider
+	_, err := provider.AnalyzeOptimized(context.Background(), "prompt", 6, config.TaskCodeReview)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("error = %v, want ErrCircuitOpen", err)
+	}
+	if mock.CalledCount != 2 {
+		t.Errorf("provider called %d times, want 2", mock.CalledCount)
+	}
+
+	if GetCircuitBreaker("other", "") == nil {
+		t.Error("each provider should get its own breaker")
+	}
+
+	// Another model of the same provider is unaffected
+	other := &modelMockProvider{MockProvider: NewMockProvider("breaker-test"), model: "other-model"}
+	if _, err := NewOptimizedProvider(other, &config.Config{
+		Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, ChunkSizeMB: 1},
+	}).AnalyzeOptimized(context.Background(), "prompt", 6, config.TaskCodeReview); err != nil {
+		t.Errorf("another model was blocked by the open circuit: %v", err)
+	}
+
+	SetCircuitBreakers(0, 0, 0)
+	if GetCircuitBreaker("breaker-test", "") != nil {
+		t.Error("a zero threshold should disable circuit breaking")
+	}
+}
+
+func TestCircuitBreakerCountsProviderFailures(t *testing.T) {
+	for _, err := range []error{
+		newAPIError("OpenAI API", 503, []byte("overloaded")),
+		newAPIError("OpenAI API", 429, []byte("slow down")),
+		context.DeadlineExceeded,
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")},
+	} {
+		breaker, _ := newTestBreaker(1, time.Minute, time.Minute)
+		breaker.Record(err)
+		if got := breaker.State(); got != CircuitOpen {
+			t.Errorf("state after %v = %v, want open", err, got)
+		}
+	}
+
+	// Errors that are neither, such as an unparseable response, leave it closed
+	breaker, _ := newTestBreaker(1, time.Minute, time.Minute)
+	breaker.Record(errors.New("failed to parse response"))
+	if got := breaker.State(); got != CircuitClosed {
+		t.Errorf("state after a parse error = %v, want closed", got)
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+func TestProviderCapabilities(t *testing.T) {
+	tests := []struct {
+		provider string
+		model    string
+		want     ProviderCapabilities
+	}{
+		{"openai", "gpt-4o", ProviderCapabilities{ContextWindow: 128000, SupportsTemperature: true, SupportsStreaming: false, SupportsJSON: true}},
+		{"openai", "o3-mini", ProviderCapabilities{ContextWindow: 200000, SupportsTemperature: false, SupportsStreaming: false, SupportsJSON: true}},
+		{"openai", "o4-mini", ProviderCapabilities{ContextWindow: 200000, SupportsTemperature: false, SupportsStreaming: false, SupportsJSON: true}},
+		{"google", "gemini-2.0-flash", ProviderCapabilities{ContextWindow: 1048576, SupportsTemperature: true, SupportsStreaming: true, SupportsJSON: true}},
+		{"mistral", "mistral-small-latest", ProviderCapabilities{ContextWindow: 32000, SupportsTemperature: true, SupportsStreaming: false, SupportsJSON: true}},
+		{"ollama", "devstral:latest", ProviderCapabilities{ContextWindow: 128000, SupportsTemperature: true, SupportsStreaming: true, SupportsJSON: true}},
+		{"ollama", "my-custom-model", ProviderCapabilities{ContextWindow: 0, SupportsTemperature: true, SupportsStreaming: true, SupportsJSON: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.model, func(t *testing.T) {
+			provider, err := NewProvider(Config{Provider: tt.provider, APIKey: "test-key", Model: tt.model})
+			if err != nil {
+				t.Fatalf("NewProvider failed: %v", err)
+			}
+			if got := provider.Capabilities(); got != tt.want {
+				t.Errorf("Capabilities() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("streaming matches StreamingProvider", func(t *testing.T) {
+		for _, name := range config.Providers {
+			provider, err := NewProvider(Config{Provider: name, APIKey: "test-key"})
+			if err != nil {
+				t.Fatalf("NewProvider(%s) failed: %v", name, err)
+			}
+			_, streams := provider.(StreamingProvider)
+			if got := provider.Capabilities().SupportsStreaming; got != streams {
+				t.Errorf("%s reports SupportsStreaming %v but implements StreamingProvider: %v", name, got, streams)
+			}
+		}
+	})
+}
+
+// paramsCapturingProvider records the request parameters and call options it receives
+type paramsCapturingProvider struct {
+	*MockProvider
+	capabilities ProviderCapabilities
+	params       requestParams
+	opts         CallOptions
+}
+
+func (p *paramsCapturingProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	p.params, _ = requestParamsFromContext(ctx)
+	p.opts = CallOptionsFromContext(ctx)
+	return p.MockProvider.Analyze(ctx, prompt)
+}
+
+func (p *paramsCapturingProvider) Capabilities() ProviderCapabilities {
+	return p.capabilities
+}
+
+func TestAnalyzeOptimizedSkipsUnsupportedParams(t *testing.T) {
+	cfg := &config.Config{
+		Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, ChunkSizeMB: 1},
+	}
+	ctx := WithCallOptions(context.Background(), CallOptions{JSONOutput: true})
+
+	t.Run("unsupported", func(t *testing.T) {
+		provider := &paramsCapturingProvider{MockProvider: NewMockProvider("ollama")}
+		if _, err := NewOptimizedProvider(provider, cfg).AnalyzeOptimized(ctx, "prompt", 6, config.TaskCodeReview); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if provider.params.Temperature != 0 {
+			t.Errorf("temperature = %v, want it dropped", provider.params.Temperature)
+		}
+		if provider.opts.JSONOutput {
+			t.Error("JSON output should be disabled for a provider without JSON support")
+		}
+	})
+
+	t.Run("supported", func(t *testing.T) {
+		provider := &paramsCapturingProvider{
+			MockProvider: NewMockProvider("ollama"),
+			capabilities: ProviderCapabilities{SupportsTemperature: true, SupportsJSON: true},
+		}
+		if _, err := NewOptimizedProvider(provider, cfg).AnalyzeOptimized(ctx, "prompt", 6, config.TaskCodeReview); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if provider.params.Temperature == 0 {
+			t.Error("temperature should be passed through")
+		}
+		if !provider.opts.JSONOutput {
+			t.Error("JSON output should be kept for a provider with JSON support")
+		}
+	})
+}
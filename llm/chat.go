@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Chat message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a conversation
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatProvider is implemented by providers that accept a multi-turn conversation, so a review
+// can be refined with follow-ups such as "now focus on the error handling you mentioned"
+type ChatProvider interface {
+	// Chat sends the conversation and returns the assistant's next reply. The system prompt for
+	// ctx is prepended unless the conversation already starts with a system message.
+	Chat(ctx context.Context, messages []Message) (string, error)
+}
+
+// ErrChatUnsupported is returned when a provider cannot hold a multi-turn conversation
+var ErrChatUnsupported = errors.New("provider does not support follow-up conversations")
+
+// errNoMessages is returned by Chat when the conversation is empty
+var errNoMessages = errors.New("chat requires at least one message")
+
+// validateMessages checks that a conversation is non-empty and uses known roles
+func validateMessages(messages []Message) error {
+	if len(messages) == 0 {
+		return errNoMessages
+	}
+	for i, message := range messages {
+		switch message.Role {
+		case RoleSystem, RoleUser, RoleAssistant:
+		default:
+			return fmt.Errorf("message %d has unsupported role %q (use system, user, or assistant)", i+1, message.Role)
+		}
+	}
+	return nil
+}
+
+// chatMessages returns messages preceded by the system prompt for ctx, unless they supply their own
+func chatMessages(ctx context.Context, messages []Message) []Message {
+	if messages[0].Role == RoleSystem {
+		return messages
+	}
+	return append([]Message{{Role: RoleSystem, Content: SystemPromptFor(ctx)}}, messages...)
+}
+
+// chatProviderOf returns the outermost provider in provider's middleware chain that accepts conversations
+func chatProviderOf(provider Provider) (ChatProvider, bool) {
+	for provider != nil {
+		if chat, ok := provider.(ChatProvider); ok {
+			return chat, true
+		}
+		unwrapper, ok := provider.(Unwrapper)
+		if !ok {
+			break
+		}
+		provider = unwrapper.Unwrap()
+	}
+	return nil, false
+}
+
+// conversationText joins a conversation's messages for size estimates
+func conversationText(messages []Message) string {
+	parts := make([]string, len(messages))
+	for i, message := range messages {
+		parts[i] = message.Content
+	}
+	return strings.Join(parts, "\n\n")
+}
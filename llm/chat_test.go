@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// conversation is a review followed by a follow-up question
+var conversation = []Message{
+	{Role: RoleUser, Content: "Review this function"},
+	{Role: RoleAssistant, Content: "The error from Close is ignored."},
+	{Role: RoleUser, Content: "Now focus on the error handling you mentioned"},
+}
+
+// decodeMessages extracts the messages array from a captured request body
+func decodeMessages(t *testing.T, body map[string]any) []Message {
+	t.Helper()
+
+	raw, err := json.Marshal(body["messages"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []Message
+	if err := json.Unmarshal(raw, &messages); err != nil {
+		t.Fatalf("messages is not an array of {role, content}: %v", err)
+	}
+	return messages
+}
+
+func TestOllamaProviderChat(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s, want /api/chat", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"message": {"role": "assistant", "content": "Check the Close error."}, "done": true}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(Config{Endpoint: server.URL, Model: "llama3.2", KeepAlive: "30m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := provider.Chat(context.Background(), conversation)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply != "Check the Close error." {
+		t.Errorf("reply = %q", reply)
+	}
+
+	if captured["model"] != "llama3.2" || captured["stream"] != false || captured["keep_alive"] != "30m" {
+		t.Errorf("unexpected request fields: %v", captured)
+	}
+	if _, ok := captured["options"].(map[string]any); !ok {
+		t.Errorf("expected sampling options, got %v", captured["options"])
+	}
+	if _, ok := captured["prompt"]; ok {
+		t.Error("chat requests should not carry a generate prompt")
+	}
+
+	messages := decodeMessages(t, captured)
+	want := append([]Message{{Role: RoleSystem, Content: SystemPromptFor(context.Background())}}, conversation...)
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("messages = %+v, want %+v", messages, want)
+	}
+}
+
+func TestOpenAICompatibleProvidersChat(t *testing.T) {
+	// Each constructor points the provider's HTTP client at the test server
+	newProviders := map[string]func(client *http.Client) ChatProvider{
+		"openai": func(client *http.Client) ChatProvider {
+			p, _ := NewOpenAIProvider(Config{APIKey: "test-key", Model: "gpt-4o-mini"})
+			p.httpClient = client
+			return p
+		},
+		"mistral": func(client *http.Client) ChatProvider {
+			p, _ := NewMistralProvider(Config{APIKey: "test-key", Model: "mistral-small-latest"})
+			p.httpClient = client
+			return p
+		},
+		"openrouter": func(client *http.Client) ChatProvider {
+			p, _ := NewOpenRouterProvider(Config{APIKey: "test-key", Model: "openai/gpt-4o-mini"})
+			p.httpClient = client
+			return p
+		},
+	}
+
+	for name, newProvider := range newProviders {
+		t.Run(name, func(t *testing.T) {
+			var captured map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Check the Close error."}, "finish_reason": "stop"}]}`))
+			}))
+			defer server.Close()
+
+			provider := newProvider(&http.Client{Transport: &testTransport{testServer: server}})
+
+			// A caller-supplied system message replaces the default one
+			history := append([]Message{{Role: RoleSystem, Content: "You are terse."}}, conversation...)
+			reply, err := provider.Chat(context.Background(), history)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if reply != "Check the Close error." {
+				t.Errorf("reply = %q", reply)
+			}
+			if messages := decodeMessages(t, captured); !reflect.DeepEqual(messages, history) {
+				t.Errorf("messages = %+v, want %+v", messages, history)
+			}
+		})
+	}
+}
+
+func TestChatValidatesMessages(t *testing.T) {
+	provider, err := NewOpenAIProvider(Config{APIKey: "test-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.Chat(context.Background(), nil); err == nil {
+		t.Error("expected an error for an empty conversation")
+	}
+	if _, err := provider.Chat(context.Background(), []Message{{Role: "tool", Content: "x"}}); err == nil {
+		t.Error("expected an error for an unknown role")
+	}
+}
@@ -0,0 +1,323 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// summaryFailingProvider succeeds for chunk prompts and fails for the final summary prompt
+type summaryFailingProvider struct {
+	err   error
+	calls int
+}
+
+func (p *summaryFailingProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	p.calls++
+	if strings.HasPrefix(prompt, "Provide a comprehensive summary") {
+		return "", p.err
+	}
+	return "chunk ok", nil
+}
+
+func (p *summaryFailingProvider) Name() string {
+	return "mock"
+}
+
+func (p *summaryFailingProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{SupportsTemperature: true}
+}
+
+func TestAnalyzeInChunksSummaryFailure(t *testing.T) {
+	cfg := &config.Config{}
+	prompt := strings.Repeat("line of diff content\n", 20)
+
+	t.Run("provider error is surfaced as a warning", func(t *testing.T) {
+		provider := &summaryFailingProvider{err: errors.New("401 unauthorized")}
+		w := NewOptimizedProvider(provider, cfg).(*optimizedProviderWrapper)
+
+		result, err := w.analyzeInChunks(context.Background(), prompt, 100, 1000, 0.2, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(result, "## Part 1 Analysis\nchunk ok") {
+			t.Errorf("missing part analyses:\n%s", result)
+		}
+		if !strings.Contains(result, "Overall Summary Unavailable") || !strings.Contains(result, "401 unauthorized") {
+			t.Errorf("missing summary failure warning:\n%s", result)
+		}
+	})
+
+	t.Run("cancellation is propagated", func(t *testing.T) {
+		provider := &summaryFailingProvider{err: context.Canceled}
+		w := NewOptimizedProvider(provider, cfg).(*optimizedProviderWrapper)
+
+		result, err := w.analyzeInChunks(context.Background(), prompt, 100, 1000, 0.2, nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("error = %v, want context.Canceled", err)
+		}
+		if result != "" {
+			t.Errorf("expected no partial output, got:\n%s", result)
+		}
+	})
+}
+
+// scriptedProvider returns its responses in order and records every prompt
+type scriptedProvider struct {
+	responses []string
+	prompts   []string
+}
+
+func (p *scriptedProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	p.prompts = append(p.prompts, prompt)
+	if len(p.prompts) > len(p.responses) {
+		return "summary", nil
+	}
+	return p.responses[len(p.prompts)-1], nil
+}
+
+func (p *scriptedProvider) Name() string {
+	return "mock"
+}
+
+func (p *scriptedProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{SupportsTemperature: true, SupportsJSON: true}
+}
+
+func TestAnalyzeInChunksDedupFindings(t *testing.T) {
+	prompt := strings.Repeat("line of diff content\n", 10) // 210 bytes, two chunks of 120
+	chunkResponses := []string{
+		`{"summary": "Part one.", "findings": [
+			{"severity": "medium", "category": "correctness", "title": "Missing error handling", "description": "Errors returned by the call are ignored"},
+			{"severity": "low", "category": "style", "title": "Long function", "description": "Function is too long"}
+		]}`,
+		`{"summary": "Part two.", "findings": [
+			{"severity": "high", "category": "correctness", "title": "Missing error handling", "description": "Errors returned by the call are silently ignored"}
+		]}`,
+	}
+	jsonCtx := WithCallOptions(context.Background(), CallOptions{JSONOutput: true})
+
+	t.Run("json findings are merged", func(t *testing.T) {
+		provider := &scriptedProvider{responses: chunkResponses}
+		w := NewOptimizedProvider(provider, &config.Config{}).(*optimizedProviderWrapper)
+
+		result, err := w.analyzeInChunks(jsonCtx, prompt, 120, 1000, 0.2, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(provider.prompts) != 2 {
+			t.Errorf("expected only the two chunk requests, got %d", len(provider.prompts))
+		}
+
+		merged, err := ParseFindings(result)
+		if err != nil {
+			t.Fatalf("merged output is not findings JSON: %v\n%s", err, result)
+		}
+		if len(merged.Findings) != 2 {
+			t.Fatalf("expected duplicates to collapse to 2 findings, got %d: %+v", len(merged.Findings), merged.Findings)
+		}
+		if merged.Findings[0].Severity != SeverityHigh {
+			t.Errorf("merged severity = %s, want the highest (high)", merged.Findings[0].Severity)
+		}
+		if merged.Summary != "Part one. Part two." {
+			t.Errorf("summary = %q", merged.Summary)
+		}
+	})
+
+	t.Run("text mode asks the summary to deduplicate", func(t *testing.T) {
+		provider := &scriptedProvider{responses: []string{"part one", "part two"}}
+		w := NewOptimizedProvider(provider, &config.Config{}).(*optimizedProviderWrapper)
+
+		if _, err := w.analyzeInChunks(context.Background(), prompt, 120, 1000, 0.2, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary := provider.prompts[len(provider.prompts)-1]; !strings.Contains(summary, "list each distinct issue only once") {
+			t.Errorf("summary prompt missing dedup instruction:\n%s", summary)
+		}
+	})
+
+	t.Run("disabled keeps the summary path", func(t *testing.T) {
+		disabled := false
+		provider := &scriptedProvider{responses: chunkResponses}
+		w := NewOptimizedProvider(provider, &config.Config{DedupFindings: &disabled}).(*optimizedProviderWrapper)
+
+		if _, err := w.analyzeInChunks(jsonCtx, prompt, 120, 1000, 0.2, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(provider.prompts) != 3 {
+			t.Fatalf("expected a summary request, got %d requests", len(provider.prompts))
+		}
+		if strings.Contains(provider.prompts[2], "list each distinct issue only once") {
+			t.Error("summary prompt should not ask for deduplication when disabled")
+		}
+	})
+}
+
+// slowProvider answers each call after the next delay in delays, or fails when ctx ends first
+type slowProvider struct {
+	delays []time.Duration
+	calls  int
+}
+
+func (p *slowProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	delay := p.delays[min(p.calls, len(p.delays)-1)]
+	p.calls++
+	select {
+	case <-time.After(delay):
+		return fmt.Sprintf("part %d ok", p.calls), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (p *slowProvider) Name() string {
+	return "mock"
+}
+
+func (p *slowProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{SupportsTemperature: true}
+}
+
+func TestAnalyzeInChunksTimeBudget(t *testing.T) {
+	prompt := strings.Repeat("line of diff content\n", 20)
+
+	t.Run("slow chunk returns completed parts", func(t *testing.T) {
+		// The first chunk is quick; the second would take far longer than the whole deadline
+		provider := &slowProvider{delays: []time.Duration{time.Millisecond, 10 * time.Second}}
+		w := NewOptimizedProvider(provider, &config.Config{}).(*optimizedProviderWrapper)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		result, err := w.analyzeInChunks(ctx, prompt, 100, 1000, 0.2, nil)
+		if err != nil {
+			t.Fatalf("expected partial results, got error: %v", err)
+		}
+		if !strings.Contains(result, "## Part 1 Analysis\npart 1 ok") {
+			t.Errorf("missing completed part:\n%s", result)
+		}
+		if !strings.Contains(result, "stopped early due to time budget: only 1 of") {
+			t.Errorf("missing time budget note:\n%s", result)
+		}
+		if strings.Contains(result, "## Part 2 Analysis") {
+			t.Errorf("unfinished part should not be reported:\n%s", result)
+		}
+		// The second chunk only gets its share, leaving time before the overall deadline
+		if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+			t.Errorf("analysis took %v, want it to stop within the deadline", elapsed)
+		}
+	})
+
+	t.Run("no completed parts is an error", func(t *testing.T) {
+		provider := &slowProvider{delays: []time.Duration{10 * time.Second}}
+		w := NewOptimizedProvider(provider, &config.Config{}).(*optimizedProviderWrapper)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := w.analyzeInChunks(ctx, prompt, 100, 1000, 0.2, nil)
+		if err == nil || !strings.Contains(err.Error(), "stopped early due to time budget") {
+			t.Errorf("error = %v, want a time budget error", err)
+		}
+	})
+
+	t.Run("no deadline leaves chunks unbounded", func(t *testing.T) {
+		ctx, cancel := chunkContext(context.Background(), 3)
+		defer cancel()
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("chunkContext() added a deadline to a context without one")
+		}
+	})
+}
+
+func TestEstimateFileCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"git headers", "diff --git a/a.go b/a.go\n+a\ndiff --git a/b.go b/b.go\n+b\n", 2},
+		{"quoted headers are not counted", "diff --git a/notes.md b/notes.md\n+Run `diff --git` to compare.\n+ diff --git a/x b/x\n", 1},
+		{"plain unified diff", "--- a.go\n+++ a.go\n+x\n--- b.go\n+++ b.go\n+y\n", 2},
+		{"no headers", "func main() {}\n", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateFileCount(tt.content); got != tt.want {
+				t.Errorf("estimateFileCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSmallPromptIsNotChunked(t *testing.T) {
+	// A small diff of a test fixture full of diff headers, under a file limit that would otherwise trip
+	cfg := &config.Config{Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 2, ChunkSizeMB: 1}}
+	var diff strings.Builder
+	for i := range 3 {
+		diff.WriteString(fmt.Sprintf("diff --git a/file%d.go b/file%d.go\n+func F%d() {}\n", i, i, i))
+	}
+	diff.WriteString("diff --git a/testdata/fixture.txt b/testdata/fixture.txt\n")
+	for i := range 50 {
+		diff.WriteString(fmt.Sprintf("+diff --git a/quoted%d b/quoted%d\n", i, i))
+	}
+
+	provider := NewMockProvider("mock")
+	w := NewOptimizedProvider(provider, cfg)
+	if _, err := w.AnalyzeOptimized(context.Background(), diff.String(), diff.Len(), config.TaskDiffAnalysis); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if provider.CalledCount != 1 {
+		t.Errorf("provider called %d times, want 1 unchunked request", provider.CalledCount)
+	}
+}
+
+// streamRecordingProvider streams its response to the context's stream callback, recording which prompts streamed
+type streamRecordingProvider struct {
+	streamed []string
+}
+
+func (p *streamRecordingProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	if onChunk := StreamCallbackFromContext(ctx); onChunk != nil {
+		p.streamed = append(p.streamed, prompt)
+		if err := onChunk("streamed"); err != nil {
+			return "", err
+		}
+	}
+	return "streamed", nil
+}
+
+func (p *streamRecordingProvider) Name() string {
+	return "mock"
+}
+
+func (p *streamRecordingProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{SupportsTemperature: true, SupportsStreaming: true}
+}
+
+func TestAnalyzeInChunksStreamsSummaryOnly(t *testing.T) {
+	provider := &streamRecordingProvider{}
+	w := NewOptimizedProvider(provider, &config.Config{}).(*optimizedProviderWrapper)
+
+	var chunks []string
+	ctx := WithStreamCallback(context.Background(), func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if _, err := w.analyzeInChunks(ctx, strings.Repeat("line of diff content\n", 20), 100, 1000, 0.2, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(provider.streamed) != 1 || !strings.HasPrefix(provider.streamed[0], "Provide a comprehensive summary") {
+		t.Errorf("streamed prompts = %q, want only the summary", provider.streamed)
+	}
+	if len(chunks) != 1 {
+		t.Errorf("callback received %d chunks, want 1", len(chunks))
+	}
+}
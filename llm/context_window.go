@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// ContextWindowError reports a prompt that likely exceeds the model's context window
+type ContextWindowError struct {
+	Model           string
+	EstimatedTokens int
+	ContextWindow   int
+	ReservedTokens  int // reserved for the response
+}
+
+func (e *ContextWindowError) Error() string {
+	return fmt.Sprintf("estimated %s tokens (plus %d reserved for the response) exceeds %s's %s window; reduce the input or set ignore_context_window to send anyway",
+		formatTokenCount(e.EstimatedTokens), e.ReservedTokens, e.Model, formatTokenCount(e.ContextWindow))
+}
+
+// Is lets errors.Is(err, ErrContextLength) match a request refused before it was sent
+func (e *ContextWindowError) Is(target error) bool { return target == ErrContextLength }
+
+// checkContextWindow returns a ContextWindowError if prompt plus maxTokens will not fit the model's window.
+// Models without a known window are not checked.
+func (w *optimizedProviderWrapper) checkContextWindow(prompt string, maxTokens int) error {
+	model := w.Model()
+	window := config.ModelContextWindow(model)
+	if window == 0 {
+		return nil
+	}
+
+	estimated := w.config.EstimateTokensForText(prompt)
+	if estimated+maxTokens <= window {
+		return nil
+	}
+
+	return &ContextWindowError{
+		Model:           model,
+		EstimatedTokens: estimated,
+		ContextWindow:   window,
+		ReservedTokens:  maxTokens,
+	}
+}
+
+// formatTokenCount renders token counts compactly, e.g. 128000 as "128k"
+func formatTokenCount(tokens int) string {
+	if tokens >= 1000 {
+		return fmt.Sprintf("%dk", tokens/1000)
+	}
+	return fmt.Sprintf("%d", tokens)
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// modelMockProvider is a MockProvider that reports a model name
+type modelMockProvider struct {
+	*MockProvider
+	model string
+}
+
+func (m *modelMockProvider) Model() string {
+	return m.model
+}
+
+func TestAnalyzeOptimizedContextWindow(t *testing.T) {
+	cfg := &config.Config{
+		Memory: config.MemoryConfig{
+			MaxDiffSizeMB: 10,
+			MaxFileCount:  1000,
+			ChunkSizeMB:   1,
+		},
+	}
+	// ~10k tokens, beyond gpt-4's 8k window
+	prompt := strings.Repeat("x", 40000)
+
+	t.Run("rejects oversized prompt", func(t *testing.T) {
+		mock := &modelMockProvider{MockProvider: NewMockProvider("openai"), model: "gpt-4"}
+		_, err := NewOptimizedProvider(mock, cfg).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview)
+
+		var windowErr *ContextWindowError
+		if !errors.As(err, &windowErr) {
+			t.Fatalf("expected ContextWindowError, got %v", err)
+		}
+		if windowErr.Model != "gpt-4" || windowErr.ContextWindow != 8192 || windowErr.EstimatedTokens != 10000 {
+			t.Errorf("unexpected error details: %+v", windowErr)
+		}
+		if !strings.Contains(err.Error(), "estimated 10k tokens") || !strings.Contains(err.Error(), "gpt-4's 8k window") {
+			t.Errorf("unexpected error message: %v", err)
+		}
+		if mock.CalledCount != 0 {
+			t.Errorf("provider was called %d times, want 0", mock.CalledCount)
+		}
+	})
+
+	t.Run("override sends anyway", func(t *testing.T) {
+		mock := &modelMockProvider{MockProvider: NewMockProvider("openai"), model: "gpt-4"}
+		ctx := WithCallOptions(context.Background(), CallOptions{IgnoreContextWindow: true})
+		if _, err := NewOptimizedProvider(mock, cfg).AnalyzeOptimized(ctx, prompt, len(prompt), config.TaskCodeReview); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if mock.CalledCount != 1 {
+			t.Errorf("provider was called %d times, want 1", mock.CalledCount)
+		}
+	})
+
+	t.Run("fits larger window", func(t *testing.T) {
+		mock := &modelMockProvider{MockProvider: NewMockProvider("openai"), model: "gpt-4o-mini"}
+		if _, err := NewOptimizedProvider(mock, cfg).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("unknown model is not checked", func(t *testing.T) {
+		mock := NewMockProvider("openai")
+		if _, err := NewOptimizedProvider(mock, cfg).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
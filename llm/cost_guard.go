@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// CostLimitError reports a request whose worst-case estimated cost exceeds max_cost_per_request_usd
+type CostLimitError struct {
+	Model        string
+	InputTokens  int
+	OutputTokens int // the response's max_tokens, so the estimate is an upper bound
+	EstimatedUSD float64
+	LimitUSD     float64
+}
+
+func (e *CostLimitError) Error() string {
+	return fmt.Sprintf("estimated cost of up to $%.2f (%s input tokens plus up to %s output tokens on %s) exceeds max_cost_per_request_usd of $%.2f; raise max_cost_per_request_usd (MAX_COST_PER_REQUEST_USD), review a smaller change, or lower memory.chunk_size_mb so it is sent in smaller chunks",
+		e.EstimatedUSD, formatTokenCount(e.InputTokens), formatTokenCount(e.OutputTokens), e.Model, e.LimitUSD)
+}
+
+// checkCost returns a CostLimitError if prompt, with a response of maxTokens, could cost more than
+// the configured limit. Without a limit or a known price for the model nothing is checked.
+func (w *optimizedProviderWrapper) checkCost(prompt string, maxTokens int) error {
+	limit := w.config.MaxCostPerRequestUSD
+	if limit <= 0 {
+		return nil
+	}
+
+	model := w.Model()
+	price, ok := config.ModelPricing(model)
+	if !ok {
+		return nil
+	}
+
+	inputTokens := w.config.EstimateTokensForText(prompt)
+	estimated := price.Cost(inputTokens, maxTokens)
+	if estimated <= limit {
+		return nil
+	}
+
+	return &CostLimitError{
+		Model:        model,
+		InputTokens:  inputTokens,
+		OutputTokens: maxTokens,
+		EstimatedUSD: estimated,
+		LimitUSD:     limit,
+	}
+}
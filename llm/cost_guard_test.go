@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+func TestAnalyzeOptimizedCostLimit(t *testing.T) {
+	cfg := &config.Config{
+		MaxCostPerRequestUSD: 0.10,
+		Memory: config.MemoryConfig{
+			MaxDiffSizeMB: 10,
+			MaxFileCount:  1000,
+			ChunkSizeMB:   1,
+		},
+	}
+	// ~10k tokens: about $0.15 of input on o1, well under a cent on gpt-4o-mini
+	prompt := strings.Repeat("x", 40000)
+
+	t.Run("expensive model is refused", func(t *testing.T) {
+		mock := &modelMockProvider{MockProvider: NewMockProvider("openai"), model: "o1"}
+		_, err := NewOptimizedProvider(mock, cfg).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview)
+
+		var costErr *CostLimitError
+		if !errors.As(err, &costErr) {
+			t.Fatalf("expected CostLimitError, got %v", err)
+		}
+		if costErr.Model != "o1" || costErr.InputTokens != 10000 || costErr.EstimatedUSD <= 0.15 || costErr.LimitUSD != 0.10 {
+			t.Errorf("unexpected error details: %+v", costErr)
+		}
+		if !strings.Contains(err.Error(), "exceeds max_cost_per_request_usd of $0.10") || !strings.Contains(err.Error(), "MAX_COST_PER_REQUEST_USD") {
+			t.Errorf("unexpected error message: %v", err)
+		}
+		if mock.CalledCount != 0 {
+			t.Errorf("provider was called %d times, want 0", mock.CalledCount)
+		}
+	})
+
+	t.Run("cheap model is sent", func(t *testing.T) {
+		mock := &modelMockProvider{MockProvider: NewMockProvider("openai"), model: "gpt-4o-mini"}
+		if _, err := NewOptimizedProvider(mock, cfg).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if mock.CalledCount != 1 {
+			t.Errorf("provider was called %d times, want 1", mock.CalledCount)
+		}
+	})
+
+	t.Run("unpriced model is not checked", func(t *testing.T) {
+		mock := &modelMockProvider{MockProvider: NewMockProvider("ollama"), model: "devstral:latest"}
+		if _, err := NewOptimizedProvider(mock, cfg).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		unlimited := *cfg
+		unlimited.MaxCostPerRequestUSD = 0
+		mock := &modelMockProvider{MockProvider: NewMockProvider("openai"), model: "o1"}
+		if _, err := NewOptimizedProvider(mock, &unlimited).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("dry run warns", func(t *testing.T) {
+		mock := &modelMockProvider{MockProvider: NewMockProvider("openai"), model: "o1"}
+		ctx := WithCallOptions(context.Background(), CallOptions{DryRun: true})
+		plan, err := NewOptimizedProvider(mock, cfg).AnalyzeOptimized(ctx, prompt, len(prompt), config.TaskCodeReview)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(plan, "max_cost_per_request_usd") || mock.CalledCount != 0 {
+			t.Errorf("expected a cost warning without a call, got %q", plan)
+		}
+	})
+}
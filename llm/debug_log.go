@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// DebugLog appends provider HTTP exchanges to a file as JSON lines for reproducing model
+// misbehavior. Bodies are redacted with RedactSecrets and headers, which carry API keys,
+// are never written.
+type DebugLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// debugLogEntry is one request/response exchange in the debug log
+type debugLogEntry struct {
+	Time       time.Time       `json:"time"`
+	Provider   string          `json:"provider"`
+	Model      string          `json:"model"`
+	Method     string          `json:"method"`
+	URL        string          `json:"url"`
+	Status     int             `json:"status,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// OpenDebugLog opens path for appending, creating it readable only by the current user
+func OpenDebugLog(path string) (*DebugLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open debug log: %w", err)
+	}
+	return &DebugLog{file: file}, nil
+}
+
+// Close closes the log file
+func (l *DebugLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// write appends entry as a single line; concurrent writers are serialized so lines never interleave
+func (l *DebugLog) write(entry debugLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		slog.Warn("failed to write debug log", "error", err)
+	}
+}
+
+// debugLog is the log providers created by NewProvider write to, or nil when disabled
+var (
+	debugLog    *DebugLog
+	debugLogMux sync.RWMutex
+)
+
+// SetDebugLog directs the HTTP exchanges of providers created afterwards to log; nil disables it
+func SetDebugLog(log *DebugLog) {
+	debugLogMux.Lock()
+	defer debugLogMux.Unlock()
+	debugLog = log
+}
+
+// withDebugLog wraps client so its exchanges are written to the debug log, when one is set
+func withDebugLog(client *http.Client, config Config) *http.Client {
+	debugLogMux.RLock()
+	log := debugLog
+	debugLogMux.RUnlock()
+	if log == nil {
+		return client
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	logged := *client
+	logged.Transport = &debugTransport{base: base, log: log, provider: config.Provider, model: config.Model}
+	return &logged
+}
+
+// debugTransport records each request and response body passing through it
+type debugTransport struct {
+	base     http.RoundTripper
+	log      *DebugLog
+	provider string
+	model    string
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := debugLogEntry{
+		Time:     time.Now(),
+		Provider: t.provider,
+		Model:    t.model,
+		Method:   req.Method,
+		URL:      redactURL(req.URL),
+	}
+
+	// Read the body for the log and hand the transport an identical copy
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		entry.Request = debugBody(body)
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	entry.DurationMS = time.Since(entry.Time).Milliseconds()
+	if err != nil {
+		entry.Error = err.Error()
+		t.log.write(entry)
+		return nil, err
+	}
+
+	entry.Status = resp.StatusCode
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	entry.Response = debugBody(body)
+	if readErr != nil {
+		entry.Error = readErr.Error()
+	}
+	t.log.write(entry)
+	return resp, readErr
+}
+
+// debugBody redacts secrets from body and keeps it as embedded JSON when it is valid JSON,
+// otherwise as a JSON string
+func debugBody(body []byte) json.RawMessage {
+	redacted, _ := RedactSecrets(string(body))
+	if json.Valid([]byte(redacted)) {
+		return json.RawMessage(redacted)
+	}
+	quoted, _ := json.Marshal(redacted)
+	return quoted
+}
+
+// redactURL renders u with any key query parameter, which some APIs accept in place of a header, masked
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("key") {
+		return u.String()
+	}
+	query.Set("key", RedactedPlaceholder)
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// Diagnostics records the decisions the optimization layer made for one analysis
+type Diagnostics struct {
+	Provider    string
+	Model       string
+	Task        config.AnalysisTask
+	ContentSize int
+	FileCount   int
+	// Chunks is how many parts the prompt was split into; 0 when it was sent whole
+	Chunks int
+	// ChunkSize is the chunk size in bytes when the prompt was chunked
+	ChunkSize int
+	// Truncated marks a prompt cut down to a single chunk instead of being split
+	Truncated       bool
+	MaxTokens       int
+	Temperature     float64
+	EstimatedTokens int
+	ProviderConfig  map[string]any
+}
+
+// DiagnosticsLog collects the Diagnostics of every analysis made with a context carrying it
+type DiagnosticsLog struct {
+	mu      sync.Mutex
+	entries []Diagnostics
+}
+
+type diagnosticsLogKey struct{}
+
+// WithDiagnosticsLog returns a context whose optimized analyses are recorded to log
+func WithDiagnosticsLog(ctx context.Context, log *DiagnosticsLog) context.Context {
+	return context.WithValue(ctx, diagnosticsLogKey{}, log)
+}
+
+// diagnosticsLogFromContext returns the log carried by ctx, or nil
+func diagnosticsLogFromContext(ctx context.Context) *DiagnosticsLog {
+	log, _ := ctx.Value(diagnosticsLogKey{}).(*DiagnosticsLog)
+	return log
+}
+
+// record appends d to the log
+func (l *DiagnosticsLog) record(d Diagnostics) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, d)
+}
+
+// Entries returns the recorded diagnostics in the order the analyses were made
+func (l *DiagnosticsLog) Entries() []Diagnostics {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Diagnostics(nil), l.entries...)
+}
+
+// String renders the recorded diagnostics as a section to append to a result, or "" if none were recorded
+func (l *DiagnosticsLog) String() string {
+	entries := l.Entries()
+	if len(entries) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("## 🔍 Diagnostics\n")
+	for i, d := range entries {
+		if len(entries) > 1 {
+			fmt.Fprintf(&b, "\n### Analysis %d of %d\n", i+1, len(entries))
+		}
+		b.WriteString(d.String())
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// String renders d as a list of its decisions
+func (d Diagnostics) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "- Provider: %s\n", d.Provider)
+	if d.Model != "" {
+		fmt.Fprintf(&b, "- Model: %s\n", d.Model)
+	}
+	fmt.Fprintf(&b, "- Task: %s\n", d.Task)
+	fmt.Fprintf(&b, "- Content size: %d bytes\n", d.ContentSize)
+	fmt.Fprintf(&b, "- Estimated file count: %d\n", d.FileCount)
+	switch {
+	case d.Truncated:
+		fmt.Fprintf(&b, "- Chunking: none, truncated to one %d-byte chunk\n", d.ChunkSize)
+	case d.Chunks > 0:
+		fmt.Fprintf(&b, "- Chunking: %d chunk(s) of up to %d bytes, plus a summary request\n", d.Chunks, d.ChunkSize)
+	default:
+		b.WriteString("- Chunking: none\n")
+	}
+	fmt.Fprintf(&b, "- Max tokens: %d\n", d.MaxTokens)
+	fmt.Fprintf(&b, "- Temperature: %g\n", d.Temperature)
+	fmt.Fprintf(&b, "- Estimated prompt tokens: %d\n", d.EstimatedTokens)
+	if len(d.ProviderConfig) > 0 {
+		fmt.Fprintf(&b, "- Provider settings: %s\n", formatProviderConfig(d.ProviderConfig))
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DryRunPlan describes the requests an analysis would make, for dry runs that skip the provider call
+type DryRunPlan struct {
+	Provider        string
+	Model           string
+	MaxTokens       int
+	Temperature     float64
+	ProviderConfig  map[string]any
+	EstimatedTokens int
+	Redactions      int
+	// ChunkSize is the chunk size in bytes when the content would be chunked, otherwise 0
+	ChunkSize int
+	// Warnings lists problems the real request would hit, such as exceeding the context window
+	Warnings     []string
+	SystemPrompt string
+	// Prompts holds each prompt that would be sent, in order; chunked content has one per part
+	Prompts []string
+}
+
+// NewDryRunPlan returns a plan for sending prompt unchanged with the given parameters
+func NewDryRunPlan(ctx context.Context, provider, model, prompt string, maxTokens int, temperature float64, estimatedTokens int) DryRunPlan {
+	return DryRunPlan{
+		Provider:        provider,
+		Model:           model,
+		MaxTokens:       maxTokens,
+		Temperature:     temperatureFor(ctx, temperature),
+		EstimatedTokens: estimatedTokens,
+		SystemPrompt:    SystemPromptFor(ctx),
+		Prompts:         []string{prompt},
+	}
+}
+
+// String renders the plan as the text returned to the caller in place of an analysis
+func (p DryRunPlan) String() string {
+	var b strings.Builder
+	b.WriteString("# Dry Run (no request sent)\n\n")
+	fmt.Fprintf(&b, "- Provider: %s\n", p.Provider)
+	if p.Model != "" {
+		fmt.Fprintf(&b, "- Model: %s\n", p.Model)
+	}
+	fmt.Fprintf(&b, "- Max tokens: %d\n", p.MaxTokens)
+	fmt.Fprintf(&b, "- Temperature: %g\n", p.Temperature)
+	fmt.Fprintf(&b, "- Estimated prompt tokens: %d\n", p.EstimatedTokens)
+	if p.ChunkSize > 0 {
+		fmt.Fprintf(&b, "- Chunking: %d part(s) of up to %d bytes, plus a summary request\n", len(p.Prompts), p.ChunkSize)
+	} else {
+		b.WriteString("- Chunking: none\n")
+	}
+	if p.Redactions > 0 {
+		fmt.Fprintf(&b, "- Secrets redacted: %d\n", p.Redactions)
+	}
+	if len(p.ProviderConfig) > 0 {
+		fmt.Fprintf(&b, "- Provider settings: %s\n", formatProviderConfig(p.ProviderConfig))
+	}
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(&b, "- ⚠️ %s\n", warning)
+	}
+
+	fmt.Fprintf(&b, "\n## System Prompt\n%s\n", p.SystemPrompt)
+	for i, prompt := range p.Prompts {
+		if len(p.Prompts) == 1 {
+			b.WriteString("\n## Prompt\n")
+		} else {
+			fmt.Fprintf(&b, "\n## Prompt Part %d of %d\n", i+1, len(p.Prompts))
+		}
+		b.WriteString(prompt)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// formatProviderConfig renders provider settings as "key=value" pairs sorted by key
+func formatProviderConfig(providerConfig map[string]any) string {
+	keys := make([]string, 0, len(providerConfig))
+	for key := range providerConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	settings := make([]string, 0, len(keys))
+	for _, key := range keys {
+		settings = append(settings, fmt.Sprintf("%s=%v", key, providerConfig[key]))
+	}
+	return strings.Join(settings, ", ")
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+func TestDryRunSkipsProvider(t *testing.T) {
+	provider := NewMockProvider("openai")
+	cfg := &config.Config{Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, ChunkSizeMB: 1}}
+	ctx := WithCallOptions(context.Background(), CallOptions{DryRun: true, Language: "go"})
+
+	prompt := "Review this code:\npassword = \"hunter2hunter2\"\nfunc main() {}"
+	result, err := NewOptimizedProvider(provider, cfg).AnalyzeOptimized(ctx, prompt, len(prompt), config.TaskCodeReview)
+	if err != nil {
+		t.Fatalf("AnalyzeOptimized() unexpected error: %v", err)
+	}
+	if provider.CalledCount != 0 {
+		t.Errorf("provider called %d times during a dry run", provider.CalledCount)
+	}
+
+	maxTokens, temperature, _ := cfg.GetProviderOptimizedConfig("openai", "", len(prompt), config.TaskCodeReview)
+	redacted, _ := RedactSecrets(prompt)
+	for _, want := range []string{
+		"# Dry Run (no request sent)",
+		"- Provider: openai",
+		fmt.Sprintf("- Max tokens: %d", maxTokens),
+		fmt.Sprintf("- Temperature: %g", temperature),
+		fmt.Sprintf("- Estimated prompt tokens: %d", cfg.EstimateTokensForText(redacted)),
+		"- Secrets redacted: 1",
+		"- Chunking: none",
+		SystemPrompt("go"),
+		redacted,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("dry run output missing %q:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "hunter2hunter2") {
+		t.Errorf("dry run should show the redacted prompt:\n%s", result)
+	}
+}
+
+func TestDryRunPlanChunked(t *testing.T) {
+	w := NewOptimizedProvider(NewMockProvider("ollama"), &config.Config{}).(*optimizedProviderWrapper)
+	ctx := WithCallOptions(context.Background(), CallOptions{DryRun: true, Deterministic: true})
+	prompt := strings.Repeat("line of diff content\n", 20)
+
+	plan := w.dryRunPlan(ctx, prompt, true, 120, 1000, 0.3, nil)
+	if len(plan.Prompts) < 2 || plan.ChunkSize != 120 {
+		t.Fatalf("expected several chunk prompts of 120 bytes, got %d (chunk size %d)", len(plan.Prompts), plan.ChunkSize)
+	}
+	if plan.Temperature != 0 {
+		t.Errorf("Temperature = %v, want 0 for a deterministic call", plan.Temperature)
+	}
+
+	output := plan.String()
+	for _, want := range []string{"part(s) of up to 120 bytes", "## Prompt Part 1 of", "Analysis part 1 of"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("dry run output missing %q:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// emptyResponseConfig returns a config small enough to skip chunking
+func emptyResponseConfig(retry *bool) *config.Config {
+	return &config.Config{
+		Memory:              config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, ChunkSizeMB: 1},
+		RetryEmptyResponses: retry,
+	}
+}
+
+func TestEmptyResponseRetriedWarmer(t *testing.T) {
+	var temperatures []float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Options map[string]any `json:"options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		temperatures = append(temperatures, request.Options["temperature"].(float64))
+
+		response := "  \n"
+		if len(temperatures) > 1 {
+			response = "Looks good."
+		}
+		json.NewEncoder(w).Encode(map[string]any{"response": response, "done": true})
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(Config{Endpoint: server.URL, Temperature: 0.3})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	result, err := NewOptimizedProvider(provider, emptyResponseConfig(nil)).
+		AnalyzeOptimized(context.Background(), "Review this", 11, config.TaskCodeReview)
+	if err != nil {
+		t.Fatalf("AnalyzeOptimized() unexpected error: %v", err)
+	}
+	if result != "Looks good." {
+		t.Errorf("result = %q, want the retried response", result)
+	}
+	if len(temperatures) != 2 {
+		t.Fatalf("got %d requests, want 2", len(temperatures))
+	}
+	if temperatures[1] <= temperatures[0] {
+		t.Errorf("retry temperature %v should be higher than %v", temperatures[1], temperatures[0])
+	}
+}
+
+func TestEmptyResponseAlwaysEmpty(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name      string
+		retry     *bool
+		wantCalls int
+	}{
+		{"retry enabled", nil, 2},
+		{"retry disabled", &disabled, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &scriptedProvider{responses: []string{"", " \t\n"}}
+
+			result, err := NewOptimizedProvider(provider, emptyResponseConfig(tt.retry)).
+				AnalyzeOptimized(context.Background(), "Review this", 11, config.TaskCodeReview)
+			if !errors.Is(err, ErrEmptyResponse) {
+				t.Errorf("error = %v (result %q), want ErrEmptyResponse", err, result)
+			}
+			if len(provider.prompts) != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", len(provider.prompts), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBoostTemperature(t *testing.T) {
+	tests := []struct {
+		temperature, boost, want float64
+	}{
+		{0.3, 0, 0.3},
+		{0.3, 0.2, 0.5},
+		{0.9, 0.2, 1},
+		{1.5, 0.2, 1.5},
+	}
+	for _, tt := range tests {
+		if got := boostTemperature(tt.temperature, tt.boost); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("boostTemperature(%v, %v) = %v, want %v", tt.temperature, tt.boost, got, tt.want)
+		}
+	}
+}
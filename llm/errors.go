@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error kinds providers classify their failures into; test for them with errors.Is
+var (
+	// ErrAuth means the API key is missing, invalid, or lacks access to the model
+	ErrAuth = errors.New("authentication failed")
+	// ErrRateLimited means the provider throttled the request or the account is out of quota
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServer means the provider failed or was unavailable
+	ErrServer = errors.New("provider server error")
+	// ErrContextLength means the prompt and response don't fit the model's context window
+	ErrContextLength = errors.New("context length exceeded")
+	// ErrContentFiltered means the provider's safety filter blocked the prompt or the response
+	ErrContentFiltered = errors.New("content filtered")
+)
+
+// APIError is an error status returned by a provider's API
+type APIError struct {
+	Label      string // how the message names the API, such as "OpenAI API"
+	StatusCode int
+	Body       string
+	Kind       error // one of the Err* kinds above, or nil if the status wasn't recognized
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s error (status %d): %s", e.Label, e.StatusCode, e.Body)
+}
+
+// Unwrap lets errors.Is match the error's kind
+func (e *APIError) Unwrap() error { return e.Kind }
+
+// newAPIError builds an APIError for an unsuccessful response, classifying it by status and body
+func newAPIError(label string, statusCode int, body []byte) *APIError {
+	return &APIError{
+		Label:      label,
+		StatusCode: statusCode,
+		Body:       string(body),
+		Kind:       classifyHTTPError(statusCode, string(body)),
+	}
+}
+
+// Phrases providers use in bad request bodies, matched case-insensitively
+var (
+	contextLengthPhrases = []string{
+		"context_length_exceeded", "context length", "context window", "maximum number of tokens",
+		"too many tokens", "token limit", "prompt is too long", "input is too long",
+	}
+	contentFilterPhrases = []string{
+		"content_filter", "content_policy", "content management policy", "blocked due to safety",
+	}
+	authPhrases = []string{"api key not valid", "api_key_invalid", "invalid api key"}
+)
+
+// classifyHTTPError maps an error status and body to an error kind, or nil if neither is recognized.
+// The status decides first; only 400 and 422 bodies are inspected, because providers report
+// context-length, content-filter, and some key errors as plain bad requests. Other statuses are
+// never reclassified by their body, which can echo the prompt or an upstream error page.
+func classifyHTTPError(statusCode int, body string) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrAuth
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= http.StatusInternalServerError:
+		return ErrServer
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrContextLength
+	case statusCode != http.StatusBadRequest && statusCode != http.StatusUnprocessableEntity:
+		return nil
+	}
+
+	body = strings.ToLower(body)
+	switch {
+	case containsAny(body, contextLengthPhrases):
+		return ErrContextLength
+	case containsAny(body, contentFilterPhrases):
+		return ErrContentFiltered
+	case containsAny(body, authPhrases):
+		return ErrAuth
+	}
+	return nil
+}
+
+// statusError reports a status RetryableHTTPRequest gave up on, classified by status alone
+func statusError(statusCode int) error {
+	if kind := classifyHTTPError(statusCode, ""); kind != nil {
+		return fmt.Errorf("HTTP %d: %w", statusCode, kind)
+	}
+	return fmt.Errorf("HTTP %d", statusCode)
+}
+
+// containsAny reports whether s contains any of substrs
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
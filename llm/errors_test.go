@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClassifyHTTPError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"unauthorized", http.StatusUnauthorized, `{"error": "bad key"}`, ErrAuth},
+		{"forbidden", http.StatusForbidden, ``, ErrAuth},
+		{"google invalid key", http.StatusBadRequest, `{"error": {"status": "INVALID_ARGUMENT", "message": "API key not valid. Please pass a valid API key."}}`, ErrAuth},
+		{"rate limited", http.StatusTooManyRequests, `{"error": "slow down"}`, ErrRateLimited},
+		{"internal error", http.StatusInternalServerError, ``, ErrServer},
+		{"overloaded", 529, `{"error": "overloaded"}`, ErrServer},
+		{"openai context length", http.StatusBadRequest, `{"error": {"code": "context_length_exceeded", "message": "This model's maximum context length is 8192 tokens."}}`, ErrContextLength},
+		{"mistral too many tokens", http.StatusBadRequest, `{"message": "Prompt contains 40000 tokens, too many tokens for model"}`, ErrContextLength},
+		{"payload too large", http.StatusRequestEntityTooLarge, ``, ErrContextLength},
+		{"azure content filter", http.StatusBadRequest, `{"error": {"code": "content_filter", "message": "The response was filtered due to the prompt triggering Azure OpenAI's content management policy."}}`, ErrContentFiltered},
+		{"unrecognized bad request", http.StatusBadRequest, `{"error": "invalid request"}`, nil},
+		{"model not found", http.StatusNotFound, `{"error": "model 'llama9' not found"}`, nil},
+		{"server error body is not inspected", http.StatusBadGateway, `<html>upstream unauthorized: context window</html>`, ErrServer},
+		{"not found body is not inspected", http.StatusNotFound, `{"error": "safety_settings endpoint not found, token limit docs at ..."}`, nil},
+		{"conflict body is not inspected", http.StatusConflict, `{"error": "content_filter update in progress"}`, nil},
+		{"bad request naming a setting", http.StatusBadRequest, `{"error": "invalid safety setting category"}`, nil},
+		{"unprocessable context length", http.StatusUnprocessableEntity, `{"detail": "prompt is too long"}`, ErrContextLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyHTTPError(tt.status, tt.body); got != tt.want {
+				t.Errorf("classifyHTTPError(%d, %q) = %v, want %v", tt.status, tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderErrorKinds(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"auth", http.StatusUnauthorized, `{"error": {"message": "Incorrect API key provided"}}`, ErrAuth},
+		{"context length", http.StatusBadRequest, `{"error": {"code": "context_length_exceeded"}}`, ErrContextLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			client := &http.Client{Transport: &testTransport{testServer: server}}
+
+			openai, _ := NewOpenAIProvider(Config{APIKey: "test-key"})
+			openai.httpClient = client
+			mistral, _ := NewMistralProvider(Config{APIKey: "test-key"})
+			mistral.httpClient = client
+			google, _ := NewGoogleProvider(Config{APIKey: "test-key"})
+			google.httpClient = client
+			ollama, _ := NewOllamaProvider(Config{Endpoint: server.URL})
+
+			for _, provider := range []Provider{openai, mistral, google, ollama} {
+				_, err := provider.Analyze(context.Background(), "Review this")
+				if !errors.Is(err, tt.want) {
+					t.Errorf("%s: error = %v, want errors.Is %v", provider.Name(), err, tt.want)
+				}
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+					t.Errorf("%s: error = %v, want an APIError with status %d", provider.Name(), err, tt.status)
+				}
+			}
+		})
+	}
+}
+
+func TestRetriedStatusErrorKinds(t *testing.T) {
+	for status, want := range map[int]error{
+		http.StatusTooManyRequests:    ErrRateLimited,
+		http.StatusServiceUnavailable: ErrServer,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		_, err := RetryableHTTPRequest(context.Background(), server.Client(), req, RetryConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffMultiple: 1})
+		server.Close()
+		if !errors.Is(err, want) {
+			t.Errorf("status %d: error = %v, want errors.Is %v", status, err, want)
+		}
+	}
+}
+
+func TestRequestErrorsDoNotTripBreaker(t *testing.T) {
+	breaker, _ := newTestBreaker(2, time.Minute, time.Minute)
+
+	for _, err := range []error{
+		newAPIError("OpenAI API", http.StatusBadRequest, []byte(`{"error": {"code": "context_length_exceeded"}}`)),
+		&ContextWindowError{Model: "gpt-4", EstimatedTokens: 9000, ContextWindow: 8192},
+		applyFinishReasonError(t),
+	} {
+		if isProviderFailure(err) {
+			t.Errorf("isProviderFailure(%v) = true, want false", err)
+		}
+		breaker.Record(err)
+	}
+	if got := breaker.State(); got != CircuitClosed {
+		t.Fatalf("state after request errors = %v, want closed", got)
+	}
+
+	// Auth failures need a config fix, not a pause, so they don't count either
+	breaker.Record(newAPIError("OpenAI API", http.StatusUnauthorized, nil))
+	breaker.Record(newAPIError("OpenAI API", http.StatusUnauthorized, nil))
+	if got := breaker.State(); got != CircuitClosed {
+		t.Fatalf("state after auth failures = %v, want closed", got)
+	}
+
+	// Server errors do
+	breaker.Record(newAPIError("OpenAI API", http.StatusServiceUnavailable, nil))
+	breaker.Record(newAPIError("OpenAI API", http.StatusServiceUnavailable, nil))
+	if got := breaker.State(); got != CircuitOpen {
+		t.Fatalf("state after server errors = %v, want open", got)
+	}
+}
+
+// applyFinishReasonError returns the error for a content-filtered completion
+func applyFinishReasonError(t *testing.T) error {
+	t.Helper()
+	_, err := applyFinishReason("OpenAI", "", "content_filter")
+	if !errors.Is(err, ErrContentFiltered) {
+		t.Fatalf("applyFinishReason error = %v, want ErrContentFiltered", err)
+	}
+	return err
+}
@@ -0,0 +1,244 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Severity ranks how serious a review finding is
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+	SeverityInfo     Severity = "info"
+)
+
+// severityLevels lists the severities from least to most serious
+var severityLevels = []Severity{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// ParseSeverity returns the severity level named by s, ignoring case
+func ParseSeverity(s string) (Severity, error) {
+	severity := Severity(strings.ToLower(strings.TrimSpace(s)))
+	for _, level := range severityLevels {
+		if severity == level {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("unknown severity %q (use info, low, medium, high, or critical)", s)
+}
+
+// AtLeast reports whether s is at least as serious as threshold
+func (s Severity) AtLeast(threshold Severity) bool {
+	return severityRank(s) >= severityRank(threshold)
+}
+
+// FilterFindings returns the findings at or above threshold, in their original order
+func FilterFindings(findings []ReviewFinding, threshold Severity) []ReviewFinding {
+	kept := make([]ReviewFinding, 0, len(findings))
+	for _, finding := range findings {
+		if finding.Severity.AtLeast(threshold) {
+			kept = append(kept, finding)
+		}
+	}
+	return kept
+}
+
+// Risk bands for a review's risk score
+const (
+	RiskLow    = "low risk"
+	RiskMedium = "medium risk"
+	RiskHigh   = "high risk"
+)
+
+// RiskScore sums the weight of each finding's severity
+func RiskScore(findings []ReviewFinding, weight func(Severity) float64) float64 {
+	score := 0.0
+	for _, finding := range findings {
+		score += weight(finding.Severity)
+	}
+	return score
+}
+
+// RiskBand describes a risk score with the default weights in mind: under 5 (a few low or medium
+// findings) is low risk, under 10 medium risk, and 10 or more (a critical finding, or several
+// high ones) high risk
+func RiskBand(score float64) string {
+	switch {
+	case score < 5:
+		return RiskLow
+	case score < 10:
+		return RiskMedium
+	default:
+		return RiskHigh
+	}
+}
+
+// ReviewFinding is a single structured issue reported by a review
+type ReviewFinding struct {
+	Severity    Severity `json:"severity"`
+	Category    string   `json:"category"`
+	File        string   `json:"file,omitempty"`
+	Line        int      `json:"line,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Suggestion  string   `json:"suggestion,omitempty"`
+}
+
+// ReviewFindings is the structured result of a review in JSON mode
+type ReviewFindings struct {
+	Summary  string          `json:"summary,omitempty"`
+	Findings []ReviewFinding `json:"findings"`
+}
+
+// findingsSchema describes the JSON shape requested from the model in JSON mode
+const findingsSchema = `{
+  "summary": "one paragraph overview",
+  "findings": [
+    {
+      "severity": "critical | high | medium | low | info",
+      "category": "security | performance | correctness | style | maintainability",
+      "file": "path if known",
+      "line": 0,
+      "title": "short title",
+      "description": "what is wrong and why it matters",
+      "suggestion": "how to fix it"
+    }
+  ]
+}`
+
+// ParseFindings extracts structured review findings from an LLM response
+func ParseFindings(response string) (*ReviewFindings, error) {
+	doc, err := ExtractJSON(response)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings ReviewFindings
+	if err := json.Unmarshal([]byte(doc), &findings); err != nil {
+		return nil, fmt.Errorf("response did not match the findings schema: %w", err)
+	}
+	if findings.Findings == nil && findings.Summary == "" {
+		return nil, fmt.Errorf("response did not contain findings")
+	}
+
+	for i := range findings.Findings {
+		findings.Findings[i].Severity = normalizeSeverity(findings.Findings[i].Severity)
+	}
+	return &findings, nil
+}
+
+// normalizeSeverity maps model-provided severities onto the known levels, defaulting to info
+func normalizeSeverity(s Severity) Severity {
+	switch Severity(strings.ToLower(strings.TrimSpace(string(s)))) {
+	case SeverityCritical:
+		return SeverityCritical
+	case SeverityHigh, "error":
+		return SeverityHigh
+	case SeverityMedium, "warning", "moderate":
+		return SeverityMedium
+	case SeverityLow, "minor":
+		return SeverityLow
+	default:
+		return SeverityInfo
+	}
+}
+
+// findingSimilarityThreshold is the word overlap above which two findings in the same category are duplicates
+const findingSimilarityThreshold = 0.6
+
+// MergeFindings combines findings from several reviews, collapsing duplicates.
+// Duplicates keep the highest severity and the first location and wording seen.
+func MergeFindings(parts []*ReviewFindings) *ReviewFindings {
+	merged := &ReviewFindings{Findings: []ReviewFinding{}}
+
+	var summaries []string
+	for _, part := range parts {
+		if part.Summary != "" {
+			summaries = append(summaries, strings.TrimSpace(part.Summary))
+		}
+
+	next:
+		for _, finding := range part.Findings {
+			for i := range merged.Findings {
+				if duplicateFindings(merged.Findings[i], finding) {
+					if severityRank(finding.Severity) > severityRank(merged.Findings[i].Severity) {
+						merged.Findings[i].Severity = finding.Severity
+					}
+					continue next
+				}
+			}
+			merged.Findings = append(merged.Findings, finding)
+		}
+	}
+
+	merged.Summary = strings.Join(summaries, " ")
+	return merged
+}
+
+// duplicateFindings reports whether two findings describe the same issue
+func duplicateFindings(a, b ReviewFinding) bool {
+	if !strings.EqualFold(strings.TrimSpace(a.Category), strings.TrimSpace(b.Category)) {
+		return false
+	}
+	if a.File != "" && a.File == b.File && a.Line != 0 && a.Line == b.Line {
+		return true
+	}
+	return wordSimilarity(a.Title+" "+a.Description, b.Title+" "+b.Description) >= findingSimilarityThreshold
+}
+
+// wordSimilarity returns the Jaccard similarity of the lowercase word sets of a and b
+func wordSimilarity(a, b string) float64 {
+	wordsA, wordsB := wordSet(a), wordSet(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+// wordSet splits text into a set of lowercase words, ignoring punctuation
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+// severityRank orders severities from info (lowest) to critical (highest); unknown values rank as info
+func severityRank(s Severity) int {
+	return max(slices.Index(severityLevels, s), 0)
+}
+
+// mergeChunkFindings merges per-chunk JSON findings into a single document.
+// It reports false if any chunk did not return parseable findings.
+func mergeChunkFindings(results []string) (string, bool) {
+	parts := make([]*ReviewFindings, 0, len(results))
+	for _, result := range results {
+		findings, err := ParseFindings(result)
+		if err != nil {
+			return "", false
+		}
+		parts = append(parts, findings)
+	}
+
+	doc, err := json.MarshalIndent(MergeFindings(parts), "", "  ")
+	if err != nil {
+		return "", false
+	}
+	return string(doc), true
+}
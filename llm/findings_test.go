@@ -0,0 +1,155 @@
+package llm
+
+import "testing"
+
+func TestParseFindings(t *testing.T) {
+	response := "```json\n" + `{
+  "summary": "Two issues",
+  "findings": [
+    {"severity": "HIGH", "category": "security", "file": "db.go", "line": 12, "title": "SQL injection", "description": "query built with string concatenation"},
+    {"severity": "minor", "category": "style", "title": "Long function", "description": "split it up"}
+  ]
+}` + "\n```"
+
+	findings, err := ParseFindings(response)
+	if err != nil {
+		t.Fatalf("ParseFindings() unexpected error: %v", err)
+	}
+	if findings.Summary != "Two issues" || len(findings.Findings) != 2 {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+
+	first := findings.Findings[0]
+	if first.Severity != SeverityHigh || first.File != "db.go" || first.Line != 12 {
+		t.Errorf("unexpected first finding: %+v", first)
+	}
+	if findings.Findings[1].Severity != SeverityLow {
+		t.Errorf("severity = %q, want %q", findings.Findings[1].Severity, SeverityLow)
+	}
+}
+
+func TestParseFindingsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{"prose only", "The code looks fine overall."},
+		{"wrong shape", `{"findings": "none"}`},
+		{"unrelated object", `{"status": "ok"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFindings(tt.response); err == nil {
+				t.Errorf("ParseFindings(%q) expected error", tt.response)
+			}
+		})
+	}
+}
+
+func TestNormalizeSeverity(t *testing.T) {
+	tests := map[Severity]Severity{
+		"Critical": SeverityCritical,
+		"error":    SeverityHigh,
+		"warning":  SeverityMedium,
+		"low":      SeverityLow,
+		"":         SeverityInfo,
+		"bogus":    SeverityInfo,
+	}
+
+	for in, want := range tests {
+		if got := normalizeSeverity(in); got != want {
+			t.Errorf("normalizeSeverity(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMergeFindings(t *testing.T) {
+	parts := []*ReviewFindings{
+		{Findings: []ReviewFinding{
+			{Severity: SeverityLow, Category: "security", File: "a.go", Line: 10, Title: "SQL built by concatenation", Description: "user input"},
+			{Severity: SeverityInfo, Category: "style", Title: "Naming", Description: "Variable names are unclear"},
+		}},
+		{Findings: []ReviewFinding{
+			// Same location and category with different wording
+			{Severity: SeverityCritical, Category: "Security", File: "a.go", Line: 10, Title: "SQL injection", Description: "query uses raw input"},
+			// Similar words but a different category
+			{Severity: SeverityInfo, Category: "maintainability", Title: "Naming", Description: "Variable names are unclear"},
+		}},
+	}
+
+	merged := MergeFindings(parts)
+	if len(merged.Findings) != 3 {
+		t.Fatalf("expected 3 findings, got %d: %+v", len(merged.Findings), merged.Findings)
+	}
+	if merged.Findings[0].Severity != SeverityCritical || merged.Findings[0].Title != "SQL built by concatenation" {
+		t.Errorf("duplicate should keep first wording with highest severity, got %+v", merged.Findings[0])
+	}
+}
+
+func TestSeverityOrdering(t *testing.T) {
+	for i := 1; i < len(severityLevels); i++ {
+		lower, higher := severityLevels[i-1], severityLevels[i]
+		if !higher.AtLeast(lower) || lower.AtLeast(higher) {
+			t.Errorf("expected %s to rank above %s", higher, lower)
+		}
+	}
+	if !SeverityMedium.AtLeast(SeverityMedium) {
+		t.Error("a severity should meet its own threshold")
+	}
+
+	if got, err := ParseSeverity(" HIGH "); err != nil || got != SeverityHigh {
+		t.Errorf("ParseSeverity(\" HIGH \") = %q, %v; want high", got, err)
+	}
+	if _, err := ParseSeverity("warning"); err == nil {
+		t.Error("expected an error for a severity outside the canonical levels")
+	}
+}
+
+func TestFilterFindings(t *testing.T) {
+	findings := []ReviewFinding{
+		{Severity: SeverityLow, Title: "naming"},
+		{Severity: SeverityCritical, Title: "sql injection"},
+		{Severity: SeverityInfo, Title: "comment"},
+		{Severity: SeverityHigh, Title: "race"},
+		{Severity: SeverityMedium, Title: "error ignored"},
+	}
+
+	kept := FilterFindings(findings, SeverityHigh)
+	if len(kept) != 2 || kept[0].Title != "sql injection" || kept[1].Title != "race" {
+		t.Errorf("FilterFindings(high) = %+v, want critical and high findings in order", kept)
+	}
+	if kept := FilterFindings(findings, SeverityInfo); len(kept) != len(findings) {
+		t.Errorf("FilterFindings(info) kept %d of %d findings", len(kept), len(findings))
+	}
+	if kept := FilterFindings(nil, SeverityHigh); kept == nil {
+		t.Error("FilterFindings() should return an empty slice, not nil, so JSON encodes []")
+	}
+}
+
+func TestRiskScore(t *testing.T) {
+	findings := []ReviewFinding{
+		{Severity: SeverityCritical, Title: "sql injection"},
+		{Severity: SeverityHigh, Title: "race"},
+		{Severity: SeverityMedium, Title: "error ignored"},
+		{Severity: SeverityMedium, Title: "unchecked cast"},
+		{Severity: SeverityLow, Title: "naming"},
+		{Severity: SeverityInfo, Title: "comment"},
+	}
+	defaults := func(s Severity) float64 {
+		return map[Severity]float64{SeverityCritical: 10, SeverityHigh: 5, SeverityMedium: 2, SeverityLow: 1}[s]
+	}
+
+	if got := RiskScore(findings, defaults); got != 20 {
+		t.Errorf("RiskScore() = %v, want 20", got)
+	}
+	if got := RiskScore(nil, defaults); got != 0 {
+		t.Errorf("RiskScore(nil) = %v, want 0", got)
+	}
+
+	for score, want := range map[float64]string{0: RiskLow, 4: RiskLow, 5: RiskMedium, 9.5: RiskMedium, 10: RiskHigh, 20: RiskHigh} {
+		if got := RiskBand(score); got != want {
+			t.Errorf("RiskBand(%v) = %q, want %q", score, got, want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TruncatedResponseNote is appended when a model stops because it ran out of output tokens
+const TruncatedResponseNote = "(response truncated — increase max_tokens)"
+
+// applyFinishReason interprets an OpenAI-style finish_reason for the returned content.
+// Truncated responses get a visible note; content-filtered responses become errors.
+func applyFinishReason(providerLabel, content, finishReason string) (string, error) {
+	switch finishReason {
+	case "length", "model_length":
+		return strings.TrimRight(content, "\n") + "\n\n" + TruncatedResponseNote, nil
+	case "content_filter":
+		return "", fmt.Errorf("%s response was blocked by the content filter; try rephrasing or removing sensitive content from the input: %w", providerLabel, ErrContentFiltered)
+	default:
+		return content, nil
+	}
+}
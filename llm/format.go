@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Output formats supported by tools that can return structured results
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatSARIF    = "sarif"
+)
+
+// jsonInstructions asks the model to reply with a bare JSON document matching schema
+func jsonInstructions(schema string) string {
+	return fmt.Sprintf(`Respond with only a JSON object, without prose or code fences, matching this shape:
+%s`, schema)
+}
+
+// ExtractJSON returns the JSON document in an LLM response, dropping code fences and any surrounding prose
+func ExtractJSON(response string) (string, error) {
+	text := strings.TrimSpace(response)
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return "", fmt.Errorf("response did not contain JSON")
+	}
+	closer := "}"
+	if text[start] == '[' {
+		closer = "]"
+	}
+	end := strings.LastIndex(text, closer)
+	if end < start {
+		return "", fmt.Errorf("response did not contain complete JSON")
+	}
+
+	doc := text[start : end+1]
+	if !json.Valid([]byte(doc)) {
+		return "", fmt.Errorf("response was not valid JSON")
+	}
+	return doc, nil
+}
@@ -0,0 +1,32 @@
+package llm
+
+import "testing"
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{"bare object", `{"a": 1}`, `{"a": 1}`, false},
+		{"code fence", "```json\n{\"a\": 1}\n```", `{"a": 1}`, false},
+		{"surrounding prose", "Here is the result:\n{\"a\": [1, 2]}\nHope this helps.", `{"a": [1, 2]}`, false},
+		{"array", "[1, 2]", "[1, 2]", false},
+		{"no json", "No action items found.", "", true},
+		{"truncated", `{"a": [1, 2`, "", true},
+		{"invalid", `{"a": nope}`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractJSON(tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,18 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/dshills/second-opinion/config"
 )
 
 // GoogleProvider implements the Provider interface for Google AI (Gemini)
@@ -15,13 +21,26 @@ type GoogleProvider struct {
 	model       string
 	temperature float64
 	maxTokens   int
+	safety      []map[string]string
 	retryConfig RetryConfig
 	httpClient  *http.Client
+
+	// Vertex AI routing: requests go to the project's regional endpoint with an OAuth token
+	// instead of the Generative Language API with the API key
+	useVertex     bool
+	vertexProject string
+	vertexRegion  string
+	tokens        *googleTokenSource
 }
 
-// NewGoogleProvider creates a new Google AI provider
+// NewGoogleProvider creates a new Google AI provider. With UseVertex set it calls Vertex AI in
+// VertexProject and VertexRegion, authenticating with Application Default Credentials.
 func NewGoogleProvider(config Config) (*GoogleProvider, error) {
-	if config.APIKey == "" {
+	if config.UseVertex {
+		if config.VertexProject == "" {
+			return nil, fmt.Errorf("the Google Cloud project is required for Vertex AI")
+		}
+	} else if config.APIKey == "" {
 		return nil, fmt.Errorf("the Google API key is required")
 	}
 
@@ -30,34 +49,189 @@ func NewGoogleProvider(config Config) (*GoogleProvider, error) {
 		model = "gemini-2.0-flash-exp"
 	}
 
-	temperature := config.Temperature
-	if temperature == 0 {
-		temperature = 0.3
-	}
+	temperature := config.temperatureOrDefault()
 
 	maxTokens := config.MaxTokens
 	if maxTokens == 0 {
 		maxTokens = 4096
 	}
 
-	return &GoogleProvider{
+	httpClient, err := httpClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &GoogleProvider{
 		apiKey:      config.APIKey,
 		model:       model,
 		temperature: temperature,
 		maxTokens:   maxTokens,
+		safety:      buildSafetySettings(config.SafetySettings),
 		retryConfig: DefaultRetryConfig(),
-		httpClient:  SharedHTTPClient,
-	}, nil
+		httpClient:  httpClient,
+	}
+	if config.UseVertex {
+		provider.useVertex = true
+		provider.vertexProject = config.VertexProject
+		provider.vertexRegion = config.VertexRegion
+		if provider.vertexRegion == "" {
+			provider.vertexRegion = DefaultVertexRegion
+		}
+		if !validVertexRegion(provider.vertexRegion) {
+			return nil, fmt.Errorf("invalid Vertex AI region %q", provider.vertexRegion)
+		}
+		tokenClient, err := baseHTTPClientFor(config)
+		if err != nil {
+			return nil, err
+		}
+		provider.tokens = newGoogleTokenSource(tokenClient)
+	}
+	return provider, nil
+}
+
+// methodURL returns the URL of a model method on the configured API
+func (p *GoogleProvider) methodURL(method string) string {
+	if p.useVertex {
+		return vertexURL(p.vertexProject, p.vertexRegion, p.model, method)
+	}
+	return googleAPIURL(p.model, method)
+}
+
+// googleResponse is a generateContent response, or one event of a streamed response
+type googleResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason  string `json:"finishReason"`
+		SafetyRatings []any  `json:"safetyRatings"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// checkBlocked reports a blocked prompt or a response stopped by the safety settings
+func (r *googleResponse) checkBlocked() error {
+	if r.PromptFeedback.BlockReason != "" {
+		return fmt.Errorf("prompt blocked: %s: %w", r.PromptFeedback.BlockReason, ErrContentFiltered)
+	}
+	if len(r.Candidates) > 0 && r.Candidates[0].FinishReason == "SAFETY" {
+		return fmt.Errorf("response blocked due to safety settings: %w", ErrContentFiltered)
+	}
+	return nil
+}
+
+// Analyze sends a prompt to Google AI and returns the response, streaming it when ctx carries
+// a stream callback
+func (p *GoogleProvider) Analyze(ctx context.Context, prompt string) (_ string, err error) {
+	if onChunk := StreamCallbackFromContext(ctx); onChunk != nil {
+		return p.StreamAnalyze(ctx, prompt, onChunk)
+	}
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	release, resp, err := p.send(ctx, "generateContent", prompt)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result googleResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if err := result.checkBlocked(); err != nil {
+		return "", err
+	}
+
+	if len(result.Candidates) == 0 || len(result.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("no response from Google AI")
+	}
+
+	return result.Candidates[0].Content.Parts[0].Text, nil
 }
 
-// Analyze sends a prompt to Google AI and returns the response
-func (p *GoogleProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+// StreamAnalyze sends a prompt to Google AI using server-sent events, calling onChunk
+// with each piece of text as it arrives, and returns the full response
+func (p *GoogleProvider) StreamAnalyze(ctx context.Context, prompt string, onChunk func(string) error) (_ string, err error) {
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	release, resp, err := p.send(ctx, "streamGenerateContent?alt=sse", prompt)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	var response strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" {
+			continue
+		}
+
+		var event googleResponse
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return "", fmt.Errorf("failed to parse stream event: %w", err)
+		}
+
+		// The prompt can be blocked after some text has already been streamed
+		if err := event.checkBlocked(); err != nil {
+			return "", err
+		}
+
+		for _, candidate := range event.Candidates {
+			for _, part := range candidate.Content.Parts {
+				if part.Text == "" {
+					continue
+				}
+				response.WriteString(part.Text)
+				if err := onChunk(part.Text); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if response.Len() == 0 {
+		return "", fmt.Errorf("no response from Google AI")
+	}
+
+	return response.String(), nil
+}
+
+// send posts the prompt to the given model method and returns the successful response.
+// The caller must call release once it has finished reading the body.
+func (p *GoogleProvider) send(ctx context.Context, method, prompt string) (func(), *http.Response, error) {
 	// SECURITY FIX: Remove API key from URL
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent", p.model)
+	url := p.methodURL(method)
 
 	requestBody := map[string]any{
 		"contents": []map[string]any{
 			{
+				// Vertex AI requires the role; the Generative Language API accepts it
+				"role": "user",
 				"parts": []map[string]string{
 					{
 						"text": prompt,
@@ -68,107 +242,160 @@ func (p *GoogleProvider) Analyze(ctx context.Context, prompt string) (string, er
 		"systemInstruction": map[string]any{
 			"parts": []map[string]string{
 				{
-					"text": "You are an expert code reviewer and git analysis assistant. Provide clear, actionable feedback.",
+					"text": SystemPromptFor(ctx),
 				},
 			},
 		},
 		"generationConfig": map[string]any{
-			"temperature":     p.temperature,
-			"maxOutputTokens": p.maxTokens,
+			"temperature":     temperatureFor(ctx, p.temperature),
+			"maxOutputTokens": maxTokensFor(ctx, p.maxTokens),
 			"topK":            40,
 			"topP":            0.95,
 		},
-		"safetySettings": []map[string]string{
-			{
-				"category":  "HARM_CATEGORY_HATE_SPEECH",
-				"threshold": "BLOCK_ONLY_HIGH",
-			},
-			{
-				"category":  "HARM_CATEGORY_DANGEROUS_CONTENT",
-				"threshold": "BLOCK_ONLY_HIGH",
-			},
-		},
+		"safetySettings": p.safety,
 	}
 
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	// SECURITY FIX: Use header for API key instead of URL parameter
-	req.Header.Set("x-goog-api-key", p.apiKey)
+	if p.useVertex {
+		token, err := p.tokens.Token(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		// SECURITY FIX: Use header for API key instead of URL parameter
+		req.Header.Set("x-goog-api-key", p.apiKey)
+	}
 
-	resp, err := RetryableHTTPRequest(ctx, p.httpClient, req, p.retryConfig)
+	// Pace requests to stay under the provider's rate limit
+	if err := waitForRateLimit(ctx, p.Name()); err != nil {
+		return nil, nil, err
+	}
+
+	// Bound total in-flight requests across all providers
+	releaseSlot, err := acquireRequestSlot(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, nil, err
 	}
-	defer func() {
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
-	}()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := RetryableHTTPRequest(ctx, p.httpClient, req, p.retryConfig)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		releaseSlot()
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	release := func() {
+		drainAndClose(resp.Body)
+		releaseSlot()
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		// Redact API key from error message if present
-		errMsg := string(body)
-		if p.apiKey != "" && len(p.apiKey) > 8 {
-			errMsg = fmt.Sprintf("Google AI API error (status %d): [response body redacted for security]", resp.StatusCode)
-		}
-		return "", fmt.Errorf("%s", errMsg)
-	}
-
-	var result struct {
-		Candidates []struct {
-			Content struct {
-				Parts []struct {
-					Text string `json:"text"`
-				} `json:"parts"`
-			} `json:"content"`
-			FinishReason  string `json:"finishReason"`
-			SafetyRatings []any  `json:"safetyRatings"`
-		} `json:"candidates"`
-		PromptFeedback struct {
-			BlockReason string `json:"blockReason"`
-		} `json:"promptFeedback"`
-		UsageMetadata struct {
-			PromptTokenCount     int `json:"promptTokenCount"`
-			CandidatesTokenCount int `json:"candidatesTokenCount"`
-			TotalTokenCount      int `json:"totalTokenCount"`
-		} `json:"usageMetadata"`
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		release()
+		label := "Google AI API"
+		if p.useVertex {
+			label = "Vertex AI API"
+		}
+		return nil, nil, newAPIError(label, resp.StatusCode, body)
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+	return release, resp, nil
+}
+
+// defaultSafetyThreshold applies to any harm category without a configured threshold
+const defaultSafetyThreshold = "BLOCK_ONLY_HIGH"
+
+// buildSafetySettings builds the safetySettings array. Without configuration it keeps the
+// original hate speech and dangerous content defaults; otherwise it covers every harm category.
+func buildSafetySettings(configured map[string]string) []map[string]string {
+	if len(configured) == 0 {
+		return []map[string]string{
+			{"category": "HARM_CATEGORY_HATE_SPEECH", "threshold": defaultSafetyThreshold},
+			{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "threshold": defaultSafetyThreshold},
+		}
 	}
 
-	// Check for blocked prompts
-	if result.PromptFeedback.BlockReason != "" {
-		return "", fmt.Errorf("prompt blocked: %s", result.PromptFeedback.BlockReason)
+	thresholds := make(map[string]string, len(configured))
+	for category, threshold := range configured {
+		thresholds[config.NormalizeHarmCategory(category)] = strings.ToUpper(strings.TrimSpace(threshold))
 	}
 
-	if len(result.Candidates) == 0 || len(result.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no response from Google AI")
+	settings := make([]map[string]string, 0, len(config.GoogleHarmCategories))
+	for _, category := range config.GoogleHarmCategories {
+		threshold := thresholds[category]
+		if threshold == "" {
+			threshold = defaultSafetyThreshold
+		}
+		settings = append(settings, map[string]string{"category": category, "threshold": threshold})
 	}
+	return settings
+}
 
-	// Check finish reason
-	if result.Candidates[0].FinishReason == "SAFETY" {
-		return "", fmt.Errorf("response blocked due to safety settings")
+// ListModels returns the models that support generateContent. Vertex AI has no list endpoint
+// for Google's publisher models, so it reports ErrNoModelList.
+func (p *GoogleProvider) ListModels(ctx context.Context) (_ []string, err error) {
+	if p.useVertex {
+		return nil, fmt.Errorf("listing models is not supported on Vertex AI: %w", ErrNoModelList)
 	}
+	defer func() { err = sanitizeError(err, p.apiKey) }()
 
-	return result.Candidates[0].Content.Parts[0].Text, nil
+	var models []string
+	pageToken := ""
+	for range maxGoogleModelPages {
+		listURL := googleModelsURL + "?pageSize=1000"
+		if pageToken != "" {
+			listURL += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+
+		var result struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		headers := map[string]string{"x-goog-api-key": p.apiKey}
+		if err := getModelList(ctx, p.httpClient, "Google AI API", listURL, headers, &result); err != nil {
+			return nil, err
+		}
+
+		for _, model := range result.Models {
+			if slices.Contains(model.SupportedGenerationMethods, "generateContent") {
+				models = append(models, strings.TrimPrefix(model.Name, "models/"))
+			}
+		}
+		if pageToken = result.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+	return sortedModels(models), nil
 }
 
 // Name returns the provider name
 func (p *GoogleProvider) Name() string {
 	return "google"
 }
+
+// Model returns the configured model name
+func (p *GoogleProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports what the configured model supports
+func (p *GoogleProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{
+		ContextWindow:       config.ModelContextWindow(p.model),
+		SupportsTemperature: true,
+		SupportsStreaming:   true,
+		SupportsJSON:        true,
+	}
+}
@@ -0,0 +1,244 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// googleTestServer captures the request body and replies with the given finish reason
+func googleTestServer(t *testing.T, finishReason string, captured *map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(captured); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{
+				{
+					"content":      map[string]any{"parts": []map[string]string{{"text": "Review text"}}},
+					"finishReason": finishReason,
+				},
+			},
+		})
+	}))
+}
+
+// safetyThresholds flattens the request's safetySettings into category -> threshold
+func safetyThresholds(t *testing.T, request map[string]any) map[string]string {
+	t.Helper()
+	raw, ok := request["safetySettings"].([]any)
+	if !ok {
+		t.Fatalf("safetySettings missing from request: %v", request)
+	}
+	thresholds := make(map[string]string)
+	for _, entry := range raw {
+		setting := entry.(map[string]any)
+		thresholds[setting["category"].(string)] = setting["threshold"].(string)
+	}
+	return thresholds
+}
+
+func TestGoogleProvider_SafetySettings(t *testing.T) {
+	t.Run("defaults when unset", func(t *testing.T) {
+		var captured map[string]any
+		server := googleTestServer(t, "STOP", &captured)
+		defer server.Close()
+
+		provider, err := NewGoogleProvider(Config{APIKey: "test-key"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+		if _, err := provider.Analyze(context.Background(), "Review this"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := map[string]string{
+			"HARM_CATEGORY_HATE_SPEECH":       "BLOCK_ONLY_HIGH",
+			"HARM_CATEGORY_DANGEROUS_CONTENT": "BLOCK_ONLY_HIGH",
+		}
+		got := safetyThresholds(t, captured)
+		if len(got) != len(want) {
+			t.Fatalf("safety settings = %v, want %v", got, want)
+		}
+		for category, threshold := range want {
+			if got[category] != threshold {
+				t.Errorf("%s = %q, want %q", category, got[category], threshold)
+			}
+		}
+	})
+
+	t.Run("configured thresholds cover all categories", func(t *testing.T) {
+		var captured map[string]any
+		server := googleTestServer(t, "STOP", &captured)
+		defer server.Close()
+
+		provider, err := NewGoogleProvider(Config{
+			APIKey: "test-key",
+			SafetySettings: map[string]string{
+				"dangerous_content":        "block_none",
+				"HARM_CATEGORY_HARASSMENT": "BLOCK_MEDIUM_AND_ABOVE",
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+		if _, err := provider.Analyze(context.Background(), "Review this exploit"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := map[string]string{
+			"HARM_CATEGORY_HARASSMENT":        "BLOCK_MEDIUM_AND_ABOVE",
+			"HARM_CATEGORY_HATE_SPEECH":       "BLOCK_ONLY_HIGH",
+			"HARM_CATEGORY_SEXUALLY_EXPLICIT": "BLOCK_ONLY_HIGH",
+			"HARM_CATEGORY_DANGEROUS_CONTENT": "BLOCK_NONE",
+		}
+		got := safetyThresholds(t, captured)
+		if len(got) != len(want) {
+			t.Fatalf("safety settings = %v, want %v", got, want)
+		}
+		for category, threshold := range want {
+			if got[category] != threshold {
+				t.Errorf("%s = %q, want %q", category, got[category], threshold)
+			}
+		}
+	})
+
+	t.Run("SAFETY finish reason is still an error", func(t *testing.T) {
+		var captured map[string]any
+		server := googleTestServer(t, "SAFETY", &captured)
+		defer server.Close()
+
+		provider, err := NewGoogleProvider(Config{APIKey: "test-key", SafetySettings: map[string]string{"dangerous_content": "BLOCK_NONE"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+		_, err = provider.Analyze(context.Background(), "Review this")
+		if err == nil || !strings.Contains(err.Error(), "blocked due to safety settings") {
+			t.Errorf("error = %v, want the safety block error", err)
+		}
+	})
+}
+
+// googleStreamServer replies to streamGenerateContent with the given SSE events
+func googleStreamServer(t *testing.T, events ...string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, ":streamGenerateContent") || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("unexpected request: %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			w.Write([]byte("data: " + event + "\r\n\r\n"))
+		}
+	}))
+}
+
+func TestGoogleProvider_StreamAnalyze(t *testing.T) {
+	t.Run("accumulates text", func(t *testing.T) {
+		server := googleStreamServer(t,
+			`{"candidates":[{"content":{"parts":[{"text":"Looks "}]}}]}`,
+			`{"candidates":[{"content":{"parts":[{"text":"good"},{"text":"."}]}}]}`,
+			`{"candidates":[{"content":{"parts":[]},"finishReason":"STOP"}]}`,
+		)
+		defer server.Close()
+
+		provider, err := NewGoogleProvider(Config{APIKey: "test-key"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+		var chunks []string
+		result, err := provider.StreamAnalyze(context.Background(), "Review this", func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "Looks good." {
+			t.Errorf("result = %q, want %q", result, "Looks good.")
+		}
+		if len(chunks) != 3 {
+			t.Errorf("received %d chunks, want 3: %q", len(chunks), chunks)
+		}
+	})
+
+	t.Run("analyze streams to the context callback", func(t *testing.T) {
+		server := googleStreamServer(t,
+			`{"candidates":[{"content":{"parts":[{"text":"Looks "}]}}]}`,
+			`{"candidates":[{"content":{"parts":[{"text":"good."}]},"finishReason":"STOP"}]}`,
+		)
+		defer server.Close()
+
+		provider, err := NewGoogleProvider(Config{APIKey: "test-key"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+		var chunks []string
+		ctx := WithStreamCallback(context.Background(), func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		result, err := provider.Analyze(ctx, "Review this")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "Looks good." || strings.Join(chunks, "|") != "Looks |good." {
+			t.Errorf("result = %q, chunks = %q; want the streamed pieces and their concatenation", result, chunks)
+		}
+	})
+
+	t.Run("blocked mid-stream", func(t *testing.T) {
+		server := googleStreamServer(t,
+			`{"candidates":[{"content":{"parts":[{"text":"Partial"}]}}]}`,
+			`{"promptFeedback":{"blockReason":"SAFETY"}}`,
+		)
+		defer server.Close()
+
+		provider, err := NewGoogleProvider(Config{APIKey: "test-key"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+		_, err = provider.StreamAnalyze(context.Background(), "Review this", func(string) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "prompt blocked: SAFETY") {
+			t.Errorf("error = %v, want prompt blocked", err)
+		}
+	})
+}
+
+func TestGoogleProvider_RequestMaxTokens(t *testing.T) {
+	var captured map[string]any
+	server := googleTestServer(t, "STOP", &captured)
+	defer server.Close()
+
+	provider, err := NewGoogleProvider(Config{APIKey: "test-key", MaxTokens: 4096})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+	ctx := withRequestParams(context.Background(), requestParams{MaxTokens: 1500})
+	if _, err := provider.Analyze(ctx, "Review this"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	generationConfig, _ := captured["generationConfig"].(map[string]any)
+	if generationConfig["maxOutputTokens"] != float64(1500) {
+		t.Errorf("maxOutputTokens = %v, want the per-request 1500", generationConfig["maxOutputTokens"])
+	}
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// gzipProviders accept gzip-compressed request bodies
+var gzipProviders = map[string]bool{"openai": true, "google": true}
+
+// withGzip wraps client so request bodies of at least config.CompressMinBytes are gzipped and
+// gzipped responses are decoded, when compression is enabled and the provider accepts it
+func withGzip(client *http.Client, config Config) *http.Client {
+	if config.CompressMinBytes <= 0 || !gzipProviders[config.Provider] {
+		return client
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	compressed := *client
+	compressed.Transport = &gzipTransport{base: base, minBytes: config.CompressMinBytes}
+	return &compressed
+}
+
+// gzipTransport compresses large request bodies and decompresses gzip responses
+type gzipTransport struct {
+	base     http.RoundTripper
+	minBytes int
+}
+
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody && req.Header.Get("Content-Encoding") == "" {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) >= t.minBytes {
+			if body, err = gzipBytes(body); err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+
+	// Asking for gzip explicitly turns off the transport's own decoding, so decode here
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp, nil
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to decode gzip response: %w", err)
+	}
+	resp.Body = &gzipResponseBody{Reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// gzipResponseBody decodes a gzip response body, closing the underlying body when closed
+type gzipResponseBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipResponseBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress request: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress request: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGzipRoundTrip(t *testing.T) {
+	// A gzip-aware server: it decodes compressed requests, echoes the body, and compresses its reply
+	var gotEncoding []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = append(gotEncoding, r.Header.Get("Content-Encoding"))
+		body := r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = reader
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			w.Write(data)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		writer := gzip.NewWriter(w)
+		writer.Write(data)
+		writer.Close()
+	}))
+	defer server.Close()
+
+	small := `{"prompt": "short"}`
+	large := `{"prompt": "` + strings.Repeat("diff --git a/x b/x\n", 1000) + `"}`
+
+	tests := []struct {
+		name         string
+		provider     string
+		minBytes     int
+		body         string
+		wantEncoding string
+	}{
+		{"large body compressed", "openai", 1024, large, "gzip"},
+		{"small body sent as is", "google", 1024, small, ""},
+		{"disabled", "openai", 0, large, ""},
+		{"provider without gzip support", "ollama", 1024, large, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEncoding = nil
+			httpClient, err := httpClientFor(Config{Provider: tt.provider, CompressMinBytes: tt.minBytes})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest("POST", server.URL, bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(data) != tt.body {
+				t.Errorf("response body of %d bytes does not match the %d byte request", len(data), len(tt.body))
+			}
+			if len(gotEncoding) != 1 || gotEncoding[0] != tt.wantEncoding {
+				t.Errorf("request Content-Encoding = %q, want %q", gotEncoding, tt.wantEncoding)
+			}
+			if resp.Header.Get("Content-Encoding") != "" {
+				t.Errorf("decoded response still has Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestGzipRetryResendsCompressedBody(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("attempt %d: request was not gzipped: %v", attempts, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(reader)
+		if !strings.HasPrefix(string(data), "{") {
+			t.Errorf("attempt %d: got body %q", attempts, data[:min(20, len(data))])
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	httpClient, err := httpClientFor(Config{Provider: "google", CompressMinBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", server.URL, strings.NewReader(`{"prompt": "`+strings.Repeat("x", 100)+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry := DefaultRetryConfig()
+	retry.BaseDelay = time.Millisecond
+	resp, err := RetryableHTTPRequest(req.Context(), httpClient, req, retry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if attempts != 2 || resp.StatusCode != http.StatusOK {
+		t.Errorf("got %d attempts ending in %d, want a successful retry", attempts, resp.StatusCode)
+	}
+}
@@ -1,25 +1,50 @@
 package llm
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/dshills/second-opinion/config"
 )
 
 // HTTPClientConfig holds configuration for HTTP client optimization
 type HTTPClientConfig struct {
-	Timeout               time.Duration
+	// Timeout bounds the whole request, including reading a slowly generated body
+	Timeout time.Duration
+	// DialTimeout bounds establishing the TCP connection, so an unreachable endpoint fails fast
+	DialTimeout time.Duration
+	// ResponseHeaderTimeout bounds the wait for response headers after the request is sent; zero
+	// leaves it to Timeout. Non-streaming APIs only reply once generation finishes, so it must
+	// allow for the slowest generation expected.
+	ResponseHeaderTimeout time.Duration
 	MaxIdleConns          int
 	MaxConnsPerHost       int
 	MaxIdleConnsPerHost   int
 	IdleConnTimeout       time.Duration
 	TLSHandshakeTimeout   time.Duration
 	ExpectContinueTimeout time.Duration
+	// TLSCACertFile is a PEM file of CA certificates trusted in addition to the system roots
+	TLSCACertFile string
+	// TLSInsecureSkipVerify disables server certificate verification
+	TLSInsecureSkipVerify bool
+	// ProxyURL sends requests through this proxy instead of the one named by the
+	// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables
+	ProxyURL string
 }
 
 // DefaultHTTPClientConfig returns optimized defaults for LLM API calls
 func DefaultHTTPClientConfig() HTTPClientConfig {
 	return HTTPClientConfig{
 		Timeout:               5 * time.Minute, // Increased from 30s to 5 minutes for large reviews
+		DialTimeout:           10 * time.Second,
 		MaxIdleConns:          100,
 		MaxConnsPerHost:       10,
 		MaxIdleConnsPerHost:   10,
@@ -29,9 +54,39 @@ func DefaultHTTPClientConfig() HTTPClientConfig {
 	}
 }
 
-// NewOptimizedHTTPClient creates an HTTP client optimized for API calls
+// NewOptimizedHTTPClient creates an HTTP client optimized for API calls. A CA file or proxy URL
+// that cannot be used is logged and ignored, leaving only the system roots trusted and the
+// proxy environment variables in effect.
 func NewOptimizedHTTPClient(config HTTPClientConfig) *http.Client {
+	client, err := newHTTPClient(config)
+	if err != nil {
+		slog.Error("ignoring TLS and proxy settings", "error", err)
+		config.TLSCACertFile = ""
+		config.TLSInsecureSkipVerify = false
+		config.ProxyURL = ""
+		client, _ = newHTTPClient(config)
+	}
+	return client
+}
+
+// newHTTPClient creates an HTTP client for config, failing if its TLS settings cannot be applied
+func newHTTPClient(config HTTPClientConfig) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(config.TLSCACertFile, config.TLSInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := newProxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
 	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
 		MaxIdleConns:          config.MaxIdleConns,
 		MaxConnsPerHost:       config.MaxConnsPerHost,
 		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
@@ -40,13 +95,154 @@ func NewOptimizedHTTPClient(config HTTPClientConfig) *http.Client {
 		ExpectContinueTimeout: config.ExpectContinueTimeout,
 		// Enable HTTP/2
 		ForceAttemptHTTP2: true,
+		TLSClientConfig:   tlsConfig,
+		Proxy:             proxy,
 	}
 
 	return &http.Client{
 		Transport: transport,
 		Timeout:   config.Timeout,
+	}, nil
+}
+
+// newTLSConfig builds the TLS configuration for a custom CA file or skipped verification,
+// returning nil when neither is set so the transport keeps Go's defaults
+func newTLSConfig(caCertFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	if caCertFile == "" && !insecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA file: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in TLS CA file %s", caCertFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if insecureSkipVerify {
+		slog.Warn("INSECURE: TLS certificate verification is disabled; LLM traffic can be intercepted. Use a CA file instead outside of testing")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return tlsConfig, nil
+}
+
+// newProxyFunc returns the transport proxy for an explicit proxy URL, or the proxy environment
+// variables when none is set. An explicit proxy still honors NO_PROXY and never proxies loopback
+// hosts, so a local Ollama keeps working alongside a proxied cloud provider.
+func newProxyFunc(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
 	}
+	parsed, err := config.ParseProxyURL(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return parsed, nil
+	}, nil
+}
+
+// bypassProxy reports whether a request to u goes direct: loopback hosts always do, as do hosts
+// matching a NO_PROXY entry. Entries are comma-separated and may be "*", an IP address, a CIDR
+// range, or a domain, which also matches its subdomains; a leading "." or "*." matches only
+// subdomains. An entry with a port matches only that port.
+func bypassProxy(u *url.URL, noProxy string) bool {
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return true
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		if entryIP := net.ParseIP(strings.Trim(entry, "[]")); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(entry, "*."):
+			if strings.HasSuffix(host, entry[1:]) {
+				return true
+			}
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(host, entry) {
+				return true
+			}
+		case host == entry || strings.HasSuffix(host, "."+entry):
+			return true
+		}
+	}
+	return false
 }
 
 // SharedHTTPClient provides a singleton HTTP client optimized for LLM API calls
 var SharedHTTPClient = NewOptimizedHTTPClient(DefaultHTTPClientConfig())
+
+// httpClientFor returns the HTTP client a provider should use: baseHTTPClientFor's client, wrapped
+// for gzip compression when enabled and for the debug log when one is set
+func httpClientFor(config Config) (*http.Client, error) {
+	client, err := baseHTTPClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return withDebugLog(withGzip(client, config), config), nil
+}
+
+// baseHTTPClientFor returns SharedHTTPClient, or a dedicated client when the provider has its own
+// TLS or proxy settings. Credential exchanges use it directly so tokens never reach the debug log.
+func baseHTTPClientFor(config Config) (*http.Client, error) {
+	if config.TLSCACertFile == "" && !config.TLSInsecureSkipVerify && config.ProxyURL == "" {
+		return SharedHTTPClient, nil
+	}
+
+	clientConfig := DefaultHTTPClientConfig()
+	clientConfig.TLSCACertFile = config.TLSCACertFile
+	clientConfig.TLSInsecureSkipVerify = config.TLSInsecureSkipVerify
+	clientConfig.ProxyURL = config.ProxyURL
+	return newHTTPClient(clientConfig)
+}
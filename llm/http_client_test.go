@@ -1,7 +1,12 @@
 package llm
 
 import (
+	"encoding/pem"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -11,6 +16,7 @@ func TestDefaultHTTPClientConfig(t *testing.T) {
 
 	expected := HTTPClientConfig{
 		Timeout:               5 * time.Minute,
+		DialTimeout:           10 * time.Second,
 		MaxIdleConns:          100,
 		MaxConnsPerHost:       10,
 		MaxIdleConnsPerHost:   10,
@@ -79,6 +85,41 @@ func TestNewOptimizedHTTPClient(t *testing.T) {
 	}
 }
 
+func TestHTTPClientTimeouts(t *testing.T) {
+	config := DefaultHTTPClientConfig()
+	config.ResponseHeaderTimeout = 50 * time.Millisecond
+
+	client := NewOptimizedHTTPClient(config)
+	transport := client.Transport.(*http.Transport)
+	if transport.ResponseHeaderTimeout != config.ResponseHeaderTimeout {
+		t.Errorf("ResponseHeaderTimeout = %v, expected %v", transport.ResponseHeaderTimeout, config.ResponseHeaderTimeout)
+	}
+	if transport.DialContext == nil {
+		t.Error("DialContext should be set to apply DialTimeout")
+	}
+	if client.Timeout != 5*time.Minute {
+		t.Errorf("Timeout = %v, expected the full body timeout", client.Timeout)
+	}
+
+	// A server slow to respond fails at the header timeout, not the overall one
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected a response header timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %v, expected it to fail at the response header timeout", elapsed)
+	}
+}
+
 func TestSharedHTTPClient(t *testing.T) {
 	if SharedHTTPClient == nil {
 		t.Fatal("SharedHTTPClient is nil")
@@ -161,3 +202,152 @@ func TestHTTPClientOptimizations(t *testing.T) {
 		t.Error("HTTP/2 should be enabled for better performance")
 	}
 }
+
+func TestNewOptimizedHTTPClientCustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// The test server's self-signed certificate stands in for a private CA
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultHTTPClientConfig()
+	config.TLSCACertFile = caFile
+	client := NewOptimizedHTTPClient(config)
+
+	transport := client.Transport.(*http.Transport)
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.RootCAs == nil {
+		t.Fatal("expected the transport to have a RootCAs pool")
+	}
+	if transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("a CA file should not disable verification")
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request to a server signed by the custom CA failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Without the CA the same server is rejected
+	if resp, err := NewOptimizedHTTPClient(DefaultHTTPClientConfig()).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected the default client to reject the self-signed certificate")
+	}
+}
+
+func TestNewOptimizedHTTPClientInsecureSkipVerify(t *testing.T) {
+	config := DefaultHTTPClientConfig()
+	config.TLSInsecureSkipVerify = true
+	transport := NewOptimizedHTTPClient(config).Transport.(*http.Transport)
+	if transport.TLSClientConfig == nil || !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("expected certificate verification to be disabled")
+	}
+
+	// Default clients keep Go's TLS defaults
+	transport = NewOptimizedHTTPClient(DefaultHTTPClientConfig()).Transport.(*http.Transport)
+	if transport.TLSClientConfig != nil {
+		t.Errorf("expected no TLS config by default, got %+v", transport.TLSClientConfig)
+	}
+}
+
+func TestHTTPClientForTLSSettings(t *testing.T) {
+	client, err := httpClientFor(Config{Provider: "ollama"})
+	if err != nil || client != SharedHTTPClient {
+		t.Errorf("expected the shared client without TLS settings, got %v, %v", client, err)
+	}
+
+	badFile := filepath.Join(t.TempDir(), "not-a-cert.pem")
+	if err := os.WriteFile(badFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewOllamaProvider(Config{TLSCACertFile: badFile}); err == nil {
+		t.Error("expected an invalid CA file to fail provider creation")
+	}
+	if _, err := httpClientFor(Config{TLSCACertFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected a missing CA file to be an error")
+	}
+
+	client, err = httpClientFor(Config{TLSInsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client == SharedHTTPClient {
+		t.Error("expected a dedicated client for per-provider TLS settings")
+	}
+}
+
+func TestNewOptimizedHTTPClientProxy(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil)
+
+	// Without an explicit URL the proxy environment variables apply
+	transport := NewOptimizedHTTPClient(DefaultHTTPClientConfig()).Transport.(*http.Transport)
+	if transport.Proxy == nil {
+		t.Fatal("expected the transport to honor the proxy environment variables")
+	}
+
+	config := DefaultHTTPClientConfig()
+	config.ProxyURL = "http://proxy.internal:3128"
+	transport = NewOptimizedHTTPClient(config).Transport.(*http.Transport)
+	proxy, err := transport.Proxy(req)
+	if err != nil || proxy == nil || proxy.String() != "http://proxy.internal:3128" {
+		t.Errorf("Proxy() = %v, %v; want the configured proxy", proxy, err)
+	}
+
+	// An explicit proxy still leaves loopback hosts direct
+	local := httptest.NewRequest(http.MethodPost, "http://localhost:11434/api/generate", nil)
+	if proxy, err := transport.Proxy(local); err != nil || proxy != nil {
+		t.Errorf("Proxy(localhost) = %v, %v; want a direct connection", proxy, err)
+	}
+
+	if _, err := httpClientFor(Config{ProxyURL: "ftp://proxy.internal"}); err == nil {
+		t.Error("expected an unsupported proxy scheme to be an error")
+	}
+	client, err := httpClientFor(Config{ProxyURL: "socks5://127.0.0.1:1080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client == SharedHTTPClient {
+		t.Error("expected a dedicated client for a proxy URL")
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		url     string
+		noProxy string
+		want    bool
+	}{
+		{"https://api.openai.com/v1", "", false},
+		{"http://localhost:11434", "", true},
+		{"http://127.0.0.1:11434", "", true},
+		{"http://[::1]:11434", "", true},
+		{"https://api.openai.com/v1", "*", true},
+		{"https://api.openai.com/v1", "openai.com", true},
+		{"https://openai.com/v1", "openai.com", true},
+		{"https://notopenai.com/v1", "openai.com", false},
+		{"https://openai.com/v1", ".openai.com", false},
+		{"https://api.openai.com/v1", ".openai.com", true},
+		{"https://api.openai.com/v1", "*.openai.com", true},
+		{"https://api.openai.com/v1", "example.com, api.openai.com", true},
+		{"https://api.openai.com/v1", "api.openai.com:443", true},
+		{"https://api.openai.com/v1", "api.openai.com:8443", false},
+		{"http://10.1.2.3:11434", "10.0.0.0/8", true},
+		{"http://192.168.1.5:11434", "10.0.0.0/8", false},
+		{"http://10.1.2.3:11434", "10.1.2.3", true},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bypassProxy(u, tt.noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%q, %q) = %v, want %v", tt.url, tt.noProxy, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// requestSemaphore bounds the number of in-flight LLM requests across all providers.
+// A nil channel means no limit is applied.
+var (
+	requestSemaphore    chan struct{}
+	requestSemaphoreMux sync.RWMutex
+)
+
+// SetMaxConcurrentRequests bounds in-flight LLM requests across all providers.
+// A value of zero or less removes the limit.
+func SetMaxConcurrentRequests(n int) {
+	requestSemaphoreMux.Lock()
+	defer requestSemaphoreMux.Unlock()
+
+	if n <= 0 {
+		requestSemaphore = nil
+		return
+	}
+	requestSemaphore = make(chan struct{}, n)
+}
+
+// acquireRequestSlot blocks until a request slot is free or ctx is done.
+// The returned release func must be called once the request completes.
+func acquireRequestSlot(ctx context.Context) (func(), error) {
+	requestSemaphoreMux.RLock()
+	sem := requestSemaphore
+	requestSemaphoreMux.RUnlock()
+
+	if sem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case sem <- struct{}{}:
+		// Release into the same channel even if the limit is reconfigured meanwhile
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
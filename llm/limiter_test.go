@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireRequestSlotBoundsConcurrency(t *testing.T) {
+	const limit = 3
+	SetMaxConcurrentRequests(limit)
+	defer SetMaxConcurrentRequests(0)
+
+	var inFlight, maxInFlight int32
+	var wg sync.WaitGroup
+	for i := 0; i < limit+2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			release, err := acquireRequestSlot(context.Background())
+			if err != nil {
+				t.Errorf("acquireRequestSlot() unexpected error: %v", err)
+				return
+			}
+			defer release()
+
+			current := atomic.AddInt32(&inFlight, 1)
+			for {
+				prev := atomic.LoadInt32(&maxInFlight)
+				if current <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, current) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight > limit {
+		t.Errorf("max in-flight requests = %d, want at most %d", maxInFlight, limit)
+	}
+	if maxInFlight < limit {
+		t.Errorf("max in-flight requests = %d, expected the limit %d to be reached", maxInFlight, limit)
+	}
+}
+
+func TestAcquireRequestSlotRespectsContext(t *testing.T) {
+	SetMaxConcurrentRequests(1)
+	defer SetMaxConcurrentRequests(0)
+
+	release, err := acquireRequestSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquireRequestSlot() unexpected error: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := acquireRequestSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquireRequestSlot() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestAcquireRequestSlotUnlimited(t *testing.T) {
+	SetMaxConcurrentRequests(0)
+
+	// Without a limit acquisition never blocks
+	for i := 0; i < 100; i++ {
+		release, err := acquireRequestSlot(context.Background())
+		if err != nil {
+			t.Fatalf("acquireRequestSlot() unexpected error: %v", err)
+		}
+		defer release()
+	}
+}
@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProviderMiddleware wraps a provider to observe or alter its requests, e.g. for metrics or tracing
+type ProviderMiddleware func(next Provider) Provider
+
+// Unwrapper is implemented by middleware providers so callers can reach the provider they wrap
+type Unwrapper interface {
+	Unwrap() Provider
+}
+
+// middlewares is the chain applied to every provider created by NewProvider
+var (
+	middlewares   []ProviderMiddleware
+	middlewareMux sync.RWMutex
+)
+
+// SetMiddleware replaces the middleware chain applied by NewProvider.
+// The first middleware is outermost and sees each request first.
+func SetMiddleware(mw ...ProviderMiddleware) {
+	middlewareMux.Lock()
+	defer middlewareMux.Unlock()
+	middlewares = append([]ProviderMiddleware(nil), mw...)
+}
+
+// applyMiddleware wraps provider in the configured middleware chain
+func applyMiddleware(provider Provider) Provider {
+	middlewareMux.RLock()
+	chain := middlewares
+	middlewareMux.RUnlock()
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		provider = chain[i](provider)
+	}
+	return provider
+}
+
+// BaseProvider unwraps any middleware and returns the underlying provider
+func BaseProvider(provider Provider) Provider {
+	for {
+		unwrapper, ok := provider.(Unwrapper)
+		if !ok {
+			return provider
+		}
+		provider = unwrapper.Unwrap()
+	}
+}
+
+// ProviderStats aggregates request outcomes for one provider
+type ProviderStats struct {
+	Calls         int64
+	Errors        int64
+	TotalDuration time.Duration
+	// Retries counts HTTP attempts repeated after a retryable failure
+	Retries int64
+	// PromptTokens and ResponseTokens are estimated from the text sent and received
+	PromptTokens   int64
+	ResponseTokens int64
+}
+
+// RequestRecord describes one completed provider request
+type RequestRecord struct {
+	Duration       time.Duration
+	Err            error
+	Retries        int
+	PromptTokens   int
+	ResponseTokens int
+}
+
+// ProviderMetrics counts requests per provider; it is safe for concurrent use
+type ProviderMetrics struct {
+	mu         sync.Mutex
+	stats      map[string]ProviderStats
+	collectors *prometheusCollectors
+}
+
+// NewProviderMetrics creates an empty metrics recorder
+func NewProviderMetrics() *ProviderMetrics {
+	return &ProviderMetrics{stats: make(map[string]ProviderStats), collectors: newPrometheusCollectors()}
+}
+
+// Record adds one request outcome for a provider
+func (m *ProviderMetrics) Record(provider string, duration time.Duration, err error) {
+	m.RecordRequest(provider, RequestRecord{Duration: duration, Err: err})
+}
+
+// RecordRequest adds one request, with its retries and token usage, for a provider
+func (m *ProviderMetrics) RecordRequest(provider string, record RequestRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats[provider]
+	stats.Calls++
+	stats.TotalDuration += record.Duration
+	if record.Err != nil {
+		stats.Errors++
+	}
+	stats.Retries += int64(record.Retries)
+	stats.PromptTokens += int64(record.PromptTokens)
+	stats.ResponseTokens += int64(record.ResponseTokens)
+	m.stats[provider] = stats
+	m.collectors.observe(provider, record)
+}
+
+// Snapshot returns a copy of the stats recorded so far, keyed by provider name
+func (m *ProviderMetrics) Snapshot() map[string]ProviderStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]ProviderStats, len(m.stats))
+	for name, stats := range m.stats {
+		snapshot[name] = stats
+	}
+	return snapshot
+}
+
+// MetricsMiddleware records the duration and success of every Analyze call into metrics
+func MetricsMiddleware(metrics *ProviderMetrics) ProviderMiddleware {
+	return func(next Provider) Provider {
+		return &metricsProvider{Provider: next, metrics: metrics}
+	}
+}
+
+// metricsProvider is the provider returned by MetricsMiddleware
+type metricsProvider struct {
+	Provider
+	metrics *ProviderMetrics
+}
+
+// Analyze times the wrapped call and records its outcome, retries, and estimated token usage
+func (p *metricsProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	var retries atomic.Int64
+	start := time.Now()
+	result, err := p.Provider.Analyze(withRetryCounter(ctx, &retries), prompt)
+	p.metrics.RecordRequest(p.Name(), RequestRecord{
+		Duration:       time.Since(start),
+		Err:            err,
+		Retries:        int(retries.Load()),
+		PromptTokens:   estimateTokens(prompt),
+		ResponseTokens: estimateTokens(result),
+	})
+	return result, err
+}
+
+// Chat records a conversation like Analyze when the wrapped provider supports one
+func (p *metricsProvider) Chat(ctx context.Context, messages []Message) (string, error) {
+	chat, ok := chatProviderOf(p.Provider)
+	if !ok {
+		return "", ErrChatUnsupported
+	}
+	var retries atomic.Int64
+	start := time.Now()
+	result, err := chat.Chat(withRetryCounter(ctx, &retries), messages)
+	p.metrics.RecordRequest(p.Name(), RequestRecord{
+		Duration:       time.Since(start),
+		Err:            err,
+		Retries:        int(retries.Load()),
+		PromptTokens:   estimateTokens(conversationText(messages)),
+		ResponseTokens: estimateTokens(result),
+	})
+	return result, err
+}
+
+// Unwrap returns the wrapped provider
+func (p *metricsProvider) Unwrap() Provider {
+	return p.Provider
+}
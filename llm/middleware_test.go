@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// countingMiddleware wraps providers so tests can observe the calls they receive
+type countingMiddleware struct {
+	label string
+	order *[]string
+	calls int
+}
+
+func (m *countingMiddleware) wrap(next Provider) Provider {
+	return &countingProvider{Provider: next, mw: m}
+}
+
+type countingProvider struct {
+	Provider
+	mw *countingMiddleware
+}
+
+func (p *countingProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	p.mw.calls++
+	if p.mw.order != nil {
+		*p.mw.order = append(*p.mw.order, p.mw.label)
+	}
+	return p.Provider.Analyze(ctx, prompt)
+}
+
+func (p *countingProvider) Unwrap() Provider {
+	return p.Provider
+}
+
+func TestNewProviderAppliesMiddleware(t *testing.T) {
+	counter := &countingMiddleware{label: "counter"}
+	SetMiddleware(counter.wrap)
+	defer SetMiddleware()
+
+	provider, err := NewProvider(Config{Provider: "ollama", Model: "llama3"})
+	if err != nil {
+		t.Fatalf("NewProvider() unexpected error: %v", err)
+	}
+	if _, ok := provider.(*countingProvider); !ok {
+		t.Fatalf("NewProvider() returned %T, want middleware wrapper", provider)
+	}
+	if provider.Name() != "ollama" {
+		t.Errorf("Name() = %q, want %q", provider.Name(), "ollama")
+	}
+
+	base, ok := BaseProvider(provider).(*OllamaProvider)
+	if !ok {
+		t.Fatalf("BaseProvider() returned %T, want *OllamaProvider", BaseProvider(provider))
+	}
+	if base.model != "llama3" {
+		t.Errorf("base model = %q, want %q", base.model, "llama3")
+	}
+
+	reporter, ok := NewOptimizedProvider(provider, nil).(ModelReporter)
+	if !ok {
+		t.Fatal("optimized wrapper does not implement ModelReporter")
+	}
+	if reporter.Model() != "llama3" {
+		t.Errorf("optimized wrapper Model() = %q, want %q", reporter.Model(), "llama3")
+	}
+}
+
+func TestMiddlewareChainOrder(t *testing.T) {
+	var order []string
+	outer := &countingMiddleware{label: "outer", order: &order}
+	inner := &countingMiddleware{label: "inner", order: &order}
+	SetMiddleware(outer.wrap, inner.wrap)
+	defer SetMiddleware()
+
+	mock := NewMockProvider("mock")
+	provider := applyMiddleware(mock)
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Analyze(context.Background(), "prompt"); err != nil {
+			t.Fatalf("Analyze() unexpected error: %v", err)
+		}
+	}
+
+	if outer.calls != 2 || inner.calls != 2 || mock.CalledCount != 2 {
+		t.Errorf("calls outer=%d inner=%d provider=%d, want 2 each", outer.calls, inner.calls, mock.CalledCount)
+	}
+	want := []string{"outer", "inner", "outer", "inner"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+	if BaseProvider(provider) != mock {
+		t.Error("BaseProvider() did not unwrap to the original provider")
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	metrics := NewProviderMetrics()
+	mock := NewMockProvider("mock")
+	provider := MetricsMiddleware(metrics)(mock)
+
+	if _, err := provider.Analyze(context.Background(), "ok"); err != nil {
+		t.Fatalf("Analyze() unexpected error: %v", err)
+	}
+	mock.Error = errors.New("boom")
+	if _, err := provider.Analyze(context.Background(), "fail"); err == nil {
+		t.Fatal("Analyze() expected error")
+	}
+
+	stats := metrics.Snapshot()["mock"]
+	if stats.Calls != 2 {
+		t.Errorf("Calls = %d, want 2", stats.Calls)
+	}
+	if stats.Errors != 1 {
+		t.Errorf("Errors = %d, want 1", stats.Errors)
+	}
+	if stats.TotalDuration < 0 {
+		t.Errorf("TotalDuration = %v, want non-negative", stats.TotalDuration)
+	}
+}
+
+func TestMetricsMiddlewareChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message": {"role": "assistant", "content": "Looks fine."}, "done": true}`))
+	}))
+	defer server.Close()
+
+	ollama, err := NewOllamaProvider(Config{Endpoint: server.URL, Model: "llama3.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := NewProviderMetrics()
+	provider := MetricsMiddleware(metrics)(ollama)
+
+	chat, ok := chatProviderOf(provider)
+	if !ok {
+		t.Fatal("expected the middleware to pass chat through")
+	}
+	if _, err := chat.Chat(context.Background(), conversation); err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if stats := metrics.Snapshot()["ollama"]; stats.Calls != 1 {
+		t.Errorf("Calls = %d, want 1", stats.Calls)
+	}
+
+	// A provider that can't chat is reported as such rather than recorded
+	plain := MetricsMiddleware(metrics)(NewMockProvider("mock"))
+	if _, err := plain.(ChatProvider).Chat(context.Background(), conversation); !errors.Is(err, ErrChatUnsupported) {
+		t.Errorf("expected ErrChatUnsupported, got %v", err)
+	}
+	if stats := metrics.Snapshot()["mock"]; stats.Calls != 0 {
+		t.Errorf("Calls = %d, want 0", stats.Calls)
+	}
+}
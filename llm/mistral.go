@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/dshills/second-opinion/config"
 )
 
 // MistralProvider implements the Provider interface for Mistral AI
@@ -15,6 +17,7 @@ type MistralProvider struct {
 	model       string
 	temperature float64
 	maxTokens   int
+	safePrompt  bool
 	retryConfig RetryConfig
 	httpClient  *http.Client
 }
@@ -30,48 +33,63 @@ func NewMistralProvider(config Config) (*MistralProvider, error) {
 		model = "mistral-small-latest"
 	}
 
-	temperature := config.Temperature
-	if temperature == 0 {
-		temperature = 0.3
-	}
+	temperature := config.temperatureOrDefault()
 
 	maxTokens := config.MaxTokens
 	if maxTokens == 0 {
 		maxTokens = 4096
 	}
 
+	httpClient, err := httpClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &MistralProvider{
 		apiKey:      config.APIKey,
 		model:       model,
 		temperature: temperature,
 		maxTokens:   maxTokens,
+		safePrompt:  config.SafePrompt,
 		retryConfig: DefaultRetryConfig(),
-		httpClient:  SharedHTTPClient,
+		httpClient:  httpClient,
 	}, nil
 }
 
 // Analyze sends a prompt to Mistral AI and returns the response
 func (p *MistralProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	return p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}})
+}
+
+// Chat sends a conversation to Mistral AI and returns the assistant's reply
+func (p *MistralProvider) Chat(ctx context.Context, messages []Message) (_ string, err error) {
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	if err := validateMessages(messages); err != nil {
+		return "", err
+	}
+
 	requestBody := map[string]any{
-		"model": p.model,
-		"messages": []map[string]any{
-			{
-				"role":    "system",
-				"content": "You are an expert code reviewer and git analysis assistant. Provide clear, actionable feedback.",
-			},
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"temperature": p.temperature,
-		"max_tokens":  p.maxTokens,
+		"model":       p.model,
+		"messages":    chatMessages(ctx, messages),
+		"temperature": temperatureFor(ctx, p.temperature),
+		"max_tokens":  maxTokensFor(ctx, p.maxTokens),
 		"top_p":       0.95,
 		"random_seed": nil,
-		"safe_prompt": false,
 		"tool_choice": "auto",
 	}
 
+	// Optional features are omitted entirely when off so the request stays compatible
+	if p.safePrompt {
+		requestBody["safe_prompt"] = true
+	}
+	if CallOptionsFromContext(ctx).JSONOutput {
+		requestBody["response_format"] = map[string]string{"type": "json_object"}
+	}
+	if opts := CallOptionsFromContext(ctx); opts.Deterministic {
+		requestBody["random_seed"] = opts.Seed
+	}
+
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
@@ -85,14 +103,23 @@ func (p *MistralProvider) Analyze(ctx context.Context, prompt string) (string, e
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 
+	// Pace requests to stay under the provider's rate limit
+	if err := waitForRateLimit(ctx, p.Name()); err != nil {
+		return "", err
+	}
+
+	// Bound total in-flight requests across all providers
+	release, err := acquireRequestSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	resp, err := RetryableHTTPRequest(ctx, p.httpClient, req, p.retryConfig)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request: %w", err)
 	}
-	defer func() {
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
-	}()
+	defer drainAndClose(resp.Body)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -100,7 +127,7 @@ func (p *MistralProvider) Analyze(ctx context.Context, prompt string) (string, e
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("the Mistral API error (status %d): %s", resp.StatusCode, string(body))
+		return "", newAPIError("the Mistral API", resp.StatusCode, body)
 	}
 
 	var result struct {
@@ -108,6 +135,7 @@ func (p *MistralProvider) Analyze(ctx context.Context, prompt string) (string, e
 			Message struct {
 				Content string `json:"content"`
 			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
 	}
 
@@ -119,10 +147,33 @@ func (p *MistralProvider) Analyze(ctx context.Context, prompt string) (string, e
 		return "", fmt.Errorf("no response from Mistral AI")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	return applyFinishReason("Mistral AI", result.Choices[0].Message.Content, result.Choices[0].FinishReason)
+}
+
+// ListModels returns the models available to the API key
+func (p *MistralProvider) ListModels(ctx context.Context) (_ []string, err error) {
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	headers := map[string]string{"Authorization": "Bearer " + p.apiKey}
+	return listOpenAIModels(ctx, p.httpClient, "Mistral API", "https://api.mistral.ai/v1/models", headers)
 }
 
 // Name returns the provider name
 func (p *MistralProvider) Name() string {
 	return "mistral"
 }
+
+// Model returns the configured model name
+func (p *MistralProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports what the configured model supports
+func (p *MistralProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{
+		ContextWindow:       config.ModelContextWindow(p.model),
+		SupportsTemperature: true,
+		SupportsStreaming:   false,
+		SupportsJSON:        true,
+	}
+}
@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMistralProvider_FinishReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		finishReason string
+		expectError  string
+		expectResult string
+	}{
+		{"stop", "stop", "", "Full answer"},
+		{"length", "length", "", "Full answer\n\n" + TruncatedResponseNote},
+		{"model length", "model_length", "", "Full answer\n\n" + TruncatedResponseNote},
+		{"content filter", "content_filter", "Mistral AI response was blocked", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				resp := map[string]any{
+					"choices": []map[string]any{
+						{
+							"message":       map[string]string{"content": "Full answer"},
+							"finish_reason": tt.finishReason,
+						},
+					},
+				}
+				json.NewEncoder(w).Encode(resp)
+			}))
+			defer server.Close()
+
+			provider, err := NewMistralProvider(Config{APIKey: "test-key"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+			result, err := provider.Analyze(context.Background(), "Test prompt")
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("error = %v, want containing %q", err, tt.expectError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expectResult {
+				t.Errorf("result = %q, want %q", result, tt.expectResult)
+			}
+		})
+	}
+}
+
+func TestMistralProvider_OptionalRequestFields(t *testing.T) {
+	tests := []struct {
+		name           string
+		safePrompt     bool
+		jsonOutput     bool
+		wantSafePrompt bool
+		wantJSONMode   bool
+	}{
+		{"defaults omit both", false, false, false, false},
+		{"safe prompt", true, false, true, false},
+		{"json mode", false, true, false, true},
+		{"both", true, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				json.NewEncoder(w).Encode(map[string]any{
+					"choices": []map[string]any{
+						{"message": map[string]string{"content": "{}"}, "finish_reason": "stop"},
+					},
+				})
+			}))
+			defer server.Close()
+
+			provider, err := NewMistralProvider(Config{APIKey: "test-key", SafePrompt: tt.safePrompt})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+			ctx := WithCallOptions(context.Background(), CallOptions{JSONOutput: tt.jsonOutput})
+			if _, err := provider.Analyze(ctx, "Return JSON"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			safePrompt, hasSafePrompt := captured["safe_prompt"]
+			if hasSafePrompt != tt.wantSafePrompt || (hasSafePrompt && safePrompt != true) {
+				t.Errorf("safe_prompt = %v (present=%v), want present=%v", safePrompt, hasSafePrompt, tt.wantSafePrompt)
+			}
+
+			format, hasFormat := captured["response_format"].(map[string]any)
+			if hasFormat != tt.wantJSONMode {
+				t.Errorf("response_format present=%v, want %v", hasFormat, tt.wantJSONMode)
+			}
+			if hasFormat && format["type"] != "json_object" {
+				t.Errorf("response_format.type = %v, want json_object", format["type"])
+			}
+		})
+	}
+}
+
+func TestMistralProvider_RequestMaxTokens(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]string{"content": "ok"}, "finish_reason": "stop"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider, err := NewMistralProvider(Config{APIKey: "test-key", MaxTokens: 4096})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+	ctx := withRequestParams(context.Background(), requestParams{MaxTokens: 1500})
+	if _, err := provider.Analyze(ctx, "Review this"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if captured["max_tokens"] != float64(1500) {
+		t.Errorf("max_tokens = %v, want the per-request 1500", captured["max_tokens"])
+	}
+}
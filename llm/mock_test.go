@@ -44,6 +44,11 @@ func (m *MockProvider) Name() string {
 	return m.ProviderName
 }
 
+// Capabilities implements the Provider interface
+func (m *MockProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{SupportsTemperature: true}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
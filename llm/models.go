@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// ErrNoModelList is returned by providers whose API has no endpoint for listing models
+var ErrNoModelList = errors.New("the provider has no model list endpoint")
+
+// maxModelListSize bounds a model list response, which can run to a few megabytes on aggregators
+const maxModelListSize = 16 * 1024 * 1024
+
+// ModelLister is implemented by providers that can list the models available to them
+type ModelLister interface {
+	// ListModels returns the sorted IDs of the models the provider can serve, in the form
+	// accepted as a model override
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// getModelList sends a GET to a model list endpoint and decodes the JSON response into result
+func getModelList(ctx context.Context, client *http.Client, label, url string, headers map[string]string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer drainAndClose(resp.Body)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxModelListSize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(label, resp.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// listOpenAIModels lists models from an OpenAI-compatible /models endpoint, which OpenAI,
+// Mistral, and OpenRouter all serve
+func listOpenAIModels(ctx context.Context, client *http.Client, label, url string, headers map[string]string) ([]string, error) {
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getModelList(ctx, client, label, url, headers, &result); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(result.Data))
+	for _, model := range result.Data {
+		if model.ID != "" {
+			models = append(models, model.ID)
+		}
+	}
+	return sortedModels(models), nil
+}
+
+// sortedModels sorts model IDs and drops duplicates
+func sortedModels(models []string) []string {
+	slices.Sort(models)
+	return slices.Compact(models)
+}
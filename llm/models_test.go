@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// modelListServer serves body at path and records the request headers
+func modelListServer(t *testing.T, path, body string, headers *http.Header) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != path {
+			t.Errorf("unexpected request: %s %s, want GET %s", r.Method, r.URL.Path, path)
+		}
+		if headers != nil {
+			*headers = r.Header.Clone()
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestListModels_OpenAICompatible(t *testing.T) {
+	const body = `{"object":"list","data":[{"id":"gpt-4o-mini","object":"model"},{"id":"gpt-4o","object":"model"},{"id":""}]}`
+
+	tests := []struct {
+		name        string
+		path        string
+		newProvider func(client *http.Client) (ModelLister, error)
+		wantHeaders map[string]string
+	}{
+		{
+			name: "openai",
+			path: "/v1/models",
+			newProvider: func(client *http.Client) (ModelLister, error) {
+				p, err := NewOpenAIProvider(Config{APIKey: "test-key", Organization: "org-123"})
+				if err == nil {
+					p.httpClient = client
+				}
+				return p, err
+			},
+			wantHeaders: map[string]string{"Authorization": "Bearer test-key", "OpenAI-Organization": "org-123"},
+		},
+		{
+			name: "mistral",
+			path: "/v1/models",
+			newProvider: func(client *http.Client) (ModelLister, error) {
+				p, err := NewMistralProvider(Config{APIKey: "test-key"})
+				if err == nil {
+					p.httpClient = client
+				}
+				return p, err
+			},
+			wantHeaders: map[string]string{"Authorization": "Bearer test-key"},
+		},
+		{
+			name: "openrouter",
+			path: "/api/v1/models",
+			newProvider: func(client *http.Client) (ModelLister, error) {
+				p, err := NewOpenRouterProvider(Config{APIKey: "test-key"})
+				if err == nil {
+					p.httpClient = client
+				}
+				return p, err
+			},
+			wantHeaders: map[string]string{"Authorization": "Bearer test-key"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers http.Header
+			server := modelListServer(t, tt.path, body, &headers)
+			provider, err := tt.newProvider(&http.Client{Transport: &testTransport{testServer: server}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			models, err := provider.ListModels(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := []string{"gpt-4o", "gpt-4o-mini"}; !reflect.DeepEqual(models, want) {
+				t.Errorf("models = %v, want %v", models, want)
+			}
+			for header, want := range tt.wantHeaders {
+				if got := headers.Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestListModels_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"Incorrect API key provided: secret-key"}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider(Config{APIKey: "secret-key"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+	_, err = provider.ListModels(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a 401 API error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("error leaks the API key: %v", err)
+	}
+}
+
+func TestListModels_Ollama(t *testing.T) {
+	server := modelListServer(t, "/api/tags", `{"models":[{"name":"qwen2.5-coder:7b","size":4683087332},{"name":"devstral:latest"}]}`, nil)
+
+	provider, err := NewOllamaProvider(Config{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	models, err := provider.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"devstral:latest", "qwen2.5-coder:7b"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("models = %v, want %v", models, want)
+	}
+}
+
+func TestListModels_Google(t *testing.T) {
+	var pageTokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("x-goog-api-key"); got != "test-key" {
+			t.Errorf("x-goog-api-key = %q, want test-key", got)
+		}
+		token := r.URL.Query().Get("pageToken")
+		pageTokens = append(pageTokens, token)
+		if token == "" {
+			w.Write([]byte(`{"models":[
+				{"name":"models/gemini-2.0-flash","supportedGenerationMethods":["generateContent","countTokens"]},
+				{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}
+			],"nextPageToken":"page-2"}`))
+			return
+		}
+		w.Write([]byte(`{"models":[{"name":"models/gemini-1.5-pro","supportedGenerationMethods":["generateContent"]}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewGoogleProvider(Config{APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+	models, err := provider.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"gemini-1.5-pro", "gemini-2.0-flash"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("models = %v, want %v", models, want)
+	}
+	if want := []string{"", "page-2"}; !reflect.DeepEqual(pageTokens, want) {
+		t.Errorf("page tokens = %v, want %v", pageTokens, want)
+	}
+
+	t.Run("vertex", func(t *testing.T) {
+		provider, err := NewGoogleProvider(Config{UseVertex: true, VertexProject: "my-project"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := provider.ListModels(context.Background()); !errors.Is(err, ErrNoModelList) {
+			t.Errorf("expected ErrNoModelList, got %v", err)
+		}
+	})
+}
@@ -1,17 +1,26 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
 )
 
 const (
 	defaultOllamaEndpoint = "http://localhost:11434"
 	defaultOllamaModel    = "devstral:latest"
+
+	// ollamaVerifyTimeout bounds the startup reachability check
+	ollamaVerifyTimeout = 5 * time.Second
 )
 
 // OllamaProvider implements the Provider interface for Ollama
@@ -20,6 +29,8 @@ type OllamaProvider struct {
 	model       string
 	temperature float64
 	maxTokens   int
+	options     map[string]any // user overrides applied last
+	keepAlive   string         // how long the model stays loaded; empty uses the server default
 	retryConfig RetryConfig
 	httpClient  *http.Client
 }
@@ -36,90 +47,371 @@ func NewOllamaProvider(config Config) (*OllamaProvider, error) {
 		model = defaultOllamaModel
 	}
 
-	temperature := config.Temperature
-	if temperature == 0 {
-		temperature = 0.3
-	}
+	temperature := config.temperatureOrDefault()
 
 	maxTokens := config.MaxTokens
 	if maxTokens == 0 {
 		maxTokens = 4096
 	}
 
-	return &OllamaProvider{
+	httpClient, err := httpClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &OllamaProvider{
 		endpoint:    endpoint,
 		model:       model,
 		temperature: temperature,
 		maxTokens:   maxTokens,
+		options:     config.Options,
+		keepAlive:   config.KeepAlive,
 		retryConfig: DefaultRetryConfig(),
-		httpClient:  SharedHTTPClient,
-	}, nil
+		httpClient:  httpClient,
+	}
+
+	// Fail fast instead of letting every later request hit a dead endpoint
+	if config.VerifyOnStartup {
+		ctx, cancel := context.WithTimeout(context.Background(), ollamaVerifyTimeout)
+		defer cancel()
+		if err := provider.HealthCheck(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return provider, nil
+}
+
+// Analyze sends a prompt to Ollama and returns the response, streaming it when ctx carries a
+// stream callback
+func (p *OllamaProvider) Analyze(ctx context.Context, prompt string) (_ string, err error) {
+	if onChunk := StreamCallbackFromContext(ctx); onChunk != nil {
+		return p.StreamAnalyze(ctx, prompt, onChunk)
+	}
+	defer func() { err = sanitizeError(err) }()
+
+	release, resp, err := p.generate(ctx, prompt, false)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result ollamaGenerateResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if result.Error != "" {
+		return "", fmt.Errorf("the Ollama error: %s", result.Error)
+	}
+
+	return result.Response, nil
+}
+
+// StreamAnalyze sends a prompt to Ollama with streaming enabled, calling onChunk with each
+// piece of text as it arrives, and returns the full response
+func (p *OllamaProvider) StreamAnalyze(ctx context.Context, prompt string, onChunk func(string) error) (_ string, err error) {
+	defer func() { err = sanitizeError(err) }()
+
+	release, resp, err := p.generate(ctx, prompt, true)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	// Ollama streams one JSON object per line
+	var response strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var event ollamaGenerateResponse
+		if err := json.Unmarshal(line, &event); err != nil {
+			return "", fmt.Errorf("failed to parse stream event: %w", err)
+		}
+		if event.Error != "" {
+			return "", fmt.Errorf("the Ollama error: %s", event.Error)
+		}
+
+		if event.Response != "" {
+			response.WriteString(event.Response)
+			if err := onChunk(event.Response); err != nil {
+				return "", err
+			}
+		}
+		if event.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return response.String(), nil
+}
+
+// Chat sends a conversation to Ollama's /api/chat endpoint and returns the assistant's reply
+func (p *OllamaProvider) Chat(ctx context.Context, messages []Message) (_ string, err error) {
+	defer func() { err = sanitizeError(err) }()
+
+	if err := validateMessages(messages); err != nil {
+		return "", err
+	}
+
+	requestBody := map[string]any{
+		"model":    p.model,
+		"messages": chatMessages(ctx, messages),
+		"stream":   false,
+		"options":  p.requestOptions(ctx),
+	}
+	if p.keepAlive != "" {
+		requestBody["keep_alive"] = keepAliveValue(p.keepAlive)
+	}
+
+	release, resp, err := p.post(ctx, "/api/chat", requestBody)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result struct {
+		Message Message `json:"message"`
+		Error   string  `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if result.Error != "" {
+		return "", fmt.Errorf("the Ollama error: %s", result.Error)
+	}
+
+	return result.Message.Content, nil
+}
+
+// ollamaGenerateResponse is a /api/generate response, or one line of a streamed response
+type ollamaGenerateResponse struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
 }
 
-// Analyze sends a prompt to Ollama and returns the response
-func (p *OllamaProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+// generate posts the prompt to /api/generate and returns the successful response.
+// The caller must call release once it has finished reading the body.
+func (p *OllamaProvider) generate(ctx context.Context, prompt string, stream bool) (func(), *http.Response, error) {
+	requestBody := map[string]any{
+		"model":   p.model,
+		"prompt":  prompt,
+		"system":  SystemPromptFor(ctx),
+		"stream":  stream,
+		"options": p.requestOptions(ctx),
+	}
+	if p.keepAlive != "" {
+		requestBody["keep_alive"] = keepAliveValue(p.keepAlive)
+	}
+
+	return p.post(ctx, "/api/generate", requestBody)
+}
+
+// post sends requestBody to an Ollama API path and returns the successful response.
+// The caller must call release once it has finished reading the body.
+func (p *OllamaProvider) post(ctx context.Context, path string, requestBody map[string]any) (func(), *http.Response, error) {
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint+path, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Pace requests to stay under the provider's rate limit
+	if err := waitForRateLimit(ctx, p.Name()); err != nil {
+		return nil, nil, err
+	}
+
+	// Bound total in-flight requests across all providers
+	releaseSlot, err := acquireRequestSlot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := RetryableHTTPRequest(ctx, p.httpClient, req, p.retryConfig)
+	if err != nil {
+		releaseSlot()
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	release := func() {
+		drainAndClose(resp.Body)
+		releaseSlot()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		release()
+		return nil, nil, newAPIError("the Ollama API", resp.StatusCode, body)
+	}
+
+	return release, resp, nil
+}
+
+// Preload loads the model into memory so the first analysis does not pay the load time.
+// Ollama loads a model without generating when the prompt is empty.
+func (p *OllamaProvider) Preload(ctx context.Context) (err error) {
+	defer func() { err = sanitizeError(err) }()
+
 	requestBody := map[string]any{
 		"model":  p.model,
-		"prompt": prompt,
-		"system": "You are an expert code reviewer and git analysis assistant. Provide clear, actionable feedback.",
 		"stream": false,
-		"options": map[string]any{
-			"temperature":    p.temperature,
-			"num_predict":    p.maxTokens,
-			"top_k":          40,
-			"top_p":          0.9,
-			"repeat_last_n":  64,
-			"repeat_penalty": 1.1,
-		},
+	}
+	if p.keepAlive != "" {
+		requestBody["keep_alive"] = keepAliveValue(p.keepAlive)
 	}
 
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint+"/api/generate", bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
-
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := RetryableHTTPRequest(ctx, p.httpClient, req, p.retryConfig)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to preload model %s: %w", p.model, err)
 	}
-	defer func() {
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
-	}()
+	defer drainAndClose(resp.Body)
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("the Ollama API error preloading %s (status %d): %s", p.model, resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// keepAliveValue converts a keep-alive setting to the form Ollama expects:
+// plain numbers (e.g. -1 to keep the model loaded indefinitely) are sent as seconds, anything else as a duration string
+func keepAliveValue(keepAlive string) any {
+	if seconds, err := strconv.Atoi(keepAlive); err == nil {
+		return seconds
+	}
+	return keepAlive
+}
+
+// requestOptions builds the sampling options: defaults, then the optimization layer's
+// provider config for this request, then user overrides from config, then deterministic mode
+func (p *OllamaProvider) requestOptions(ctx context.Context) map[string]any {
+	options := map[string]any{
+		"temperature":    p.temperature,
+		"num_predict":    maxTokensFor(ctx, p.maxTokens),
+		"top_k":          40,
+		"top_p":          0.9,
+		"repeat_last_n":  64,
+		"repeat_penalty": 1.1,
+	}
+
+	if params, ok := requestParamsFromContext(ctx); ok {
+		for k, v := range params.ProviderConfig {
+			options[k] = v
+		}
+	}
+
+	for k, v := range p.options {
+		options[k] = v
+	}
+
+	// Retries of empty responses sample slightly warmer
+	if temperature, ok := options["temperature"].(float64); ok {
+		options["temperature"] = boostTemperature(temperature, temperatureBoostFromContext(ctx))
+	}
+
+	// A deterministic call takes precedence over configured sampling options
+	if opts := CallOptionsFromContext(ctx); opts.Deterministic {
+		options["temperature"] = 0
+		options["seed"] = opts.Seed
+	}
+
+	return options
+}
+
+// HealthCheck verifies the Ollama server is reachable by listing its local models
+func (p *OllamaProvider) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.endpoint+"/api/tags", nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("the Ollama endpoint %s is unreachable: %w", p.endpoint, err)
+	}
+	defer drainAndClose(resp.Body)
+
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("the Ollama API error (status %d): %s", resp.StatusCode, string(body))
+		return fmt.Errorf("the Ollama endpoint %s returned status %d", p.endpoint, resp.StatusCode)
 	}
 
+	return nil
+}
+
+// ListModels returns the models pulled to the Ollama server
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
 	var result struct {
-		Response string `json:"response"`
-		Error    string `json:"error,omitempty"`
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
 	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+	if err := getModelList(ctx, p.httpClient, "Ollama API", p.endpoint+"/api/tags", nil, &result); err != nil {
+		return nil, err
 	}
 
-	if result.Error != "" {
-		return "", fmt.Errorf("the Ollama error: %s", result.Error)
+	models := make([]string, 0, len(result.Models))
+	for _, model := range result.Models {
+		if model.Name != "" {
+			models = append(models, model.Name)
+		}
 	}
-
-	return result.Response, nil
+	return sortedModels(models), nil
 }
 
 // Name returns the provider name
 func (p *OllamaProvider) Name() string {
 	return "ollama"
 }
+
+// Model returns the configured model name
+func (p *OllamaProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports what the configured model supports
+func (p *OllamaProvider) Capabilities() ProviderCapabilities {
+	// Local models outside the known table report an unknown context window
+	return ProviderCapabilities{
+		ContextWindow:       config.ModelContextWindow(p.model),
+		SupportsTemperature: true,
+		SupportsStreaming:   true,
+		SupportsJSON:        true,
+	}
+}
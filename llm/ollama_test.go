@@ -3,12 +3,15 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/dshills/second-opinion/config"
 )
 
 // TestOllamaEndpointConnectivity tests basic connectivity to the Ollama endpoint
@@ -607,3 +610,274 @@ index 123..456 100644
 		})
 	}
 }
+
+// TestOllamaHealthCheck tests the /api/tags health probe
+func TestOllamaHealthCheck(t *testing.T) {
+	t.Run("reachable", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/tags" || r.Method != http.MethodGet {
+				t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			}
+			w.Write([]byte(`{"models":[]}`))
+		}))
+		defer server.Close()
+
+		provider, err := NewOllamaProvider(Config{Endpoint: server.URL})
+		if err != nil {
+			t.Fatalf("Failed to create provider: %v", err)
+		}
+		if err := provider.HealthCheck(context.Background()); err != nil {
+			t.Errorf("HealthCheck() unexpected error: %v", err)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		provider, err := NewOllamaProvider(Config{Endpoint: server.URL})
+		if err != nil {
+			t.Fatalf("Failed to create provider: %v", err)
+		}
+		err = provider.HealthCheck(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "status 500") {
+			t.Errorf("HealthCheck() error = %v, want status 500", err)
+		}
+	})
+}
+
+// TestOllamaOptimizedOptions verifies the optimization layer's sampling options reach the request body
+func TestOllamaOptimizedOptions(t *testing.T) {
+	var capturedOptions map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		capturedOptions, _ = body["options"].(map[string]any)
+		json.NewEncoder(w).Encode(map[string]any{"response": "OK", "done": true})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, ChunkSizeMB: 1},
+	}
+	prompt := "Review this code"
+
+	t.Run("optimized values", func(t *testing.T) {
+		provider, err := NewOllamaProvider(Config{Endpoint: server.URL, Model: "test-model"})
+		if err != nil {
+			t.Fatalf("Failed to create provider: %v", err)
+		}
+
+		if _, err := NewOptimizedProvider(provider, cfg).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview); err != nil {
+			t.Fatalf("AnalyzeOptimized() unexpected error: %v", err)
+		}
+
+		if capturedOptions["top_k"] != float64(20) {
+			t.Errorf("top_k = %v, want 20", capturedOptions["top_k"])
+		}
+		if capturedOptions["top_p"] != 0.8 {
+			t.Errorf("top_p = %v, want 0.8", capturedOptions["top_p"])
+		}
+		if capturedOptions["repeat_penalty"] != 1.05 {
+			t.Errorf("repeat_penalty = %v, want 1.05", capturedOptions["repeat_penalty"])
+		}
+	})
+
+	t.Run("config overrides win", func(t *testing.T) {
+		provider, err := NewOllamaProvider(Config{
+			Endpoint: server.URL,
+			Model:    "test-model",
+			Options:  map[string]any{"top_k": 30},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create provider: %v", err)
+		}
+
+		if _, err := NewOptimizedProvider(provider, cfg).AnalyzeOptimized(context.Background(), prompt, len(prompt), config.TaskCodeReview); err != nil {
+			t.Fatalf("AnalyzeOptimized() unexpected error: %v", err)
+		}
+
+		if capturedOptions["top_k"] != float64(30) {
+			t.Errorf("top_k = %v, want 30", capturedOptions["top_k"])
+		}
+		if capturedOptions["top_p"] != 0.8 {
+			t.Errorf("top_p = %v, want 0.8", capturedOptions["top_p"])
+		}
+	})
+}
+
+// TestOllamaKeepAlive verifies keep_alive is sent only when configured
+func TestOllamaKeepAlive(t *testing.T) {
+	var capturedRequest map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedRequest = nil
+		if err := json.NewDecoder(r.Body).Decode(&capturedRequest); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"response": "OK", "done": true})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		keepAlive string
+		want      any
+	}{
+		{"duration", "30m", "30m"},
+		{"indefinitely", "-1", float64(-1)},
+		{"unset", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewOllamaProvider(Config{Endpoint: server.URL, KeepAlive: tt.keepAlive})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			if _, err := provider.Analyze(context.Background(), "Test prompt"); err != nil {
+				t.Fatalf("Analyze() unexpected error: %v", err)
+			}
+
+			got, present := capturedRequest["keep_alive"]
+			if tt.want == nil {
+				if present {
+					t.Errorf("keep_alive = %v, want it omitted", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("keep_alive = %v (%T), want %v", got, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOllamaPreload verifies preloading sends an empty-prompt generate request
+func TestOllamaPreload(t *testing.T) {
+	var capturedRequest map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&capturedRequest); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"response": "", "done": true})
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(Config{Endpoint: server.URL, Model: "test-model", KeepAlive: "1h"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if err := provider.Preload(context.Background()); err != nil {
+		t.Fatalf("Preload() unexpected error: %v", err)
+	}
+
+	if capturedRequest["model"] != "test-model" {
+		t.Errorf("model = %v, want test-model", capturedRequest["model"])
+	}
+	if _, hasPrompt := capturedRequest["prompt"]; hasPrompt {
+		t.Errorf("preload request should not include a prompt: %v", capturedRequest)
+	}
+	if capturedRequest["keep_alive"] != "1h" {
+		t.Errorf("keep_alive = %v, want 1h", capturedRequest["keep_alive"])
+	}
+}
+
+// TestOllamaVerifyOnStartup verifies provider creation fails fast for an unreachable endpoint
+func TestOllamaVerifyOnStartup(t *testing.T) {
+	// Reserve a port and close it so nothing is listening there
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	closedEndpoint := "http://" + listener.Addr().String()
+	listener.Close()
+
+	if _, err := NewOllamaProvider(Config{Endpoint: closedEndpoint}); err != nil {
+		t.Errorf("lazy provider creation should not connect, got error: %v", err)
+	}
+
+	_, err = NewOllamaProvider(Config{Endpoint: closedEndpoint, VerifyOnStartup: true})
+	if err == nil {
+		t.Fatal("expected an error for an unreachable endpoint")
+	}
+	if !strings.Contains(err.Error(), closedEndpoint) {
+		t.Errorf("error should name the endpoint %s, got: %v", closedEndpoint, err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"models": []any{}})
+	}))
+	defer server.Close()
+
+	if _, err := NewOllamaProvider(Config{Endpoint: server.URL, VerifyOnStartup: true}); err != nil {
+		t.Errorf("expected reachable endpoint to verify, got: %v", err)
+	}
+}
+
+func TestOllamaStreamAnalyze(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if request["stream"] != true {
+			t.Errorf("stream = %v, want true", request["stream"])
+		}
+		w.Write([]byte(`{"response":"Looks ","done":false}` + "\n"))
+		w.Write([]byte(`{"response":"good.","done":false}` + "\n"))
+		w.Write([]byte(`{"response":"","done":true}` + "\n"))
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(Config{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	var chunks []string
+	result, err := provider.StreamAnalyze(context.Background(), "Review this", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamAnalyze() unexpected error: %v", err)
+	}
+	if result != "Looks good." {
+		t.Errorf("result = %q, want %q", result, "Looks good.")
+	}
+	if len(chunks) != 2 {
+		t.Errorf("received %d chunks, want 2: %q", len(chunks), chunks)
+	}
+}
+
+func TestOllamaDeterministicOptions(t *testing.T) {
+	provider, err := NewOllamaProvider(Config{
+		Model:       "llama3",
+		Temperature: 0.7,
+		Options:     map[string]any{"temperature": 0.9},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	options := provider.requestOptions(context.Background())
+	if _, ok := options["seed"]; ok {
+		t.Errorf("seed should be omitted for non-deterministic calls, got %v", options["seed"])
+	}
+
+	ctx := WithCallOptions(context.Background(), CallOptions{Deterministic: true, Seed: 7})
+	options = provider.requestOptions(ctx)
+	if options["temperature"] != 0 {
+		t.Errorf("temperature = %v, want 0", options["temperature"])
+	}
+	if options["seed"] != 7 {
+		t.Errorf("seed = %v, want 7", options["seed"])
+	}
+}
@@ -8,21 +8,26 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/dshills/second-opinion/config"
 )
 
 const (
-	OpenAIURL      = "https://api.openai.com/v1/chat/completions"
-	openAIProvider = "openai"
+	OpenAIURL       = "https://api.openai.com/v1/chat/completions"
+	OpenAIModelsURL = "https://api.openai.com/v1/models"
+	openAIProvider  = "openai"
 )
 
 // OpenAIProvider implements the Provider interface for OpenAI
 type OpenAIProvider struct {
-	apiKey      string
-	model       string
-	temperature float64
-	maxTokens   int
-	retryConfig RetryConfig
-	httpClient  *http.Client
+	apiKey       string
+	model        string
+	organization string
+	project      string
+	temperature  float64
+	maxTokens    int
+	retryConfig  RetryConfig
+	httpClient   *http.Client
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
@@ -36,21 +41,27 @@ func NewOpenAIProvider(config Config) (*OpenAIProvider, error) {
 		model = "gpt-4o-mini"
 	}
 
-	// Use temperature as provided - OpenAI supports 0 temperature for deterministic output
-	temperature := config.Temperature
+	temperature := config.temperatureOrDefault()
 
 	maxTokens := config.MaxTokens
 	if maxTokens == 0 {
 		maxTokens = 4096
 	}
 
+	httpClient, err := httpClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &OpenAIProvider{
-		apiKey:      config.APIKey,
-		model:       model,
-		temperature: temperature,
-		maxTokens:   maxTokens,
-		retryConfig: DefaultRetryConfig(),
-		httpClient:  SharedHTTPClient,
+		apiKey:       config.APIKey,
+		model:        model,
+		organization: config.Organization,
+		project:      config.Project,
+		temperature:  temperature,
+		maxTokens:    maxTokens,
+		retryConfig:  DefaultRetryConfig(),
+		httpClient:   httpClient,
 	}, nil
 }
 
@@ -62,36 +73,41 @@ func (p *OpenAIProvider) isNewGenerationModel() bool {
 
 // supportsCustomTemperature checks if the model supports custom temperature values
 func (p *OpenAIProvider) supportsCustomTemperature() bool {
-	return !p.isNewGenerationModel() // o3/o4 models only support default temperature of 1.0
+	return !config.HasFixedTemperature(p.Name(), p.model) // o3/o4 models only support default temperature of 1.0
 }
 
 // Analyze sends a prompt to OpenAI and returns the response
 func (p *OpenAIProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	return p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}})
+}
+
+// Chat sends a conversation to OpenAI and returns the assistant's reply
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message) (_ string, err error) {
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	if err := validateMessages(messages); err != nil {
+		return "", err
+	}
+
 	requestBody := map[string]any{
-		"model": p.model,
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": "You are an expert code reviewer and git analysis assistant. Provide clear, actionable feedback.",
-			},
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-	}
-
-	// Set temperature only for models that support custom values
+		"model":    p.model,
+		"messages": chatMessages(ctx, messages),
+	}
+
+	// Set temperature and seed only for models that support custom values
 	if p.supportsCustomTemperature() {
-		requestBody["temperature"] = p.temperature
+		requestBody["temperature"] = temperatureFor(ctx, p.temperature)
+		if opts := CallOptionsFromContext(ctx); opts.Deterministic {
+			requestBody["seed"] = opts.Seed
+		}
 	}
 	// o3/o4 models use default temperature of 1.0 (no need to set explicitly)
 
 	// Use max_completion_tokens for o3/o4 models, max_tokens for others
 	if p.isNewGenerationModel() {
-		requestBody["max_completion_tokens"] = p.maxTokens
+		requestBody["max_completion_tokens"] = maxTokensFor(ctx, p.maxTokens)
 	} else {
-		requestBody["max_tokens"] = p.maxTokens
+		requestBody["max_tokens"] = maxTokensFor(ctx, p.maxTokens)
 	}
 
 	jsonBody, err := json.Marshal(requestBody)
@@ -107,14 +123,31 @@ func (p *OpenAIProvider) Analyze(ctx context.Context, prompt string) (string, er
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 
+	// Billing attribution headers are only sent when configured, so personal keys keep working
+	if p.organization != "" {
+		req.Header.Set("OpenAI-Organization", p.organization)
+	}
+	if p.project != "" {
+		req.Header.Set("OpenAI-Project", p.project)
+	}
+
+	// Pace requests to stay under the provider's rate limit
+	if err := waitForRateLimit(ctx, p.Name()); err != nil {
+		return "", err
+	}
+
+	// Bound total in-flight requests across all providers
+	release, err := acquireRequestSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	resp, err := RetryableHTTPRequest(ctx, p.httpClient, req, p.retryConfig)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request: %w", err)
 	}
-	defer func() {
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
-	}()
+	defer drainAndClose(resp.Body)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -122,7 +155,7 @@ func (p *OpenAIProvider) Analyze(ctx context.Context, prompt string) (string, er
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
+		return "", newAPIError("OpenAI API", resp.StatusCode, body)
 	}
 
 	var result struct {
@@ -130,6 +163,7 @@ func (p *OpenAIProvider) Analyze(ctx context.Context, prompt string) (string, er
 			Message struct {
 				Content string `json:"content"`
 			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
 	}
 
@@ -141,10 +175,39 @@ func (p *OpenAIProvider) Analyze(ctx context.Context, prompt string) (string, er
 		return "", fmt.Errorf("no response from OpenAI")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	return applyFinishReason("OpenAI", result.Choices[0].Message.Content, result.Choices[0].FinishReason)
+}
+
+// ListModels returns the models available to the API key
+func (p *OpenAIProvider) ListModels(ctx context.Context) (_ []string, err error) {
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	headers := map[string]string{"Authorization": "Bearer " + p.apiKey}
+	if p.organization != "" {
+		headers["OpenAI-Organization"] = p.organization
+	}
+	if p.project != "" {
+		headers["OpenAI-Project"] = p.project
+	}
+	return listOpenAIModels(ctx, p.httpClient, "OpenAI API", OpenAIModelsURL, headers)
 }
 
 // Name returns the provider name
 func (p *OpenAIProvider) Name() string {
 	return openAIProvider
 }
+
+// Model returns the configured model name
+func (p *OpenAIProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports what the configured model supports
+func (p *OpenAIProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{
+		ContextWindow:       config.ModelContextWindow(p.model),
+		SupportsTemperature: p.supportsCustomTemperature(),
+		SupportsStreaming:   false,
+		SupportsJSON:        true,
+	}
+}
@@ -46,14 +46,15 @@ func TestNewOpenAIProvider(t *testing.T) {
 			},
 			expectError: false,
 			expectModel: "gpt-4o-mini",
-			expectTemp:  0,
+			expectTemp:  0.3,
 			expectMax:   4096,
 		},
 		{
 			name: "Zero temperature explicitly set",
 			config: Config{
-				APIKey:      "test-key",
-				Temperature: 0,
+				APIKey:         "test-key",
+				Temperature:    0,
+				TemperatureSet: true,
 			},
 			expectError: false,
 			expectModel: "gpt-4o-mini",
@@ -332,3 +333,195 @@ func (t *testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	req.URL.Host = strings.TrimPrefix(testURL, "http://")
 	return http.DefaultTransport.RoundTrip(req)
 }
+
+func TestOpenAIProvider_OrganizationHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		organization string
+		project      string
+	}{
+		{"both set", "org-123", "proj_456"},
+		{"organization only", "org-123", ""},
+		{"neither set", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers = r.Header.Clone()
+				w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+			}))
+			defer server.Close()
+
+			provider, err := NewOpenAIProvider(Config{
+				APIKey:       "test-key",
+				Organization: tt.organization,
+				Project:      tt.project,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+			if _, err := provider.Analyze(context.Background(), "Test prompt"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for header, want := range map[string]string{
+				"OpenAI-Organization": tt.organization,
+				"OpenAI-Project":      tt.project,
+			} {
+				values, present := headers[http.CanonicalHeaderKey(header)]
+				if want == "" {
+					if present {
+						t.Errorf("%s header should be absent, got %v", header, values)
+					}
+					continue
+				}
+				if got := headers.Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIProvider_FinishReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		finishReason string
+		expectError  string
+		expectResult string
+	}{
+		{"stop", "stop", "", "Full answer"},
+		{"length", "length", "", "Full answer\n\n" + TruncatedResponseNote},
+		{"content filter", "content_filter", "blocked by the content filter", ""},
+		{"missing", "", "", "Full answer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				resp := map[string]any{
+					"choices": []map[string]any{
+						{
+							"message":       map[string]string{"content": "Full answer"},
+							"finish_reason": tt.finishReason,
+						},
+					},
+				}
+				json.NewEncoder(w).Encode(resp)
+			}))
+			defer server.Close()
+
+			provider, err := NewOpenAIProvider(Config{APIKey: "test-key"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+			result, err := provider.Analyze(context.Background(), "Test prompt")
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("error = %v, want containing %q", err, tt.expectError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expectResult {
+				t.Errorf("result = %q, want %q", result, tt.expectResult)
+			}
+		})
+	}
+}
+
+func TestOpenAIProvider_Deterministic(t *testing.T) {
+	tests := []struct {
+		model    string
+		wantSeed bool
+	}{
+		{"gpt-4o-mini", true},
+		{"o3-mini", false},
+		{"o4-mini", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			var captured map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+			}))
+			defer server.Close()
+
+			provider, err := NewOpenAIProvider(Config{APIKey: "test-key", Model: tt.model, Temperature: 0.7})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+			ctx := WithCallOptions(context.Background(), CallOptions{Deterministic: true, Seed: 7})
+			if _, err := provider.Analyze(ctx, "Test prompt"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			seed, hasSeed := captured["seed"]
+			temperature, hasTemperature := captured["temperature"]
+			if !tt.wantSeed {
+				if hasSeed || hasTemperature {
+					t.Errorf("seed/temperature should be omitted, got seed=%v temperature=%v", seed, temperature)
+				}
+				return
+			}
+			if seed != float64(7) {
+				t.Errorf("seed = %v, want 7", seed)
+			}
+			if temperature != float64(0) {
+				t.Errorf("temperature = %v, want 0", temperature)
+			}
+		})
+	}
+}
+
+func TestOpenAIProvider_RequestMaxTokens(t *testing.T) {
+	tests := []struct {
+		model string
+		field string
+	}{
+		{"gpt-4o-mini", "max_tokens"},
+		{"o3-mini", "max_completion_tokens"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			var captured map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+			}))
+			defer server.Close()
+
+			provider, err := NewOpenAIProvider(Config{APIKey: "test-key", Model: tt.model, MaxTokens: 4096})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+			ctx := withRequestParams(context.Background(), requestParams{MaxTokens: 1500})
+			if _, err := provider.Analyze(ctx, "Test prompt"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if captured[tt.field] != float64(1500) {
+				t.Errorf("%s = %v, want the per-request 1500", tt.field, captured[tt.field])
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+const (
+	OpenRouterURL       = "https://openrouter.ai/api/v1/chat/completions"
+	OpenRouterModelsURL = "https://openrouter.ai/api/v1/models"
+	openRouterProvider  = "openrouter"
+
+	defaultOpenRouterModel   = "openai/gpt-4o-mini"
+	defaultOpenRouterReferer = "https://github.com/dshills/second-opinion"
+	defaultOpenRouterTitle   = "Second Opinion"
+)
+
+// OpenRouterProvider implements the Provider interface for OpenRouter's OpenAI-compatible API
+type OpenRouterProvider struct {
+	apiKey      string
+	model       string
+	models      []string // fallback models tried in order if the primary fails
+	referer     string
+	title       string
+	temperature float64
+	maxTokens   int
+	retryConfig RetryConfig
+	httpClient  *http.Client
+}
+
+// NewOpenRouterProvider creates a new OpenRouter provider
+func NewOpenRouterProvider(config Config) (*OpenRouterProvider, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("the OpenRouter API key is required")
+	}
+
+	model := config.Model
+	if model == "" {
+		model = defaultOpenRouterModel
+	}
+
+	maxTokens := config.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 4096
+	}
+
+	referer := config.Referer
+	if referer == "" {
+		referer = defaultOpenRouterReferer
+	}
+
+	title := config.Title
+	if title == "" {
+		title = defaultOpenRouterTitle
+	}
+
+	httpClient, err := httpClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpenRouterProvider{
+		apiKey:      config.APIKey,
+		model:       model,
+		models:      config.Models,
+		referer:     referer,
+		title:       title,
+		temperature: config.temperatureOrDefault(),
+		maxTokens:   maxTokens,
+		retryConfig: DefaultRetryConfig(),
+		httpClient:  httpClient,
+	}, nil
+}
+
+// Analyze sends a prompt to OpenRouter and returns the response
+func (p *OpenRouterProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	return p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}})
+}
+
+// Chat sends a conversation to OpenRouter and returns the assistant's reply
+func (p *OpenRouterProvider) Chat(ctx context.Context, messages []Message) (_ string, err error) {
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	if err := validateMessages(messages); err != nil {
+		return "", err
+	}
+
+	requestBody := map[string]any{
+		"model":       p.model,
+		"messages":    chatMessages(ctx, messages),
+		"temperature": temperatureFor(ctx, p.temperature),
+		"max_tokens":  maxTokensFor(ctx, p.maxTokens),
+	}
+
+	// OpenRouter falls back through these models when the primary is unavailable
+	if len(p.models) > 0 {
+		requestBody["models"] = p.models
+	}
+
+	if CallOptionsFromContext(ctx).JSONOutput {
+		requestBody["response_format"] = map[string]string{"type": "json_object"}
+	}
+	if opts := CallOptionsFromContext(ctx); opts.Deterministic {
+		requestBody["seed"] = opts.Seed
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", OpenRouterURL, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	// Attribution headers OpenRouter uses to identify the calling app
+	req.Header.Set("HTTP-Referer", p.referer)
+	req.Header.Set("X-Title", p.title)
+
+	// Pace requests to stay under the provider's rate limit
+	if err := waitForRateLimit(ctx, p.Name()); err != nil {
+		return "", err
+	}
+
+	// Bound total in-flight requests across all providers
+	release, err := acquireRequestSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	resp, err := RetryableHTTPRequest(ctx, p.httpClient, req, p.retryConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer drainAndClose(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError("OpenRouter API", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("no response from OpenRouter")
+	}
+
+	return applyFinishReason("OpenRouter", result.Choices[0].Message.Content, result.Choices[0].FinishReason)
+}
+
+// ListModels returns the models OpenRouter can route to, as provider-prefixed IDs
+func (p *OpenRouterProvider) ListModels(ctx context.Context) (_ []string, err error) {
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	headers := map[string]string{"Authorization": "Bearer " + p.apiKey}
+	return listOpenAIModels(ctx, p.httpClient, "OpenRouter API", OpenRouterModelsURL, headers)
+}
+
+// Name returns the provider name
+func (p *OpenRouterProvider) Name() string {
+	return openRouterProvider
+}
+
+// Model returns the configured model name
+func (p *OpenRouterProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports what the configured model supports
+func (p *OpenRouterProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{
+		ContextWindow:       config.ModelContextWindow(p.model),
+		SupportsTemperature: !config.HasFixedTemperature("openai", p.model),
+		SupportsStreaming:   false,
+		SupportsJSON:        true,
+	}
+}
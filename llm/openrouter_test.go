@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewOpenRouterProvider(t *testing.T) {
+	if _, err := NewOpenRouterProvider(Config{}); err == nil {
+		t.Error("expected error without an API key")
+	}
+
+	provider, err := NewOpenRouterProvider(Config{APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if provider.Model() != "openai/gpt-4o-mini" {
+		t.Errorf("default model = %q, want openai/gpt-4o-mini", provider.Model())
+	}
+	if provider.Name() != "openrouter" {
+		t.Errorf("Name() = %q, want openrouter", provider.Name())
+	}
+
+	registered, err := NewProvider(Config{Provider: "openrouter", APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("NewProvider(openrouter) failed: %v", err)
+	}
+	if _, ok := registered.(*OpenRouterProvider); !ok {
+		t.Errorf("NewProvider returned %T, want *OpenRouterProvider", registered)
+	}
+}
+
+func TestOpenRouterProvider_Request(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		wantModels []any
+		wantTitle  string
+	}{
+		{
+			name:      "single model with default attribution",
+			config:    Config{APIKey: "test-key", Model: "anthropic/claude-3.5-sonnet"},
+			wantTitle: "Second Opinion",
+		},
+		{
+			name:       "fallback models and custom attribution",
+			config:     Config{APIKey: "test-key", Model: "openai/gpt-4o", Models: []string{"openai/gpt-4o", "mistralai/mistral-large"}, Title: "My Reviewer", Referer: "https://example.com"},
+			wantModels: []any{"openai/gpt-4o", "mistralai/mistral-large"},
+			wantTitle:  "My Reviewer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured map[string]any
+			var headers http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers = r.Header.Clone()
+				if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				json.NewEncoder(w).Encode(map[string]any{
+					"choices": []map[string]any{
+						{"message": map[string]string{"content": "Looks good"}, "finish_reason": "stop"},
+					},
+				})
+			}))
+			defer server.Close()
+
+			provider, err := NewOpenRouterProvider(tt.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+			result, err := provider.Analyze(context.Background(), "Review this")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != "Looks good" {
+				t.Errorf("result = %q, want %q", result, "Looks good")
+			}
+
+			if got := headers.Get("Authorization"); got != "Bearer test-key" {
+				t.Errorf("Authorization = %q", got)
+			}
+			if headers.Get("HTTP-Referer") == "" {
+				t.Error("missing HTTP-Referer header")
+			}
+			if got := headers.Get("X-Title"); got != tt.wantTitle {
+				t.Errorf("X-Title = %q, want %q", got, tt.wantTitle)
+			}
+
+			if captured["model"] != tt.config.Model {
+				t.Errorf("model = %v, want %s", captured["model"], tt.config.Model)
+			}
+			models, hasModels := captured["models"].([]any)
+			if tt.wantModels == nil {
+				if hasModels {
+					t.Errorf("models should be omitted without fallbacks, got %v", models)
+				}
+				return
+			}
+			if len(models) != len(tt.wantModels) || models[0] != tt.wantModels[0] || models[1] != tt.wantModels[1] {
+				t.Errorf("models = %v, want %v", models, tt.wantModels)
+			}
+		})
+	}
+}
+
+func TestOpenRouterProvider_RequestMaxTokens(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]string{"content": "ok"}, "finish_reason": "stop"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenRouterProvider(Config{APIKey: "test-key", MaxTokens: 4096})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+	ctx := withRequestParams(context.Background(), requestParams{MaxTokens: 1500})
+	if _, err := provider.Analyze(ctx, "Review this"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if captured["max_tokens"] != float64(1500) {
+		t.Errorf("max_tokens = %v, want the per-request 1500", captured["max_tokens"])
+	}
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"math"
+)
+
+// CallOptions carries per-call settings from tool handlers down to the optimization layer and providers
+type CallOptions struct {
+	// IgnoreContextWindow sends prompts even when they likely exceed the model's context window
+	IgnoreContextWindow bool
+	// JSONOutput asks providers with a native JSON mode to return a single JSON object
+	JSONOutput bool
+	// Deterministic forces temperature 0, sends Seed to providers that support seeded
+	// sampling, and disables retry jitter so repeated calls give reproducible output
+	Deterministic bool
+	// Seed is the sampling seed used when Deterministic is set
+	Seed int
+	// DryRun returns a description of the assembled request in place of calling the provider
+	DryRun bool
+	// Language selects a language-specific reviewer system prompt; empty uses the generic one
+	Language string
+	// OutputLanguage is the English name of the language responses are written in, as resolved
+	// by config.ResolveOutputLanguage; empty leaves it to the model
+	OutputLanguage string
+	// Persona selects a reviewer tone from config.Personas, prefixed to the system prompt; empty
+	// uses the neutral voice
+	Persona string
+}
+
+// DefaultSeed is the sampling seed used by deterministic calls that do not choose one
+const DefaultSeed = 42
+
+type callOptionsKey struct{}
+
+// WithCallOptions returns a context carrying per-call options
+func WithCallOptions(ctx context.Context, opts CallOptions) context.Context {
+	return context.WithValue(ctx, callOptionsKey{}, opts)
+}
+
+// CallOptionsFromContext returns the per-call options carried by ctx, or the zero value
+func CallOptionsFromContext(ctx context.Context) CallOptions {
+	if opts, ok := ctx.Value(callOptionsKey{}).(CallOptions); ok {
+		return opts
+	}
+	return CallOptions{}
+}
+
+// temperatureFor returns the temperature for a request: 0 for deterministic calls, otherwise the
+// configured value raised by any retry boost
+func temperatureFor(ctx context.Context, configured float64) float64 {
+	if CallOptionsFromContext(ctx).Deterministic {
+		return 0
+	}
+	return boostTemperature(configured, temperatureBoostFromContext(ctx))
+}
+
+// boostTemperature raises temperature by boost without pushing it past 1, unless it was already higher
+func boostTemperature(temperature, boost float64) float64 {
+	if boost <= 0 {
+		return temperature
+	}
+	return math.Max(temperature, math.Min(temperature+boost, 1))
+}
+
+type temperatureBoostKey struct{}
+
+// withTemperatureBoost returns a context asking providers to sample slightly warmer than configured
+func withTemperatureBoost(ctx context.Context, boost float64) context.Context {
+	return context.WithValue(ctx, temperatureBoostKey{}, boost)
+}
+
+// temperatureBoostFromContext returns the temperature boost carried by ctx, or 0
+func temperatureBoostFromContext(ctx context.Context) float64 {
+	boost, _ := ctx.Value(temperatureBoostKey{}).(float64)
+	return boost
+}
+
+// requestParams carries the parameters computed by the optimization layer down to providers
+type requestParams struct {
+	MaxTokens      int
+	Temperature    float64
+	ProviderConfig map[string]any
+}
+
+type requestParamsKey struct{}
+
+// withRequestParams returns a context carrying optimized request parameters
+func withRequestParams(ctx context.Context, params requestParams) context.Context {
+	return context.WithValue(ctx, requestParamsKey{}, params)
+}
+
+// requestParamsFromContext returns the optimized request parameters carried by ctx, if any
+func requestParamsFromContext(ctx context.Context) (requestParams, bool) {
+	params, ok := ctx.Value(requestParamsKey{}).(requestParams)
+	return params, ok
+}
+
+// maxTokensFor returns the output budget for a request: the optimization layer's value when ctx
+// carries one, otherwise the provider's configured value
+func maxTokensFor(ctx context.Context, configured int) int {
+	if params, ok := requestParamsFromContext(ctx); ok && params.MaxTokens > 0 {
+		return params.MaxTokens
+	}
+	return configured
+}
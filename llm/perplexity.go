@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+const (
+	PerplexityURL      = "https://api.perplexity.ai/chat/completions"
+	perplexityProvider = "perplexity"
+
+	defaultPerplexityModel = "sonar"
+)
+
+// PerplexityProvider implements the Provider interface for Perplexity's OpenAI-compatible API,
+// whose sonar models ground answers in live web search results
+type PerplexityProvider struct {
+	apiKey      string
+	model       string
+	temperature float64
+	maxTokens   int
+	retryConfig RetryConfig
+	httpClient  *http.Client
+}
+
+// NewPerplexityProvider creates a new Perplexity provider
+func NewPerplexityProvider(config Config) (*PerplexityProvider, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("the Perplexity API key is required")
+	}
+
+	model := config.Model
+	if model == "" {
+		model = defaultPerplexityModel
+	}
+
+	maxTokens := config.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 4096
+	}
+
+	httpClient, err := httpClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PerplexityProvider{
+		apiKey:      config.APIKey,
+		model:       model,
+		temperature: config.temperatureOrDefault(),
+		maxTokens:   maxTokens,
+		retryConfig: DefaultRetryConfig(),
+		httpClient:  httpClient,
+	}, nil
+}
+
+// Analyze sends a prompt to Perplexity and returns the response
+func (p *PerplexityProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	return p.Chat(ctx, []Message{{Role: RoleUser, Content: prompt}})
+}
+
+// Chat sends a conversation to Perplexity and returns the assistant's reply, followed by the
+// web sources it cited
+func (p *PerplexityProvider) Chat(ctx context.Context, messages []Message) (_ string, err error) {
+	defer func() { err = sanitizeError(err, p.apiKey) }()
+
+	if err := validateMessages(messages); err != nil {
+		return "", err
+	}
+
+	// Perplexity has no JSON object mode or sampling seed, so neither is sent
+	requestBody := map[string]any{
+		"model":       p.model,
+		"messages":    chatMessages(ctx, messages),
+		"temperature": temperatureFor(ctx, p.temperature),
+		"max_tokens":  maxTokensFor(ctx, p.maxTokens),
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", PerplexityURL, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	// Pace requests to stay under the provider's rate limit
+	if err := waitForRateLimit(ctx, p.Name()); err != nil {
+		return "", err
+	}
+
+	// Bound total in-flight requests across all providers
+	release, err := acquireRequestSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	resp, err := RetryableHTTPRequest(ctx, p.httpClient, req, p.retryConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer drainAndClose(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError("the Perplexity API", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Citations []string `json:"citations"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("no response from Perplexity")
+	}
+
+	content, err := applyFinishReason("Perplexity", result.Choices[0].Message.Content, result.Choices[0].FinishReason)
+	if err != nil {
+		return "", err
+	}
+	return appendCitations(content, result.Citations), nil
+}
+
+// appendCitations lists the sources behind a grounded answer, numbered to match the [1]-style
+// references in its text
+func appendCitations(content string, citations []string) string {
+	if len(citations) == 0 || strings.TrimSpace(content) == "" {
+		return content
+	}
+
+	var out strings.Builder
+	out.WriteString(strings.TrimRight(content, "\n"))
+	out.WriteString("\n\nSources:\n")
+	for i, citation := range citations {
+		out.WriteString(fmt.Sprintf("[%d] %s\n", i+1, citation))
+	}
+	return out.String()
+}
+
+// Name returns the provider name
+func (p *PerplexityProvider) Name() string {
+	return perplexityProvider
+}
+
+// Model returns the configured model name
+func (p *PerplexityProvider) Model() string {
+	return p.model
+}
+
+// Capabilities reports what the configured model supports
+func (p *PerplexityProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{
+		ContextWindow:       config.ModelContextWindow(p.model),
+		SupportsTemperature: true,
+		SupportsStreaming:   false,
+	}
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewPerplexityProvider(t *testing.T) {
+	if _, err := NewPerplexityProvider(Config{}); err == nil {
+		t.Error("expected an error without an API key")
+	}
+
+	provider, err := NewProvider(Config{Provider: "perplexity", APIKey: "pplx-test"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if provider.Name() != "perplexity" {
+		t.Errorf("Name() = %q, want perplexity", provider.Name())
+	}
+	if p := BaseProvider(provider).(*PerplexityProvider); p.model != "sonar" {
+		t.Errorf("model = %q, want the sonar default", p.model)
+	}
+}
+
+func TestPerplexityProvider_Chat(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  map[string]any
+		want      string
+		wantError string
+	}{
+		{
+			name: "citations appended",
+			response: map[string]any{
+				"choices": []map[string]any{
+					{"message": map[string]string{"content": "lodash 4.17.20 is affected by CVE-2021-23337 [1][2].\n"}, "finish_reason": "stop"},
+				},
+				"citations": []string{"https://nvd.nist.gov/vuln/detail/CVE-2021-23337", "https://github.com/advisories/GHSA-35jh-r3h4-6jhm"},
+			},
+			want: "lodash 4.17.20 is affected by CVE-2021-23337 [1][2].\n\nSources:\n" +
+				"[1] https://nvd.nist.gov/vuln/detail/CVE-2021-23337\n" +
+				"[2] https://github.com/advisories/GHSA-35jh-r3h4-6jhm\n",
+		},
+		{
+			name: "no citations",
+			response: map[string]any{
+				"choices": []map[string]any{
+					{"message": map[string]string{"content": "No known issues."}, "finish_reason": "stop"},
+				},
+			},
+			want: "No known issues.",
+		},
+		{
+			name:      "no choices",
+			response:  map[string]any{"choices": []any{}},
+			wantError: "no response from Perplexity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer pplx-test" {
+					t.Errorf("unexpected request %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+				}
+				json.NewDecoder(r.Body).Decode(&captured)
+				json.NewEncoder(w).Encode(tt.response)
+			}))
+			defer server.Close()
+
+			provider, err := NewPerplexityProvider(Config{APIKey: "pplx-test", Model: "sonar-pro"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+			ctx := WithCallOptions(context.Background(), CallOptions{Deterministic: true, Seed: 7})
+			got, err := provider.Analyze(ctx, "Is lodash 4.17.20 vulnerable?")
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("error = %v, want containing %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %q, want %q", got, tt.want)
+			}
+
+			if captured["model"] != "sonar-pro" || captured["temperature"] != float64(0) {
+				t.Errorf("unexpected request: %v", captured)
+			}
+			for _, field := range []string{"seed", "random_seed", "response_format"} {
+				if _, ok := captured[field]; ok {
+					t.Errorf("request should not include %s: %v", field, captured)
+				}
+			}
+		})
+	}
+}
+
+func TestPerplexityProvider_RequestMaxTokens(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]string{"content": "ok"}, "finish_reason": "stop"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider, err := NewPerplexityProvider(Config{APIKey: "test-key", MaxTokens: 4096})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+	ctx := withRequestParams(context.Background(), requestParams{MaxTokens: 1500})
+	if _, err := provider.Analyze(ctx, "Review this"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if captured["max_tokens"] != float64(1500) {
+		t.Errorf("max_tokens = %v, want the per-request 1500", captured["max_tokens"])
+	}
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request duration histogram
+var durationBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// estimateTokens approximates the token count of text the same way as config.EstimateTokensForText
+func estimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// prometheusCollectors exports ProviderMetrics on a dedicated registry, so only these metrics are
+// served and each recorder can be created without clashing with another's registration
+type prometheusCollectors struct {
+	registry       *prometheus.Registry
+	requests       *prometheus.CounterVec
+	errors         *prometheus.CounterVec
+	retries        *prometheus.CounterVec
+	promptTokens   *prometheus.CounterVec
+	responseTokens *prometheus.CounterVec
+	duration       *prometheus.HistogramVec
+}
+
+// newPrometheusCollectors creates the per-provider collectors and registers them
+func newPrometheusCollectors() *prometheusCollectors {
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, []string{"provider"})
+	}
+
+	c := &prometheusCollectors{
+		registry:       prometheus.NewRegistry(),
+		requests:       counter("second_opinion_llm_requests_total", "LLM requests sent, by provider."),
+		errors:         counter("second_opinion_llm_request_errors_total", "LLM requests that failed, by provider."),
+		retries:        counter("second_opinion_llm_retries_total", "HTTP attempts retried after a retryable failure, by provider."),
+		promptTokens:   counter("second_opinion_llm_prompt_tokens_total", "Estimated prompt tokens sent, by provider."),
+		responseTokens: counter("second_opinion_llm_response_tokens_total", "Estimated response tokens received, by provider."),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "second_opinion_llm_request_duration_seconds",
+			Help:    "LLM request latency in seconds, by provider.",
+			Buckets: durationBuckets,
+		}, []string{"provider"}),
+	}
+	c.registry.MustRegister(c.requests, c.errors, c.retries, c.promptTokens, c.responseTokens, c.duration)
+	return c
+}
+
+// observe adds one request for provider. Every series is touched so a provider's error and retry
+// counts are exported as 0 rather than missing.
+func (c *prometheusCollectors) observe(provider string, record RequestRecord) {
+	c.requests.WithLabelValues(provider).Inc()
+	errors := c.errors.WithLabelValues(provider)
+	if record.Err != nil {
+		errors.Inc()
+	}
+	c.retries.WithLabelValues(provider).Add(float64(record.Retries))
+	c.promptTokens.WithLabelValues(provider).Add(float64(record.PromptTokens))
+	c.responseTokens.WithLabelValues(provider).Add(float64(record.ResponseTokens))
+	c.duration.WithLabelValues(provider).Observe(record.Duration.Seconds())
+}
+
+// Handler serves the recorded stats for Prometheus to scrape
+func (m *ProviderMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.collectors.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// promptTemplateExt is the file extension of prompt templates in a prompt directory
+const promptTemplateExt = ".tmpl"
+
+// BuiltinPromptVersion identifies the built-in prompts. Bump it whenever a built-in prompt or the
+// system prompt changes in a way that affects the analysis, so cached analyses made with the old
+// prompts are no longer reused.
+const BuiltinPromptVersion = 1
+
+// AnalysisTypes lists the analysis types AnalysisPrompt builds prompts for; a prompt directory
+// may hold a "<type>.tmpl" template for any of them
+var AnalysisTypes = []string{
+	"diff", "code_review", "commit", "commit_summary", "explain_commit", "commit_range",
+	"uncommitted_work", "action_items", "compare_branches", "rereview", "merge_conflict", "release_notes",
+}
+
+// PromptData is the data a prompt template is executed with
+type PromptData struct {
+	// Type is the analysis type, such as "diff" or "code_review"
+	Type string
+	// Content is the input to analyze, already delimited as untrusted data
+	Content string
+	// Language is the programming language of the code, or "" if unknown
+	Language string
+	// Focus describes what a code review should emphasize, or "" for everything
+	Focus string
+	// SecurityHints lists the bug classes to check for in a security-focused review of a known
+	// language, and is nil otherwise
+	SecurityHints []string
+	// FormatInstructions describes the JSON shape to respond with when a structured format was
+	// requested, and is "" for markdown; templates must include it for JSON output to parse
+	FormatInstructions string
+	// Options holds the remaining tool-specific options, such as summarize, staged_only, or branch_a
+	Options map[string]any
+}
+
+// PromptRegistry holds prompt templates that replace the built-in prompts, keyed by analysis type
+type PromptRegistry struct {
+	dir       string
+	templates map[string]*template.Template
+	// digests holds a hash of each template's source, keyed by analysis type
+	digests map[string]string
+}
+
+// LoadPromptRegistry parses every "<analysis type>.tmpl" file in dir as a text/template. A file
+// named for an unknown analysis type, a template that fails to parse, or one that fails on sample
+// data is an error, so mistakes surface at startup rather than on the first request.
+func LoadPromptRegistry(dir string) (*PromptRegistry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt directory: %w", err)
+	}
+
+	registry := &PromptRegistry{dir: dir, templates: make(map[string]*template.Template), digests: make(map[string]string)}
+	var problems []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != promptTemplateExt {
+			continue
+		}
+
+		analysisType := strings.TrimSuffix(name, promptTemplateExt)
+		if !slices.Contains(AnalysisTypes, analysisType) {
+			problems = append(problems, fmt.Errorf("%s: unknown analysis type %q (use %s)", name, analysisType, strings.Join(AnalysisTypes, ", ")))
+			continue
+		}
+
+		source, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		tmpl, err := template.New(name).Parse(string(source))
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		sample := PromptData{Type: analysisType, Content: fenceUntrusted("sample"), Options: map[string]any{}}
+		if err := tmpl.Execute(new(strings.Builder), sample); err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		registry.templates[analysisType] = tmpl
+		sum := sha256.Sum256(source)
+		registry.digests[analysisType] = hex.EncodeToString(sum[:])
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid prompt templates in %s: %w", dir, errors.Join(problems...))
+	}
+	return registry, nil
+}
+
+// Types lists the analysis types the registry has templates for, sorted
+func (r *PromptRegistry) Types() []string {
+	types := make([]string, 0, len(r.templates))
+	for analysisType := range r.templates {
+		types = append(types, analysisType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Render executes the template for data.Type, reporting false when the registry has none
+func (r *PromptRegistry) Render(data PromptData) (string, bool, error) {
+	tmpl, ok := r.templates[data.Type]
+	if !ok {
+		return "", false, nil
+	}
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return "", true, err
+	}
+	return prompt.String(), true, nil
+}
+
+var (
+	promptRegistry    *PromptRegistry
+	promptRegistryMux sync.RWMutex
+)
+
+// SetPromptRegistry makes AnalysisPrompt use registry's templates; nil restores the built-in prompts
+func SetPromptRegistry(registry *PromptRegistry) {
+	promptRegistryMux.Lock()
+	defer promptRegistryMux.Unlock()
+	promptRegistry = registry
+}
+
+// PromptVersion identifies the prompts used for the given analysis types: BuiltinPromptVersion,
+// followed by a hash of the registered templates when any of the types has one. It changes
+// whenever those prompts do, so it can key cached analyses.
+func PromptVersion(analysisTypes ...string) string {
+	version := strconv.Itoa(BuiltinPromptVersion)
+
+	promptRegistryMux.RLock()
+	registry := promptRegistry
+	promptRegistryMux.RUnlock()
+	if registry == nil {
+		return version
+	}
+
+	hash := sha256.New()
+	custom := false
+	for _, analysisType := range analysisTypes {
+		if digest, ok := registry.digests[analysisType]; ok {
+			custom = true
+			fmt.Fprintf(hash, "%s:%s\n", analysisType, digest)
+		}
+	}
+	if !custom {
+		return version
+	}
+	return version + "-" + hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// customPrompt renders the registered template for analysisType with already-fenced content.
+// It reports false when there is no template, or when it fails and the built-in prompt is used.
+func customPrompt(analysisType, content string, options map[string]any) (string, bool) {
+	promptRegistryMux.RLock()
+	registry := promptRegistry
+	promptRegistryMux.RUnlock()
+	if registry == nil {
+		return "", false
+	}
+
+	if options == nil {
+		options = map[string]any{}
+	}
+	data := PromptData{Type: analysisType, Content: content, Options: options}
+	data.Language, _ = options["language"].(string)
+	if focus, ok := options["focus"].(string); ok && focus != "" && focus != "all" {
+		data.Focus = reviewFocus(focus)
+		if focus == "security" {
+			data.SecurityHints = SecurityHints(data.Language)
+		}
+	}
+	if format, ok := options["format"].(string); ok && format == FormatJSON {
+		switch analysisType {
+		case "code_review":
+			data.FormatInstructions = jsonInstructions(findingsSchema)
+		case "action_items":
+			data.FormatInstructions = jsonInstructions(actionItemsSchema)
+		}
+	}
+
+	prompt, ok, err := registry.Render(data)
+	if err != nil {
+		slog.Warn("prompt template failed, using the built-in prompt", "type", analysisType, "dir", registry.dir, "error", err)
+		return "", false
+	}
+	return prompt, ok
+}
@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePromptTemplates writes name/content pairs into a fresh prompt directory
+func writePromptTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPromptRegistry(t *testing.T) {
+	dir := writePromptTemplates(t, map[string]string{
+		"code_review.tmpl": `Review this {{.Language}} code for our team{{with .Focus}}, emphasizing {{.}}{{end}}.
+{{.Content}}
+{{- with .FormatInstructions}}
+{{.}}{{end}}`,
+		"diff.tmpl": `{{if .Options.summarize}}Summarize{{else}}Explain{{end}} this diff:
+{{.Content}}`,
+		"README.md": "not a template",
+	})
+
+	registry, err := LoadPromptRegistry(dir)
+	if err != nil {
+		t.Fatalf("LoadPromptRegistry() unexpected error: %v", err)
+	}
+	if got := strings.Join(registry.Types(), ","); got != "code_review,diff" {
+		t.Errorf("Types() = %s, want code_review,diff", got)
+	}
+
+	SetPromptRegistry(registry)
+	defer SetPromptRegistry(nil)
+
+	prompt := AnalysisPrompt("code_review", "func main() {}", map[string]any{"language": "go", "focus": "security"})
+	want := "Review this go code for our team, emphasizing security.\n" + fenceUntrusted("func main() {}")
+	if prompt != want {
+		t.Errorf("code_review prompt = %q, want %q", prompt, want)
+	}
+
+	prompt = AnalysisPrompt("code_review", "func main() {}", map[string]any{"language": "go", "format": FormatJSON})
+	if !strings.Contains(prompt, `"findings": [`) || strings.Contains(prompt, "emphasizing") {
+		t.Errorf("expected the JSON instructions and no focus for the default focus:\n%s", prompt)
+	}
+
+	if prompt := AnalysisPrompt("diff", "+x", map[string]any{"summarize": true}); !strings.HasPrefix(prompt, "Summarize this diff:") {
+		t.Errorf("diff prompt = %q, want the summarize branch", prompt)
+	}
+
+	// Types without a template keep the built-in prompt
+	if prompt := AnalysisPrompt("commit", "abc123", nil); !strings.Contains(prompt, "Quality of the commit message") {
+		t.Errorf("expected the built-in commit prompt, got %q", prompt)
+	}
+
+	SetPromptRegistry(nil)
+	if prompt := AnalysisPrompt("diff", "+x", nil); !strings.HasPrefix(prompt, "Analyze this git diff") {
+		t.Errorf("expected the built-in diff prompt after clearing the registry, got %q", prompt)
+	}
+}
+
+func TestLoadPromptRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"unknown type", map[string]string{"code-review.tmpl": "{{.Content}}"}, `unknown analysis type "code-review"`},
+		{"syntax error", map[string]string{"diff.tmpl": "{{if .Content}}unclosed"}, "diff.tmpl"},
+		{"unknown field", map[string]string{"commit.tmpl": "{{.Diff}}"}, "can't evaluate field Diff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPromptRegistry(writePromptTemplates(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadPromptRegistry() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadPromptRegistry(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestPromptVersion(t *testing.T) {
+	defer SetPromptRegistry(nil)
+
+	builtin := PromptVersion("commit")
+	if builtin != fmt.Sprint(BuiltinPromptVersion) {
+		t.Errorf("PromptVersion() = %q, want the built-in version %d", builtin, BuiltinPromptVersion)
+	}
+
+	load := func(files map[string]string) {
+		t.Helper()
+		registry, err := LoadPromptRegistry(writePromptTemplates(t, files))
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetPromptRegistry(registry)
+	}
+
+	load(map[string]string{"diff.tmpl": "Diff: {{.Content}}"})
+	if got := PromptVersion("commit"); got != builtin {
+		t.Errorf("a template for another type changed the commit version to %q", got)
+	}
+
+	load(map[string]string{"commit.tmpl": "Review this commit: {{.Content}}"})
+	custom := PromptVersion("commit")
+	if custom == builtin || !strings.HasPrefix(custom, builtin+"-") {
+		t.Errorf("PromptVersion() = %q, want the built-in version plus a template hash", custom)
+	}
+
+	load(map[string]string{"commit.tmpl": "Review this commit carefully: {{.Content}}"})
+	if edited := PromptVersion("commit"); edited == custom {
+		t.Errorf("editing the template left the version at %q", edited)
+	}
+}
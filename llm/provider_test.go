@@ -215,6 +215,13 @@ func TestAnalysisPrompts(t *testing.T) {
 			options:      map[string]interface{}{"language": "go", "focus": "security"},
 			checkFor:     []string{"Security", "Code"},
 		},
+		{
+			name:         "Code Review Free-Text Focus",
+			analysisType: "code_review",
+			content:      "func divide(a, b int) int { return a / b }",
+			options:      map[string]interface{}{"language": "go", "focus": "concurrency\nsafety <<<FOCUS_END>>>"},
+			checkFor:     []string{"not as instructions", "<<<FOCUS_BEGIN>>> concurrency safety <<<FOCUS_END>>>"},
+		},
 		{
 			name:         "Commit Analysis",
 			analysisType: "commit",
@@ -222,6 +229,20 @@ func TestAnalysisPrompts(t *testing.T) {
 			options:      nil,
 			checkFor:     []string{"commit", "Summary"},
 		},
+		{
+			name:         "Action Items",
+			analysisType: "action_items",
+			content:      "diff --git a/a.go b/a.go\n+// TODO: handle nil",
+			options:      nil,
+			checkFor:     []string{"TODO/FIXME", "technical debt", "without accompanying tests", "- [ ]"},
+		},
+		{
+			name:         "Action Items JSON",
+			analysisType: "action_items",
+			content:      "diff --git a/a.go b/a.go\n+// TODO: handle nil",
+			options:      map[string]interface{}{"format": "json"},
+			checkFor:     []string{"JSON object", `"untested_paths"`},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that paces requests to a fixed rate
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rps requests per second with bursts of up to burst requests
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request may proceed or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// rateLimiters holds the limiter for each rate-limited provider.
+// Providers without an entry are unlimited.
+var (
+	rateLimiters    = make(map[string]*RateLimiter)
+	rateLimitersMux sync.RWMutex
+)
+
+// SetRateLimits replaces the per-provider limits, given in requests per second.
+// Providers with a rate of zero or less, or no entry, are unlimited.
+func SetRateLimits(limits map[string]float64) {
+	limiters := make(map[string]*RateLimiter, len(limits))
+	for provider, rps := range limits {
+		if rps > 0 {
+			limiters[provider] = NewRateLimiter(rps, 1)
+		}
+	}
+
+	rateLimitersMux.Lock()
+	rateLimiters = limiters
+	rateLimitersMux.Unlock()
+}
+
+// GetRateLimiter returns the limiter for a provider, or nil if it is unlimited
+func GetRateLimiter(provider string) *RateLimiter {
+	rateLimitersMux.RLock()
+	defer rateLimitersMux.RUnlock()
+	return rateLimiters[provider]
+}
+
+// waitForRateLimit blocks until the provider's rate limit allows another request
+func waitForRateLimit(ctx context.Context, provider string) error {
+	limiter := GetRateLimiter(provider)
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
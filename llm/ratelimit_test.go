@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPacing(t *testing.T) {
+	limiter := NewRateLimiter(2, 1)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() unexpected error: %v", err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	// The first request is immediate, the remaining four are spaced 500ms apart
+	if elapsed < 1900*time.Millisecond {
+		t.Errorf("5 requests at 2 rps took %v, want at least ~2s", elapsed)
+	}
+	if elapsed > 3*time.Second {
+		t.Errorf("5 requests at 2 rps took %v, want about 2s", elapsed)
+	}
+}
+
+func TestRateLimiterRespectsContext(t *testing.T) {
+	limiter := NewRateLimiter(0.1, 1)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait() unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := limiter.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait() returned after %v, expected prompt return on cancellation", elapsed)
+	}
+}
+
+func TestSetRateLimits(t *testing.T) {
+	SetRateLimits(map[string]float64{"openai": 2, "mistral": 0})
+	defer SetRateLimits(nil)
+
+	if GetRateLimiter("openai") == nil {
+		t.Error("expected a limiter for openai")
+	}
+	if GetRateLimiter("mistral") != nil {
+		t.Error("expected mistral to be unlimited with a zero rate")
+	}
+	if GetRateLimiter("google") != nil {
+		t.Error("expected google to be unlimited without an entry")
+	}
+	if err := waitForRateLimit(context.Background(), "google"); err != nil {
+		t.Errorf("waitForRateLimit() for unlimited provider returned %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// NewRawProvider wraps baseProvider for raw mode: prompts are sent in one request with the
+// provider's own max_tokens and temperature, without chunking or size- and task-based tuning.
+// Secret redaction, the context window and cost checks, and the circuit breaker still apply.
+// The wrapper's Chat sends follow-up conversations under the same guards.
+func NewRawProvider(baseProvider Provider, cfg *config.Config, maxTokens int, temperature float64) OptimizedProvider {
+	return &rawProviderWrapper{
+		optimizedProviderWrapper: optimizedProviderWrapper{Provider: baseProvider, config: cfg},
+		maxTokens:                maxTokens,
+		temperature:              temperature,
+	}
+}
+
+// rawProviderWrapper sends prompts untuned but guarded
+type rawProviderWrapper struct {
+	optimizedProviderWrapper
+	maxTokens   int
+	temperature float64
+}
+
+// AnalyzeOptimized ignores the size and task hints and sends prompt as a single request
+func (r *rawProviderWrapper) AnalyzeOptimized(ctx context.Context, prompt string, _ int, _ config.AnalysisTask) (string, error) {
+	suspicious := scanPromptForInjection(prompt)
+
+	redactions := 0
+	if r.config.ShouldRedactSecrets(r.Name()) {
+		prompt, redactions = RedactSecrets(prompt)
+	}
+
+	if CallOptionsFromContext(ctx).DryRun {
+		plan := NewDryRunPlan(ctx, r.Name(), r.Model(), prompt, r.maxTokens, r.temperature, r.config.EstimateTokensForText(prompt))
+		plan.Redactions = redactions
+		if err := r.checkGuards(ctx, prompt); err != nil {
+			plan.Warnings = append(plan.Warnings, err.Error())
+		}
+		return appendInjectionWarning(plan.String(), suspicious), nil
+	}
+
+	if err := r.checkGuards(ctx, prompt); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	result, err := callWithCircuitBreaker(r.Name(), r.Model(), func() (string, error) {
+		return r.Analyze(ctx, prompt)
+	})
+	attrs := []any{
+		"provider", r.Name(),
+		"model", r.Model(),
+		"duration_ms", time.Since(start).Milliseconds(),
+		"raw", true,
+	}
+	if err != nil {
+		slog.Warn("llm request failed", append(attrs, "error", err)...)
+		return "", err
+	}
+	slog.Info("llm request completed", attrs...)
+
+	result = appendRedactionNote(result, redactions, r.Name())
+	return appendInjectionWarning(result, suspicious), nil
+}
+
+// checkGuards applies the context window and cost checks to prompt at the provider's max_tokens
+func (r *rawProviderWrapper) checkGuards(ctx context.Context, prompt string) error {
+	if !CallOptionsFromContext(ctx).IgnoreContextWindow {
+		if err := r.checkContextWindow(prompt, r.maxTokens); err != nil {
+			return err
+		}
+	}
+	return r.checkCost(prompt, r.maxTokens)
+}
+
+// Chat sends a conversation through the middleware chain under the same guards as
+// AnalyzeOptimized. It returns ErrChatUnsupported if the provider cannot hold one.
+func (r *rawProviderWrapper) Chat(ctx context.Context, messages []Message) (string, error) {
+	chat, ok := chatProviderOf(r.Provider)
+	if !ok {
+		return "", ErrChatUnsupported
+	}
+
+	if r.config.ShouldRedactSecrets(r.Name()) {
+		messages = append([]Message(nil), messages...)
+		for i := range messages {
+			messages[i].Content, _ = RedactSecrets(messages[i].Content)
+		}
+	}
+	if err := r.checkGuards(ctx, conversationText(messages)); err != nil {
+		return "", err
+	}
+
+	return callWithCircuitBreaker(r.Name(), r.Model(), func() (string, error) {
+		return chat.Chat(ctx, messages)
+	})
+}
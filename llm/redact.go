@@ -0,0 +1,229 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedactedPlaceholder replaces any detected secret
+const RedactedPlaceholder = "[REDACTED]"
+
+// secretRule describes a secret pattern and which capture group holds the secret value
+type secretRule struct {
+	name    string
+	pattern *regexp.Regexp
+	group   int // 0 redacts the whole match
+}
+
+// secretRules are applied in order; earlier rules take precedence over later ones
+var secretRules = []secretRule{
+	{
+		name:    "private_key_block",
+		pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	},
+	{
+		name:    "aws_access_key_id",
+		pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	},
+	{
+		name:    "openai_key",
+		pattern: regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+	},
+	{
+		name:    "google_api_key",
+		pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),
+	},
+	{
+		name:    "github_token",
+		pattern: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	},
+	{
+		name:    "bearer_token",
+		pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{8,}=*)`),
+		group:   1,
+	},
+	{
+		name:    "credential_assignment",
+		pattern: regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token|client[_-]?secret|aws_secret_access_key)["']?\s*[:=]\s*["']?([^\s"',;]{4,})`),
+		group:   1,
+	},
+}
+
+// highEntropyCandidate matches long opaque tokens that may be secrets
+var highEntropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_\-=]{32,}`)
+
+// highEntropyThreshold is the Shannon entropy (bits per char) above which a token is treated as a secret.
+// Hex strings such as commit SHAs top out at 4 bits per char and stay below it.
+const highEntropyThreshold = 4.5
+
+// secretSpan is the byte range of a likely secret in a text and the rule that found it
+type secretSpan struct {
+	start, end int
+	rule       string
+}
+
+// findSecretSpans locates likely secrets in text with secretRules and then the high-entropy
+// check, in text order. A span overlapping one found by an earlier rule is skipped, as is a
+// value that is already RedactedPlaceholder. RedactSecrets and FindSecrets both use it, so
+// the lint pre-pass reports exactly what redaction removes.
+func findSecretSpans(text string) []secretSpan {
+	var spans []secretSpan
+	overlaps := func(start, end int) bool {
+		for _, s := range spans {
+			if start < s.end && s.start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, rule := range secretRules {
+		for _, loc := range rule.pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*rule.group], loc[2*rule.group+1]
+			if start < 0 || text[start:end] == RedactedPlaceholder || overlaps(start, end) {
+				continue
+			}
+			spans = append(spans, secretSpan{start, end, rule.name})
+		}
+	}
+	for _, loc := range highEntropyCandidate.FindAllStringIndex(text, -1) {
+		if looksLikeSecret(text[loc[0]:loc[1]]) && !overlaps(loc[0], loc[1]) {
+			spans = append(spans, secretSpan{loc[0], loc[1], "high_entropy_string"})
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	return spans
+}
+
+// RedactSecrets replaces likely secrets in text with RedactedPlaceholder
+// and returns the scrubbed text along with the number of redactions made
+func RedactSecrets(text string) (string, int) {
+	spans := findSecretSpans(text)
+	if len(spans) == 0 {
+		return text, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		b.WriteString(text[last:s.start])
+		b.WriteString(RedactedPlaceholder)
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String(), len(spans)
+}
+
+// SecretMatch is a likely secret located by FindSecrets
+type SecretMatch struct {
+	// Rule names the pattern that matched, e.g. "aws_access_key_id" or "high_entropy_string"
+	Rule string
+	// Line is the 1-based line the secret starts on
+	Line int
+}
+
+// FindSecrets reports where the secrets RedactSecrets would replace appear in text, in order
+func FindSecrets(text string) []SecretMatch {
+	spans := findSecretSpans(text)
+	matches := make([]SecretMatch, 0, len(spans))
+	for _, s := range spans {
+		matches = append(matches, SecretMatch{Rule: s.rule, Line: strings.Count(text[:s.start], "\n") + 1})
+	}
+	return matches
+}
+
+// looksLikeSecret reports whether a token has the character mix and entropy of a generated secret
+func looksLikeSecret(token string) bool {
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range token {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		}
+	}
+	if !hasUpper || !hasLower || !hasDigit {
+		return false
+	}
+
+	return shannonEntropy(token) > highEntropyThreshold
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	freq := make(map[rune]int)
+	for _, r := range s {
+		freq[r]++
+	}
+
+	length := float64(len([]rune(s)))
+	entropy := 0.0
+	for _, n := range freq {
+		p := float64(n) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redactionNote describes how many secrets were removed before sending to a provider
+func redactionNote(count int, provider string) string {
+	noun := "secrets were"
+	if count == 1 {
+		noun = "secret was"
+	}
+	return fmt.Sprintf("🔒 Note: %d potential %s redacted before sending to %s.", count, noun, provider)
+}
+
+// appendRedactionNote appends the redaction note to an analysis result
+func appendRedactionNote(result string, count int, provider string) string {
+	if count == 0 {
+		return result
+	}
+	return strings.TrimRight(result, "\n") + "\n\n" + redactionNote(count, provider)
+}
+
+// minSecretLength is the shortest configured secret sanitizeError will search for,
+// so tiny placeholder values don't mangle unrelated text
+const minSecretLength = 4
+
+// sanitizedError is an error whose message had secrets removed; the original is kept for errors.Is/As
+type sanitizedError struct {
+	msg string
+	err error
+}
+
+func (e *sanitizedError) Error() string { return e.msg }
+
+func (e *sanitizedError) Unwrap() error { return e.err }
+
+// sanitizeError strips the given secrets and any recognizable key patterns from err's message.
+// Providers route their errors through it because API responses and proxies can echo credentials back.
+func sanitizeError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			msg = strings.ReplaceAll(msg, secret, RedactedPlaceholder)
+		}
+	}
+	msg, _ = RedactSecrets(msg)
+
+	if msg == err.Error() {
+		return err
+	}
+	return &sanitizedError{msg: msg, err: err}
+}
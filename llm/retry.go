@@ -3,11 +3,15 @@ package llm
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"math/rand/v2"
 	"net"
 	"net/http"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -17,6 +21,8 @@ type RetryConfig struct {
 	BaseDelay       time.Duration
 	MaxDelay        time.Duration
 	BackoffMultiple float64
+	// DisableJitter makes retry delays exact, for deterministic runs
+	DisableJitter bool
 }
 
 // DefaultRetryConfig returns sensible defaults for retry configuration
@@ -45,17 +51,32 @@ func IsRetryableError(err error) bool {
 		return false
 	}
 
-	// Context deadline exceeded or canceled should not be retried
-	if err == context.DeadlineExceeded || err == context.Canceled {
+	// Context deadline exceeded or canceled should not be retried, even when wrapped
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 		return false
 	}
 
-	// Network errors are retryable
-	if _, ok := err.(net.Error); ok {
+	// Connections dropped mid-request are transient
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
 		return true
 	}
 
-	return false
+	// Network errors are retryable, including those wrapped by *url.Error
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// maxDrainBytes bounds how much of an unread response body is discarded so its connection can be
+// reused; a longer body is abandoned and its connection closed instead of being read to the end
+const maxDrainBytes = 64 * 1024
+
+// maxErrorBodySize bounds how much of an error response body is read for the error message
+const maxErrorBodySize = 64 * 1024
+
+// drainAndClose discards up to maxDrainBytes of body and closes it
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
 }
 
 // IsRetryableHTTPStatus determines if an HTTP status code should trigger a retry
@@ -76,30 +97,46 @@ func IsRetryableHTTPStatus(statusCode int) bool {
 	}
 }
 
-// CalculateDelay calculates the delay for a retry attempt using exponential backoff
+// jitterFraction is the maximum relative jitter applied to every retry delay (±25%)
+const jitterFraction = 0.25
+
+// CalculateDelay calculates the delay for a retry attempt using exponential backoff with jitter
 func (rc RetryConfig) CalculateDelay(attempt int) time.Duration {
-	if attempt == 0 {
-		return rc.BaseDelay
+	if attempt < 0 {
+		attempt = 0
 	}
 
+	// Cap the backoff before jitter so huge attempts can't overflow to +Inf
 	delay := float64(rc.BaseDelay) * math.Pow(rc.BackoffMultiple, float64(attempt))
+	if delay > float64(rc.MaxDelay) {
+		delay = float64(rc.MaxDelay)
+	}
 
-	// Add jitter (±25% random variation)
-	jitter := 0.25 * delay * (2*float64(time.Now().UnixNano()%1000)/1000 - 1)
-	delay += jitter
+	// Add uniformly distributed jitter so concurrent retries don't align
+	if !rc.DisableJitter {
+		delay += jitterFraction * delay * (2*rand.Float64() - 1)
+	}
 
-	delayDuration := time.Duration(delay)
-	if delayDuration > rc.MaxDelay {
-		delayDuration = rc.MaxDelay
+	// Guard against negative delays and re-apply the cap after jitter
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > float64(rc.MaxDelay) {
+		return rc.MaxDelay
 	}
 
-	return delayDuration
+	return time.Duration(delay)
 }
 
 // RetryableHTTPRequest performs an HTTP request with retry logic
 func RetryableHTTPRequest(ctx context.Context, client *http.Client, req *http.Request, config RetryConfig) (*http.Response, error) {
 	var lastErr error
 
+	// Deterministic runs retry on an exact schedule
+	if CallOptionsFromContext(ctx).Deterministic {
+		config.DisableJitter = true
+	}
+
 	// Read the request body once if it exists
 	var bodyBytes []byte
 	if req.Body != nil {
@@ -111,7 +148,7 @@ func RetryableHTTPRequest(ctx context.Context, client *http.Client, req *http.Re
 		req.Body.Close()
 	}
 
-	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
+	for attempt := 0; ; attempt++ {
 		// Clone the request for retry attempts
 		reqCopy := req.Clone(ctx)
 
@@ -122,38 +159,41 @@ func RetryableHTTPRequest(ctx context.Context, client *http.Client, req *http.Re
 
 		resp, err := client.Do(reqCopy)
 
-		// If successful, return immediately
-		if err == nil && !IsRetryableHTTPStatus(resp.StatusCode) {
+		retryable := false
+		switch {
+		case err != nil:
+			retryable = IsRetryableError(err)
+			lastErr = err
+		case IsRetryableHTTPStatus(resp.StatusCode):
+			retryable = true
+			lastErr = statusError(resp.StatusCode)
+		default:
+			// The only path that hands a live response to the caller, who must close its body.
+			// Non-retryable error statuses are returned too so callers can report the body.
 			return resp, nil
 		}
 
-		// Check if this error/status is retryable
-		shouldRetry := false
-		if err != nil {
-			shouldRetry = IsRetryableError(err)
-			lastErr = err
-		} else if resp != nil {
-			shouldRetry = IsRetryableHTTPStatus(resp.StatusCode)
-			lastErr = fmt.Errorf("HTTP %d", resp.StatusCode)
-			// Close the response body for failed attempts
-			resp.Body.Close()
+		// Every response not returned above is drained and closed here
+		if resp != nil {
+			drainAndClose(resp.Body)
 		}
 
-		// If not retryable, return immediately
-		if !shouldRetry {
-			if resp != nil && err == nil {
-				return resp, nil // Return the response for non-retryable status codes
-			}
+		// Report cancellation as the context error itself rather than the transport's wrapper
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !retryable {
 			return nil, lastErr
 		}
 
 		// If this was the last attempt, return the error
-		if attempt == config.MaxRetries {
+		if attempt >= config.MaxRetries {
 			return nil, fmt.Errorf("request failed after %d attempts: %w", attempt+1, lastErr)
 		}
 
 		// Wait before retrying
 		delay := config.CalculateDelay(attempt)
+		countRetry(ctx)
 
 		select {
 		case <-ctx.Done():
@@ -162,8 +202,6 @@ func RetryableHTTPRequest(ctx context.Context, client *http.Client, req *http.Re
 			// Continue to next retry
 		}
 	}
-
-	return nil, fmt.Errorf("request failed after %d attempts: %w", config.MaxRetries+1, lastErr)
 }
 
 // RetryableOperation performs a generic operation with retry logic
@@ -193,6 +231,7 @@ func RetryableOperation[T any](ctx context.Context, config RetryConfig, operatio
 
 		// Wait before retrying
 		delay := config.CalculateDelay(attempt)
+		countRetry(ctx)
 
 		select {
 		case <-ctx.Done():
@@ -204,3 +243,17 @@ func RetryableOperation[T any](ctx context.Context, config RetryConfig, operatio
 
 	return zero, fmt.Errorf("operation failed after %d attempts: %w", config.MaxRetries+1, lastErr)
 }
+
+type retryCounterKey struct{}
+
+// withRetryCounter returns a context whose retries are added to counter
+func withRetryCounter(ctx context.Context, counter *atomic.Int64) context.Context {
+	return context.WithValue(ctx, retryCounterKey{}, counter)
+}
+
+// countRetry adds one retry to the counter carried by ctx, if any
+func countRetry(ctx context.Context) {
+	if counter, ok := ctx.Value(retryCounterKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+}
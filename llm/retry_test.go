@@ -3,10 +3,18 @@ package llm
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -59,6 +67,36 @@ func TestIsRetryableError(t *testing.T) {
 			err:      errors.New("some error"),
 			expected: false,
 		},
+		{
+			name:     "connection reset wrapped in url.Error",
+			err:      &url.Error{Op: "Post", URL: "https://example.com", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}},
+			expected: true,
+		},
+		{
+			name:     "EOF wrapped in url.Error",
+			err:      &url.Error{Op: "Post", URL: "https://example.com", Err: io.EOF},
+			expected: true,
+		},
+		{
+			name:     "unexpected EOF wrapped twice",
+			err:      fmt.Errorf("reading response: %w", &url.Error{Op: "Post", URL: "https://example.com", Err: io.ErrUnexpectedEOF}),
+			expected: true,
+		},
+		{
+			name:     "network error wrapped with fmt.Errorf",
+			err:      fmt.Errorf("request failed: %w", &net.DNSError{}),
+			expected: true,
+		},
+		{
+			name:     "context canceled wrapped in url.Error",
+			err:      &url.Error{Op: "Post", URL: "https://example.com", Err: context.Canceled},
+			expected: false,
+		},
+		{
+			name:     "deadline exceeded wrapped in url.Error",
+			err:      &url.Error{Op: "Post", URL: "https://example.com", Err: context.DeadlineExceeded},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -117,6 +155,80 @@ func TestCalculateDelay(t *testing.T) {
 	}
 }
 
+func TestCalculateDelayJitterDistribution(t *testing.T) {
+	config := RetryConfig{
+		BaseDelay:       1 * time.Second,
+		MaxDelay:        time.Hour,
+		BackoffMultiple: 2.0,
+	}
+
+	const samples = 10000
+	for _, attempt := range []int{0, 1, 3} {
+		expected := float64(config.BaseDelay) * math.Pow(config.BackoffMultiple, float64(attempt))
+		low, high := expected*(1-jitterFraction), expected*(1+jitterFraction)
+
+		// Split the ±25% range into buckets; uniform jitter should fill each roughly equally
+		const buckets = 10
+		counts := make([]int, buckets)
+		sum := 0.0
+		for i := 0; i < samples; i++ {
+			d := float64(config.CalculateDelay(attempt))
+			if d < low || d > high {
+				t.Fatalf("attempt %d: delay %v outside ±25%% of %v", attempt, time.Duration(d), time.Duration(expected))
+			}
+			sum += d
+			idx := int((d - low) / (high - low) * buckets)
+			if idx == buckets {
+				idx--
+			}
+			counts[idx]++
+		}
+
+		mean := sum / samples
+		if math.Abs(mean-expected)/expected > 0.02 {
+			t.Errorf("attempt %d: mean delay %v deviates more than 2%% from %v", attempt, time.Duration(mean), time.Duration(expected))
+		}
+		for i, c := range counts {
+			if c < samples/buckets/2 {
+				t.Errorf("attempt %d: bucket %d has only %d samples, jitter is not uniform: %v", attempt, i, c, counts)
+			}
+		}
+	}
+}
+
+func TestCalculateDelayBounds(t *testing.T) {
+	config := RetryConfig{
+		BaseDelay:       1 * time.Second,
+		MaxDelay:        5 * time.Second,
+		BackoffMultiple: 2.0,
+	}
+
+	// Very large attempts must clamp rather than overflow
+	for _, attempt := range []int{-1, 0, 50, 1000} {
+		delay := config.CalculateDelay(attempt)
+		if delay < 0 || delay > config.MaxDelay {
+			t.Errorf("CalculateDelay(%d) = %v, want within [0, %v]", attempt, delay, config.MaxDelay)
+		}
+	}
+}
+
+func TestCalculateDelayWithoutJitter(t *testing.T) {
+	config := RetryConfig{
+		BaseDelay:       100 * time.Millisecond,
+		MaxDelay:        10 * time.Second,
+		BackoffMultiple: 2.0,
+		DisableJitter:   true,
+	}
+
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		for i := 0; i < 10; i++ {
+			if got := config.CalculateDelay(attempt); got != want {
+				t.Fatalf("CalculateDelay(%d) = %v, want exactly %v", attempt, got, want)
+			}
+		}
+	}
+}
+
 func TestRetryableHTTPRequest_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -276,6 +388,109 @@ func TestRetryableHTTPRequest_ContextCancellation(t *testing.T) {
 	}
 }
 
+// bodyTrackingTransport serves canned status codes in order and counts response bodies left open
+type bodyTrackingTransport struct {
+	mu       sync.Mutex
+	statuses []int
+	calls    int
+	open     int
+}
+
+// trackedBody decrements its transport's open count when closed
+type trackedBody struct {
+	io.Reader
+	transport *bodyTrackingTransport
+	closed    bool
+}
+
+func (b *trackedBody) Close() error {
+	b.transport.mu.Lock()
+	defer b.transport.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.transport.open--
+	}
+	return nil
+}
+
+func (t *bodyTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := t.statuses[min(t.calls, len(t.statuses)-1)]
+	t.calls++
+	t.open++
+	return &http.Response{
+		StatusCode: status,
+		Body:       &trackedBody{Reader: strings.NewReader(http.StatusText(status)), transport: t},
+		Request:    req,
+	}, nil
+}
+
+func (t *bodyTrackingTransport) unclosed() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.open
+}
+
+func TestRetryableHTTPRequestClosesBodies(t *testing.T) {
+	config := RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffMultiple: 1}
+
+	tests := []struct {
+		name       string
+		statuses   []int
+		ctxTimeout time.Duration
+		wantStatus int // 0 when an error is expected
+	}{
+		{"success first try", []int{200}, 0, 200},
+		{"success after retries", []int{503, 502, 200}, 0, 200},
+		{"retries exhausted", []int{503, 503, 503}, 0, 0},
+		{"non-retryable status", []int{503, 400}, 0, 400},
+		{"cancelled while backing off", []int{503}, 5 * time.Millisecond, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &bodyTrackingTransport{statuses: tt.statuses}
+			client := &http.Client{Transport: transport}
+
+			ctx := context.Background()
+			cfg := config
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+				cfg.BaseDelay, cfg.MaxDelay = time.Second, time.Second
+			}
+			req, _ := http.NewRequest("POST", "http://example.invalid", strings.NewReader("body"))
+
+			resp, err := RetryableHTTPRequest(ctx, client, req, cfg)
+			if tt.wantStatus == 0 {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("expected an error")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+				}
+				// Only the returned response may still be open
+				if open := transport.unclosed(); open != 1 {
+					t.Errorf("%d bodies open before the caller closed the response, want 1", open)
+				}
+				resp.Body.Close()
+			}
+
+			if open := transport.unclosed(); open != 0 {
+				t.Errorf("%d response bodies leaked after %d attempts", open, transport.calls)
+			}
+		})
+	}
+}
+
 func TestRetryableOperation_Success(t *testing.T) {
 	attempts := 0
 	operation := func() (string, error) {
@@ -330,3 +545,60 @@ func TestRetryableOperation_NonRetryableError(t *testing.T) {
 		t.Errorf("Expected 1 attempt for non-retryable error, got %d", attempts)
 	}
 }
+
+// endlessBody is a response body that never ends and records how much was read and whether it was closed
+type endlessBody struct {
+	read   int
+	closed bool
+}
+
+func (b *endlessBody) Read(p []byte) (int, error) {
+	b.read += len(p)
+	return len(p), nil
+}
+
+func (b *endlessBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestDrainAndCloseIsBounded(t *testing.T) {
+	body := &endlessBody{}
+	drainAndClose(body)
+	if body.read > maxDrainBytes {
+		t.Errorf("drained %d bytes, want at most %d", body.read, maxDrainBytes)
+	}
+	if !body.closed {
+		t.Error("body was not closed")
+	}
+}
+
+func TestRetryableHTTPRequestCountsRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var retries atomic.Int64
+	ctx := withRetryCounter(context.Background(), &retries)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := RetryConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffMultiple: 1}
+
+	resp, err := RetryableHTTPRequest(ctx, server.Client(), req, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if retries.Load() != 2 {
+		t.Errorf("retries = %d, want 2", retries.Load())
+	}
+}
@@ -0,0 +1,19 @@
+package llm
+
+import "context"
+
+type streamCallbackKey struct{}
+
+// WithStreamCallback returns a context whose analyses pass each piece of the response to onChunk
+// as it arrives. Providers that implement StreamingProvider stream when it is set; the others
+// ignore it and return the whole response at once. Chunked analyses stream only the final
+// summary, not the per-part requests. An error from onChunk aborts the request.
+func WithStreamCallback(ctx context.Context, onChunk func(string) error) context.Context {
+	return context.WithValue(ctx, streamCallbackKey{}, onChunk)
+}
+
+// StreamCallbackFromContext returns the stream callback carried by ctx, or nil
+func StreamCallbackFromContext(ctx context.Context) func(string) error {
+	onChunk, _ := ctx.Value(streamCallbackKey{}).(func(string) error)
+	return onChunk
+}
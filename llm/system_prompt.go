@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultSystemPrompt is used when the language is unknown or not given
+const defaultSystemPrompt = "You are an expert code reviewer and git analysis assistant. Provide clear, actionable feedback."
+
+// languageSystemPrompts holds reviewer personas keyed by normalized language name
+var languageSystemPrompts = map[string]string{
+	"go": "You are an expert Go reviewer familiar with idiomatic Go, error wrapping, goroutine and channel lifecycles, " +
+		"the race detector, and common pitfalls such as loop variable capture, nil interfaces, and leaked contexts. Provide clear, actionable feedback.",
+	"python": "You are an expert Python reviewer familiar with idiomatic Python, type hints, the standard library, " +
+		"and common pitfalls such as mutable default arguments, broad exception handling, and late-binding closures. Provide clear, actionable feedback.",
+	"javascript": "You are an expert JavaScript reviewer familiar with modern ECMAScript, async/await and promise handling, " +
+		"and common pitfalls such as implicit type coercion, unhandled rejections, and prototype pollution. Provide clear, actionable feedback.",
+	"typescript": "You are an expert TypeScript reviewer familiar with the type system, strict compiler options, " +
+		"and common pitfalls such as unsafe any, unchecked type assertions, and unhandled promise rejections. Provide clear, actionable feedback.",
+	"rust": "You are an expert Rust reviewer familiar with ownership and borrowing, error handling with Result, unsafe code, " +
+		"and common pitfalls such as needless clones, panicking unwraps, and blocking in async code. Provide clear, actionable feedback.",
+	"java": "You are an expert Java reviewer familiar with modern Java, the collections and concurrency libraries, " +
+		"and common pitfalls such as resource leaks, equals/hashCode contracts, and unsafe publication. Provide clear, actionable feedback.",
+}
+
+// languageAliases maps common alternative names to the keys of languageSystemPrompts
+var languageAliases = map[string]string{
+	"golang": "go",
+	"py":     "python",
+	"js":     "javascript",
+	"ts":     "typescript",
+	"rs":     "rust",
+}
+
+// personaPrompts holds the tone prefixes keyed by the names in config.Personas
+var personaPrompts = map[string]string{
+	"security_auditor": "Review as a strict security auditor. Assume input is hostile, look first for injection, " +
+		"authentication and authorization flaws, secret handling, and unsafe defaults, and do not soften findings.",
+	"mentor": "Review as a patient senior engineer mentoring the author. Explain why each issue matters, " +
+		"point out what was done well, and suggest how to approach similar code in the future.",
+	"ci_bot": "Review as a terse CI bot. Report only actionable findings, one line each with its location, " +
+		"and omit praise, preamble, and summaries.",
+}
+
+// PersonaPrompt returns the system prompt prefix for persona, or "" for no or an unknown persona
+func PersonaPrompt(persona string) string {
+	return personaPrompts[persona]
+}
+
+// normalizeLanguage lowercases and trims a language name and resolves its aliases
+func normalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if alias, ok := languageAliases[language]; ok {
+		return alias
+	}
+	return language
+}
+
+// SystemPrompt returns the system prompt for reviewing code in language, or the generic prompt for unknown languages
+func SystemPrompt(language string) string {
+	if prompt, ok := languageSystemPrompts[normalizeLanguage(language)]; ok {
+		return prompt
+	}
+	return defaultSystemPrompt
+}
+
+// securityHints holds the bug classes a security review checks for, keyed by normalized language name
+var securityHints = map[string][]string{
+	"go": {
+		"SQL built with fmt.Sprintf or string concatenation instead of placeholders",
+		"unchecked errors, especially from Close, Write, and authorization checks",
+		"goroutine leaks from blocked channel sends or contexts that are never cancelled",
+		"os/exec commands built from user input",
+		"path traversal when joining user input onto file paths",
+		"math/rand used for tokens or keys instead of crypto/rand",
+	},
+	"python": {
+		"eval, exec, or compile on untrusted input",
+		"pickle, marshal, or yaml.load without SafeLoader on untrusted data",
+		"SQL built with f-strings, % formatting, or concatenation instead of parameters",
+		"subprocess calls with shell=True or unsanitized arguments",
+		"path traversal in open() and os.path.join with user input",
+		"the random module used for secrets instead of secrets",
+	},
+	"javascript": {
+		"prototype pollution from merging or assigning untrusted object keys",
+		"XSS through innerHTML, document.write, or unescaped template output",
+		"eval, new Function, or string arguments to setTimeout",
+		"SQL or NoSQL queries built from request input",
+		"child_process calls with unsanitized arguments",
+		"ReDoS from user-controlled or catastrophic regular expressions",
+	},
+	"typescript": {
+		"prototype pollution from merging or assigning untrusted object keys",
+		"XSS through innerHTML, dangerouslySetInnerHTML, or unescaped template output",
+		"type assertions or any hiding unvalidated external input",
+		"SQL or NoSQL queries built from request input",
+		"eval, new Function, or string arguments to setTimeout",
+	},
+	"rust": {
+		"unsafe blocks whose invariants are not upheld or documented",
+		"panics from unwrap, expect, or indexing on untrusted input",
+		"integer overflow in release builds on size or length arithmetic",
+		"SQL built with format! instead of bound parameters",
+		"std::process::Command built from user input",
+	},
+	"java": {
+		"SQL built with string concatenation instead of PreparedStatement parameters",
+		"deserialization of untrusted data with ObjectInputStream",
+		"XML parsers with external entities enabled (XXE)",
+		"Runtime.exec or ProcessBuilder with user input",
+		"path traversal in File and Paths built from user input",
+	},
+}
+
+// SecurityHints returns the checklist of bug classes for a security review of code in language,
+// or nil for unknown languages
+func SecurityHints(language string) []string {
+	return securityHints[normalizeLanguage(language)]
+}
+
+// securityChecklist renders the security hints for language as a prompt section, or "" if there are none
+func securityChecklist(language string) string {
+	hints := SecurityHints(language)
+	if len(hints) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nCheck in particular for these common security issues in this language:")
+	for _, hint := range hints {
+		b.WriteString("\n- " + hint)
+	}
+	return b.String()
+}
+
+// outputLanguageInstruction asks for the response in language while keeping code as written
+func outputLanguageInstruction(language string) string {
+	return fmt.Sprintf(" Respond in %s. Keep code, identifiers, file paths, and quoted source text unchanged.", language)
+}
+
+// SystemPromptFor returns the system prompt for the language carried by ctx, prefixed with the
+// call's persona and asking for the response in the call's output language when those are set.
+// The instructions live in the system prompt so every request of a chunked analysis, including
+// the summary, follows them.
+func SystemPromptFor(ctx context.Context) string {
+	opts := CallOptionsFromContext(ctx)
+	prompt := SystemPrompt(opts.Language)
+	if persona := PersonaPrompt(opts.Persona); persona != "" {
+		prompt = persona + " " + prompt
+	}
+	if opts.OutputLanguage != "" {
+		prompt += outputLanguageInstruction(opts.OutputLanguage)
+	}
+	return prompt
+}
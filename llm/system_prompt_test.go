@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+func TestSystemPrompt(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"go", "expert Go reviewer"},
+		{"Golang", "expert Go reviewer"},
+		{" python ", "expert Python reviewer"},
+		{"py", "expert Python reviewer"},
+		{"cobol", defaultSystemPrompt},
+		{"", defaultSystemPrompt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			if got := SystemPrompt(tt.language); !strings.Contains(got, tt.want) {
+				t.Errorf("SystemPrompt(%q) = %q, want it to contain %q", tt.language, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemPromptOutputLanguage(t *testing.T) {
+	ctx := WithCallOptions(context.Background(), CallOptions{Language: "go", OutputLanguage: "Spanish"})
+	got := SystemPromptFor(ctx)
+	if !strings.HasPrefix(got, SystemPrompt("go")) || !strings.Contains(got, "Respond in Spanish.") {
+		t.Errorf("SystemPromptFor() = %q, want the Go persona followed by the language instruction", got)
+	}
+
+	if got := SystemPromptFor(context.Background()); strings.Contains(got, "Respond in") {
+		t.Errorf("expected no language instruction by default, got %q", got)
+	}
+}
+
+func TestSystemPromptPersona(t *testing.T) {
+	for _, persona := range config.Personas {
+		if PersonaPrompt(persona) == "" {
+			t.Errorf("persona %q has no prompt", persona)
+		}
+	}
+
+	ctx := WithCallOptions(context.Background(), CallOptions{Language: "go", OutputLanguage: "Spanish", Persona: "security_auditor"})
+	got := SystemPromptFor(ctx)
+	want := PersonaPrompt("security_auditor") + " " + SystemPrompt("go")
+	if !strings.HasPrefix(got, want) || !strings.HasSuffix(got, outputLanguageInstruction("Spanish")) {
+		t.Errorf("SystemPromptFor() = %q, want the persona, then the Go prompt, then the language instruction", got)
+	}
+}
+
+func TestSecurityHints(t *testing.T) {
+	goHint := securityHints["go"][0]
+	code := "db.Query(fmt.Sprintf(\"SELECT * FROM users WHERE id = %s\", id))"
+
+	tests := []struct {
+		name    string
+		options map[string]any
+		want    bool
+	}{
+		{"go security review", map[string]any{"language": "go", "focus": "security"}, true},
+		{"go alias in json format", map[string]any{"language": "Golang", "focus": "security", "format": FormatJSON}, true},
+		{"other focus", map[string]any{"language": "go", "focus": "performance"}, false},
+		{"default focus", map[string]any{"language": "go"}, false},
+		{"unknown language", map[string]any{"language": "cobol", "focus": "security"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := AnalysisPrompt("code_review", code, tt.options)
+			if got := strings.Contains(prompt, goHint); got != tt.want {
+				t.Errorf("Go hints present = %v, want %v:\n%s", got, tt.want, prompt)
+			}
+		})
+	}
+
+	t.Run("python hints", func(t *testing.T) {
+		prompt := AnalysisPrompt("code_review", "pickle.loads(data)", map[string]any{"language": "py", "focus": "security"})
+		if !strings.Contains(prompt, "pickle") || strings.Contains(prompt, goHint) {
+			t.Errorf("expected only the Python hints:\n%s", prompt)
+		}
+	})
+
+	t.Run("prompt template", func(t *testing.T) {
+		registry, err := LoadPromptRegistry(writePromptTemplates(t, map[string]string{
+			"code_review.tmpl": "{{range .SecurityHints}}- {{.}}\n{{end}}{{.Content}}",
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetPromptRegistry(registry)
+		defer SetPromptRegistry(nil)
+
+		if prompt := AnalysisPrompt("code_review", code, map[string]any{"language": "go", "focus": "security"}); !strings.HasPrefix(prompt, "- "+goHint+"\n") {
+			t.Errorf("expected the template to list the Go hints, got:\n%s", prompt)
+		}
+	})
+}
+
+func TestProviderUsesLanguageSystemPrompt(t *testing.T) {
+	var captured struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider(Config{APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+
+	for language, want := range map[string]string{
+		"go":     SystemPrompt("go"),
+		"python": SystemPrompt("python"),
+		"":       defaultSystemPrompt,
+	} {
+		ctx := WithCallOptions(context.Background(), CallOptions{Language: language})
+		if _, err := provider.Analyze(ctx, "Review this"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(captured.Messages) == 0 || captured.Messages[0].Role != "system" {
+			t.Fatalf("missing system message: %+v", captured.Messages)
+		}
+		if captured.Messages[0].Content != want {
+			t.Errorf("language %q: system prompt = %q, want %q", language, captured.Messages[0].Content, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package llm
+
+import "testing"
+
+func TestProviderTemperatureDefault(t *testing.T) {
+	constructors := map[string]func(Config) (float64, error){
+		"openai": func(c Config) (float64, error) {
+			p, err := NewOpenAIProvider(c)
+			if err != nil {
+				return 0, err
+			}
+			return p.temperature, nil
+		},
+		"google": func(c Config) (float64, error) {
+			p, err := NewGoogleProvider(c)
+			if err != nil {
+				return 0, err
+			}
+			return p.temperature, nil
+		},
+		"ollama": func(c Config) (float64, error) {
+			p, err := NewOllamaProvider(c)
+			if err != nil {
+				return 0, err
+			}
+			return p.temperature, nil
+		},
+		"mistral": func(c Config) (float64, error) {
+			p, err := NewMistralProvider(c)
+			if err != nil {
+				return 0, err
+			}
+			return p.temperature, nil
+		},
+		"openrouter": func(c Config) (float64, error) {
+			p, err := NewOpenRouterProvider(c)
+			if err != nil {
+				return 0, err
+			}
+			return p.temperature, nil
+		},
+		"perplexity": func(c Config) (float64, error) {
+			p, err := NewPerplexityProvider(c)
+			if err != nil {
+				return 0, err
+			}
+			return p.temperature, nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   float64
+	}{
+		{"unset", Config{}, 0.3},
+		{"explicit zero", Config{Temperature: 0, TemperatureSet: true}, 0},
+		{"explicit value", Config{Temperature: 0.7, TemperatureSet: true}, 0.7},
+		{"value without flag", Config{Temperature: 0.7}, 0.7},
+	}
+
+	for name, newProvider := range constructors {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				config := tt.config
+				config.Provider = name
+				config.APIKey = "test-key"
+				got, err := newProvider(config)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("temperature = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// elisionMarkerReserve is the space held back from the limit for elision markers
+const elisionMarkerReserve = 64
+
+// elisionMarker stands in for n bytes of content dropped to fit the chunk size
+func elisionMarker(n int) string {
+	return fmt.Sprintf("[... %d bytes elided ...]\n", n)
+}
+
+// truncateContent reduces content to about limit bytes using strategy, marking where content was
+// dropped. Content within the limit and unknown strategies leave content unchanged.
+func truncateContent(content string, limit int, strategy string) string {
+	if len(content) <= limit {
+		return content
+	}
+	budget := max(limit-elisionMarkerReserve, 0)
+
+	switch strategy {
+	case config.TruncationHead:
+		head := headOf(content, budget)
+		return ensureNewline(head) + elisionMarker(len(content)-len(head))
+	case config.TruncationTail:
+		tail := tailOf(content, budget)
+		return elisionMarker(len(content)-len(tail)) + tail
+	case config.TruncationHeadTail:
+		return truncateHeadTail(content, budget)
+	case config.TruncationSmart:
+		return truncateSmart(content, limit, budget)
+	}
+	return content
+}
+
+// truncateHeadTail keeps the beginning and end of content with a marker for the middle
+func truncateHeadTail(content string, budget int) string {
+	head := headOf(content, budget/2)
+	tail := tailOf(content, budget-len(head))
+	return ensureNewline(head) + elisionMarker(len(content)-len(head)-len(tail)) + tail
+}
+
+// truncateSmart keeps a diff's headers and changed lines, replacing runs of unchanged context
+// lines with markers. Content that is not a diff, or is still too large without its context,
+// falls back to head_tail.
+func truncateSmart(content string, limit, budget int) string {
+	if !strings.Contains(content, "\n@@ ") {
+		return truncateHeadTail(content, budget)
+	}
+
+	var b strings.Builder
+	elided := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		// Unchanged context lines start with a space; everything else shapes or changes the diff
+		if strings.HasPrefix(line, " ") {
+			elided += len(line)
+			continue
+		}
+		if elided > 0 {
+			b.WriteString(elisionMarker(elided))
+			elided = 0
+		}
+		b.WriteString(line)
+	}
+	if elided > 0 {
+		b.WriteString(elisionMarker(elided))
+	}
+
+	reduced := b.String()
+	if len(reduced) > limit {
+		return truncateHeadTail(reduced, budget)
+	}
+	return reduced
+}
+
+// headOf returns at most n bytes from the start of s, ending at a line break when one is close
+func headOf(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	if i := strings.LastIndex(s[:n], "\n"); i > n/2 {
+		return s[:i+1]
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// tailOf returns at most n bytes from the end of s, starting after a line break when one is close
+func tailOf(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	start := len(s) - n
+	if i := strings.Index(s[start:], "\n"); i >= 0 && i < n/2 {
+		return s[start+i+1:]
+	}
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
+
+// ensureNewline terminates s with a newline so a following marker starts its own line
+func ensureNewline(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// numberedLines returns n lines of the form "line 001\n"
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %03d\n", i)
+	}
+	return b.String()
+}
+
+func TestTruncateContentStrategies(t *testing.T) {
+	content := numberedLines(200) // 1800 bytes
+	limit := 400
+
+	tests := []struct {
+		strategy   string
+		keepFirst  bool
+		keepLast   bool
+		markerLine int // index of the marker among the output lines: 0 first, -1 last, otherwise somewhere inside
+	}{
+		{config.TruncationHead, true, false, -1},
+		{config.TruncationTail, false, true, 0},
+		{config.TruncationHeadTail, true, true, 1},
+		// Not a diff, so smart falls back to head_tail
+		{config.TruncationSmart, true, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			got := truncateContent(content, limit, tt.strategy)
+
+			if len(got) > limit {
+				t.Errorf("got %d bytes, want at most %d", len(got), limit)
+			}
+			if strings.Count(got, "bytes elided ...]") != 1 {
+				t.Fatalf("expected exactly one elision marker:\n%s", got)
+			}
+			if strings.Contains(got, "line 001\n") != tt.keepFirst {
+				t.Errorf("keeps first line = %v, want %v:\n%s", !tt.keepFirst, tt.keepFirst, got)
+			}
+			if strings.Contains(got, "line 200\n") != tt.keepLast {
+				t.Errorf("keeps last line = %v, want %v:\n%s", !tt.keepLast, tt.keepLast, got)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+			markerAt := -2
+			for i, line := range lines {
+				if strings.Contains(line, "bytes elided") {
+					markerAt = i
+				}
+			}
+			switch tt.markerLine {
+			case 0:
+				if markerAt != 0 {
+					t.Errorf("expected the marker on the first line, got line %d", markerAt)
+				}
+			case -1:
+				if markerAt != len(lines)-1 {
+					t.Errorf("expected the marker on the last line, got line %d of %d", markerAt, len(lines))
+				}
+			default:
+				if markerAt <= 0 || markerAt >= len(lines)-1 {
+					t.Errorf("expected the marker between kept lines, got line %d of %d", markerAt, len(lines))
+				}
+			}
+
+			// Kept lines are whole lines
+			for _, line := range lines {
+				if !strings.HasPrefix(line, "line ") && !strings.Contains(line, "bytes elided") {
+					t.Errorf("unexpected partial line %q", line)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateContentSmartKeepsChangedHunks(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("diff --git a/big.go b/big.go\n--- a/big.go\n+++ b/big.go\n")
+	b.WriteString("@@ -1,200 +1,200 @@\n")
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&b, " unchanged context line %03d\n", i)
+	}
+	b.WriteString("-removed line\n+added line\n")
+	for i := 101; i <= 200; i++ {
+		fmt.Fprintf(&b, " unchanged context line %03d\n", i)
+	}
+	content := b.String()
+
+	got := truncateContent(content, 500, config.TruncationSmart)
+
+	if len(got) > 500 {
+		t.Errorf("got %d bytes, want at most 500", len(got))
+	}
+	for _, want := range []string{"diff --git a/big.go b/big.go", "+++ b/big.go", "@@ -1,200 +1,200 @@", "-removed line\n+added line"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected smart truncation to keep %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "unchanged context line") {
+		t.Errorf("expected unchanged context to be elided:\n%s", got)
+	}
+	if strings.Count(got, "bytes elided ...]") != 2 {
+		t.Errorf("expected a marker for the context before and after the change:\n%s", got)
+	}
+}
+
+func TestTruncateContentWithinLimit(t *testing.T) {
+	content := numberedLines(3)
+	for _, strategy := range config.TruncationStrategies {
+		if got := truncateContent(content, 1000, strategy); got != content {
+			t.Errorf("%s changed content within the limit: %q", strategy, got)
+		}
+	}
+}
+
+func TestChunkerAppliesTruncationStrategy(t *testing.T) {
+	content := numberedLines(200)
+
+	t.Run("configured strategy sends one truncated request", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Memory.TruncationStrategy = config.TruncationHeadTail
+		provider := NewMockProvider("mock")
+		w := NewOptimizedProvider(provider, cfg).(*optimizedProviderWrapper)
+
+		if _, err := w.analyzeInChunks(context.Background(), content, 400, 1000, 0.2, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if provider.CalledCount != 1 {
+			t.Errorf("expected a single request without a summary, got %d", provider.CalledCount)
+		}
+		if !strings.Contains(provider.CalledWith, "bytes elided ...]") {
+			t.Errorf("expected the truncated prompt to carry an elision marker:\n%s", provider.CalledWith)
+		}
+	})
+
+	t.Run("multiple files are still split", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Memory.TruncationStrategy = config.TruncationHead
+		w := NewOptimizedProvider(NewMockProvider("mock"), cfg).(*optimizedProviderWrapper)
+
+		diff := "diff --git a/a.go b/a.go\n" + content + "diff --git a/b.go b/b.go\n" + content
+		if chunks := w.splitContentIntoChunks(diff, 400); len(chunks) < 2 {
+			t.Errorf("expected a multi-file diff to be split, got %d chunk(s)", len(chunks))
+		}
+	})
+
+	t.Run("no strategy keeps splitting", func(t *testing.T) {
+		w := NewOptimizedProvider(NewMockProvider("mock"), &config.Config{}).(*optimizedProviderWrapper)
+		if chunks := w.splitContentIntoChunks(content, 400); len(chunks) < 2 {
+			t.Errorf("expected content to be split without a strategy, got %d chunk(s)", len(chunks))
+		}
+	})
+}
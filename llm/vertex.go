@@ -0,0 +1,297 @@
+package llm
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// DefaultVertexRegion is the Vertex AI region used when none is configured
+const DefaultVertexRegion = "us-central1"
+
+// googleCloudScope is the OAuth scope Vertex AI requests need
+const googleCloudScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// googleTokenURL is where Application Default Credentials are exchanged for access tokens
+var googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// defaultMetadataHost is the GCE metadata server; GCE_METADATA_HOST overrides it
+const defaultMetadataHost = "169.254.169.254"
+
+// metadataTimeout bounds the metadata server lookup, which hangs or fails outside GCP
+const metadataTimeout = 3 * time.Second
+
+// tokenExpiryMargin refreshes a cached token this long before it expires
+const tokenExpiryMargin = time.Minute
+
+// googleAPIURL is the Generative Language API URL for a model method
+func googleAPIURL(model, method string) string {
+	return fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:%s", url.PathEscape(model), method)
+}
+
+// googleModelsURL lists the Generative Language API models
+const googleModelsURL = "https://generativelanguage.googleapis.com/v1beta/models"
+
+// maxGoogleModelPages bounds how many pages of the model list are fetched
+const maxGoogleModelPages = 10
+
+// validVertexRegion reports whether region can be used in a Vertex AI host name
+func validVertexRegion(region string) bool {
+	return config.ValidVertexRegion(region)
+}
+
+// vertexURL is the Vertex AI URL for a Google model method in a project and region.
+// The global location has no regional host.
+func vertexURL(project, region, model, method string) string {
+	host := region + "-aiplatform.googleapis.com"
+	if region == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+	return fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
+		host, url.PathEscape(project), region, url.PathEscape(model), method)
+}
+
+// googleTokenSource fetches OAuth access tokens from the Application Default Credentials chain
+// and caches them until shortly before they expire. The chain is, in order: the
+// GOOGLE_OAUTH_ACCESS_TOKEN variable, the credentials file named by GOOGLE_APPLICATION_CREDENTIALS
+// or written by `gcloud auth application-default login`, and the GCE metadata server.
+type googleTokenSource struct {
+	mu     sync.Mutex
+	client *http.Client
+	token  string
+	expiry time.Time
+}
+
+// newGoogleTokenSource returns a token source exchanging credentials through client
+func newGoogleTokenSource(client *http.Client) *googleTokenSource {
+	return &googleTokenSource{client: client}
+}
+
+// Token returns a valid access token, fetching a new one when the cached token is about to expire
+func (s *googleTokenSource) Token(ctx context.Context) (string, error) {
+	// An explicit token is used as given and never cached, so it can be rotated
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Add(tokenExpiryMargin).Before(s.expiry) {
+		return s.token, nil
+	}
+
+	var token tokenResponse
+	var err error
+	if path := credentialsFilePath(); path != "" {
+		token, err = s.fromCredentialsFile(ctx, path)
+	} else {
+		token, err = s.fromMetadataServer(ctx)
+		if err != nil {
+			err = fmt.Errorf("no Google Cloud credentials found (set GOOGLE_APPLICATION_CREDENTIALS, run `gcloud auth application-default login`, or run on GCP): %w", err)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token.AccessToken
+	s.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// tokenResponse is an OAuth token endpoint or metadata server response
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// googleCredentials is an Application Default Credentials file: an authorized user from gcloud,
+// or a service account key
+type googleCredentials struct {
+	Type string `json:"type"`
+
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// credentialsFilePath returns the Application Default Credentials file to use, or "" if there is none
+func credentialsFilePath() string {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		return path
+	}
+
+	var dir string
+	if runtime.GOOS == "windows" {
+		dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+	if dir == "" {
+		return ""
+	}
+	path := filepath.Join(dir, "application_default_credentials.json")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// fromCredentialsFile exchanges the credentials in path for an access token
+func (s *googleTokenSource) fromCredentialsFile(ctx context.Context, path string) (tokenResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("failed to read Google credentials: %w", err)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return tokenResponse{}, fmt.Errorf("failed to parse Google credentials %s: %w", path, err)
+	}
+
+	switch creds.Type {
+	case "authorized_user":
+		return s.exchange(ctx, googleTokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {creds.ClientID},
+			"client_secret": {creds.ClientSecret},
+			"refresh_token": {creds.RefreshToken},
+		})
+	case "service_account":
+		tokenURI := creds.TokenURI
+		if tokenURI == "" {
+			tokenURI = googleTokenURL
+		}
+		assertion, err := serviceAccountAssertion(creds, tokenURI, time.Now())
+		if err != nil {
+			return tokenResponse{}, err
+		}
+		return s.exchange(ctx, tokenURI, url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		})
+	case "external_account", "impersonated_service_account":
+		// Workload identity federation and impersonation need the STS and IAM Credentials
+		// flows, which are not implemented; fail loudly rather than fall through
+		return tokenResponse{}, fmt.Errorf("unsupported Google credentials type %q in %s: set GOOGLE_OAUTH_ACCESS_TOKEN (e.g. from `gcloud auth print-access-token`) or use a service account key", creds.Type, path)
+	default:
+		return tokenResponse{}, fmt.Errorf("unsupported Google credentials type %q in %s (use authorized_user or service_account)", creds.Type, path)
+	}
+}
+
+// exchange posts an OAuth token request and returns the token it grants
+func (s *googleTokenSource) exchange(ctx context.Context, tokenURL string, form url.Values) (tokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.fetchToken(s.client, req)
+}
+
+// fromMetadataServer asks the GCE metadata server for the default service account's token
+func (s *googleTokenSource) fromMetadataServer(ctx context.Context) (tokenResponse, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = defaultMetadataHost
+	}
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/computeMetadata/v1/instance/service-accounts/default/token", host), nil)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	// The metadata server is link-local, so it is never reached through the configured proxy
+	return s.fetchToken(&http.Client{}, req)
+}
+
+// fetchToken sends a token request and decodes the granted token
+func (s *googleTokenSource) fetchToken(client *http.Client, req *http.Request) (tokenResponse, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("failed to read access token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return tokenResponse{}, newAPIError("Google OAuth", resp.StatusCode, body)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return tokenResponse{}, fmt.Errorf("failed to parse access token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return tokenResponse{}, errors.New("token response did not contain an access token")
+	}
+	return token, nil
+}
+
+// serviceAccountAssertion builds the signed JWT a service account exchanges for an access token
+func serviceAccountAssertion(creds googleCredentials, audience string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", errors.New("service account private_key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("service account private_key is not an RSA key")
+		}
+		key = rsaKey
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", fmt.Errorf("failed to parse service account private_key: %w", err)
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   creds.ClientEmail,
+		"scope": googleCloudScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign service account assertion: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,236 @@
+package llm
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoogleMethodURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		method string
+		want   string
+	}{
+		{
+			name:   "generative language API",
+			config: Config{APIKey: "test-key", Model: "gemini-2.5-pro"},
+			method: "generateContent",
+			want:   "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-pro:generateContent",
+		},
+		{
+			name:   "vertex AI",
+			config: Config{UseVertex: true, VertexProject: "my-project", VertexRegion: "europe-west4", Model: "gemini-2.5-pro"},
+			method: "generateContent",
+			want:   "https://europe-west4-aiplatform.googleapis.com/v1/projects/my-project/locations/europe-west4/publishers/google/models/gemini-2.5-pro:generateContent",
+		},
+		{
+			name:   "vertex AI default region and streaming",
+			config: Config{UseVertex: true, VertexProject: "my-project", Model: "gemini-2.0-flash"},
+			method: "streamGenerateContent?alt=sse",
+			want:   "https://us-central1-aiplatform.googleapis.com/v1/projects/my-project/locations/us-central1/publishers/google/models/gemini-2.0-flash:streamGenerateContent?alt=sse",
+		},
+		{
+			name:   "vertex AI global location",
+			config: Config{UseVertex: true, VertexProject: "my-project", VertexRegion: "global", Model: "gemini-2.5-pro"},
+			method: "generateContent",
+			want:   "https://aiplatform.googleapis.com/v1/projects/my-project/locations/global/publishers/google/models/gemini-2.5-pro:generateContent",
+		},
+		{
+			name:   "model is escaped",
+			config: Config{UseVertex: true, VertexProject: "my-project", Model: "../../evil?x=1"},
+			method: "generateContent",
+			want:   "https://us-central1-aiplatform.googleapis.com/v1/projects/my-project/locations/us-central1/publishers/google/models/..%2F..%2Fevil%3Fx=1:generateContent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewGoogleProvider(tt.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := provider.methodURL(tt.method); got != tt.want {
+				t.Errorf("methodURL(%q) = %q, want %q", tt.method, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("vertex AI requires a project", func(t *testing.T) {
+		if _, err := NewGoogleProvider(Config{UseVertex: true, APIKey: "test-key"}); err == nil || !strings.Contains(err.Error(), "project") {
+			t.Errorf("expected a missing project error, got %v", err)
+		}
+	})
+
+	t.Run("vertex AI rejects an invalid region", func(t *testing.T) {
+		_, err := NewGoogleProvider(Config{UseVertex: true, VertexProject: "my-project", VertexRegion: "evil.com/x#"})
+		if err == nil || !strings.Contains(err.Error(), "region") {
+			t.Errorf("expected an invalid region error, got %v", err)
+		}
+	})
+}
+
+func TestGoogleProviderVertexAuth(t *testing.T) {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "ya29.test-token")
+
+	var gotAuth, gotKey, gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotKey, gotPath = r.Header.Get("Authorization"), r.Header.Get("x-goog-api-key"), r.URL.Path
+		json.NewEncoder(w).Encode(map[string]any{
+			"candidates": []map[string]any{{"content": map[string]any{"parts": []map[string]string{{"text": "Review text"}}}, "finishReason": "STOP"}},
+		})
+	}))
+	defer server.Close()
+
+	for _, vertex := range []bool{false, true} {
+		config := Config{APIKey: "test-key", Model: "gemini-2.5-pro"}
+		if vertex {
+			config = Config{UseVertex: true, VertexProject: "my-project", Model: "gemini-2.5-pro"}
+		}
+		provider, err := NewGoogleProvider(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider.httpClient = &http.Client{Transport: &testTransport{testServer: server}}
+		if _, err := provider.Analyze(context.Background(), "Review this"); err != nil {
+			t.Fatalf("vertex=%v: unexpected error: %v", vertex, err)
+		}
+
+		if vertex {
+			if gotAuth != "Bearer ya29.test-token" || gotKey != "" {
+				t.Errorf("vertex request sent Authorization %q and API key %q, want only the bearer token", gotAuth, gotKey)
+			}
+			if gotPath != "/v1/projects/my-project/locations/us-central1/publishers/google/models/gemini-2.5-pro:generateContent" {
+				t.Errorf("vertex request path = %q", gotPath)
+			}
+		} else if gotKey != "test-key" || gotAuth != "" {
+			t.Errorf("API request sent Authorization %q and API key %q, want only the API key", gotAuth, gotKey)
+		}
+	}
+}
+
+func TestGoogleTokenSource(t *testing.T) {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		switch r.Form.Get("grant_type") {
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "refresh-me" || r.Form.Get("client_id") != "client" {
+				http.Error(w, "bad refresh request", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "user-token", "expires_in": 3600})
+		case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			parts := strings.Split(r.Form.Get("assertion"), ".")
+			if len(parts) != 3 {
+				http.Error(w, "malformed assertion", http.StatusBadRequest)
+				return
+			}
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+				http.Error(w, "bad signature", http.StatusUnauthorized)
+				return
+			}
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			var decoded map[string]any
+			json.Unmarshal(claims, &decoded)
+			if decoded["iss"] != "sa@my-project.iam.gserviceaccount.com" || decoded["scope"] != googleCloudScope {
+				http.Error(w, "bad claims", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "sa-token", "expires_in": 3600})
+		default:
+			http.Error(w, "unsupported grant", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	originalURL := googleTokenURL
+	googleTokenURL = server.URL
+	defer func() { googleTokenURL = originalURL }()
+
+	writeCredentials := func(creds map[string]string) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		data, _ := json.Marshal(creds)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+	}
+
+	t.Run("authorized user", func(t *testing.T) {
+		writeCredentials(map[string]string{"type": "authorized_user", "client_id": "client", "client_secret": "secret", "refresh_token": "refresh-me"})
+		requests = 0
+		source := newGoogleTokenSource(http.DefaultClient)
+		for range 2 {
+			token, err := source.Token(context.Background())
+			if err != nil || token != "user-token" {
+				t.Fatalf("Token() = %q, %v", token, err)
+			}
+		}
+		if requests != 1 {
+			t.Errorf("made %d token requests, want the token cached after 1", requests)
+		}
+	})
+
+	t.Run("service account", func(t *testing.T) {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeCredentials(map[string]string{
+			"type":         "service_account",
+			"client_email": "sa@my-project.iam.gserviceaccount.com",
+			"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			"token_uri":    server.URL,
+		})
+		token, err := newGoogleTokenSource(http.DefaultClient).Token(context.Background())
+		if err != nil || token != "sa-token" {
+			t.Fatalf("Token() = %q, %v", token, err)
+		}
+	})
+
+	t.Run("rejected credentials", func(t *testing.T) {
+		writeCredentials(map[string]string{"type": "authorized_user", "client_id": "client", "refresh_token": "stale"})
+		if _, err := newGoogleTokenSource(http.DefaultClient).Token(context.Background()); err == nil || !strings.Contains(err.Error(), "Google OAuth") {
+			t.Errorf("expected a token endpoint error, got %v", err)
+		}
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		writeCredentials(map[string]string{"type": "external_account"})
+		if _, err := newGoogleTokenSource(http.DefaultClient).Token(context.Background()); err == nil || !strings.Contains(err.Error(), "unsupported Google credentials type") {
+			t.Errorf("expected an unsupported type error, got %v", err)
+		}
+	})
+
+	t.Run("workload identity credentials point to an access token", func(t *testing.T) {
+		writeCredentials(map[string]string{"type": "external_account"})
+		if _, err := newGoogleTokenSource(http.DefaultClient).Token(context.Background()); err == nil || !strings.Contains(err.Error(), "GOOGLE_OAUTH_ACCESS_TOKEN") {
+			t.Errorf("expected the error to suggest GOOGLE_OAUTH_ACCESS_TOKEN, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+// newLogger creates a text logger at the given level.
+// Logs must never go to stdout, which carries the MCP stdio protocol.
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// logStartup records the effective configuration without exposing credentials
+func logStartup(logger *slog.Logger, conf *config.Config) {
+	logger.Info("loaded configuration",
+		"source", conf.ConfigType,
+		"log_level", conf.GetLogLevel().String(),
+	)
+
+	for _, name := range enabledProviders(conf) {
+		_, model, _ := conf.GetProviderConfig(name)
+		logger.Info("provider enabled", "provider", name, "model", model)
+	}
+
+	logger.Info("default provider", "provider", conf.DefaultProvider)
+}
+
+// enabledProviders returns the providers that have the credentials or endpoint they need
+func enabledProviders(conf *config.Config) []string {
+	var providers []string
+	if conf.OpenAI.APIKey != "" {
+		providers = append(providers, "openai")
+	}
+	if conf.Google.APIKey != "" || (conf.Google.UseVertex && conf.Google.Project != "") {
+		providers = append(providers, "google")
+	}
+	if conf.Ollama.Endpoint != "" {
+		providers = append(providers, "ollama")
+	}
+	if conf.Mistral.APIKey != "" {
+		providers = append(providers, "mistral")
+	}
+	if conf.OpenRouter.APIKey != "" {
+		providers = append(providers, "openrouter")
+	}
+	if conf.Perplexity.APIKey != "" {
+		providers = append(providers, "perplexity")
+	}
+	return providers
+}
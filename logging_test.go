@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+func TestLogStartupOmitsSecrets(t *testing.T) {
+	conf := &config.Config{
+		DefaultProvider: "openai",
+		ConfigType:      ".second-opinion.json",
+		LogLevel:        "debug",
+	}
+	conf.OpenAI.APIKey = "sk-test-openai-secret-value"
+	conf.OpenAI.Model = "gpt-4o-mini"
+	conf.Google.APIKey = "AIza-test-google-secret-value"
+	conf.Google.Model = "gemini-2.0-flash"
+	conf.Mistral.APIKey = "mistral-test-secret-value"
+	conf.Mistral.Model = "mistral-small-latest"
+
+	var buf bytes.Buffer
+	logStartup(newLogger(&buf, slog.LevelDebug), conf)
+	output := buf.String()
+
+	for _, secret := range []string{conf.OpenAI.APIKey, conf.Google.APIKey, conf.Mistral.APIKey} {
+		if strings.Contains(output, secret) {
+			t.Errorf("startup log contains API key %q:\n%s", secret, output)
+		}
+	}
+
+	for _, want := range []string{"provider=openai", "provider=google", "provider=mistral", "model=gpt-4o-mini"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("startup log missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestNewLoggerRespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, slog.LevelWarn)
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") {
+		t.Errorf("info message logged at warn level: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "shown") {
+		t.Errorf("warn message missing: %s", buf.String())
+	}
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/dshills/second-opinion/config"
 	"github.com/dshills/second-opinion/llm"
@@ -11,58 +18,127 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// ollamaPreloadTimeout bounds how long startup model warming may take
+const ollamaPreloadTimeout = 5 * time.Minute
+
 var (
 	cfg                   *config.Config
 	llmProviders          = make(map[string]llm.Provider)
 	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
 	llmProvidersMux       sync.RWMutex
+	providerUsage         = newProviderLRU()
+	// providerMetrics records LLM requests for the metrics endpoint
+	providerMetrics = llm.NewProviderMetrics()
 )
 
 func main() {
+	transport := flag.String("transport", "", "Server transport: stdio or http (overrides SERVER_TRANSPORT)")
+	addr := flag.String("addr", "", "Listen address for the http transport (overrides SERVER_ADDR)")
+	flag.Parse()
+
 	// Load configuration
 	var err error
 	cfg, err = config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
 	}
 
-	log.Printf("%+v", cfg)
-	log.Printf("Loaded configuration from %s", cfg.ConfigType)
-	if cfg.OpenAI.APIKey != "" {
-		log.Println("OpenAI Enabled")
+	// Command-line flags win over the configuration
+	if *transport != "" {
+		cfg.ServerTransport = *transport
 	}
-	if cfg.Google.APIKey != "" {
-		log.Println("Google Enabled")
+	if *addr != "" {
+		cfg.ServerAddr = *addr
 	}
-	if cfg.Ollama.Endpoint != "" {
-		log.Println("Ollama Enabled")
+
+	// Catch missing credentials and bad limits now rather than on the first request
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration (%s):\n%v\n", cfg.ConfigType, err)
+		os.Exit(1)
 	}
-	if cfg.Mistral.APIKey != "" {
-		log.Println("Mistral Enabled")
+
+	logger := newLogger(os.Stderr, cfg.GetLogLevel())
+	slog.SetDefault(logger)
+	logStartup(logger, cfg)
+
+	// Repository tools need git; say so now rather than on the first call
+	if err := requireGit(); err != nil {
+		logger.Warn("repository tools are unavailable", "error", err)
 	}
-	log.Printf("Default provider: %s", cfg.DefaultProvider)
 
-	// Initialize default LLM provider
-	apiKey, model, endpoint := cfg.GetProviderConfig(cfg.DefaultProvider)
-	defaultConfig := llm.Config{
-		Provider:    cfg.DefaultProvider,
-		APIKey:      apiKey,
-		Model:       model,
-		Endpoint:    endpoint,
-		Temperature: cfg.Temperature,
-		MaxTokens:   cfg.MaxTokens,
+	// Record provider traffic for reproducing model misbehavior; providers must be created after this
+	if cfg.DebugLogFile != "" {
+		if cfg.GetLogLevel() != slog.LevelDebug {
+			logger.Warn("debug_log_file is ignored unless log_level is debug", "path", cfg.DebugLogFile)
+		} else if debugLog, err := llm.OpenDebugLog(cfg.DebugLogFile); err != nil {
+			logger.Error("failed to open debug log", "path", cfg.DebugLogFile, "error", err)
+		} else {
+			defer debugLog.Close()
+			llm.SetDebugLog(debugLog)
+			logger.Debug("logging provider requests and responses", "path", cfg.DebugLogFile)
+		}
+	}
+
+	// Replace built-in prompts with the user's templates; a broken template stops startup
+	if cfg.PromptDir != "" {
+		registry, err := llm.LoadPromptRegistry(cfg.PromptDir)
+		if err != nil {
+			logger.Error("failed to load prompt templates", "dir", cfg.PromptDir, "error", err)
+			os.Exit(1)
+		}
+		llm.SetPromptRegistry(registry)
+		logger.Info("loaded prompt templates", "dir", cfg.PromptDir, "types", registry.Types())
 	}
 
+	// Bound concurrent LLM requests across all tools
+	llm.SetMaxConcurrentRequests(cfg.MaxConcurrentRequests)
+
+	// Pace requests per provider to avoid 429s
+	llm.SetRateLimits(cfg.RateLimits)
+
+	// Fail fast for providers that keep failing instead of retrying every call
+	llm.SetCircuitBreakers(cfg.CircuitBreaker.FailureThreshold, cfg.CircuitBreaker.Window(), cfg.CircuitBreaker.Cooldown())
+
+	// Record request metrics when they are served; providers must be created after the middleware is set
+	if cfg.MetricsAddr != "" {
+		llm.SetMiddleware(llm.MetricsMiddleware(providerMetrics))
+	}
+
+	// Initialize default LLM provider
+	defaultConfig := newProviderConfig(cfg.DefaultProvider, "")
+
 	defaultProvider, err := llm.NewProvider(defaultConfig)
 	if err != nil {
-		log.Fatalf("Failed to initialize default LLM provider: %v", err)
+		logger.Error("failed to initialize default LLM provider", "provider", cfg.DefaultProvider, "error", err)
+		os.Exit(1)
 	}
 
 	llmProvidersMux.Lock()
-	llmProviders[cfg.DefaultProvider] = defaultProvider
-	optimizedLLMProviders[cfg.DefaultProvider] = llm.NewOptimizedProvider(defaultProvider, cfg)
+	cacheProviderLocked(cfg.DefaultProvider, defaultProvider, llm.NewOptimizedProvider(defaultProvider, cfg))
 	llmProvidersMux.Unlock()
 
+	// Create the other configured providers now so their first call does not pay for it
+	if cfg.PreinitializeProviders {
+		preinitializeProviders(logger)
+	}
+
+	// Warm the Ollama model in the background so startup is not blocked
+	if cfg.Ollama.Preload && cfg.Ollama.Endpoint != "" {
+		go preloadOllama(logger)
+	}
+
+	s := newMCPServer()
+
+	logger.Info("starting server", "name", cfg.ServerName, "version", cfg.ServerVersion, "default_provider", cfg.DefaultProvider, "transport", cfg.ServerTransport)
+	if err := serve(s, logger); err != nil {
+		logger.Error("server error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// newMCPServer creates the MCP server with every tool registered
+func newMCPServer() *server.MCPServer {
 	s := server.NewMCPServer(
 		cfg.ServerName,
 		cfg.ServerVersion,
@@ -71,26 +147,67 @@ func main() {
 	)
 
 	// Git diff analysis tool
-	gitDiffTool := mcp.NewTool("analyze_git_diff",
+	gitDiffTool := mcp.NewTool("analyze_git_diff", withAnalysisOptions(withDiffFilterOptions(
 		mcp.WithDescription("Analyze git diff output to understand code changes using LLM"),
 		mcp.WithString("diff_content",
-			mcp.Required(),
-			mcp.Description("Git diff output to analyze"),
+			mcp.Description("Git diff output to analyze (provide this or diff_file)"),
+		),
+		mcp.WithString("diff_file",
+			mcp.Description("Path to a file containing the diff, within the working directory or an allowed root (provide this or diff_content)"),
 		),
 		mcp.WithBoolean("summarize",
 			mcp.Description("Whether to provide a summary of changes"),
 		),
+		mcp.WithBoolean("per_file",
+			mcp.Description("Review each changed file in its own labeled section, followed by an overall summary (default: false)"),
+		),
+		mcp.WithBoolean("force",
+			mcp.Description("Review even when the diff only renames files or changes whitespace (default: false)"),
+		),
 		mcp.WithString("provider",
-			mcp.Description("LLM provider to use (openai, google, ollama, mistral)"),
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
 		),
 		mcp.WithString("model",
 			mcp.Description("Model to use (overrides default for provider)"),
 		),
-	)
-	s.AddTool(gitDiffTool, handleGitDiff)
+	)...)...)
+	s.AddTool(gitDiffTool, withAttribution(handleGitDiff))
+
+	// GitHub pull request review tool
+	githubPRTool := mcp.NewTool("review_github_pr", withAnalysisOptions(withDiffFilterOptions(
+		mcp.WithDescription("Fetch a GitHub pull request's diff and review it using LLM. Private repositories need GITHUB_TOKEN"),
+		mcp.WithString("pr_url",
+			mcp.Description("Pull request URL, e.g. https://github.com/owner/repo/pull/123 (provide this or owner, repo, and number)"),
+		),
+		mcp.WithString("owner",
+			mcp.Description("Repository owner"),
+		),
+		mcp.WithString("repo",
+			mcp.Description("Repository name"),
+		),
+		mcp.WithNumber("number",
+			mcp.Description("Pull request number"),
+		),
+		mcp.WithBoolean("summarize",
+			mcp.Description("Whether to provide a summary of changes"),
+		),
+		mcp.WithBoolean("per_file",
+			mcp.Description("Review each changed file in its own labeled section, followed by an overall summary (default: false)"),
+		),
+		mcp.WithBoolean("force",
+			mcp.Description("Review even when the diff only renames files or changes whitespace (default: false)"),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)...)
+	s.AddTool(githubPRTool, withAttribution(handleReviewGitHubPR))
 
 	// Code review tool
-	codeReviewTool := mcp.NewTool("review_code",
+	codeReviewTool := mcp.NewTool("review_code", withAnalysisOptions(
 		mcp.WithDescription("Review code for quality, security, and best practices using LLM"),
 		mcp.WithString("code",
 			mcp.Required(),
@@ -100,20 +217,65 @@ func main() {
 			mcp.Description("Programming language of the code"),
 		),
 		mcp.WithString("focus",
-			mcp.Description("Specific focus area for review (security, performance, style, etc.)"),
-			mcp.Enum("security", "performance", "style", "all"),
+			mcp.Description(fmt.Sprintf("Focus area for the review: security, performance, style, or all (default), or any free-text topic such as \"concurrency safety\" or \"API ergonomics\" (at most %d characters)", llm.MaxFocusLength)),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: markdown text, json findings, or sarif for code scanning (default: markdown)"),
+			mcp.Enum("markdown", "json", "sarif"),
+		),
+		mcp.WithString("min_severity",
+			mcp.Description("Only report findings at or above this severity; json and sarif findings are filtered after parsing (default: all findings)"),
+			mcp.Enum("info", "low", "medium", "high", "critical"),
+		),
+		mcp.WithBoolean("lint",
+			mcp.Description("Run deterministic checks (trailing whitespace, mixed indentation, long lines, TODO/FIXME markers, hardcoded secrets) and merge their findings with the review (default: the configured lint_code, off)"),
 		),
 		mcp.WithString("provider",
-			mcp.Description("LLM provider to use (openai, google, ollama, mistral)"),
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
 		),
 		mcp.WithString("model",
 			mcp.Description("Model to use (overrides default for provider)"),
 		),
-	)
-	s.AddTool(codeReviewTool, handleCodeReview)
+	)...)
+	s.AddTool(codeReviewTool, withAttribution(handleCodeReview))
+
+	// Batch code review tool
+	codeReviewBatchTool := mcp.NewTool("review_code_batch", withAnalysisOptions(
+		mcp.WithDescription("Review several independent code snippets in one call with bounded concurrency, returning each review keyed by snippet id; a failing snippet does not fail the batch"),
+		mcp.WithArray("items",
+			mcp.Required(),
+			mcp.Description(fmt.Sprintf("Snippets to review (at most %d)", maxBatchItems)),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id":       map[string]any{"type": "string", "description": "Unique id the review is returned under"},
+					"code":     map[string]any{"type": "string", "description": "Code to review"},
+					"language": map[string]any{"type": "string", "description": "Programming language of the code"},
+				},
+				"required": []string{"id", "code"},
+			}),
+		),
+		mcp.WithString("focus",
+			mcp.Description("Focus area applied to every snippet, as for review_code (default: all)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: markdown sections, or json mapping each id to its review or error (default: markdown)"),
+			mcp.Enum("markdown", "json"),
+		),
+		mcp.WithNumber("concurrency",
+			mcp.Description(fmt.Sprintf("Maximum snippets reviewed at once (default: %d, maximum: %d)", defaultBatchConcurrency, maxBatchConcurrency)),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)
+	s.AddTool(codeReviewBatchTool, withAttribution(handleReviewCodeBatch))
 
 	// Commit analysis tool
-	commitAnalysisTool := mcp.NewTool("analyze_commit",
+	commitAnalysisTool := mcp.NewTool("analyze_commit", withAnalysisOptions(
 		mcp.WithDescription("Analyze a git commit for quality and adherence to best practices using LLM"),
 		mcp.WithString("commit_sha",
 			mcp.Description("Git commit SHA to analyze (default: HEAD)"),
@@ -121,14 +283,46 @@ func main() {
 		mcp.WithString("repo_path",
 			mcp.Description("Path to the git repository (default: current directory)"),
 		),
+		mcp.WithArray("paths",
+			mcp.Description("Only analyze changes to these files or directories, relative to the repository root (default: the whole commit). Paths are matched exactly; wildcards are not supported"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithBoolean("include_blame",
+			mcp.Description(fmt.Sprintf("Add who last changed each line the commit removes or changes, and in which commit, blamed in the commit's parent; at most %d lines are blamed (default: false)", maxBlameLines)),
+		),
+		mcp.WithBoolean("no_cache",
+			mcp.Description("Analyze the commit again instead of reusing a cached analysis from the same provider, model, and prompts (default: false)"),
+		),
 		mcp.WithString("provider",
-			mcp.Description("LLM provider to use (openai, google, ollama, mistral)"),
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
 		),
 		mcp.WithString("model",
 			mcp.Description("Model to use (overrides default for provider)"),
 		),
-	)
-	s.AddTool(commitAnalysisTool, handleCommitAnalysis)
+	)...)
+	s.AddTool(commitAnalysisTool, withAttribution(handleCommitAnalysis))
+
+	// Commit explanation tool
+	explainCommitTool := mcp.NewTool("explain_commit", withAnalysisOptions(
+		mcp.WithDescription("Explain what a git commit does for a chosen audience: engineers, managers, or a changelog entry"),
+		mcp.WithString("commit_sha",
+			mcp.Description("Git commit SHA to explain (default: HEAD)"),
+		),
+		mcp.WithString("audience",
+			mcp.Description("Who the explanation is for (default: engineer)"),
+			mcp.Enum(llm.AudienceEngineer, llm.AudienceManager, llm.AudienceChangelog),
+		),
+		mcp.WithString("repo_path",
+			mcp.Description("Path to the git repository (default: current directory)"),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)
+	s.AddTool(explainCommitTool, withAttribution(handleExplainCommit))
 
 	// Get repository info tool
 	repoInfoTool := mcp.NewTool("get_repo_info",
@@ -139,8 +333,29 @@ func main() {
 	)
 	s.AddTool(repoInfoTool, handleRepoInfo)
 
+	// Diff statistics tool (no LLM call)
+	diffStatsTool := mcp.NewTool("get_diff_stats",
+		mcp.WithDescription("Get file, insertion, and deletion counts for a diff without invoking an LLM"),
+		mcp.WithString("repo_path",
+			mcp.Description("Path to the git repository (default: current directory)"),
+		),
+		mcp.WithString("from",
+			mcp.Description("Ref to diff from; when omitted, uncommitted changes against HEAD are counted"),
+		),
+		mcp.WithString("to",
+			mcp.Description("Ref to diff to (requires from; default: the working tree)"),
+		),
+		mcp.WithBoolean("staged_only",
+			mcp.Description("Count only staged changes (default: false)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: markdown (default) or json"),
+		),
+	)
+	s.AddTool(diffStatsTool, handleGetDiffStats)
+
 	// Analyze uncommitted work tool
-	uncommittedWorkTool := mcp.NewTool("analyze_uncommitted_work",
+	uncommittedWorkTool := mcp.NewTool("analyze_uncommitted_work", withAnalysisOptions(withDiffFilterOptions(
 		mcp.WithDescription("Analyze uncommitted changes in a git repository using LLM"),
 		mcp.WithString("repo_path",
 			mcp.Description("Path to the git repository (default: current directory)"),
@@ -148,44 +363,367 @@ func main() {
 		mcp.WithBoolean("staged_only",
 			mcp.Description("Analyze only staged changes (default: false, analyzes all uncommitted changes)"),
 		),
+		mcp.WithBoolean("force",
+			mcp.Description("Review even when the diff only renames files or changes whitespace (default: false)"),
+		),
+		mcp.WithString("base_ref",
+			mcp.Description("Diff against the fork point with this branch or commit (e.g. main) instead of HEAD, including committed changes"),
+		),
+		mcp.WithBoolean("include_blame",
+			mcp.Description(fmt.Sprintf("Add who last changed each removed or changed line, and in which commit, so the review can weigh recency and ownership; at most %d lines are blamed (default: false)", maxBlameLines)),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)...)
+	s.AddTool(uncommittedWorkTool, withAttribution(handleAnalyzeUncommittedWork))
+
+	// Compare branches tool
+	compareBranchesTool := mcp.NewTool("compare_branches", withAnalysisOptions(withDiffFilterOptions(
+		mcp.WithDescription("Compare two git branches and assess merge risk using LLM"),
+		mcp.WithString("branch_a",
+			mcp.Description("First branch to compare (default: the repository's default branch, from origin/HEAD or else main or master)"),
+		),
+		mcp.WithString("branch_b",
+			mcp.Required(),
+			mcp.Description("Second branch to compare (e.g. feature/login)"),
+		),
+		mcp.WithString("repo_path",
+			mcp.Description("Path to the git repository (default: current directory)"),
+		),
+		mcp.WithBoolean("include_blame",
+			mcp.Description(fmt.Sprintf("Add who last changed each line branch_b removes or changes on branch_a, and in which commit, so the review can weigh recency and ownership; at most %d lines are blamed (default: false)", maxBlameLines)),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)...)
+	s.AddTool(compareBranchesTool, withAttribution(handleCompareBranches))
+
+	// Commit range analysis tool
+	commitRangeTool := mcp.NewTool("analyze_commit_range", withAnalysisOptions(
+		mcp.WithDescription("Summarize each commit in a range and roll them up into an overview using LLM"),
+		mcp.WithString("from",
+			mcp.Description("Start of the range, exclusive (commit SHA or HEAD~N). Omit to use count instead"),
+		),
+		mcp.WithString("to",
+			mcp.Description("End of the range, inclusive (default: HEAD)"),
+		),
+		mcp.WithNumber("count",
+			mcp.Description("Number of most recent commits up to 'to' to analyze when 'from' is omitted (default: 5)"),
+		),
+		mcp.WithString("repo_path",
+			mcp.Description("Path to the git repository (default: current directory)"),
+		),
+		mcp.WithBoolean("include_blame",
+			mcp.Description(fmt.Sprintf("Add who last changed each line a commit removes or changes, blamed in that commit's parent, to each commit's summary prompt; at most %d lines are blamed per commit (default: false)", maxBlameLines)),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)
+	s.AddTool(commitRangeTool, withAttribution(handleAnalyzeCommitRange))
+
+	// Release notes tool
+	releaseNotesTool := mcp.NewTool("generate_release_notes", withAnalysisOptions(
+		mcp.WithDescription("Write Markdown release notes for the commits between two tags or refs, grouped by Conventional Commit type, using LLM"),
+		mcp.WithString("from_tag",
+			mcp.Description("Tag the previous release was made from, exclusive (alternatively use from_ref)"),
+		),
+		mcp.WithString("to_tag",
+			mcp.Description("Tag of the release, inclusive (default: HEAD)"),
+		),
+		mcp.WithString("from_ref",
+			mcp.Description("Start of the range as any commit SHA, branch, or HEAD~N, exclusive"),
+		),
+		mcp.WithString("to_ref",
+			mcp.Description("End of the range as any commit SHA, branch, or HEAD~N, inclusive (default: HEAD)"),
+		),
+		mcp.WithString("repo_path",
+			mcp.Description("Path to the git repository (default: current directory)"),
+		),
 		mcp.WithString("provider",
-			mcp.Description("LLM provider to use (openai, google, ollama, mistral)"),
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
 		),
 		mcp.WithString("model",
 			mcp.Description("Model to use (overrides default for provider)"),
 		),
+	)...)
+	s.AddTool(releaseNotesTool, withAttribution(handleGenerateReleaseNotes))
+
+	// Action item extraction tool
+	actionItemsTool := mcp.NewTool("extract_action_items", withAnalysisOptions(
+		mcp.WithDescription("Extract TODO/FIXME comments, new tech debt, and untested code paths introduced by a change as a checklist using LLM"),
+		mcp.WithString("diff_content",
+			mcp.Description("Git diff output to scan (alternatively use repo_path with from/to)"),
+		),
+		mcp.WithString("repo_path",
+			mcp.Description("Path to the git repository (default: current directory)"),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start of the range to diff when diff_content is omitted (e.g. main or HEAD~3)"),
+		),
+		mcp.WithString("to",
+			mcp.Description("End of the range to diff (default: HEAD)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format (default: markdown)"),
+			mcp.Enum("markdown", "json"),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)
+	s.AddTool(actionItemsTool, withAttribution(handleExtractActionItems))
+
+	// Incremental re-review tool
+	rereviewTool := mcp.NewTool("rereview", withAnalysisOptions(withDiffFilterOptions(
+		mcp.WithDescription("Review work in progress incrementally: the first call reviews all changes, later calls on the same branch send only what changed since the previous review along with its findings"),
+		mcp.WithString("repo_path",
+			mcp.Description("Path to the git repository (default: current directory)"),
+		),
+		mcp.WithString("base_ref",
+			mcp.Description("Branch or ref the first review compares against from its merge base (default: HEAD, i.e. uncommitted changes)"),
+		),
+		mcp.WithBoolean("reset",
+			mcp.Description("Forget the previous review and review all changes again"),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)...)
+	s.AddTool(rereviewTool, withAttribution(handleRereview))
+
+	// Merge conflict analysis tool
+	mergeConflictTool := mcp.NewTool("analyze_merge_conflict", withAnalysisOptions(
+		mcp.WithDescription("Explain git merge conflicts and suggest a resolution for each, from conflict-marked content or the conflicted files in a repository, using LLM"),
+		mcp.WithString("content",
+			mcp.Description("File content containing conflict markers (alternatively use repo_path to read the conflicted files)"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("Name of the file the content came from, or a single repository file to analyze instead of every conflicted file"),
+		),
+		mcp.WithString("repo_path",
+			mcp.Description("Path to the git repository with an in-progress merge, used when content is omitted (default: current directory)"),
+		),
+		mcp.WithString("provider",
+			mcp.Description("LLM provider to use (openai, google, ollama, mistral, openrouter, perplexity)"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (overrides default for provider)"),
+		),
+	)...)
+	s.AddTool(mergeConflictTool, withAttribution(handleAnalyzeMergeConflict))
+
+	// Provider comparison tool
+	compareProvidersTool := mcp.NewTool("compare_providers", withAnalysisOptions(
+		mcp.WithDescription("Send the same prompt to several providers/models and compare their responses, latency, and token usage side by side, without merging them"),
+		mcp.WithString("prompt",
+			mcp.Required(),
+			mcp.Description("Prompt to send to every provider"),
+		),
+		mcp.WithString("targets",
+			mcp.Required(),
+			mcp.Description(fmt.Sprintf("Comma-separated providers to compare, each optionally with a model as provider:model (e.g. \"openai,openai:gpt-4o,ollama:llama3.2\"); at most %d", maxCompareTargets)),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time to wait for each provider (default: 300)"),
+		),
+	)...)
+	s.AddTool(compareProvidersTool, handleCompareProviders)
+
+	// Follow-up tool
+	followupTool := mcp.NewTool("followup",
+		mcp.WithDescription("Ask a follow-up question about an earlier analysis, such as \"expand on point 2\", continuing the conversation with the same provider and model"),
+		mcp.WithString("conversation_id",
+			mcp.Required(),
+			mcp.Description("Conversation ID returned by an analysis tool called with return_conversation_id"),
+		),
+		mcp.WithString("question",
+			mcp.Required(),
+			mcp.Description("Question about the analysis"),
+		),
 	)
-	s.AddTool(uncommittedWorkTool, handleAnalyzeUncommittedWork)
+	s.AddTool(followupTool, handleFollowup)
 
-	// Start the stdio server
-	log.Printf("Starting %s with default provider: %s", cfg.ServerName, cfg.DefaultProvider)
-	if err := server.ServeStdio(s); err != nil {
-		log.Fatalf("Server error: %v", err)
-	}
+	// Provider health check tool
+	checkProvidersTool := mcp.NewTool("check_providers",
+		mcp.WithDescription("Check which configured LLM providers are reachable and report their latency"),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time to wait for each provider (default: 30)"),
+		),
+	)
+	s.AddTool(checkProvidersTool, handleCheckProviders)
+
+	// Model discovery tool
+	listModelsTool := mcp.NewTool("list_models",
+		mcp.WithDescription("List the models each configured LLM provider offers, as JSON, to help choose a model override"),
+		mcp.WithString("provider",
+			mcp.Description("Only list models for this provider (default: all configured providers)"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum time to wait for each provider (default: 30)"),
+		),
+	)
+	s.AddTool(listModelsTool, handleListModels)
+
+	return s
 }
 
-// getOrCreateProvider gets an existing provider or creates a new one with the specified config
-func getOrCreateProvider(providerName, modelOverride string) (llm.Provider, error) {
-	// Use default provider if not specified
-	if providerName == "" {
-		providerName = cfg.DefaultProvider
+// serve runs the MCP server over the configured transport until it stops
+func serve(s *server.MCPServer, logger *slog.Logger) error {
+	if cfg.MetricsAddr != "" {
+		if err := serveMetrics(logger); err != nil {
+			return err
+		}
+	}
+	if cfg.ServerTransport == config.TransportHTTP {
+		// Streamable HTTP serves multiple and remote clients from one process
+		logger.Info("listening for MCP clients over HTTP", "addr", cfg.ServerAddr, "endpoint", mcpEndpointPath)
+		httpServer := &http.Server{Addr: cfg.ServerAddr, Handler: newHTTPHandler(s)}
+		return httpServer.ListenAndServe()
 	}
+	return server.ServeStdio(s)
+}
 
-	// Create a cache key that includes both provider and model
-	cacheKey := providerName
-	if modelOverride != "" {
-		cacheKey = fmt.Sprintf("%s:%s", providerName, modelOverride)
+// serveMetrics serves Prometheus metrics on their own address in the background, so they can
+// be scraped without the MCP endpoint's token. Failing to listen stops startup.
+func serveMetrics(logger *slog.Logger) error {
+	listener, err := net.Listen("tcp", cfg.MetricsAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics: %w", err)
 	}
+	logger.Info("serving metrics", "addr", cfg.MetricsAddr, "endpoint", metricsPath)
+	go func() {
+		metricsServer := &http.Server{Handler: newMetricsHandler()}
+		if err := metricsServer.Serve(listener); err != nil {
+			logger.Error("metrics server stopped", "error", err)
+		}
+	}()
+	return nil
+}
 
-	// Check if we already have this provider configured
-	llmProvidersMux.RLock()
-	if provider, exists := llmProviders[cacheKey]; exists {
-		llmProvidersMux.RUnlock()
-		return provider, nil
+// mcpEndpointPath is where the http transport accepts MCP requests
+const mcpEndpointPath = "/mcp"
+
+// metricsPath is where metrics_addr serves Prometheus metrics
+const metricsPath = "/metrics"
+
+// newHTTPHandler routes the MCP endpoint for the http transport, behind the Origin and bearer
+// token checks
+func newHTTPHandler(s *server.MCPServer) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(mcpEndpointPath, newHTTPServer(s))
+	return withHTTPGuard(cfg, mux)
+}
+
+// newMetricsHandler routes the metrics endpoint served on metrics_addr
+func newMetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, providerMetrics.Handler())
+	return mux
+}
+
+// newHTTPServer wraps the MCP server in the streamable HTTP transport
+func newHTTPServer(s *server.MCPServer) *server.StreamableHTTPServer {
+	return server.NewStreamableHTTPServer(s, server.WithEndpointPath(mcpEndpointPath))
+}
+
+// withAnalysisOptions appends the arguments shared by every LLM-backed tool
+func withAnalysisOptions(opts ...mcp.ToolOption) []mcp.ToolOption {
+	return append(opts,
+		mcp.WithBoolean("ignore_context_window",
+			mcp.Description("Send the request even if it likely exceeds the model's context window (default: false)"),
+		),
+		mcp.WithBoolean("raw",
+			mcp.Description("Send the prompt verbatim with the configured temperature and max_tokens, skipping chunking and size/task tuning; secret redaction and the context window, cost, and circuit breaker checks still apply (default: false)"),
+		),
+		mcp.WithBoolean("deterministic",
+			mcp.Description("Force temperature 0 and a fixed sampling seed for reproducible output where the model supports it (default: false)"),
+		),
+		mcp.WithNumber("seed",
+			mcp.Description(fmt.Sprintf("Sampling seed used with deterministic for providers that support one (default: %d)", llm.DefaultSeed)),
+		),
+		mcp.WithBoolean("quiet",
+			mcp.Description("Omit the \"Analyzed by <provider>/<model>\" header from the result (default: false)"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the assembled prompt and computed request parameters without calling the LLM (default: false)"),
+		),
+		mcp.WithBoolean("verbose",
+			mcp.Description("Append a diagnostics section showing the optimization decisions: estimated file count, chunking, max tokens, temperature, estimated tokens, and provider settings (default: false)"),
+		),
+		mcp.WithBoolean("return_conversation_id",
+			mcp.Description("Also return a conversation ID to pass to followup for questions about this analysis (default: false)"),
+		),
+		mcp.WithString("output_language",
+			mcp.Description(fmt.Sprintf("Language to write the response in, as a code or English name (%s; default: the configured output_language)", strings.Join(config.OutputLanguageCodes(), ", "))),
+		),
+		mcp.WithString("persona",
+			mcp.Description(fmt.Sprintf("Reviewer tone: security_auditor (strict, security first), mentor (explains and teaches), or ci_bot (terse, findings only); one of %s (default: the configured persona, else neutral)", strings.Join(config.Personas, ", "))),
+		),
+	)
+}
+
+// withCallOptions attaches the shared per-call arguments from a tool request to ctx, along with
+// a diff cache scoped to the call
+func withCallOptions(ctx context.Context, request mcp.CallToolRequest) context.Context {
+	var opts llm.CallOptions
+	if ignore, ok := request.GetArguments()["ignore_context_window"].(bool); ok {
+		opts.IgnoreContextWindow = ignore
+	}
+	// Structured output formats are built from JSON, so let providers enforce it natively
+	if format, ok := request.GetArguments()["format"].(string); ok {
+		opts.JSONOutput = format == llm.FormatJSON || format == llm.FormatSARIF
+	}
+	if deterministic, ok := request.GetArguments()["deterministic"].(bool); ok && deterministic {
+		opts.Deterministic = true
+		opts.Seed = llm.DefaultSeed
+		if seed, ok := request.GetArguments()["seed"].(float64); ok {
+			opts.Seed = int(seed)
+		}
+	}
+	if dryRun, ok := request.GetArguments()["dry_run"].(bool); ok {
+		opts.DryRun = dryRun
+	}
+	// An unsupported language is rejected by getAnalysisProvider, so it is simply skipped here
+	language := ""
+	if cfg != nil {
+		language = cfg.OutputLanguage
+	}
+	if l, ok := request.GetArguments()["output_language"].(string); ok && l != "" {
+		language = l
 	}
-	llmProvidersMux.RUnlock()
+	opts.OutputLanguage, _ = config.ResolveOutputLanguage(language)
+	// Likewise an unknown persona is rejected by getAnalysisProvider
+	persona := ""
+	if cfg != nil {
+		persona = cfg.Persona
+	}
+	if p, ok := request.GetArguments()["persona"].(string); ok && p != "" {
+		persona = p
+	}
+	opts.Persona, _ = config.NormalizePersona(persona)
+	return llm.WithCallOptions(withDiffCache(ctx), opts)
+}
 
-	// Get provider configuration
+// newProviderConfig builds the llm.Config for a provider from the loaded configuration
+func newProviderConfig(providerName, modelOverride string) llm.Config {
 	apiKey, model, endpoint := cfg.GetProviderConfig(providerName)
 
 	// Use model override if provided
@@ -193,7 +731,6 @@ func getOrCreateProvider(providerName, modelOverride string) (llm.Provider, erro
 		model = modelOverride
 	}
 
-	// Create new provider
 	providerConfig := llm.Config{
 		Provider:    providerName,
 		APIKey:      apiKey,
@@ -201,7 +738,122 @@ func getOrCreateProvider(providerName, modelOverride string) (llm.Provider, erro
 		Endpoint:    endpoint,
 		Temperature: cfg.Temperature,
 		MaxTokens:   cfg.MaxTokens,
+		// The loaders default the temperature, so zero here was asked for
+		TemperatureSet: true,
+	}
+
+	providerConfig.TLSCACertFile, providerConfig.TLSInsecureSkipVerify = cfg.TLSSettings(providerName)
+	providerConfig.ProxyURL = cfg.ProxyURL
+	if cfg.CompressRequests {
+		providerConfig.CompressMinBytes = cfg.CompressMinBytes
+	}
+
+	// Explicit per-model settings replace the global defaults
+	if override, ok := cfg.ModelOverrides[model]; ok {
+		if override.MaxTokens > 0 {
+			providerConfig.MaxTokens = override.MaxTokens
+		}
+		if override.Temperature != nil {
+			providerConfig.Temperature = *override.Temperature
+		}
+	}
+
+	// Provider-specific settings
+	switch providerName {
+	case "openai":
+		providerConfig.Organization = cfg.OpenAI.Organization
+		providerConfig.Project = cfg.OpenAI.Project
+	case "google":
+		providerConfig.SafetySettings = cfg.Google.SafetySettings
+		providerConfig.UseVertex = cfg.Google.UseVertex
+		providerConfig.VertexProject = cfg.Google.Project
+		providerConfig.VertexRegion = cfg.Google.Region
+	case "mistral":
+		providerConfig.SafePrompt = cfg.Mistral.SafePrompt
+	case "openrouter":
+		providerConfig.Models = cfg.OpenRouter.Models
+		providerConfig.Referer = cfg.OpenRouter.Referer
+		providerConfig.Title = cfg.OpenRouter.Title
+	case "ollama":
+		providerConfig.Options = cfg.Ollama.Options
+		providerConfig.KeepAlive = cfg.Ollama.KeepAlive
+		providerConfig.VerifyOnStartup = cfg.Ollama.VerifyOnStartup
+	}
+
+	return providerConfig
+}
+
+// preinitializeProviders concurrently creates and caches every configured provider. A provider that
+// fails is logged and left to be created, and report its error, on first use.
+func preinitializeProviders(logger *slog.Logger) {
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, name := range enabledProviders(cfg) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := getOrCreateOptimizedProvider(name, ""); err != nil {
+				logger.Warn("failed to preinitialize provider", "provider", name, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+	logger.Info("preinitialized providers", "duration_ms", time.Since(start).Milliseconds())
+}
+
+// preloadOllama loads the configured Ollama model into memory
+func preloadOllama(logger *slog.Logger) {
+	provider, err := getOrCreateProvider("ollama", "")
+	if err != nil {
+		logger.Warn("failed to create Ollama provider for preload", "error", err)
+		return
+	}
+
+	ollama, ok := llm.BaseProvider(provider).(*llm.OllamaProvider)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaPreloadTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := ollama.Preload(ctx); err != nil {
+		logger.Warn("failed to preload Ollama model", "model", ollama.Model(), "error", err)
+		return
+	}
+	logger.Info("preloaded Ollama model", "model", ollama.Model(), "duration_ms", time.Since(start).Milliseconds())
+}
+
+// getOrCreateProvider gets an existing provider or creates a new one with the specified config.
+// The provider name is trimmed and lowercased, and an unknown name is an error rather than
+// silently falling back to the default provider.
+func getOrCreateProvider(providerName, modelOverride string) (llm.Provider, error) {
+	requested := providerName
+	providerName = providerCacheName(providerName)
+	modelOverride = cfg.ResolveModelAlias(providerName, modelOverride)
+
+	// Create a cache key that includes both provider and model
+	cacheKey := providerName
+	if modelOverride != "" {
+		cacheKey = fmt.Sprintf("%s:%s", providerName, modelOverride)
+	}
+
+	// Check if we already have this provider configured
+	llmProvidersMux.Lock()
+	if provider, exists := llmProviders[cacheKey]; exists {
+		providerUsage.touch(cacheKey)
+		llmProvidersMux.Unlock()
+		return provider, nil
 	}
+	llmProvidersMux.Unlock()
+
+	if err := checkProviderName(requested); err != nil {
+		return nil, err
+	}
+
+	// Create new provider
+	providerConfig := newProviderConfig(providerName, modelOverride)
 
 	provider, err := llm.NewProvider(providerConfig)
 	if err != nil {
@@ -210,18 +862,71 @@ func getOrCreateProvider(providerName, modelOverride string) (llm.Provider, erro
 
 	// Cache the provider with write lock
 	llmProvidersMux.Lock()
-	llmProviders[cacheKey] = provider
-	optimizedLLMProviders[cacheKey] = llm.NewOptimizedProvider(provider, cfg)
+	cacheProviderLocked(cacheKey, provider, llm.NewOptimizedProvider(provider, cfg))
 	llmProvidersMux.Unlock()
 	return provider, nil
 }
 
-// getOrCreateOptimizedProvider gets or creates an optimized LLM provider
-func getOrCreateOptimizedProvider(providerName, modelOverride string) (llm.OptimizedProvider, error) {
-	// Use default provider if not specified
+// providerCacheName returns the provider name requests are cached under: the default provider when
+// none is given, otherwise the name trimmed and lowercased. Names are validated only when a provider
+// is created, so tests can register providers the factory doesn't know.
+func providerCacheName(providerName string) string {
+	providerName = strings.ToLower(strings.TrimSpace(providerName))
 	if providerName == "" {
-		providerName = cfg.DefaultProvider
+		return cfg.DefaultProvider
+	}
+	return providerName
+}
+
+// checkProviderName validates a provider name that has no cached provider, reporting it as the
+// caller spelled it
+func checkProviderName(requested string) error {
+	if strings.TrimSpace(requested) == "" {
+		requested = cfg.DefaultProvider
+	}
+	_, err := config.NormalizeProviderName(requested)
+	return err
+}
+
+// getAnalysisProvider returns the provider a tool should analyze with:
+// the raw wrapper when the request sets raw, otherwise the optimized wrapper.
+// Either way it is recorded as the analyzing provider when used. An unsupported
+// output_language or persona is rejected here, before any analysis runs.
+func getAnalysisProvider(request mcp.CallToolRequest, providerName, modelOverride string) (llm.OptimizedProvider, error) {
+	if language, ok := request.GetArguments()["output_language"].(string); ok {
+		if _, err := config.ResolveOutputLanguage(language); err != nil {
+			return nil, err
+		}
+	}
+	if persona, ok := request.GetArguments()["persona"].(string); ok {
+		if _, err := config.NormalizePersona(persona); err != nil {
+			return nil, err
+		}
+	}
+	if raw, ok := request.GetArguments()["raw"].(bool); ok && raw {
+		provider, err := getOrCreateProvider(providerName, modelOverride)
+		if err != nil {
+			return nil, err
+		}
+		model := ""
+		if reporter, ok := llm.BaseProvider(provider).(llm.ModelReporter); ok {
+			model = reporter.Model()
+		}
+		defaults := newProviderConfig(provider.Name(), model)
+		return attributedProvider{llm.NewRawProvider(provider, cfg, defaults.MaxTokens, defaults.Temperature)}, nil
 	}
+	provider, err := getOrCreateOptimizedProvider(providerName, modelOverride)
+	if err != nil {
+		return nil, err
+	}
+	return attributedProvider{provider}, nil
+}
+
+// getOrCreateOptimizedProvider gets or creates an optimized LLM provider
+func getOrCreateOptimizedProvider(providerName, modelOverride string) (llm.OptimizedProvider, error) {
+	requested := providerName
+	providerName = providerCacheName(providerName)
+	modelOverride = cfg.ResolveModelAlias(providerName, modelOverride)
 
 	// Create a cache key that includes both provider and model
 	cacheKey := providerName
@@ -230,15 +935,16 @@ func getOrCreateOptimizedProvider(providerName, modelOverride string) (llm.Optim
 	}
 
 	// Check if we already have this optimized provider configured
-	llmProvidersMux.RLock()
+	llmProvidersMux.Lock()
 	if optimizedProvider, exists := optimizedLLMProviders[cacheKey]; exists {
-		llmProvidersMux.RUnlock()
+		providerUsage.touch(cacheKey)
+		llmProvidersMux.Unlock()
 		return optimizedProvider, nil
 	}
-	llmProvidersMux.RUnlock()
+	llmProvidersMux.Unlock()
 
-	// Get or create the base provider first
-	baseProvider, err := getOrCreateProvider(providerName, modelOverride)
+	// Get or create the base provider first, passing the name as requested for its errors
+	baseProvider, err := getOrCreateProvider(requested, modelOverride)
 	if err != nil {
 		return nil, err
 	}
@@ -254,7 +960,7 @@ func getOrCreateOptimizedProvider(providerName, modelOverride string) (llm.Optim
 
 	// Create new optimized provider
 	optimizedProvider := llm.NewOptimizedProvider(baseProvider, cfg)
-	optimizedLLMProviders[cacheKey] = optimizedProvider
+	cacheProviderLocked(cacheKey, baseProvider, optimizedProvider)
 
 	return optimizedProvider, nil
 }
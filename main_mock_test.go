@@ -32,6 +32,10 @@ func (m *MockProvider) Name() string {
 	return m.name
 }
 
+func (m *MockProvider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{SupportsTemperature: true}
+}
+
 // TestHandlersWithMock tests handlers using mock provider
 func TestHandlersWithMock(t *testing.T) {
 	// Save original state
@@ -22,6 +22,9 @@ func TestMain(m *testing.M) {
 	// Force default provider to ollama for tests to avoid API rate limits
 	cfg.DefaultProvider = "ollama"
 
+	// Identical test commits must not answer each other from the user's analysis cache
+	commitAnalysisCache = nil
+
 	// Initialize default provider for tests
 	apiKey, model, endpoint := cfg.GetProviderConfig(cfg.DefaultProvider)
 	defaultConfig := llm.Config{
@@ -1,11 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -26,10 +26,10 @@ const (
 
 // DiffStats holds statistics about a diff
 type DiffStats struct {
-	FileCount       int
-	Insertions      int
-	Deletions       int
-	EstimatedSizeKB int64
+	FileCount       int   `json:"file_count"`
+	Insertions      int   `json:"insertions"`
+	Deletions       int   `json:"deletions"`
+	EstimatedSizeKB int64 `json:"estimated_size_kb"`
 }
 
 // TruncatedDiff represents a potentially truncated diff
@@ -40,41 +40,57 @@ type TruncatedDiff struct {
 	FileCount     int
 	TruncatedAt   string
 	WarningReason string
+	FilesFiltered int        // files dropped by include/exclude patterns
+	Stats         *DiffStats // numstat totals for the files that pass the filter, before truncation
 }
 
-// getDiffStats gets statistics about a diff without loading the full content
-func getDiffStats(ctx context.Context, repoPath string, args ...string) (*DiffStats, error) {
-	// Build command arguments
-	cmdArgs := []string{"-C", repoPath, "diff", "--numstat"}
+// getDiffStats gets statistics about a diff without loading the full content, counting only the
+// files that pass filter (nil counts all)
+func getDiffStats(ctx context.Context, repoPath string, filter *DiffFilter, args ...string) (*DiffStats, error) {
+	// Build command arguments; -z keeps renamed and unusual paths unquoted so the filter sees them as git diff does
+	cmdArgs := []string{"-C", repoPath, "diff", "--numstat", "-z"}
 	cmdArgs = append(cmdArgs, args...)
 
 	cmd := exec.CommandContext(ctx, "git", cmdArgs...)
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get diff stats: %w", err)
+		// Diffing a commit against its parent fails for the first commit, which has none,
+		// so count its changes with git show instead
+		commit, ok := parentDiffCommit(args)
+		if !ok {
+			return nil, fmt.Errorf("failed to get diff stats: %w", err)
+		}
+		showCmd := exec.CommandContext(ctx, "git", "-C", repoPath, "show", "--numstat", "-z", "--format=", commit)
+		if output, err = showCmd.Output(); err != nil {
+			return nil, fmt.Errorf("failed to get diff stats: %w", err)
+		}
 	}
 
 	stats := &DiffStats{}
-	scanner := bufio.NewScanner(bytes.NewReader(output))
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
+	// Each entry is "added\tdeleted\tpath", or "added\tdeleted\t" followed by the old and new
+	// paths as separate fields for a rename
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(strings.TrimLeft(fields[i], "\n"), "\t", 3)
+		if len(parts) < 3 {
 			continue
 		}
+		path := parts[2]
+		if path == "" && i+2 < len(fields) {
+			path = fields[i+2]
+			i += 2
+		}
+		if !filter.Keep(path) {
+			continue
+		}
+		stats.FileCount++
 
-		// Parse numstat format: added deleted filename
-		parts := strings.Fields(line)
-		if len(parts) >= 3 {
-			stats.FileCount++
-
-			// Handle binary files (shown as "-")
-			if added, err := strconv.Atoi(parts[0]); err == nil {
-				stats.Insertions += added
-			}
-			if deleted, err := strconv.Atoi(parts[1]); err == nil {
-				stats.Deletions += deleted
-			}
+		// Handle binary files (shown as "-")
+		if added, err := strconv.Atoi(parts[0]); err == nil {
+			stats.Insertions += added
+		}
+		if deleted, err := strconv.Atoi(parts[1]); err == nil {
+			stats.Deletions += deleted
 		}
 	}
 
@@ -84,13 +100,28 @@ func getDiffStats(ctx context.Context, repoPath string, args ...string) (*DiffSt
 	return stats, nil
 }
 
-// checkDiffSize checks if a diff is within acceptable size limits
-func checkDiffSize(ctx context.Context, repoPath string, memConfig *config.MemoryConfig, args ...string) error {
-	stats, err := getDiffStats(ctx, repoPath, args...)
+// parentDiffCommit reports whether args compare a single commit against its parent ("<sha>^", "<sha>")
+// and returns that commit
+func parentDiffCommit(args []string) (string, bool) {
+	if len(args) != 2 || args[0] != args[1]+"^" {
+		return "", false
+	}
+	return args[1], true
+}
+
+// emptyTreeHash returns the ID of the empty tree in the repository's hash format, used to diff a first commit
+func emptyTreeHash(ctx context.Context, repoPath string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "hash-object", "-t", "tree", "--stdin")
+	cmd.Stdin = strings.NewReader("")
+	output, err := cmd.Output()
 	if err != nil {
-		return err
+		return "", fmt.Errorf("failed to get empty tree hash: %w", err)
 	}
+	return strings.TrimSpace(string(output)), nil
+}
 
+// checkDiffStats checks if a diff is within acceptable size limits
+func checkDiffStats(stats *DiffStats, memConfig *config.MemoryConfig) error {
 	maxSizeKB := int64(memConfig.MaxDiffSizeMB * 1024)
 	if stats.EstimatedSizeKB > maxSizeKB {
 		return fmt.Errorf("diff too large: estimated %dKB exceeds limit of %dKB",
@@ -157,6 +188,7 @@ type SafeDiffProcessor struct {
 	filesRead   int
 	isTruncated bool
 	truncateMsg string
+	sections    diffSectionFilter
 }
 
 // NewSafeDiffProcessor creates a new safe diff processor
@@ -168,6 +200,11 @@ func NewSafeDiffProcessor(memConfig *config.MemoryConfig) *SafeDiffProcessor {
 	}
 }
 
+// SetFilter drops file sections rejected by filter as the diff is processed
+func (p *SafeDiffProcessor) SetFilter(filter *DiffFilter) {
+	p.sections.filter = filter
+}
+
 // ProcessChunk processes a chunk of diff output
 func (p *SafeDiffProcessor) ProcessChunk(chunk []byte) error {
 	if p.isTruncated {
@@ -190,6 +227,12 @@ func (p *SafeDiffProcessor) ProcessChunk(chunk []byte) error {
 			// Process complete line
 			line := string(p.lineBuffer)
 
+			// Skip files rejected by the include/exclude patterns
+			if !p.sections.keepLine(line) {
+				p.lineBuffer = p.lineBuffer[:0]
+				continue
+			}
+
 			// Count files
 			if strings.HasPrefix(line, "diff --git") {
 				p.filesRead++
@@ -221,7 +264,7 @@ func (p *SafeDiffProcessor) ProcessChunk(chunk []byte) error {
 // GetResult returns the processed diff result
 func (p *SafeDiffProcessor) GetResult() *TruncatedDiff {
 	// Handle any remaining line
-	if len(p.lineBuffer) > 0 {
+	if len(p.lineBuffer) > 0 && p.sections.keepLine(string(p.lineBuffer)) {
 		line := truncateLine(string(p.lineBuffer), p.memConfig.MaxLineLength)
 		p.buffer.WriteString(line)
 		p.buffer.WriteByte('\n')
@@ -234,25 +277,48 @@ func (p *SafeDiffProcessor) GetResult() *TruncatedDiff {
 		FileCount:     p.filesRead,
 		TruncatedAt:   p.truncateMsg,
 		WarningReason: p.truncateMsg,
+		FilesFiltered: p.sections.filtered,
 	}
 }
 
-// getGitDiffSafe safely retrieves a git diff with memory limits
-func getGitDiffSafe(ctx context.Context, repoPath string, memConfig *config.MemoryConfig, args ...string) (*TruncatedDiff, error) {
-	// First check if diff is within limits
-	if err := checkDiffSize(ctx, repoPath, memConfig, args...); err != nil {
-		// Get stats for the warning
-		stats, _ := getDiffStats(ctx, repoPath, args...)
+// getGitDiffSafe safely retrieves a git diff with memory limits, keeping only files that pass filter (nil keeps all).
+// Within a tool call whose context carries a diff cache, repeating a retrieval reuses the first result.
+func getGitDiffSafe(ctx context.Context, repoPath string, memConfig *config.MemoryConfig, filter *DiffFilter, args ...string) (*TruncatedDiff, error) {
+	cache := diffCacheFromContext(ctx)
+	key := diffCacheKeyFor(repoPath, filter, args)
+	if diff, ok := cache.get(key); ok {
+		return diff, nil
+	}
+
+	diff, err := readGitDiffSafe(ctx, repoPath, memConfig, filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	cache.put(key, diff)
+	return diff, nil
+}
+
+// readGitDiffSafe runs git to read a diff for getGitDiffSafe
+func readGitDiffSafe(ctx context.Context, repoPath string, memConfig *config.MemoryConfig, filter *DiffFilter, args ...string) (*TruncatedDiff, error) {
+	// First check if the files that will be kept are within limits; if the stats are unavailable
+	// the diff cannot be read either
+	stats, err := getDiffStats(ctx, repoPath, filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDiffStats(stats, memConfig); err != nil {
 		return &TruncatedDiff{
 			Content:       "",
 			IsTruncated:   true,
 			TotalSizeKB:   stats.EstimatedSizeKB,
 			FileCount:     stats.FileCount,
 			WarningReason: err.Error(),
+			Stats:         stats,
 		}, nil
 	}
 
 	processor := NewSafeDiffProcessor(memConfig)
+	processor.SetFilter(filter)
 
 	// Build command arguments
 	cmdArgs := []string{"-C", repoPath, "diff"}
@@ -277,5 +343,52 @@ func getGitDiffSafe(ctx context.Context, repoPath string, memConfig *config.Memo
 		}
 	}
 
+	result := processor.GetResult()
+	result.Stats = stats
+	return result, nil
+}
+
+// readDiffFileSafe reads a diff from a file with the same size, file count, line limits, and filtering as getGitDiffSafe
+func readDiffFileSafe(ctx context.Context, path string, memConfig *config.MemoryConfig, filter *DiffFilter) (*TruncatedDiff, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open diff file: %w", err)
+	}
+	defer f.Close()
+
+	diff, err := readDiffSafe(ctx, f, memConfig, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read diff file: %w", err)
+	}
+	return diff, nil
+}
+
+// readDiffSafe reads a diff from r with the same limits and filtering as getGitDiffSafe, stopping
+// without reading the rest once the diff is truncated
+func readDiffSafe(ctx context.Context, r io.Reader, memConfig *config.MemoryConfig, filter *DiffFilter) (*TruncatedDiff, error) {
+	processor := NewSafeDiffProcessor(memConfig)
+	processor.SetFilter(filter)
+
+	// Read in chunks, stopping once the processor has truncated
+	buf := make([]byte, DefaultChunkSize)
+	for !processor.isTruncated {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		n, err := r.Read(buf)
+		if n > 0 {
+			if procErr := processor.ProcessChunk(buf[:n]); procErr != nil {
+				return nil, procErr
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+	}
+
 	return processor.GetResult(), nil
 }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -12,7 +14,7 @@ func TestDiffStats(t *testing.T) {
 	ctx := context.Background()
 
 	// Test with current repository
-	stats, err := getDiffStats(ctx, ".", "HEAD~1", "HEAD")
+	stats, err := getDiffStats(ctx, ".", nil, "HEAD~1", "HEAD")
 	if err != nil {
 		// Not a fatal error - might not have commits
 		t.Logf("Could not get diff stats: %v", err)
@@ -186,3 +188,104 @@ func TestMemoryConfigDefaults(t *testing.T) {
 		t.Error("Expected EnableStreaming=true")
 	}
 }
+
+func TestReadDiffFileSafe(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "change.diff")
+	content := "diff --git a/a.go b/a.go\n+line\ndiff --git a/b.go b/b.go\n+line\ndiff --git a/c.go b/c.go\n+line\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write diff file: %v", err)
+	}
+
+	memConfig := &config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 100, MaxLineLength: 1000, ChunkSizeMB: 1}
+	result, err := readDiffFileSafe(context.Background(), path, memConfig, nil)
+	if err != nil {
+		t.Fatalf("readDiffFileSafe failed: %v", err)
+	}
+	if result.IsTruncated || result.FileCount != 3 {
+		t.Errorf("expected 3 files without truncation, got %d files (truncated=%v)", result.FileCount, result.IsTruncated)
+	}
+
+	memConfig.MaxFileCount = 2
+	result, err = readDiffFileSafe(context.Background(), path, memConfig, nil)
+	if err != nil {
+		t.Fatalf("readDiffFileSafe failed: %v", err)
+	}
+	if !result.IsTruncated {
+		t.Error("expected truncation when the file count limit is exceeded")
+	}
+}
+
+func TestDiffStatsFirstCommit(t *testing.T) {
+	repo := newTestRepo(t)
+	writeTestFile(t, repo, "main.go", "package main\n\nfunc main() {}\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "--amend", "--no-edit")
+	ctx := context.Background()
+
+	stats, err := getDiffStats(ctx, repo, nil, "HEAD^", "HEAD")
+	if err != nil {
+		t.Fatalf("getDiffStats() unexpected error: %v", err)
+	}
+	if stats.FileCount != 2 || stats.Insertions != 4 || stats.Deletions != 0 {
+		t.Errorf("stats = %+v, want 2 files and 4 insertions", stats)
+	}
+
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+
+	cfg = &config.Config{Memory: config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1}}
+	info, err := getCommitInfo(ctx, repo, "HEAD")
+	if err != nil {
+		t.Fatalf("getCommitInfo() unexpected error: %v", err)
+	}
+	for _, want := range []string{"Diff (first commit):", "+# test repo", "+func main() {}"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("commit info missing %q:\n%s", want, info)
+		}
+	}
+
+	// The size check applies to a large initial import too
+	cfg.Memory.MaxFileCount = 1
+	info, err = getCommitInfo(ctx, repo, "HEAD")
+	if err != nil {
+		t.Fatalf("getCommitInfo() unexpected error: %v", err)
+	}
+	if !strings.Contains(info, "too many files changed: 2 exceeds limit of 1") {
+		t.Errorf("expected the file limit to apply to the first commit:\n%s", info)
+	}
+}
+
+func TestGitDiffLimitsApplyAfterFiltering(t *testing.T) {
+	repo := newTestRepo(t)
+	runGit(t, repo, "mv", "README.md", "GUIDE.md")
+	for _, name := range []string{"vendor/a.go", "vendor/b.go", "vendor/c.go"} {
+		writeTestFile(t, repo, name, "package vendor\n")
+	}
+	writeTestFile(t, repo, "main.go", "package main\n")
+	runGit(t, repo, "add", ".")
+	ctx := context.Background()
+
+	filter, err := NewDiffFilter(nil, []string{"vendor/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := getDiffStats(ctx, repo, filter, "--cached", "-M", "HEAD")
+	if err != nil {
+		t.Fatalf("getDiffStats() unexpected error: %v", err)
+	}
+	if stats.FileCount != 2 || stats.Insertions != 1 {
+		t.Errorf("stats = %+v, want the rename and main.go only", stats)
+	}
+
+	// The excluded files alone exceed the file limit, but must not truncate the diff
+	memConfig := &config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 2, MaxLineLength: 1000, ChunkSizeMB: 1}
+	diff, err := getGitDiffSafe(ctx, repo, memConfig, filter, "--cached", "HEAD")
+	if err != nil {
+		t.Fatalf("getGitDiffSafe() unexpected error: %v", err)
+	}
+	if diff.IsTruncated || !strings.Contains(diff.Content, "main.go") || diff.FilesFiltered != 3 {
+		t.Errorf("expected main.go without truncation and 3 files filtered, got truncated=%v (%s), %d filtered:\n%s",
+			diff.IsTruncated, diff.WarningReason, diff.FilesFiltered, diff.Content)
+	}
+}
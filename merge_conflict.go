@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Conflict markers git writes into conflicted files
+const (
+	conflictOursMarker   = "<<<<<<<"
+	conflictBaseMarker   = "|||||||"
+	conflictSplitMarker  = "======="
+	conflictTheirsMarker = ">>>>>>>"
+)
+
+// conflictContextLines is how many lines around a conflict are shown to the LLM
+const conflictContextLines = 3
+
+// errNoConflictMarkers is returned when content has no merge conflict to analyze
+var errNoConflictMarkers = errors.New("no merge conflict markers (<<<<<<<, =======, >>>>>>>) found")
+
+// mergeConflict is one conflicted hunk of a file
+type mergeConflict struct {
+	File string
+	// Line is the 1-based line of the <<<<<<< marker
+	Line        int
+	OursLabel   string
+	TheirsLabel string
+	Ours        string
+	// Base is the common ancestor's version, present only with the diff3 conflict style
+	Base   string
+	Theirs string
+	Before string
+	After  string
+}
+
+// isConflictMarker reports whether line is the given marker, optionally followed by a label
+func isConflictMarker(line, marker string) bool {
+	rest, ok := strings.CutPrefix(line, marker)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\r')
+}
+
+// parseConflicts extracts the conflicted hunks from a file's content, failing when the content
+// has no conflicts or a conflict is malformed
+func parseConflicts(file, content string) ([]mergeConflict, error) {
+	lines := strings.Split(content, "\n")
+
+	var conflicts []mergeConflict
+	for i := 0; i < len(lines); i++ {
+		if !isConflictMarker(lines[i], conflictOursMarker) {
+			continue
+		}
+
+		conflict := mergeConflict{
+			File:      file,
+			Line:      i + 1,
+			OursLabel: strings.TrimSpace(strings.TrimPrefix(lines[i], conflictOursMarker)),
+			Before:    strings.Join(lines[max(i-conflictContextLines, 0):i], "\n"),
+		}
+
+		// Collect ours, then the optional base, then theirs
+		section := &conflict.Ours
+		var sectionLines []string
+		end := -1
+		for j := i + 1; j < len(lines) && end < 0; j++ {
+			line := lines[j]
+			switch {
+			case isConflictMarker(line, conflictOursMarker):
+				return nil, fmt.Errorf("%s:%d: conflict starting at line %d is not terminated", file, j+1, i+1)
+			case isConflictMarker(line, conflictBaseMarker) && section == &conflict.Ours:
+				*section = strings.Join(sectionLines, "\n")
+				section, sectionLines = &conflict.Base, nil
+			case line == conflictSplitMarker || strings.TrimSuffix(line, "\r") == conflictSplitMarker:
+				if section == &conflict.Theirs {
+					return nil, fmt.Errorf("%s:%d: unexpected ======= in conflict starting at line %d", file, j+1, i+1)
+				}
+				*section = strings.Join(sectionLines, "\n")
+				section, sectionLines = &conflict.Theirs, nil
+			case isConflictMarker(line, conflictTheirsMarker) && section == &conflict.Theirs:
+				*section = strings.Join(sectionLines, "\n")
+				conflict.TheirsLabel = strings.TrimSpace(strings.TrimPrefix(line, conflictTheirsMarker))
+				end = j
+			default:
+				sectionLines = append(sectionLines, line)
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("%s:%d: conflict is not terminated by >>>>>>>", file, i+1)
+		}
+
+		conflict.After = strings.Join(lines[end+1:min(end+1+conflictContextLines, len(lines))], "\n")
+		conflicts = append(conflicts, conflict)
+		i = end
+	}
+
+	if len(conflicts) == 0 {
+		return nil, errNoConflictMarkers
+	}
+	return conflicts, nil
+}
+
+// formatConflicts renders conflicts as numbered sections for the prompt
+func formatConflicts(conflicts []mergeConflict) string {
+	var b strings.Builder
+	section := func(title, code string) {
+		fmt.Fprintf(&b, "%s:\n```\n%s\n```\n\n", title, code)
+	}
+	labelled := func(side, label string) string {
+		if label == "" {
+			return side
+		}
+		return fmt.Sprintf("%s (%s)", side, label)
+	}
+
+	for i, conflict := range conflicts {
+		fmt.Fprintf(&b, "## Conflict %d: %s (line %d)\n\n", i+1, conflict.File, conflict.Line)
+		if conflict.Before != "" {
+			section("Context before", conflict.Before)
+		}
+		section(labelled("Ours", conflict.OursLabel), conflict.Ours)
+		if conflict.Base != "" {
+			section("Common ancestor", conflict.Base)
+		}
+		section(labelled("Theirs", conflict.TheirsLabel), conflict.Theirs)
+		if conflict.After != "" {
+			section("Context after", conflict.After)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// getConflictedFiles lists the repository's files with unresolved merge conflicts
+func getConflictedFiles(ctx context.Context, repoPath string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "diff", "--name-only", "-z", "--diff-filter=U")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conflicted files: %w", err)
+	}
+
+	var files []string
+	for _, name := range bytes.Split(output, []byte{0}) {
+		if len(name) > 0 {
+			files = append(files, string(name))
+		}
+	}
+	return files, nil
+}
+
+// readConflictedFile reads a repository-relative file, refusing paths outside the repository
+// and files larger than the configured diff size limit
+func readConflictedFile(repoPath, name string) (string, error) {
+	path := filepath.Join(repoPath, filepath.Clean(name))
+	if !isWithinDir(path, repoPath) {
+		return "", fmt.Errorf("%s is outside the repository", name)
+	}
+
+	// Git lists a symlink as a path in the work tree; follow it only if it stays inside
+	realPath, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		realPath, err = filepath.Abs(realPath)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	realRepo, err := filepath.Abs(repoPath)
+	if err == nil {
+		realRepo, err = filepath.EvalSymlinks(realRepo)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the repository path: %w", err)
+	}
+	if !isWithinDir(realPath, realRepo) {
+		return "", fmt.Errorf("%s links to outside the repository", name)
+	}
+	path = realPath
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if limit := int64(cfg.Memory.MaxDiffSizeMB) * 1024 * 1024; limit > 0 && info.Size() > limit {
+		return "", fmt.Errorf("%s is larger than the %d MB limit", name, cfg.Memory.MaxDiffSizeMB)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return string(content), nil
+}
+
+func handleAnalyzeMergeConflict(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	content := ""
+	if c, ok := request.GetArguments()["content"].(string); ok {
+		content = c
+	}
+
+	filePath := ""
+	if f, ok := request.GetArguments()["file_path"].(string); ok {
+		filePath = f
+	}
+
+	var conflicts []mergeConflict
+	files := 0
+	if content != "" {
+		label := filePath
+		if label == "" {
+			label = "content"
+		}
+		parsed, err := parseConflicts(label, content)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid content: %v", err)), nil
+		}
+		conflicts, files = parsed, 1
+	} else {
+		repoPath := "."
+		if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
+			repoPath = path
+		}
+
+		// Validate repo path
+		validPath, err := validateRepoPath(repoPath)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+		if err := requireGit(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		names := []string{filePath}
+		if filePath == "" {
+			names, err = getConflictedFiles(ctx, validPath)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if len(names) == 0 {
+				return mcp.NewToolResultError("no conflicted files found in the repository; pass content to analyze conflict markers directly"), nil
+			}
+		}
+
+		for _, name := range names {
+			fileContent, err := readConflictedFile(validPath, name)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			parsed, err := parseConflicts(name, fileContent)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid conflicted file: %v", err)), nil
+			}
+			conflicts = append(conflicts, parsed...)
+			files++
+		}
+	}
+
+	// Get provider and model from request
+	providerName := ""
+	if p, ok := request.GetArguments()["provider"].(string); ok {
+		providerName = p
+	}
+
+	modelOverride := ""
+	if m, ok := request.GetArguments()["model"].(string); ok {
+		modelOverride = m
+	}
+
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	formatted := formatConflicts(conflicts)
+	prompt := llm.AnalysisPrompt("merge_conflict", formatted, nil)
+
+	task := llm.GetTaskFromAnalysisType("merge_conflict")
+	analysis, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, len(formatted), task)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+	}
+
+	header := fmt.Sprintf("# Merge Conflict Analysis\n\nFound %d conflict(s) in %d file(s).\n\n", len(conflicts), files)
+	return mcp.NewToolResultText(header + limitResponse(analysis)), nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const conflictedFile = `package greet
+
+import "fmt"
+
+func Greet(name string) string {
+<<<<<<< HEAD
+	return fmt.Sprintf("Hello, %s!", name)
+||||||| merged common ancestors
+	return "Hello, " + name
+=======
+	return fmt.Sprintf("Hi %s", strings.TrimSpace(name))
+>>>>>>> feature
+}
+
+func Farewell() string {
+<<<<<<< HEAD
+	return "Goodbye"
+=======
+	return "Bye"
+>>>>>>> feature
+}
+`
+
+func TestParseConflicts(t *testing.T) {
+	conflicts, err := parseConflicts("greet.go", conflictedFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("got %d conflicts, want 2: %+v", len(conflicts), conflicts)
+	}
+
+	first := conflicts[0]
+	want := mergeConflict{
+		File:        "greet.go",
+		Line:        6,
+		OursLabel:   "HEAD",
+		TheirsLabel: "feature",
+		Ours:        "\treturn fmt.Sprintf(\"Hello, %s!\", name)",
+		Base:        "\treturn \"Hello, \" + name",
+		Theirs:      "\treturn fmt.Sprintf(\"Hi %s\", strings.TrimSpace(name))",
+		Before:      "import \"fmt\"\n\nfunc Greet(name string) string {",
+		After:       "}\n\nfunc Farewell() string {",
+	}
+	if first != want {
+		t.Errorf("first conflict = %+v, want %+v", first, want)
+	}
+
+	second := conflicts[1]
+	if second.Line != 16 || second.Ours != "\treturn \"Goodbye\"" || second.Theirs != "\treturn \"Bye\"" || second.Base != "" {
+		t.Errorf("unexpected second conflict: %+v", second)
+	}
+}
+
+func TestParseConflictsErrors(t *testing.T) {
+	tests := map[string]string{
+		"no markers":     "package greet\n\nfunc Greet() {}\n",
+		"unterminated":   "<<<<<<< HEAD\na\n=======\nb\n",
+		"missing split":  "<<<<<<< HEAD\na\n>>>>>>> feature\n",
+		"nested":         "<<<<<<< HEAD\n<<<<<<< HEAD\na\n=======\nb\n>>>>>>> feature\n",
+		"repeated split": "<<<<<<< HEAD\na\n=======\nb\n=======\nc\n>>>>>>> feature\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseConflicts("file.go", content); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestHandleAnalyzeMergeConflict(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	call := func(args map[string]any) (*mcp.CallToolResult, *countingProvider) {
+		t.Helper()
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		result, err := handleAnalyzeMergeConflict(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "analyze_merge_conflict", Arguments: args},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result, provider
+	}
+
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+
+	t.Run("content", func(t *testing.T) {
+		result, provider := call(map[string]any{"content": conflictedFile, "file_path": "greet.go"})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "Found 2 conflict(s) in 1 file(s)") {
+			t.Errorf("unexpected result: %s", text)
+		}
+		if provider.calls != 1 {
+			t.Fatalf("expected one LLM call, got %d", provider.calls)
+		}
+		for _, want := range []string{
+			"Help resolve these git merge conflicts",
+			"## Conflict 1: greet.go (line 6)",
+			"## Conflict 2: greet.go (line 16)",
+			"Ours (HEAD)",
+			"Common ancestor",
+			"Theirs (feature)",
+			"strings.TrimSpace(name)",
+		} {
+			if !strings.Contains(provider.prompts[0], want) {
+				t.Errorf("expected prompt to contain %q:\n%s", want, provider.prompts[0])
+			}
+		}
+	})
+
+	t.Run("no markers", func(t *testing.T) {
+		result, provider := call(map[string]any{"content": "package greet\n"})
+		if !result.IsError {
+			t.Error("expected an error for content without conflict markers")
+		}
+		if provider.calls != 0 {
+			t.Errorf("expected no LLM call, got %d", provider.calls)
+		}
+	})
+
+	t.Run("repository", func(t *testing.T) {
+		repo := newTestRepo(t)
+		writeTestFile(t, repo, "greet.go", "package greet\n\nconst Greeting = \"Hello\"\n")
+		runGit(t, repo, "add", ".")
+		runGit(t, repo, "commit", "--quiet", "-m", "Add greeting")
+		runGit(t, repo, "checkout", "--quiet", "-b", "feature")
+		writeTestFile(t, repo, "greet.go", "package greet\n\nconst Greeting = \"Hi\"\n")
+		runGit(t, repo, "commit", "--quiet", "-am", "Shorten greeting")
+		runGit(t, repo, "checkout", "--quiet", "main")
+		writeTestFile(t, repo, "greet.go", "package greet\n\nconst Greeting = \"Hello there\"\n")
+		runGit(t, repo, "commit", "--quiet", "-am", "Lengthen greeting")
+
+		// The merge is expected to stop with a conflict
+		merge := exec.Command("git", "-C", repo, "merge", "--quiet", "feature")
+		merge.Env = append(os.Environ(), "GIT_COMMITTER_NAME=Test User", "GIT_COMMITTER_EMAIL=test@example.com")
+		if err := merge.Run(); err == nil {
+			t.Fatal("expected the merge to conflict")
+		}
+
+		cfg.AllowedRepoRoots = []string{repo}
+		result, provider := call(map[string]any{"repo_path": repo})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "Found 1 conflict(s) in 1 file(s)") {
+			t.Errorf("unexpected result: %s", text)
+		}
+		for _, want := range []string{"## Conflict 1: greet.go (line 3)", `"Hello there"`, `"Hi"`} {
+			if !strings.Contains(provider.prompts[0], want) {
+				t.Errorf("expected prompt to contain %q:\n%s", want, provider.prompts[0])
+			}
+		}
+
+		// Paths outside the repository are refused
+		result, _ = call(map[string]any{"repo_path": repo, "file_path": "../outside.go"})
+		if !result.IsError {
+			t.Error("expected an error for a file outside the repository")
+		}
+	})
+}
+
+func TestReadConflictedFileSymlink(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{Memory: config.MemoryConfig{MaxDiffSizeMB: 10}}
+
+	repo := t.TempDir()
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secret, []byte("top secret"), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", secret, err)
+	}
+	if err := os.Symlink(secret, filepath.Join(repo, "greet.go")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	if _, err := readConflictedFile(repo, "greet.go"); err == nil || !strings.Contains(err.Error(), "outside the repository") {
+		t.Errorf("readConflictedFile error = %v, want a symlink rejection", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultListModelsTimeout bounds each individual provider's model list request
+const defaultListModelsTimeout = 30 * time.Second
+
+// Model list statuses reported per provider
+const (
+	modelListOK          = "ok"
+	modelListUnsupported = "unsupported"
+	modelListError       = "error"
+)
+
+// ProviderModels holds the models one provider reported
+type ProviderModels struct {
+	Provider        string   `json:"provider"`
+	ConfiguredModel string   `json:"configured_model"`
+	Status          string   `json:"status"`
+	Models          []string `json:"models,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// modelListResult is the list_models response
+type modelListResult struct {
+	Providers []ProviderModels `json:"providers"`
+}
+
+func handleListModels(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	timeout := defaultListModelsTimeout
+	if secs, ok := request.GetArguments()["timeout_seconds"].(float64); ok && secs > 0 {
+		timeout = time.Duration(secs * float64(time.Second))
+	}
+
+	providers := configuredProviders()
+	if name, ok := request.GetArguments()["provider"].(string); ok && name != "" {
+		normalized, err := config.NormalizeProviderName(name)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if !slices.Contains(providers, normalized) {
+			return mcp.NewToolResultError(fmt.Sprintf("provider '%s' is not configured", normalized)), nil
+		}
+		providers = []string{normalized}
+	}
+
+	result := modelListResult{Providers: listModels(ctx, providers, timeout)}
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to encode model list: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(output)), nil
+}
+
+// listModels queries every provider concurrently, returning results in input order
+func listModels(ctx context.Context, providers []string, timeout time.Duration) []ProviderModels {
+	results := make([]ProviderModels, len(providers))
+
+	var wg sync.WaitGroup
+	for i, name := range providers {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = listProviderModels(ctx, name, timeout)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// listProviderModels lists a single provider's models, reporting providers without a model list
+// endpoint as unsupported rather than failed
+func listProviderModels(ctx context.Context, providerName string, timeout time.Duration) ProviderModels {
+	_, model, _ := cfg.GetProviderConfig(providerName)
+	result := ProviderModels{
+		Provider:        providerName,
+		ConfiguredModel: model,
+		Status:          modelListError,
+	}
+
+	provider, err := getOrCreateProvider(providerName, "")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	lister, ok := llm.BaseProvider(provider).(llm.ModelLister)
+	if !ok {
+		result.Status = modelListUnsupported
+		result.Error = llm.ErrNoModelList.Error()
+		return result
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	models, err := lister.ListModels(listCtx)
+	if err != nil {
+		if errors.Is(err, llm.ErrNoModelList) {
+			result.Status = modelListUnsupported
+		}
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = modelListOK
+	result.Models = models
+	return result
+}
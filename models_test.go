@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// listingProvider is a mock provider with a model list endpoint
+type listingProvider struct {
+	MockProvider
+	models []string
+	err    error
+}
+
+func (p *listingProvider) ListModels(ctx context.Context) ([]string, error) {
+	return p.models, p.err
+}
+
+func TestHandleListModels(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("unexpected Ollama path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"models":[{"name":"qwen2.5-coder:7b"},{"name":"devstral:latest"}]}`))
+	}))
+	defer ollama.Close()
+
+	cfg = &config.Config{DefaultProvider: "openai"}
+	cfg.OpenAI.APIKey = "test-key"
+	cfg.OpenAI.Model = "gpt-4o-mini"
+	cfg.Mistral.APIKey = "test-key"
+	cfg.Mistral.Model = "mistral-small-latest"
+	cfg.Perplexity.APIKey = "test-key"
+	cfg.Perplexity.Model = "sonar"
+	cfg.Ollama.Endpoint = ollama.URL
+	cfg.Ollama.Model = "devstral:latest"
+
+	llmProviders = map[string]llm.Provider{
+		"openai":     &listingProvider{MockProvider: MockProvider{name: "openai"}, models: []string{"gpt-4o", "gpt-4o-mini"}},
+		"mistral":    &listingProvider{MockProvider: MockProvider{name: "mistral"}, err: errors.New("invalid API key")},
+		"perplexity": &MockProvider{name: "perplexity"},
+	}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	call := func(arguments map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		result, err := handleListModels(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "list_models", Arguments: arguments},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return result
+	}
+
+	result := call(map[string]any{"timeout_seconds": 5.0})
+	if result.IsError {
+		t.Fatalf("one failing provider must not fail the call: %v", result.Content)
+	}
+	var response modelListResult
+	if err := json.Unmarshal([]byte(getTextResponseMock(result)), &response); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, getTextResponseMock(result))
+	}
+
+	want := []ProviderModels{
+		{Provider: "openai", ConfiguredModel: "gpt-4o-mini", Status: modelListOK, Models: []string{"gpt-4o", "gpt-4o-mini"}},
+		{Provider: "ollama", ConfiguredModel: "devstral:latest", Status: modelListOK, Models: []string{"devstral:latest", "qwen2.5-coder:7b"}},
+		{Provider: "mistral", ConfiguredModel: "mistral-small-latest", Status: modelListError, Error: "invalid API key"},
+		{Provider: "perplexity", ConfiguredModel: "sonar", Status: modelListUnsupported, Error: llm.ErrNoModelList.Error()},
+	}
+	if !reflect.DeepEqual(response.Providers, want) {
+		t.Errorf("providers = %+v\nwant %+v", response.Providers, want)
+	}
+
+	t.Run("single provider", func(t *testing.T) {
+		result := call(map[string]any{"provider": "OpenAI"})
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		text := getTextResponseMock(result)
+		if !strings.Contains(text, `"provider": "openai"`) || strings.Contains(text, "ollama") {
+			t.Errorf("expected only openai in the response:\n%s", text)
+		}
+	})
+
+	for _, tt := range []struct {
+		provider string
+		want     string
+	}{
+		{"anthropic", "unknown provider"},
+		{"google", "not configured"},
+	} {
+		t.Run("rejects "+tt.provider, func(t *testing.T) {
+			result := call(map[string]any{"provider": tt.provider})
+			if !result.IsError || !strings.Contains(getTextResponseMock(result), tt.want) {
+				t.Errorf("expected a %q error, got %v", tt.want, result.Content)
+			}
+		})
+	}
+}
+
+func TestListModelsTimeout(t *testing.T) {
+	originalProviders := llmProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		cfg = originalCfg
+	}()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	cfg = &config.Config{DefaultProvider: "ollama"}
+	cfg.Ollama.Endpoint = slow.URL
+	llmProviders = map[string]llm.Provider{}
+
+	start := time.Now()
+	results := listModels(context.Background(), []string{"ollama"}, 200*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("list ignored the timeout, took %v", elapsed)
+	}
+	if results[0].Status != modelListError || !strings.Contains(results[0].Error, "context deadline exceeded") {
+		t.Errorf("result = %+v, want a deadline error", results[0])
+	}
+}
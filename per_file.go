@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dshills/second-opinion/analysis"
+	"github.com/dshills/second-opinion/llm"
+)
+
+// diffFileLabel describes a diff file section for its review heading, e.g. "b.go (renamed from a.go)"
+func diffFileLabel(file analysis.DiffFile) string {
+	switch {
+	case file.Added:
+		return file.NewPath + " (new file)"
+	case file.Deleted:
+		return file.OldPath + " (deleted)"
+	case file.Renamed:
+		return fmt.Sprintf("%s (renamed from %s)", file.NewPath, file.OldPath)
+	default:
+		return file.Path()
+	}
+}
+
+// reviewDiffPerFile reviews each file of a diff separately, then asks for an overall summary.
+// Deleted, binary, and pure-rename files are noted without an LLM call.
+func reviewDiffPerFile(ctx context.Context, provider llm.OptimizedProvider, diff string, summarize bool) (string, error) {
+	sections := analysis.ParseDiff(diff)
+	if len(sections) == 0 {
+		return "", fmt.Errorf("no file sections found in the diff")
+	}
+
+	task := llm.GetTaskFromAnalysisType("diff")
+
+	var reviews []string
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("# Per-File Review (%d files)\n", len(sections)))
+
+	for i, section := range sections {
+		var review string
+		switch {
+		case section.Deleted:
+			review = "File deleted; nothing to review."
+		case section.Binary:
+			review = "Binary file changed; not reviewed."
+		case !section.HasHunks && section.Renamed:
+			review = "Renamed with no content changes."
+		case !section.HasHunks:
+			review = "Metadata-only change (e.g. file mode); nothing to review."
+		default:
+			prompt := llm.AnalysisPrompt("diff", section.Text, map[string]interface{}{
+				"summarize": summarize,
+			})
+			result, err := provider.AnalyzeOptimized(ctx, prompt, len(section.Text), task)
+			if err != nil {
+				return "", fmt.Errorf("review of %s failed: %w", section.Path(), err)
+			}
+			review = result
+			reviews = append(reviews, fmt.Sprintf("### %s\n%s", diffFileLabel(section), result))
+		}
+
+		report.WriteString(fmt.Sprintf("\n## %d. %s\n%s\n", i+1, diffFileLabel(section), review))
+	}
+
+	if len(reviews) == 0 {
+		return report.String(), nil
+	}
+
+	summaryPrompt := fmt.Sprintf(`Provide an overall summary of this change set based on the following per-file reviews:
+
+%s
+
+Please provide:
+1. Overall assessment of the change set
+2. Cross-file issues and concerns
+3. The most important recommendations`, strings.Join(reviews, "\n\n"))
+
+	summary, err := provider.AnalyzeOptimized(ctx, summaryPrompt, len(summaryPrompt), task)
+	if err != nil {
+		return "", fmt.Errorf("overall summary failed: %w", err)
+	}
+	report.WriteString(fmt.Sprintf("\n## Overall Summary\n%s\n", summary))
+
+	return report.String(), nil
+}
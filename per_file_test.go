@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/analysis"
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const threeFileDiff = `diff --git a/handlers.go b/handlers.go
+index 1111111..2222222 100644
+--- a/handlers.go
++++ b/handlers.go
+@@ -1 +1 @@
+-return nil
++return err
+diff --git a/util/old.go b/util/new.go
+similarity index 90%
+rename from util/old.go
+rename to util/new.go
+--- a/util/old.go
++++ b/util/new.go
+@@ -1 +1 @@
+-package old
++package util
+diff --git a/legacy.go b/legacy.go
+deleted file mode 100644
+index 3333333..0000000
+--- a/legacy.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+`
+
+func TestDiffFileLabel(t *testing.T) {
+	sections := analysis.ParseDiff("preamble text\n" + threeFileDiff + renameOnlyDiff)
+
+	want := []struct {
+		label    string
+		hasHunks bool
+	}{
+		{"handlers.go", true},
+		{"util/new.go (renamed from util/old.go)", true},
+		{"legacy.go (deleted)", true},
+		{"new/name.go (renamed from old/name.go)", false},
+	}
+	if len(sections) != len(want) {
+		t.Fatalf("got %d sections, want %d", len(sections), len(want))
+	}
+	for i, w := range want {
+		if got := diffFileLabel(sections[i]); got != w.label {
+			t.Errorf("section %d label = %q, want %q", i, got, w.label)
+		}
+		if sections[i].HasHunks != w.hasHunks {
+			t.Errorf("section %d hasHunks = %v, want %v", i, sections[i].HasHunks, w.hasHunks)
+		}
+		if !strings.HasPrefix(sections[i].Text, "diff --git ") {
+			t.Errorf("section %d content should start at its header, got %q", i, sections[i].Text)
+		}
+	}
+	if strings.Contains(sections[0].Text, "preamble") {
+		t.Error("text before the first file should be ignored")
+	}
+}
+
+func TestHandleGitDiffPerFile(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	provider := &countingProvider{name: "mock"}
+	llmProviders = map[string]llm.Provider{"mock": provider}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	result, err := handleGitDiff(context.Background(), mcp.CallToolRequest{
+		Params: mcp.CallToolParams{Name: "analyze_git_diff", Arguments: map[string]any{"diff_content": threeFileDiff, "per_file": true}},
+	})
+	if err != nil || result.IsError {
+		t.Fatalf("unexpected failure: %v %v", err, result)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+
+	// Sections appear in diff order, each labeled with its path
+	labels := []string{
+		"## 1. handlers.go\n",
+		"## 2. util/new.go (renamed from util/old.go)\n",
+		"## 3. legacy.go (deleted)\n",
+		"## Overall Summary\n",
+	}
+	last := -1
+	for _, label := range labels {
+		index := strings.Index(text, label)
+		if index < 0 {
+			t.Fatalf("missing section %q in:\n%s", label, text)
+		}
+		if index < last {
+			t.Errorf("section %q is out of order", label)
+		}
+		last = index
+	}
+
+	// Two file reviews plus the summary; the deleted file needs no LLM call
+	if provider.calls != 3 {
+		t.Errorf("provider called %d times, want 3", provider.calls)
+	}
+	if !strings.Contains(text, "File deleted") {
+		t.Errorf("deleted file should be noted, got:\n%s", text)
+	}
+	if strings.Contains(provider.prompts[0], "legacy.go") || !strings.Contains(provider.prompts[0], "handlers.go") {
+		t.Errorf("first review prompt should only contain handlers.go, got:\n%s", provider.prompts[0])
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"container/list"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+)
+
+// providerLRU tracks provider cache keys from least to most recently used.
+// It is guarded by llmProvidersMux.
+type providerLRU struct {
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newProviderLRU() *providerLRU {
+	return &providerLRU{
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// touch marks key as the most recently used
+func (l *providerLRU) touch(key string) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.MoveToBack(elem)
+		return
+	}
+	l.elements[key] = l.order.PushBack(key)
+}
+
+// remove forgets key
+func (l *providerLRU) remove(key string) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.Remove(elem)
+		delete(l.elements, key)
+	}
+}
+
+// oldest returns the least recently used key that is not pinned
+func (l *providerLRU) oldest(pinned func(string) bool) (string, bool) {
+	for elem := l.order.Front(); elem != nil; elem = elem.Next() {
+		key := elem.Value.(string)
+		if !pinned(key) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// maxCachedProviders returns the configured cap on cached provider instances
+func maxCachedProviders() int {
+	if cfg.MaxCachedProviders > 0 {
+		return cfg.MaxCachedProviders
+	}
+	return config.DefaultMaxCachedProviders
+}
+
+// isPinnedProvider reports whether a cache key must never be evicted
+func isPinnedProvider(key string) bool {
+	return key == cfg.DefaultProvider
+}
+
+// cacheProviderLocked stores a provider and its optimized wrapper, evicting the least
+// recently used entries beyond the limit. The caller must hold llmProvidersMux for writing.
+func cacheProviderLocked(key string, provider llm.Provider, optimized llm.OptimizedProvider) {
+	llmProviders[key] = provider
+	optimizedLLMProviders[key] = optimized
+	providerUsage.touch(key)
+
+	for len(llmProviders) > maxCachedProviders() {
+		victim, ok := providerUsage.oldest(isPinnedProvider)
+		if !ok {
+			return
+		}
+		delete(llmProviders, victim)
+		delete(optimizedLLMProviders, victim)
+		providerUsage.remove(victim)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+)
+
+func TestProviderCacheEviction(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalUsage := providerUsage
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		providerUsage = originalUsage
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{DefaultProvider: "ollama", MaxCachedProviders: 3}
+	cfg.Ollama.Endpoint = "http://localhost:11434"
+	cfg.Ollama.Model = "devstral:latest"
+	llmProviders = make(map[string]llm.Provider)
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+	providerUsage = newProviderLRU()
+
+	mustCreate := func(model string) {
+		t.Helper()
+		if _, err := getOrCreateOptimizedProvider("ollama", model); err != nil {
+			t.Fatalf("getOrCreateOptimizedProvider(%q) failed: %v", model, err)
+		}
+	}
+
+	// The default provider is created first and becomes the least recently used entry
+	mustCreate("")
+	mustCreate("model-a")
+	mustCreate("model-b")
+
+	// Use model-a so model-b is now the least recently used non-default entry
+	mustCreate("model-a")
+	mustCreate("model-c")
+
+	if len(llmProviders) != 3 || len(optimizedLLMProviders) != 3 {
+		t.Fatalf("cache sizes = %d/%d, want 3/3", len(llmProviders), len(optimizedLLMProviders))
+	}
+	if _, ok := llmProviders["ollama"]; !ok {
+		t.Error("default provider was evicted")
+	}
+	if _, ok := llmProviders["ollama:model-b"]; ok {
+		t.Error("least recently used provider was not evicted")
+	}
+	for _, key := range []string{"ollama:model-a", "ollama:model-c"} {
+		if _, ok := optimizedLLMProviders[key]; !ok {
+			t.Errorf("recently used provider %q was evicted", key)
+		}
+	}
+}
+
+func TestProviderCacheConcurrentAccess(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalUsage := providerUsage
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		providerUsage = originalUsage
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{DefaultProvider: "ollama", MaxCachedProviders: 4}
+	cfg.Ollama.Endpoint = "http://localhost:11434"
+	llmProviders = make(map[string]llm.Provider)
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+	providerUsage = newProviderLRU()
+
+	models := []string{"", "a", "b", "c", "d", "e", "f"}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := getOrCreateOptimizedProvider("ollama", models[i%len(models)]); err != nil {
+				t.Errorf("getOrCreateOptimizedProvider failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(llmProviders) > 4 {
+		t.Errorf("cache grew to %d entries, want at most 4", len(llmProviders))
+	}
+}
+
+func TestModelAliasResolution(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalUsage := providerUsage
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		providerUsage = originalUsage
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "ollama",
+		ModelAliases:    map[string]string{"fast": "gpt-4o-mini", "ollama:fast": "llama3.2"},
+	}
+	cfg.Ollama.Endpoint = "http://localhost:11434"
+	cfg.Ollama.Model = "devstral:latest"
+	llmProviders = make(map[string]llm.Provider)
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+	providerUsage = newProviderLRU()
+
+	tests := []struct {
+		model     string
+		wantModel string
+	}{
+		{"fast", "llama3.2"},
+		{"llama3.2", "llama3.2"},
+		{"qwen2.5-coder", "qwen2.5-coder"},
+	}
+	for _, tt := range tests {
+		provider, err := getOrCreateOptimizedProvider("ollama", tt.model)
+		if err != nil {
+			t.Fatalf("getOrCreateOptimizedProvider(%q) failed: %v", tt.model, err)
+		}
+		if got := provider.(llm.ModelReporter).Model(); got != tt.wantModel {
+			t.Errorf("model %q resolved to %q, want %q", tt.model, got, tt.wantModel)
+		}
+	}
+
+	// An alias and the model it names share one cached provider
+	if len(llmProviders) != 2 {
+		t.Errorf("cached providers = %v, want the alias to reuse ollama:llama3.2", len(llmProviders))
+	}
+	if _, ok := llmProviders["ollama:fast"]; ok {
+		t.Error("provider cached under the alias instead of the model ID")
+	}
+}
+
+func TestPreinitializeProviders(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalUsage := providerUsage
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		providerUsage = originalUsage
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{DefaultProvider: "openai", MaxCachedProviders: config.DefaultMaxCachedProviders}
+	cfg.OpenAI.APIKey = "sk-test"
+	cfg.Google.APIKey = "google-test"
+	cfg.Mistral.APIKey = "mistral-test"
+	cfg.Ollama.Endpoint = "http://localhost:11434"
+	// A bad CA file makes one provider fail without stopping the others
+	cfg.Perplexity.APIKey = "pplx-test"
+	cfg.ProviderTLS = map[string]config.TLSConfig{"perplexity": {CACertFile: filepath.Join(t.TempDir(), "missing.pem")}}
+	llmProviders = make(map[string]llm.Provider)
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+	providerUsage = newProviderLRU()
+
+	var logs bytes.Buffer
+	preinitializeProviders(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	for _, name := range []string{"openai", "google", "mistral", "ollama"} {
+		if _, ok := llmProviders[name]; !ok {
+			t.Errorf("provider %q was not preinitialized", name)
+		}
+		if _, ok := optimizedLLMProviders[name]; !ok {
+			t.Errorf("optimized provider %q was not preinitialized", name)
+		}
+	}
+	if _, ok := llmProviders["perplexity"]; ok {
+		t.Error("expected the misconfigured provider to be skipped")
+	}
+	if !strings.Contains(logs.String(), "failed to preinitialize provider") || !strings.Contains(logs.String(), "provider=perplexity") {
+		t.Errorf("expected a warning for the failed provider, got:\n%s", logs.String())
+	}
+}
+
+func TestProviderNameNormalization(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalUsage := providerUsage
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		providerUsage = originalUsage
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{DefaultProvider: "ollama", MaxCachedProviders: config.DefaultMaxCachedProviders}
+	cfg.Ollama.Endpoint = "http://localhost:11434"
+	llmProviders = make(map[string]llm.Provider)
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+	providerUsage = newProviderLRU()
+
+	for _, name := range []string{"ollama", " Ollama ", "OLLAMA"} {
+		if _, err := getOrCreateOptimizedProvider(name, ""); err != nil {
+			t.Fatalf("getOrCreateOptimizedProvider(%q) failed: %v", name, err)
+		}
+	}
+	if len(llmProviders) != 1 {
+		t.Errorf("got %d cached providers, want spellings of one name to share an entry", len(llmProviders))
+	}
+
+	// An unknown name is an error, not the default provider
+	for _, get := range []func() error{
+		func() error { _, err := getOrCreateProvider("GPT", ""); return err },
+		func() error { _, err := getOrCreateOptimizedProvider("GPT", ""); return err },
+	} {
+		err := get()
+		if err == nil || !strings.Contains(err.Error(), "unknown provider 'GPT'; valid: openai, google") {
+			t.Errorf("got %v, want an unknown provider error", err)
+		}
+	}
+}
+
+func TestNewProviderConfigModelOverrides(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+
+	zero := 0.0
+	cfg = &config.Config{
+		DefaultProvider: "ollama",
+		Temperature:     0.7,
+		MaxTokens:       4096,
+		ModelOverrides:  map[string]config.ModelOverride{"llama3.2": {MaxTokens: 2048, Temperature: &zero}},
+	}
+
+	overridden := newProviderConfig("ollama", "llama3.2")
+	if overridden.Temperature != 0 || overridden.MaxTokens != 2048 {
+		t.Errorf("overridden config: temperature = %v, max tokens = %d, want 0 and 2048", overridden.Temperature, overridden.MaxTokens)
+	}
+
+	other := newProviderConfig("ollama", "devstral:latest")
+	if other.Temperature != 0.7 || other.MaxTokens != 4096 {
+		t.Errorf("other model: temperature = %v, max tokens = %d, want the defaults 0.7 and 4096", other.Temperature, other.MaxTokens)
+	}
+}
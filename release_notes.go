@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// conventionalCommitRegex parses a Conventional Commits subject: type(scope)!: description
+var conventionalCommitRegex = regexp.MustCompile(`^([A-Za-z]+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+// Release note sections, in the order they are presented
+const (
+	sectionBreaking = "Breaking Changes"
+	sectionFeatures = "Features"
+	sectionFixes    = "Fixes"
+	sectionOther    = "Other Changes"
+)
+
+var releaseNoteSections = []string{sectionBreaking, sectionFeatures, sectionFixes, sectionOther}
+
+// releaseCommit is a commit parsed for release notes
+type releaseCommit struct {
+	CommitRef
+	Type        string // Conventional Commit type, lowercased, or "" if the subject doesn't follow the convention
+	Scope       string
+	Description string // subject without the type and scope
+	Breaking    bool   // marked with "!" or a BREAKING CHANGE footer
+}
+
+// parseReleaseCommit parses a commit's subject and body as a Conventional Commit
+func parseReleaseCommit(commit CommitRef) releaseCommit {
+	parsed := releaseCommit{CommitRef: commit, Description: commit.Subject}
+	if m := conventionalCommitRegex.FindStringSubmatch(commit.Subject); m != nil {
+		parsed.Type = strings.ToLower(m[1])
+		parsed.Scope = m[2]
+		parsed.Breaking = m[3] == "!"
+		parsed.Description = m[4]
+	}
+	if strings.Contains(commit.Body, "BREAKING CHANGE:") || strings.Contains(commit.Body, "BREAKING-CHANGE:") {
+		parsed.Breaking = true
+	}
+	return parsed
+}
+
+// section returns the release note section a commit belongs in
+func (c releaseCommit) section() string {
+	switch {
+	case c.Breaking:
+		return sectionBreaking
+	case c.Type == "feat":
+		return sectionFeatures
+	case c.Type == "fix":
+		return sectionFixes
+	default:
+		return sectionOther
+	}
+}
+
+// groupReleaseCommits parses commits and groups them by section, skipping merge commits,
+// whose changes are already listed through the commits they merged
+func groupReleaseCommits(commits []CommitRef) map[string][]releaseCommit {
+	groups := make(map[string][]releaseCommit)
+	for _, commit := range commits {
+		if commit.Merge {
+			continue
+		}
+		parsed := parseReleaseCommit(commit)
+		groups[parsed.section()] = append(groups[parsed.section()], parsed)
+	}
+	return groups
+}
+
+// formatReleaseCommits renders grouped commits as the input to the release notes prompt
+func formatReleaseCommits(groups map[string][]releaseCommit) string {
+	var out strings.Builder
+	for _, section := range releaseNoteSections {
+		commits := groups[section]
+		if len(commits) == 0 {
+			continue
+		}
+		out.WriteString(fmt.Sprintf("%s:\n", section))
+		for _, commit := range commits {
+			out.WriteString(fmt.Sprintf("- %s %s", commit.SHA[:min(7, len(commit.SHA))], commit.Description))
+			if commit.Scope != "" {
+				out.WriteString(fmt.Sprintf(" (scope: %s)", commit.Scope))
+			}
+			if commit.Type != "" && commit.Type != "feat" && commit.Type != "fix" {
+				out.WriteString(fmt.Sprintf(" [%s]", commit.Type))
+			}
+			out.WriteString("\n")
+			if commit.Body != "" {
+				for _, line := range strings.Split(commit.Body, "\n") {
+					out.WriteString("    " + line + "\n")
+				}
+			}
+		}
+		out.WriteString("\n")
+	}
+	return strings.TrimRight(out.String(), "\n")
+}
+
+func handleGenerateReleaseNotes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	// from_tag/to_tag and from_ref/to_ref are interchangeable
+	from, _ := request.GetArguments()["from_tag"].(string)
+	if f, ok := request.GetArguments()["from_ref"].(string); ok && f != "" {
+		from = f
+	}
+	to := "HEAD"
+	if t, ok := request.GetArguments()["to_tag"].(string); ok && t != "" {
+		to = t
+	}
+	if t, ok := request.GetArguments()["to_ref"].(string); ok && t != "" {
+		to = t
+	}
+
+	// Validate range endpoints
+	if from == "" {
+		return mcp.NewToolResultError("from_tag or from_ref is required"), nil
+	}
+	if err := validateGitRef(from); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid from: %v", err)), nil
+	}
+	if err := validateGitRef(to); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid to: %v", err)), nil
+	}
+
+	repoPath := "."
+	if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
+		repoPath = path
+	}
+
+	// Validate repo path
+	validPath, err := validateRepoPath(repoPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	for _, ref := range []string{from, to} {
+		if !refExists(ctx, validPath, ref) {
+			return mcp.NewToolResultError(fmt.Sprintf("'%s' does not exist in the repository", ref)), nil
+		}
+	}
+
+	// Get provider and model from request
+	providerName := ""
+	if p, ok := request.GetArguments()["provider"].(string); ok {
+		providerName = p
+	}
+
+	modelOverride := ""
+	if m, ok := request.GetArguments()["model"].(string); ok {
+		modelOverride = m
+	}
+
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	commitRange, err := listCommitRange(ctx, validPath, from, to, 0, maxCommitsPerRange())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	groups := groupReleaseCommits(commitRange.Commits)
+	if len(groups) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No commits found between %s and %s.", from, to)), nil
+	}
+
+	content := formatReleaseCommits(groups)
+	prompt := llm.AnalysisPrompt("release_notes", content, map[string]any{
+		"from": from,
+		"to":   to,
+	})
+	task := llm.GetTaskFromAnalysisType("release_notes")
+	notes, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, len(content), task)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText(limitResponse(notes))
+	if commitRange.IsTruncated() {
+		result = withResultNote(result, fmt.Sprintf("⚠️ Note: range contains %d commits; only the most recent %d were included (see max_commits_per_range)",
+			commitRange.Total, len(commitRange.Commits)))
+	}
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestGroupReleaseCommits(t *testing.T) {
+	commits := []CommitRef{
+		{SHA: "1111111aaaa", Subject: "feat(api): add pagination to list endpoints"},
+		{SHA: "2222222bbbb", Subject: "fix: handle empty diffs"},
+		{SHA: "3333333cccc", Subject: "feat!: drop the v1 config format"},
+		{SHA: "4444444dddd", Subject: "refactor(llm): simplify retries", Body: "BREAKING CHANGE: RetryConfig.Jitter was removed"},
+		{SHA: "5555555eeee", Subject: "docs: update README"},
+		{SHA: "6666666ffff", Subject: "Tidy up imports"},
+		{SHA: "7777777aaaa", Subject: "Merge pull request #42 from feature", Merge: true},
+		{SHA: "8888888bbbb", Subject: "Fix(ui): Align buttons"},
+	}
+
+	groups := groupReleaseCommits(commits)
+	want := map[string][]string{
+		sectionBreaking: {"3333333cccc", "4444444dddd"},
+		sectionFeatures: {"1111111aaaa"},
+		sectionFixes:    {"2222222bbbb", "8888888bbbb"},
+		sectionOther:    {"5555555eeee", "6666666ffff"},
+	}
+	for section, shas := range want {
+		var got []string
+		for _, commit := range groups[section] {
+			got = append(got, commit.SHA)
+		}
+		if strings.Join(got, ",") != strings.Join(shas, ",") {
+			t.Errorf("%s = %v, want %v", section, got, shas)
+		}
+	}
+
+	content := formatReleaseCommits(groups)
+	for _, want := range []string{
+		"Breaking Changes:\n- 3333333 drop the v1 config format\n- 4444444 simplify retries (scope: llm) [refactor]\n    BREAKING CHANGE: RetryConfig.Jitter was removed",
+		"Features:\n- 1111111 add pagination to list endpoints (scope: api)",
+		"- 5555555 update README [docs]",
+		"- 6666666 Tidy up imports",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content missing %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "Merge pull request") {
+		t.Errorf("merge commits should be skipped:\n%s", content)
+	}
+	if strings.Index(content, "Breaking Changes:") > strings.Index(content, "Features:") {
+		t.Errorf("breaking changes should come first:\n%s", content)
+	}
+}
+
+func TestHandleGenerateReleaseNotes(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	repo := newTestRepo(t)
+	runGit(t, repo, "tag", "v1.0.0")
+	for _, subject := range []string{"feat: add dark mode", "fix(auth): refresh expired tokens", "chore: bump deps"} {
+		writeTestFile(t, repo, "CHANGES", subject+"\n")
+		runGit(t, repo, "add", ".")
+		runGit(t, repo, "commit", "--quiet", "-m", subject)
+	}
+	runGit(t, repo, "tag", "v1.1.0")
+
+	cfg = &config.Config{
+		DefaultProvider:    "mock",
+		AllowedRepoRoots:   []string{repo},
+		MaxCommitsPerRange: 2,
+		Memory:             config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+
+	call := func(args map[string]any) (*mcp.CallToolResult, *countingProvider) {
+		t.Helper()
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		args["repo_path"] = repo
+		result, err := handleGenerateReleaseNotes(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "generate_release_notes", Arguments: args},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result, provider
+	}
+
+	result, provider := call(map[string]any{"from_tag": "v1.0.0", "to_tag": "v1.1.0"})
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %v", result.Content)
+	}
+	if provider.calls != 1 {
+		t.Fatalf("provider called %d times, want 1", provider.calls)
+	}
+	prompt := provider.prompts[0]
+	for _, want := range []string{"from v1.0.0 to v1.1.0", "Fixes:\n-", "refresh expired tokens (scope: auth)", "bump deps [chore]"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	// max_commits_per_range keeps the most recent commits
+	if strings.Contains(prompt, "add dark mode") {
+		t.Errorf("prompt should omit commits beyond the cap:\n%s", prompt)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "range contains 3 commits; only the most recent 2 were included") {
+		t.Errorf("result missing the cap note:\n%s", text)
+	}
+
+	// from_ref and to_ref work the same way, and to defaults to HEAD
+	result, provider = call(map[string]any{"from_ref": "HEAD~1"})
+	if result.IsError || provider.calls != 1 || !strings.Contains(provider.prompts[0], "from HEAD~1 to HEAD") {
+		t.Errorf("from_ref: IsError=%v calls=%d", result.IsError, provider.calls)
+	}
+
+	for _, args := range []map[string]any{
+		{},
+		{"from_tag": "--upload-pack=evil"},
+		{"from_tag": "v0.9.0"},
+	} {
+		result, provider = call(args)
+		if !result.IsError || provider.calls != 0 {
+			t.Errorf("args %v: expected an error without a provider call, got IsError=%v and %d calls", args, result.IsError, provider.calls)
+		}
+	}
+}
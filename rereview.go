@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxPriorFindingsChars caps how much of the previous review is carried into the next prompt
+const maxPriorFindingsChars = 8000
+
+// reviewState records what rereview last reviewed for a repository branch
+type reviewState struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	// Tree is the git tree object snapshotting the working tree at the last review
+	Tree       string    `json:"tree"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+	// Findings is the (possibly truncated) previous review, reminded to the model next time
+	Findings string `json:"findings"`
+}
+
+// reviewStateStore persists review state between rereview calls
+type reviewStateStore interface {
+	// Load returns the state saved under key, or nil when there is none
+	Load(key string) (*reviewState, error)
+	Save(key string, state reviewState) error
+	Delete(key string) error
+}
+
+// fileReviewStateStore keeps one JSON file per key in a directory
+type fileReviewStateStore struct {
+	dir string
+}
+
+// newFileReviewStateStore stores state under the user's cache directory, falling back to the
+// temp directory when there is none
+func newFileReviewStateStore() *fileReviewStateStore {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return &fileReviewStateStore{dir: filepath.Join(base, "second-opinion", "reviews")}
+}
+
+func (s *fileReviewStateStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *fileReviewStateStore) Load(key string) (*reviewState, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read review state: %w", err)
+	}
+
+	var state reviewState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse review state %s: %w", s.path(key), err)
+	}
+	return &state, nil
+}
+
+func (s *fileReviewStateStore) Save(key string, state reviewState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.dir, key+".json", data); err != nil {
+		return fmt.Errorf("failed to save review state: %w", err)
+	}
+	return nil
+}
+
+func (s *fileReviewStateStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete review state: %w", err)
+	}
+	return nil
+}
+
+// rereviewStore holds rereview state; tests replace it with an in-memory store
+var rereviewStore reviewStateStore = newFileReviewStateStore()
+
+// reviewStateKey identifies a repository branch in the state store
+func reviewStateKey(repoPath, branch string) string {
+	sum := sha256.Sum256([]byte(repoPath + "\x00" + branch))
+	return hex.EncodeToString(sum[:16])
+}
+
+// currentBranch returns the checked-out branch, or "HEAD" when detached
+func currentBranch(ctx context.Context, repoPath string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "rev-parse", "--abbrev-ref", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine the current branch: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// snapshotWorkingTree writes the working tree, including untracked files that are not ignored,
+// to a git tree object and returns its hash. A throwaway index is used so the user's staging
+// area is left untouched.
+func snapshotWorkingTree(ctx context.Context, repoPath string) (string, error) {
+	dir, err := os.MkdirTemp("", "second-opinion-index-")
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot the working tree: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	env := append(os.Environ(), "GIT_INDEX_FILE="+filepath.Join(dir, "index"))
+
+	for _, args := range [][]string{{"read-tree", "HEAD"}, {"add", "--all"}} {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repoPath}, args...)...)
+		cmd.Env = env
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to snapshot the working tree: git %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "write-tree")
+	cmd.Env = env
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot the working tree: git write-tree: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// treeExists reports whether a snapshot tree is still in the object database; git gc may
+// prune it since nothing references it
+func treeExists(ctx context.Context, repoPath, tree string) bool {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "cat-file", "-e", tree+"^{tree}")
+	return cmd.Run() == nil
+}
+
+// priorFindings trims a review to the part reminded to the model on the next call
+func priorFindings(review string) string {
+	runes := []rune(review)
+	if len(runes) <= maxPriorFindingsChars {
+		return review
+	}
+	return string(runes[:maxPriorFindingsChars]) + "\n(earlier findings truncated)"
+}
+
+// formatRereviewDiff renders a diff for the rereview prompt, noting any truncation
+func formatRereviewDiff(diff *TruncatedDiff) string {
+	var b strings.Builder
+	if diff.IsTruncated {
+		fmt.Fprintf(&b, "⚠️ WARNING: %s\n", diff.WarningReason)
+		fmt.Fprintf(&b, "Total size: %dKB, Files: %d\n\n", diff.TotalSizeKB, diff.FileCount)
+	}
+	if note := filteredFilesNote(diff.FilesFiltered); note != "" {
+		b.WriteString(note + "\n\n")
+	}
+	b.WriteString(diff.Content)
+	return b.String()
+}
+
+func handleRereview(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx = withCallOptions(ctx, request)
+
+	repoPath := "."
+	if path, ok := request.GetArguments()["repo_path"].(string); ok && path != "" {
+		repoPath = path
+	}
+
+	// Validate repo path
+	validPath, err := validateRepoPath(repoPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+	}
+	if err := requireGit(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	baseRef := ""
+	if b, ok := request.GetArguments()["base_ref"].(string); ok && b != "" {
+		if err := validateGitRef(b); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid base_ref: %v", err)), nil
+		}
+		baseRef = b
+	}
+
+	reset := false
+	if r, ok := request.GetArguments()["reset"].(bool); ok {
+		reset = r
+	}
+
+	// Get provider and model from request
+	providerName := ""
+	if p, ok := request.GetArguments()["provider"].(string); ok {
+		providerName = p
+	}
+
+	modelOverride := ""
+	if m, ok := request.GetArguments()["model"].(string); ok {
+		modelOverride = m
+	}
+
+	// Get or create the provider (the optimized wrapper unless raw is set)
+	optimizedProvider, err := getAnalysisProvider(request, providerName, modelOverride)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	filter, err := diffFilterFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	contextArgs, err := contextLinesArgsFromRequest(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	branch, err := currentBranch(ctx, validPath)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	key := reviewStateKey(validPath, branch)
+
+	tree, err := snapshotWorkingTree(ctx, validPath)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var previous *reviewState
+	if reset {
+		if err := rereviewStore.Delete(key); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	} else {
+		previous, err = rereviewStore.Load(key)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		// A pruned snapshot cannot be diffed against, so review everything again
+		if previous != nil && !treeExists(ctx, validPath, previous.Tree) {
+			previous = nil
+		}
+	}
+
+	// Review only what changed since the last snapshot, or everything since the base the first time
+	from := "HEAD"
+	switch {
+	case previous != nil:
+		if previous.Tree == tree {
+			return mcp.NewToolResultText(fmt.Sprintf("No changes since the last review at %s.", previous.ReviewedAt.Format(time.RFC3339))), nil
+		}
+		from = previous.Tree
+	case baseRef != "":
+		from, err = getMergeBase(ctx, validPath, baseRef)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	diff, err := getGitDiffSafe(ctx, validPath, &cfg.Memory, filter, append(contextArgs, from, tree)...)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to get diff: %v", err)), nil
+	}
+	if diff.Content == "" && !diff.IsTruncated {
+		if diff.FilesFiltered > 0 {
+			return mcp.NewToolResultText(noMatchingFilesMessage), nil
+		}
+		return mcp.NewToolResultText("No changes to review."), nil
+	}
+
+	diffContent := formatRereviewDiff(diff)
+	content := diffContent
+	if previous != nil {
+		content = fmt.Sprintf("Findings from the previous review:\n%s\n\nChanges since the previous review:\n%s", previous.Findings, diffContent)
+	}
+
+	prompt := llm.AnalysisPrompt("rereview", content, map[string]any{
+		"incremental": previous != nil,
+	})
+
+	task := llm.GetTaskFromAnalysisType("rereview")
+	analysis, err := optimizedProvider.AnalyzeOptimized(ctx, prompt, len(content), task)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM analysis failed: %v", err)), nil
+	}
+
+	var header string
+	if previous != nil {
+		header = fmt.Sprintf("# Incremental Review\n\nReviewing only the changes since the last review at %s.\n\n", previous.ReviewedAt.Format(time.RFC3339))
+	} else {
+		header = "# Full Review\n\nNo earlier review of this branch was found, so all changes were reviewed. Later calls review only new changes.\n\n"
+	}
+
+	// A dry run reviewed nothing, and a truncated or filtered diff left changes unreviewed, so the
+	// next call must still see them
+	switch {
+	case llm.CallOptionsFromContext(ctx).DryRun:
+	case diff.IsTruncated || diff.FilesFiltered > 0:
+		header += "⚠️ Some changes were left out of this review by the diff limits or filters, so the review state was not saved and the next call will review them again.\n\n"
+	default:
+		state := reviewState{
+			Repo:       validPath,
+			Branch:     branch,
+			Tree:       tree,
+			ReviewedAt: time.Now(),
+			Findings:   priorFindings(analysis),
+		}
+		if err := rereviewStore.Save(key, state); err != nil {
+			header += fmt.Sprintf("⚠️ The review state could not be saved, so the next call will review everything again: %v\n\n", err)
+		}
+	}
+
+	return mcp.NewToolResultText(header + limitResponse(analysis)), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// memReviewStore keeps review state in memory
+type memReviewStore struct {
+	states map[string]reviewState
+}
+
+func (m *memReviewStore) Load(key string) (*reviewState, error) {
+	state, ok := m.states[key]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (m *memReviewStore) Save(key string, state reviewState) error {
+	m.states[key] = state
+	return nil
+}
+
+func (m *memReviewStore) Delete(key string) error {
+	delete(m.states, key)
+	return nil
+}
+
+func TestHandleRereview(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	originalStore := rereviewStore
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+		rereviewStore = originalStore
+	}()
+
+	repo := newTestRepo(t)
+	writeTestFile(t, repo, "calc.go", "package calc\n\nfunc Add(a, b int) int { return a + b }\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "--quiet", "-m", "Add calc")
+
+	cfg = &config.Config{
+		DefaultProvider:  "mock",
+		AllowedRepoRoots: []string{repo},
+		Memory:           config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	store := &memReviewStore{states: make(map[string]reviewState)}
+	rereviewStore = store
+
+	call := func(args map[string]any) (string, *countingProvider) {
+		t.Helper()
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		args["repo_path"] = repo
+		result, err := handleRereview(context.Background(), mcp.CallToolRequest{
+			Params: mcp.CallToolParams{Name: "rereview", Arguments: args},
+		})
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		return result.Content[0].(mcp.TextContent).Text, provider
+	}
+
+	// The first run reviews every uncommitted change, including untracked files
+	writeTestFile(t, repo, "calc.go", "package calc\n\nfunc Add(a, b int) int { return a + b }\n\nfunc Sub(a, b int) int { return a - b }\n")
+	writeTestFile(t, repo, "mul.go", "package calc\n\nfunc Mul(a, b int) int { return a * b }\n")
+	text, provider := call(map[string]any{})
+	if !strings.Contains(text, "# Full Review") {
+		t.Errorf("expected a full review, got:\n%s", text)
+	}
+	for _, want := range []string{"Review this work in progress", "func Sub", "func Mul"} {
+		if !strings.Contains(provider.prompts[0], want) {
+			t.Errorf("expected full prompt to contain %q:\n%s", want, provider.prompts[0])
+		}
+	}
+	if len(store.states) != 1 {
+		t.Fatalf("expected one saved state, got %d", len(store.states))
+	}
+
+	// Nothing changed, so no LLM call is made
+	text, provider = call(map[string]any{})
+	if provider.calls != 0 || !strings.Contains(text, "No changes since the last review") {
+		t.Errorf("expected no review for an unchanged tree (%d calls):\n%s", provider.calls, text)
+	}
+
+	// The next run sends only the new change and the earlier findings
+	writeTestFile(t, repo, "mul.go", "package calc\n\nfunc Mul(a, b int) int { return a * b }\n\nfunc Div(a, b int) int { return a / b }\n")
+	text, provider = call(map[string]any{})
+	if !strings.Contains(text, "# Incremental Review") {
+		t.Errorf("expected an incremental review, got:\n%s", text)
+	}
+	prompt := provider.prompts[0]
+	for _, want := range []string{"Continue reviewing work in progress", "Findings from the previous review:\nSummary 1", "+func Div"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected incremental prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "func Sub") || strings.Contains(prompt, "+func Mul") {
+		t.Errorf("incremental prompt should omit already reviewed changes:\n%s", prompt)
+	}
+
+	// A filtered review leaves the other changes unreviewed, so the saved state is kept
+	saved := maps.Clone(store.states)
+	writeTestFile(t, repo, "calc.go", "package calc\n\nfunc Add(a, b int) int { return b + a }\n\nfunc Sub(a, b int) int { return a - b }\n")
+	writeTestFile(t, repo, "mul.go", "package calc\n\nfunc Mul(a, b int) int { return b * a }\n")
+	text, _ = call(map[string]any{"exclude_patterns": []any{"mul.go"}})
+	if !strings.Contains(text, "review state was not saved") || !maps.Equal(store.states, saved) {
+		t.Errorf("expected a filtered review to keep the saved state:\n%s", text)
+	}
+	if text, provider = call(map[string]any{}); !strings.Contains(provider.prompts[0], "return b * a") {
+		t.Errorf("expected the next call to review the excluded change:\n%s", text)
+	}
+
+	// Reset forgets the earlier review
+	text, provider = call(map[string]any{"reset": true})
+	if !strings.Contains(text, "# Full Review") || !strings.Contains(provider.prompts[0], "func Sub") {
+		t.Errorf("expected reset to review everything again:\n%s", text)
+	}
+
+	// The user's index is left alone
+	if status := runGit(t, repo, "status", "--short"); !strings.Contains(status, "?? mul.go") {
+		t.Errorf("expected mul.go to stay untracked, got status:\n%s", status)
+	}
+}
+
+func TestFileReviewStateStore(t *testing.T) {
+	store := &fileReviewStateStore{dir: t.TempDir()}
+
+	if state, err := store.Load("missing"); err != nil || state != nil {
+		t.Fatalf("Load(missing) = %v, %v; want nil, nil", state, err)
+	}
+
+	want := reviewState{Repo: "/repo", Branch: "main", Tree: "abc123", Findings: "Looks good"}
+	if err := store.Save("key", want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Load("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != want {
+		t.Errorf("Load() = %+v, want %+v", *got, want)
+	}
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := store.Load("key"); state != nil {
+		t.Errorf("expected the state to be deleted, got %+v", state)
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+import "github.com/dshills/second-opinion/analysis"
+
+// limitResponse applies the configured MaxResponseChars cap to an LLM response
+func limitResponse(text string) string {
+	return analysis.LimitResponse(cfg, text)
+}
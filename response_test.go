@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+func TestLimitResponseUsesConfig(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+
+	cfg = &config.Config{MaxResponseChars: 10}
+	if got := limitResponse("alpha beta gamma delta"); !strings.HasSuffix(got, "(output truncated at 10 characters)") {
+		t.Errorf("limitResponse() = %q, want truncation note", got)
+	}
+
+	cfg = &config.Config{}
+	if got := limitResponse("alpha beta gamma delta"); got != "alpha beta gamma delta" {
+		t.Errorf("limitResponse() = %q, want unchanged text", got)
+	}
+}
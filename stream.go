@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// withProgressStream streams the analysis to the client as it is generated, sending each piece of
+// the response as the message of a notifications/progress notification. MCP tool results cannot
+// be sent in parts, so this only happens when the client asked for progress by sending a progress
+// token; the complete result is still returned at the end. Other clients, and providers that
+// cannot stream, get the buffered result alone.
+func withProgressStream(ctx context.Context, request mcp.CallToolRequest) context.Context {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return ctx
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return ctx
+	}
+
+	token := request.Params.Meta.ProgressToken
+	var mu sync.Mutex
+	progress := 0
+	return llm.WithStreamCallback(ctx, func(chunk string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		progress++
+		err := srv.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      progress,
+			"message":       chunk,
+		})
+		// A dropped notification only loses part of the preview; the full result still follows
+		if err != nil {
+			slog.Debug("failed to stream analysis progress", "error", err)
+		}
+		return nil
+	})
+}
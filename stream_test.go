@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// streamingProvider streams its chunks to the context's stream callback, waiting on next between
+// chunks so tests can observe each one before the next is produced
+type streamingProvider struct {
+	chunks []string
+	next   chan struct{}
+}
+
+func (p *streamingProvider) Analyze(ctx context.Context, prompt string) (string, error) {
+	if onChunk := llm.StreamCallbackFromContext(ctx); onChunk != nil {
+		for i, chunk := range p.chunks {
+			if i > 0 {
+				select {
+				case <-p.next:
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+			}
+			if err := onChunk(chunk); err != nil {
+				return "", err
+			}
+		}
+	}
+	return strings.Join(p.chunks, ""), nil
+}
+
+func (p *streamingProvider) Name() string {
+	return "mock"
+}
+
+func (p *streamingProvider) Capabilities() llm.ProviderCapabilities {
+	return llm.ProviderCapabilities{SupportsTemperature: true, SupportsStreaming: true}
+}
+
+// notificationSession is an initialized client session that collects notifications
+type notificationSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *notificationSession) Initialize()       {}
+func (s *notificationSession) Initialized() bool { return true }
+func (s *notificationSession) SessionID() string { return "test-session" }
+func (s *notificationSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func TestReviewCodeStreamsProgress(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		ServerName:      "test",
+		ServerVersion:   "1.0.0",
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	s := newMCPServer()
+
+	// call sends a review_code request and returns the response once the handler finishes
+	call := func(provider llm.Provider, session *notificationSession, arguments, meta string) <-chan string {
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"review_code","arguments":` + arguments + meta + `}}`
+		done := make(chan string, 1)
+		go func() {
+			response, _ := json.Marshal(s.HandleMessage(s.WithContext(context.Background(), session), json.RawMessage(message)))
+			done <- string(response)
+		}()
+		return done
+	}
+	withToken := `,"_meta":{"progressToken":"review-1"}`
+
+	t.Run("chunks are delivered progressively", func(t *testing.T) {
+		provider := &streamingProvider{chunks: []string{"Looks ", "good ", "overall."}, next: make(chan struct{})}
+		session := &notificationSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+		done := call(provider, session, `{"code":"func main() {}"}`, withToken)
+
+		for i, want := range provider.chunks {
+			select {
+			case notification := <-session.notifications:
+				params := notification.Params.AdditionalFields
+				if notification.Method != "notifications/progress" || params["progressToken"] != "review-1" || params["message"] != want || params["progress"] != i+1 {
+					t.Fatalf("notification %d = %s %v, want progress %d with message %q", i+1, notification.Method, params, i+1, want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for chunk %d", i+1)
+			}
+
+			if i == len(provider.chunks)-1 {
+				break
+			}
+			// The result is held back until the stream completes
+			select {
+			case response := <-done:
+				t.Fatalf("result arrived after only %d of %d chunks: %s", i+1, len(provider.chunks), response)
+			default:
+			}
+			provider.next <- struct{}{}
+		}
+
+		select {
+		case response := <-done:
+			if !strings.Contains(response, "Looks good overall.") {
+				t.Errorf("final result missing the full review: %s", response)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the result")
+		}
+	})
+
+	fallbacks := []struct {
+		name      string
+		provider  llm.Provider
+		arguments string
+		meta      string
+	}{
+		{"no progress token", &streamingProvider{chunks: []string{"Looks good."}}, `{"code":"func main() {}"}`, ""},
+		{"provider cannot stream", &countingProvider{name: "mock"}, `{"code":"func main() {}"}`, withToken},
+		{"structured format", &streamingProvider{chunks: []string{`{"findings": []}`}}, `{"code":"func main() {}","format":"json"}`, withToken},
+	}
+	for _, tt := range fallbacks {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &notificationSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+			select {
+			case response := <-call(tt.provider, session, tt.arguments, tt.meta):
+				if strings.Contains(response, `"isError":true`) {
+					t.Fatalf("unexpected tool error: %s", response)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the result")
+			}
+			if len(session.notifications) != 0 {
+				t.Errorf("expected a buffered result only, got %d notifications", len(session.notifications))
+			}
+		})
+	}
+}
+
+var _ server.ClientSession = (*notificationSession)(nil)
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+)
+
+// postMCP sends one JSON-RPC request to the http transport and decodes the response
+func postMCP(t *testing.T, url, sessionID, body string) (*http.Response, map[string]any) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("failed to decode response (status %d): %v", resp.StatusCode, err)
+	}
+	return resp, decoded
+}
+
+func TestHTTPTransportListsTools(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{ServerName: "test", ServerVersion: "1.0.0", ServerTransport: config.TransportHTTP}
+
+	server := httptest.NewServer(newHTTPServer(newMCPServer()))
+	defer server.Close()
+	url := server.URL + mcpEndpointPath
+
+	resp, initResult := postMCP(t, url, "",
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"smoke-test","version":"1.0.0"}}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d, want 200: %v", resp.StatusCode, initResult)
+	}
+	sessionID := resp.Header.Get("Mcp-Session-Id")
+
+	_, listResult := postMCP(t, url, sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	result, ok := listResult["result"].(map[string]any)
+	if !ok {
+		t.Fatalf("tools/list returned no result: %v", listResult)
+	}
+	tools, _ := result["tools"].([]any)
+
+	names := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		names[tool.(map[string]any)["name"].(string)] = true
+	}
+	for _, want := range []string{"analyze_git_diff", "review_code", "check_providers"} {
+		if !names[want] {
+			t.Errorf("tools/list is missing %s; got %v", want, names)
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	originalCfg := cfg
+	originalMetrics := providerMetrics
+	defer func() {
+		cfg = originalCfg
+		providerMetrics = originalMetrics
+	}()
+	cfg = &config.Config{ServerName: "test", ServerVersion: "1.0.0", ServerTransport: config.TransportHTTP}
+	providerMetrics = llm.NewProviderMetrics()
+
+	// A mock request through the metrics middleware
+	provider := llm.MetricsMiddleware(providerMetrics)(&MockProvider{name: "mock", response: "looks good"})
+	if _, err := provider.Analyze(context.Background(), "review this"); err != nil {
+		t.Fatalf("Analyze() unexpected error: %v", err)
+	}
+
+	server := httptest.NewServer(newMetricsHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + metricsPath)
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("metrics status = %d, want 200", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", contentType)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`second_opinion_llm_requests_total{provider="mock"} 1`,
+		`second_opinion_llm_request_errors_total{provider="mock"} 0`,
+		`second_opinion_llm_retries_total{provider="mock"} 0`,
+		`second_opinion_llm_prompt_tokens_total{provider="mock"}`,
+		`second_opinion_llm_response_tokens_total{provider="mock"}`,
+		`second_opinion_llm_request_duration_seconds_bucket{provider="mock",le="+Inf"} 1`,
+		`second_opinion_llm_request_duration_seconds_count{provider="mock"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics are missing %q:\n%s", want, body)
+		}
+	}
+
+	// Metrics are kept off the MCP listener, which may be reachable remotely
+	mcpServer := httptest.NewServer(newHTTPHandler(newMCPServer()))
+	defer mcpServer.Close()
+	mcpResp, err := http.Get(mcpServer.URL + metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcpResp.Body.Close()
+	if mcpResp.StatusCode != http.StatusNotFound {
+		t.Errorf("metrics on the MCP listener: status = %d, want 404", mcpResp.StatusCode)
+	}
+}
+
+func TestHTTPTransportGuard(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{
+		ServerName:           "test",
+		ServerVersion:        "1.0.0",
+		ServerTransport:      config.TransportHTTP,
+		ServerAllowedOrigins: []string{"https://tools.example.com"},
+	}
+
+	server := httptest.NewServer(newHTTPHandler(newMCPServer()))
+	defer server.Close()
+
+	const initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"guard-test","version":"1.0.0"}}}`
+	status := func(header map[string]string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, server.URL+mcpEndpointPath, strings.NewReader(initialize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		header map[string]string
+		want   int
+	}{
+		{"no origin", "", nil, http.StatusOK},
+		{"localhost origin", "", map[string]string{"Origin": "http://localhost:3000"}, http.StatusOK},
+		{"allowed origin", "", map[string]string{"Origin": "https://tools.example.com"}, http.StatusOK},
+		{"foreign origin", "", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"missing token", "s3cret", nil, http.StatusUnauthorized},
+		{"wrong token", "s3cret", map[string]string{"Authorization": "Bearer guess"}, http.StatusUnauthorized},
+		{"valid token", "s3cret", map[string]string{"Authorization": "Bearer s3cret"}, http.StatusOK},
+		{"valid token from a foreign origin", "s3cret", map[string]string{"Authorization": "Bearer s3cret", "Origin": "https://evil.example"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.ServerAuthToken = tt.token
+			if got := status(tt.header); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
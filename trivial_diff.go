@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/dshills/second-opinion/analysis"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// diffFileChange summarizes one file section of a unified diff
+type diffFileChange struct {
+	analysis.DiffFile
+	removed strings.Builder // the current change block's removed lines, normalized by stripCodeWhitespace
+	added   strings.Builder // the current change block's added lines, normalized likewise
+	changed bool            // some change block differs by more than whitespace
+}
+
+// structural reports new, deleted, binary, and mode-only changes, which always deserve a review
+func (c *diffFileChange) structural() bool {
+	return c.Added || c.Deleted || c.Binary || (!c.HasHunks && !c.Renamed)
+}
+
+// endBlock compares the change block just finished, a run of removed and added lines between
+// context lines, and starts the next one. Comparing block by block keeps a line moved past
+// unchanged code from cancelling out against itself.
+func (c *diffFileChange) endBlock() {
+	if c.removed.String() != c.added.String() {
+		c.changed = true
+	}
+	c.removed.Reset()
+	c.added.Reset()
+}
+
+// trivialDiffNote returns a deterministic note when a diff only renames files or changes whitespace,
+// or "" when the diff has real content changes worth sending to an LLM
+func trivialDiffNote(diff string) string {
+	files := parseDiffFileChanges(diff)
+	if len(files) == 0 {
+		return ""
+	}
+
+	var renames []string
+	whitespaceOnly := 0
+	for _, file := range files {
+		if file.structural() || file.changed {
+			return ""
+		}
+		if file.Renamed {
+			renames = append(renames, fmt.Sprintf("%s → %s", file.OldPath, file.NewPath))
+		}
+		if file.HasHunks {
+			whitespaceOnly++
+		}
+	}
+
+	var note strings.Builder
+	switch {
+	case whitespaceOnly == 0:
+		note.WriteString(fmt.Sprintf("♻️ Rename-only diff: %d file(s) moved with no content changes.\n", len(renames)))
+	case len(renames) == 0:
+		note.WriteString(fmt.Sprintf("♻️ Whitespace-only diff: %d file(s) changed only in whitespace or formatting.\n", whitespaceOnly))
+	default:
+		note.WriteString(fmt.Sprintf("♻️ Rename and whitespace-only diff: %d file(s) moved, %d file(s) changed only in whitespace.\n", len(renames), whitespaceOnly))
+	}
+	for _, rename := range renames {
+		note.WriteString(fmt.Sprintf("- %s\n", rename))
+	}
+	note.WriteString("\nSkipped the LLM review. Pass force: true to review it anyway.")
+	return note.String()
+}
+
+// parseDiffFileChanges compares the change blocks of each file in a unified diff
+func parseDiffFileChanges(diff string) []*diffFileChange {
+	var files []*diffFileChange
+	for _, file := range analysis.ParseDiff(diff) {
+		current := &diffFileChange{DiffFile: file}
+		for _, line := range file.HunkLines() {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				current.removed.WriteString(stripCodeWhitespace(line[1:]))
+			case strings.HasPrefix(line, "+"):
+				current.added.WriteString(stripCodeWhitespace(line[1:]))
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file" belongs to the line before it
+			default:
+				// Context lines and hunk headers end a change block
+				current.endBlock()
+			}
+		}
+		current.endBlock()
+		files = append(files, current)
+	}
+	return files
+}
+
+// stripCodeWhitespace removes whitespace from a line of code except inside quoted strings, where
+// it changes the program's output. Blank lines and reflowed code therefore strip to the same text.
+func stripCodeWhitespace(line string) string {
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\' && quote != '`':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case unicode.IsSpace(r):
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// forceRequested reports whether the request asks to review trivial diffs anyway
+func forceRequested(request mcp.CallToolRequest) bool {
+	force, ok := request.GetArguments()["force"].(bool)
+	return ok && force
+}
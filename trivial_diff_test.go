@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+	"github.com/dshills/second-opinion/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const renameOnlyDiff = `diff --git a/old/name.go b/new/name.go
+similarity index 100%
+rename from old/name.go
+rename to new/name.go
+`
+
+const whitespaceOnlyDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,5 @@
+-func main() {
+-fmt.Println("hi")
+-}
++func main()  {
++	fmt.Println( "hi" )
++
++}
++
+`
+
+const contentDiff = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-fmt.Println("hi")
++fmt.Println("bye")
+`
+
+func TestTrivialDiffNote(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		wantNote string
+	}{
+		{"rename only", renameOnlyDiff, "Rename-only diff: 1 file(s)"},
+		{"whitespace only", whitespaceOnlyDiff, "Whitespace-only diff: 1 file(s)"},
+		{"rename and whitespace", renameOnlyDiff + whitespaceOnlyDiff, "Rename and whitespace-only diff"},
+		{"content change", contentDiff, ""},
+		{"whitespace inside a string", "diff --git a/m.go b/m.go\n@@ -1 +1 @@\n-fmt.Println(\"a b\")\n+fmt.Println(\"ab\")\n", ""},
+		{"line moved past context", "diff --git a/m.go b/m.go\n@@ -1,3 +1,3 @@\n-unlock()\n write()\n+unlock()\n", ""},
+		{"lines reordered", "diff --git a/m.go b/m.go\n@@ -1,2 +1,2 @@\n-a()\n-b()\n+b()\n+a()\n", ""},
+		{"line reflowed", "diff --git a/m.go b/m.go\n@@ -1 +1,2 @@\n-call(a, b)\n+call(a,\n+\tb)\n", "Whitespace-only diff"},
+		{"rename with edits", renameOnlyDiff + "@@ -1 +1 @@\n-a\n+b\n", ""},
+		{"rename plus content change", renameOnlyDiff + contentDiff, ""},
+		{"new file", "diff --git a/x b/x\nnew file mode 100644\n--- /dev/null\n+++ b/x\n@@ -0,0 +1 @@\n+ \n", ""},
+		{"mode change", "diff --git a/x b/x\nold mode 100644\nnew mode 100755\n", ""},
+		{"empty", "", ""},
+		{"header text is ignored", "📝 Uncommitted Work Analysis\n\nFiles changed:\nR  old/name.go -> new/name.go\n\n" + renameOnlyDiff, "Rename-only diff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			note := trivialDiffNote(tt.diff)
+			if tt.wantNote == "" {
+				if note != "" {
+					t.Errorf("expected no note, got:\n%s", note)
+				}
+				return
+			}
+			if !strings.Contains(note, tt.wantNote) {
+				t.Errorf("note = %q, want it to contain %q", note, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestHandleGitDiffTrivialDiff(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	cfg = &config.Config{
+		DefaultProvider: "mock",
+		Memory:          config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+
+	for _, diff := range []string{renameOnlyDiff, whitespaceOnlyDiff} {
+		provider := &countingProvider{name: "mock"}
+		llmProviders = map[string]llm.Provider{"mock": provider}
+		optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+		call := func(force bool) string {
+			t.Helper()
+			result, err := handleGitDiff(context.Background(), mcp.CallToolRequest{
+				Params: mcp.CallToolParams{Name: "analyze_git_diff", Arguments: map[string]any{"diff_content": diff, "force": force}},
+			})
+			if err != nil || result.IsError {
+				t.Fatalf("unexpected failure: %v %v", err, result)
+			}
+			return result.Content[0].(mcp.TextContent).Text
+		}
+
+		if text := call(false); !strings.Contains(text, "Skipped the LLM review") || provider.calls != 0 {
+			t.Errorf("expected a short-circuit without LLM calls, got %d calls:\n%s", provider.calls, text)
+		}
+		if call(true); provider.calls != 1 {
+			t.Errorf("force should send the diff to the LLM, got %d calls", provider.calls)
+		}
+	}
+}
+
+func TestHandleAnalyzeUncommittedWorkRenameOnly(t *testing.T) {
+	originalProviders := llmProviders
+	originalOptimized := optimizedLLMProviders
+	originalCfg := cfg
+	defer func() {
+		llmProviders = originalProviders
+		optimizedLLMProviders = originalOptimized
+		cfg = originalCfg
+	}()
+
+	repo := newTestRepo(t)
+	runGit(t, repo, "mv", "README.md", "GUIDE.md")
+
+	cfg = &config.Config{
+		DefaultProvider:  "mock",
+		AllowedRepoRoots: []string{repo},
+		Memory:           config.MemoryConfig{MaxDiffSizeMB: 10, MaxFileCount: 1000, MaxLineLength: 1000, ChunkSizeMB: 1},
+	}
+	provider := &countingProvider{name: "mock"}
+	llmProviders = map[string]llm.Provider{"mock": provider}
+	optimizedLLMProviders = make(map[string]llm.OptimizedProvider)
+
+	result, err := handleAnalyzeUncommittedWork(context.Background(), mcp.CallToolRequest{
+		Params: mcp.CallToolParams{Name: "analyze_uncommitted_work", Arguments: map[string]any{"repo_path": repo}},
+	})
+	if err != nil || result.IsError {
+		t.Fatalf("unexpected failure: %v %v", err, result)
+	}
+
+	text := result.Content[0].(mcp.TextContent).Text
+	if !strings.Contains(text, "README.md → GUIDE.md") || provider.calls != 0 {
+		t.Errorf("expected a rename-only note without LLM calls, got %d calls:\n%s", provider.calls, text)
+	}
+}
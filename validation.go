@@ -14,6 +14,9 @@ var (
 
 	// headRefRegex validates HEAD references
 	headRefRegex = regexp.MustCompile(`^HEAD(~\d+)?(\^\d*)?$`)
+
+	// branchNameRegex validates branch names (local or remote-tracking)
+	branchNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
 )
 
 // validateRepoPath validates and cleans a repository path
@@ -38,9 +41,9 @@ func validateRepoPath(path string) (string, error) {
 		return "", fmt.Errorf("failed to get working directory: %w", err)
 	}
 
-	// Ensure the path is within or is the current working directory
-	if !strings.HasPrefix(absPath, cwd) && absPath != cwd {
-		return "", fmt.Errorf("path must be within the current working directory")
+	// Ensure the path is within the current working directory or an allowlisted root
+	if !isWithinDir(absPath, cwd) && !isWithinAllowedRoot(absPath) {
+		return "", fmt.Errorf("path must be within the current working directory or an allowed repository root")
 	}
 
 	// Check if path exists
@@ -48,15 +51,125 @@ func validateRepoPath(path string) (string, error) {
 		return "", fmt.Errorf("path does not exist: %w", err)
 	}
 
+	// A symlink inside an allowed directory must not lead outside it
+	if _, err := resolveAllowedPath(absPath, cwd); err != nil {
+		return "", err
+	}
+
 	// Check if it's a git repository
-	gitDir := filepath.Join(absPath, ".git")
-	if _, err := os.Stat(gitDir); err != nil {
-		return "", fmt.Errorf("not a git repository (no .git directory found)")
+	if !isGitRepository(absPath) {
+		return "", fmt.Errorf("not a git repository (no .git directory or gitdir file found)")
 	}
 
 	return cleanPath, nil
 }
 
+// validateFilePath validates a path to a regular file within the working directory or an allowed repository root
+func validateFilePath(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+
+	absPath, err := filepath.Abs(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	if !isWithinDir(absPath, cwd) && !isWithinAllowedRoot(absPath) {
+		return "", fmt.Errorf("path must be within the current working directory or an allowed repository root")
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("file does not exist: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file")
+	}
+
+	// Return the resolved path so the file read is the one that was checked
+	return resolveAllowedPath(absPath, cwd)
+}
+
+// resolveAllowedPath resolves the symlinks in absPath and checks the real path is still within
+// cwd or an allowed repository root, themselves resolved, returning the real path
+func resolveAllowedPath(absPath, cwd string) (string, error) {
+	realPath, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if isWithinDir(realPath, resolveDir(cwd)) {
+		return realPath, nil
+	}
+	if cfg != nil {
+		for _, root := range cfg.AllowedRepoRoots {
+			if filepath.IsAbs(root) && isWithinDir(realPath, resolveDir(filepath.Clean(root))) {
+				return realPath, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("path resolves through a symlink to outside the current working directory or an allowed repository root")
+}
+
+// resolveDir resolves the symlinks in dir, returning dir unchanged if it cannot be resolved
+func resolveDir(dir string) string {
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		return resolved
+	}
+	return dir
+}
+
+// isGitRepository reports whether dir is a repository work tree, a linked worktree or submodule, or a bare repository
+func isGitRepository(dir string) bool {
+	gitPath := filepath.Join(dir, ".git")
+	if info, err := os.Stat(gitPath); err == nil {
+		if info.IsDir() {
+			return true
+		}
+		// Worktrees and submodules use a .git file pointing at the real git directory
+		content, err := os.ReadFile(gitPath)
+		return err == nil && strings.HasPrefix(string(content), "gitdir:")
+	}
+
+	// Bare repositories keep HEAD, objects, and refs at the top level
+	if info, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil || info.IsDir() {
+		return false
+	}
+	for _, sub := range []string{"objects", "refs"} {
+		if info, err := os.Stat(filepath.Join(dir, sub)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// isWithinDir reports whether path is dir or one of its descendants
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// isWithinAllowedRoot reports whether path is under one of the configured allowed repository roots
+func isWithinAllowedRoot(path string) bool {
+	if cfg == nil {
+		return false
+	}
+	for _, root := range cfg.AllowedRepoRoots {
+		if filepath.IsAbs(root) && isWithinDir(path, filepath.Clean(root)) {
+			return true
+		}
+	}
+	return false
+}
+
 // validateCommitSHA validates a git commit reference
 func validateCommitSHA(sha string) error {
 	if sha == "" || sha == "HEAD" {
@@ -79,3 +192,59 @@ func validateCommitSHA(sha string) error {
 
 	return nil
 }
+
+// validateBranchName validates a git branch name
+func validateBranchName(name string) error {
+	if name == "" {
+		return fmt.Errorf("branch name is required")
+	}
+
+	// Reject anything git itself would refuse, plus option-like input
+	if !branchNameRegex.MatchString(name) ||
+		strings.Contains(name, "..") ||
+		strings.Contains(name, "//") ||
+		strings.HasSuffix(name, "/") ||
+		strings.HasSuffix(name, ".lock") {
+		return fmt.Errorf("invalid branch name format")
+	}
+
+	return nil
+}
+
+// validateGitRef validates a ref that may be a commit SHA, HEAD reference, or branch name
+func validateGitRef(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("ref is required")
+	}
+	if validateCommitSHA(ref) == nil || validateBranchName(ref) == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid git ref format")
+}
+
+// validateRepoRelativePath validates a file or directory path relative to a repository root,
+// returning it cleaned and slash-separated for use as a git pathspec
+func validateRepoRelativePath(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	// Leading dashes would be read as options and leading colons as pathspec magic
+	if strings.HasPrefix(path, "-") || strings.HasPrefix(path, ":") {
+		return "", fmt.Errorf("invalid path %q", path)
+	}
+	cleanPath := filepath.ToSlash(filepath.Clean(path))
+	if !filepath.IsLocal(cleanPath) || cleanPath == "." {
+		return "", fmt.Errorf("path %q must be relative to the repository root and stay within it", path)
+	}
+	return cleanPath, nil
+}
+
+// validateOutputFormat validates a requested output format against those a tool supports
+func validateOutputFormat(format string, allowed ...string) error {
+	for _, a := range allowed {
+		if format == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported format %q (use %s)", format, strings.Join(allowed, ", "))
+}
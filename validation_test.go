@@ -0,0 +1,235 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dshills/second-opinion/config"
+)
+
+func TestValidateRepoPathAllowedRoots(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+
+	root := t.TempDir()
+	repo := filepath.Join(root, "project")
+	sibling := filepath.Join(root+"-other", "project")
+	notRepo := filepath.Join(root, "plain")
+	for _, dir := range []string{filepath.Join(repo, ".git"), filepath.Join(sibling, ".git"), notRepo} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	t.Cleanup(func() { os.RemoveAll(root + "-other") })
+
+	t.Run("outside cwd rejected by default", func(t *testing.T) {
+		cfg = &config.Config{}
+		_, err := validateRepoPath(repo)
+		if err == nil || !strings.Contains(err.Error(), "allowed repository root") {
+			t.Errorf("validateRepoPath(%q) error = %v, want rejection", repo, err)
+		}
+	})
+
+	cfg = &config.Config{AllowedRepoRoots: []string{root}}
+
+	t.Run("under allowed root", func(t *testing.T) {
+		if _, err := validateRepoPath(repo); err != nil {
+			t.Errorf("validateRepoPath(%q) unexpected error: %v", repo, err)
+		}
+	})
+
+	t.Run("cwd still allowed", func(t *testing.T) {
+		if _, err := validateRepoPath("."); err != nil {
+			t.Errorf("validateRepoPath(\".\") unexpected error: %v", err)
+		}
+	})
+
+	t.Run("sibling sharing a name prefix rejected", func(t *testing.T) {
+		if _, err := validateRepoPath(sibling); err == nil {
+			t.Errorf("validateRepoPath(%q) expected rejection", sibling)
+		}
+	})
+
+	t.Run("allowed root still requires a repository", func(t *testing.T) {
+		_, err := validateRepoPath(notRepo)
+		if err == nil || !strings.Contains(err.Error(), "not a git repository") {
+			t.Errorf("validateRepoPath(%q) error = %v, want not a git repository", notRepo, err)
+		}
+	})
+
+	t.Run("allowed root still requires existence", func(t *testing.T) {
+		missing := filepath.Join(root, "missing")
+		_, err := validateRepoPath(missing)
+		if err == nil || !strings.Contains(err.Error(), "does not exist") {
+			t.Errorf("validateRepoPath(%q) error = %v, want does not exist", missing, err)
+		}
+	})
+
+	t.Run("relative roots ignored", func(t *testing.T) {
+		cfg = &config.Config{AllowedRepoRoots: []string{"relative/root"}}
+		if _, err := validateRepoPath(repo); err == nil {
+			t.Errorf("validateRepoPath(%q) expected rejection with only a relative root", repo)
+		}
+	})
+}
+
+func TestIsWithinDir(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		want      bool
+	}{
+		{"/a/b", "/a/b", true},
+		{"/a/b/c", "/a/b", true},
+		{"/a/bc", "/a/b", false},
+		{"/a", "/a/b", false},
+		{"/a/b/../c", "/a/b", false},
+	}
+
+	for _, tt := range tests {
+		if got := isWithinDir(filepath.Clean(tt.path), tt.dir); got != tt.want {
+			t.Errorf("isWithinDir(%q, %q) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRepoRelativePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"main.go", "main.go", false},
+		{"./llm//provider.go", "llm/provider.go", false},
+		{"config/", "config", false},
+		{"", "", true},
+		{".", "", true},
+		{"../outside.go", "", true},
+		{"llm/../../outside.go", "", true},
+		{"/etc/passwd", "", true},
+		{"--output=/tmp/x", "", true},
+		{":(glob)**", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := validateRepoRelativePath(tt.path)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("validateRepoRelativePath(%q) = %q, %v; want %q, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestIsGitRepository(t *testing.T) {
+	dir := t.TempDir()
+
+	mkdir := func(parts ...string) string {
+		t.Helper()
+		path := filepath.Join(append([]string{dir}, parts...)...)
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatalf("failed to create %s: %v", path, err)
+		}
+		return path
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	standard := mkdir("standard")
+	mkdir("standard", ".git")
+
+	worktree := mkdir("worktree")
+	write(filepath.Join(worktree, ".git"), "gitdir: /repos/main/.git/worktrees/feature\n")
+
+	bogusGitFile := mkdir("bogus")
+	write(filepath.Join(bogusGitFile, ".git"), "not a pointer\n")
+
+	bare := mkdir("bare.git")
+	mkdir("bare.git", "objects")
+	mkdir("bare.git", "refs")
+	write(filepath.Join(bare, "HEAD"), "ref: refs/heads/main\n")
+
+	incompleteBare := mkdir("incomplete.git")
+	write(filepath.Join(incompleteBare, "HEAD"), "ref: refs/heads/main\n")
+
+	plain := mkdir("plain")
+
+	tests := []struct {
+		name string
+		dir  string
+		want bool
+	}{
+		{"standard", standard, true},
+		{"worktree .git file", worktree, true},
+		{".git file without gitdir", bogusGitFile, false},
+		{"bare repository", bare, true},
+		{"bare without objects and refs", incompleteBare, false},
+		{"plain directory", plain, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isGitRepository(tt.dir); got != tt.want {
+				t.Errorf("isGitRepository(%q) = %v, want %v", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRepoPathWorktree(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+
+	root := t.TempDir()
+	cfg = &config.Config{AllowedRepoRoots: []string{root}}
+
+	worktree := filepath.Join(root, "feature")
+	if err := os.MkdirAll(worktree, 0o755); err != nil {
+		t.Fatalf("failed to create worktree dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: /repos/main/.git/worktrees/feature\n"), 0o600); err != nil {
+		t.Fatalf("failed to write .git file: %v", err)
+	}
+
+	if _, err := validateRepoPath(worktree); err != nil {
+		t.Errorf("validateRepoPath(%q) unexpected error: %v", worktree, err)
+	}
+}
+
+func TestValidateFilePathSymlinks(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	inside := filepath.Join(root, "inside.diff")
+	secret := filepath.Join(outside, "secret.txt")
+	for _, file := range []string{inside, secret} {
+		if err := os.WriteFile(file, []byte("content"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+	escaping := filepath.Join(root, "escape.diff")
+	internal := filepath.Join(root, "link.diff")
+	if err := os.Symlink(secret, escaping); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink(inside, internal); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	cfg = &config.Config{AllowedRepoRoots: []string{root}}
+
+	if _, err := validateFilePath(escaping); err == nil || !strings.Contains(err.Error(), "symlink") {
+		t.Errorf("validateFilePath(%q) error = %v, want a symlink rejection", escaping, err)
+	}
+	got, err := validateFilePath(internal)
+	if err != nil {
+		t.Fatalf("validateFilePath(%q) unexpected error: %v", internal, err)
+	}
+	if want, _ := filepath.EvalSymlinks(inside); got != want {
+		t.Errorf("validateFilePath(%q) = %q, want the resolved path %q", internal, got, want)
+	}
+}